@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+// runAgent 非交互执行一次 AI 任务：ruoyi-proxy agent --prompt "..." 或从标准输入读取任务，
+// 最终回答输出到标准输出，适合脚本与 cron。退出码：0 成功，1 执行失败，2 参数错误
+func runAgent(args []string) int {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	prompt := fs.String("prompt", "", "任务内容（留空则从标准输入读取）")
+	write := fs.String("write", agent.HeadlessWriteDeny, "写操作策略：deny 全部拒绝，all 全部允许，或逗号分隔的允许工具名")
+	service := fs.String("service", "default", "当前服务 ID")
+	timeout := fs.Duration("timeout", 10*time.Minute, "任务超时，0 表示不限")
+	asJSON := fs.Bool("json", false, "以 JSON 输出最终回答与工具调用记录")
+	verbose := fs.Bool("verbose", false, "在标准错误输出工具调用进度")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: ruoyi-proxy agent [--prompt <任务>] [--write deny|all|工具,...] [--json] [--service id] [--timeout 10m] [--verbose]")
+		fmt.Fprintln(os.Stderr, "示例: ruoyi-proxy agent --prompt \"检查最近日志是否有 OOM 并总结\"")
+		fmt.Fprintln(os.Stderr, "      echo \"重启服务\" | ruoyi-proxy agent --write service_control --json")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	task := strings.TrimSpace(*prompt)
+	if task == "" && fs.NArg() > 0 {
+		task = strings.TrimSpace(strings.Join(fs.Args(), " "))
+	}
+	if task == "" {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			fs.Usage()
+			return 2
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取标准输入失败: %v\n", err)
+			return 2
+		}
+		task = strings.TrimSpace(string(data))
+	}
+	if task == "" {
+		fmt.Fprintln(os.Stderr, "任务内容为空")
+		return 2
+	}
+
+	opts := agent.HeadlessOptions{Prompt: task, Service: *service, Write: *write, Timeout: *timeout}
+	// 配置加载等内部日志只在 --verbose 时输出，避免 cron 把每次运行都当作异常邮件发出
+	log.SetOutput(io.Discard)
+	if *verbose {
+		opts.Log = ansiStripper{os.Stderr}
+		log.SetOutput(opts.Log)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := agent.RunHeadless(ctx, opts)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else if result.Answer != "" {
+		fmt.Println(result.Answer)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runMCP 以 MCP 服务器方式运行（stdio），供 MCP 客户端调用本机运维工具，例如：
+// {"command": "ssh", "args": ["host", "/opt/ruoyi/ruoyi-proxy", "mcp", "--workdir", "/opt/ruoyi"]}
+// 标准输出只用于协议消息，日志写标准错误。退出码：0 正常结束，1 运行失败，2 参数错误
+func runMCP(args []string) int {
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	write := fs.String("write", agent.HeadlessWriteAsk, "写操作策略：ask 通过客户端确认（elicitation），deny 全部拒绝，all 全部允许，或逗号分隔的允许工具名")
+	service := fs.String("service", "default", "当前服务 ID")
+	workdir := fs.String("workdir", "", "工作目录（ruoyi-proxy 部署目录，含 configs/ 与 scripts/）")
+	verbose := fs.Bool("verbose", false, "在标准错误输出内部日志")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: ruoyi-proxy mcp [--write ask|deny|all|工具,...] [--service id] [--workdir dir] [--verbose]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *workdir != "" {
+		if err := os.Chdir(*workdir); err != nil {
+			fmt.Fprintf(os.Stderr, "切换工作目录失败: %v\n", err)
+			return 2
+		}
+	}
+
+	// 工具实现中零散的终端输出会破坏协议流，全部转到标准错误
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+	logOut := ansiStripper{os.Stderr}
+	log.SetOutput(io.Discard)
+	if *verbose {
+		log.SetOutput(logOut)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := agent.ServeMCP(ctx, os.Stdin, protocolOut, agent.MCPServeOptions{Service: *service, Write: *write, Log: logOut})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// ansiStripper 去掉颜色控制符，便于写入日志文件
+type ansiStripper struct{ w io.Writer }
+
+func (s ansiStripper) Write(p []byte) (int, error) {
+	if _, err := s.w.Write(ansiPattern.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
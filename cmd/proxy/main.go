@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"syscall"
 	"time"
 
+	"ruoyi-proxy/internal/agent"
 	"ruoyi-proxy/internal/buildinfo"
 	"ruoyi-proxy/internal/cli"
 	"ruoyi-proxy/internal/config"
+	"ruoyi-proxy/internal/dashboard"
 	"ruoyi-proxy/internal/hub"
 	"ruoyi-proxy/internal/proxy"
+	"ruoyi-proxy/internal/spoke"
 )
 
 //go:embed scripts/*
@@ -26,15 +34,42 @@ func main() {
 	if len(os.Args) > 1 {
 		mode = os.Args[1]
 	}
+	if mode == agent.SandboxHelperArg {
+		agent.RunSandboxHelper(os.Args[2:])
+	}
 
 	// Spoke 包默认进入 Agent/CLI；代理必须通过 /proxy-start 显式启动。
 	if mode == "cli" || (mode == "" && buildinfo.IsSpoke()) {
 		runCLI()
 		return
 	}
+	if mode == "spoke" {
+		runSpoke()
+		return
+	}
+	if mode == "agent" {
+		os.Exit(runAgent(os.Args[2:]))
+	}
+	if mode == "mcp" {
+		os.Exit(runMCP(os.Args[2:]))
+	}
 	runProxy()
 }
 
+// runSpoke 无人值守运行 Spoke 心跳与远程命令通道（适合 systemd 托管）
+func runSpoke() {
+	if _, err := agent.HubConnection(); err != nil {
+		log.Fatalf("Spoke 通道启动失败: %v（请先运行 cli 中的 /agent-config 注册到 Hub）", err)
+	}
+	log.Println("Spoke 心跳与远程命令通道启动，等待 Hub 下发命令...")
+	ansi := regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	notify := func(s string) { log.Println(ansi.ReplaceAllString(s, "")) }
+	ctx := context.Background()
+	go spoke.RunHeartbeat(ctx)
+	go spoke.RunDiscovery(ctx)
+	spoke.NewController(false, notify).Run(ctx)
+}
+
 func runCLI() {
 	// 注入嵌入的文件系统
 	cli.SetEmbedFS(scriptsFS, configsFS)
@@ -55,6 +90,9 @@ func runProxy() {
 		} else if hubSettings.Enabled || buildinfo.IsHub() {
 			log.Println("Hub AI 网关已启用")
 		}
+		hub.StartAuditMaintenance()
+		hub.StartReplication()
+		flushSpokesOnExit()
 	}
 
 	// 初始化代理
@@ -70,15 +108,34 @@ func runProxy() {
 	startProxyServer(p, hubActive)
 }
 
+// flushSpokesOnExit 收到退出信号时写出延迟落盘的 spoke 注册表
+func flushSpokesOnExit() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		if err := hub.FlushSpokes(); err != nil {
+			log.Printf("保存 Hub spoke 注册表失败: %v", err)
+		}
+		os.Exit(0)
+	}()
+}
+
 // startProxyServer 启动代理服务器
 func startProxyServer(p *proxy.Proxy, hubEnabled bool) {
 	proxyMux := http.NewServeMux()
 	proxyMux.HandleFunc("/", p.HandleProxy)
 	if hubEnabled {
-		proxyMux.HandleFunc("/__hub__/v1/token", hub.RegisterTokenHandler)
-		proxyMux.HandleFunc("/__hub__/v1/register", hub.RegisterHandler)
-		proxyMux.HandleFunc("/__hub__/v1/profile", hub.ProfileHandler)
-		proxyMux.HandleFunc("/__hub__/v1/chat", hub.ChatHandler)
+		proxyMux.HandleFunc("/__hub__/v1/register", hub.Guard("register", hub.RegisterHandler))
+		proxyMux.HandleFunc("/__hub__/v1/profile", hub.Guard("profile", hub.ProfileHandler))
+		proxyMux.HandleFunc("/__hub__/v1/chat", hub.Guard("chat", hub.ChatHandler))
+		proxyMux.HandleFunc("/__hub__/v1/models", hub.Guard("models", hub.ModelsHandler))
+		proxyMux.HandleFunc("/__hub__/v1/policy", hub.Guard("policy", hub.PolicyHandler))
+		proxyMux.HandleFunc("/__hub__/v1/heartbeat", hub.Guard("heartbeat", hub.HeartbeatHandler))
+		proxyMux.HandleFunc("/__hub__/v1/control/poll", hub.Guard("control/poll", hub.ControlPollHandler))
+		proxyMux.HandleFunc("/__hub__/v1/control/result", hub.Guard("control/result", hub.ControlResultHandler))
+		proxyMux.HandleFunc("/__hub__/v1/replicate", hub.Guard("replicate", hub.ReplicateHandler))
+		proxyMux.HandleFunc("/__hub__/v1/artifact", hub.Guard("artifact", hub.ArtifactHandler))
 	}
 
 	proxyServer := &http.Server{
@@ -108,19 +165,30 @@ func startMgmtServer(p *proxy.Proxy, hubEnabled bool) {
 	mgmtMux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
 		handleStatus(p, w, r)
 	})
+	mgmtMux.HandleFunc("/switch-history", handleSwitchHistory)
+	dashboard.Register(mgmtMux)
 	if hubEnabled {
-		mgmtMux.HandleFunc("/hub/token", hub.TokenAdminHandler)
+		mgmtMux.HandleFunc("/hub/token", hub.Guard("token", hub.TokenAdminHandler))
 		mgmtMux.HandleFunc("/hub/status", hub.StatusAdminHandler)
 		mgmtMux.HandleFunc("/hub/spoke", hub.SpokeAdminHandler)
-		mgmtMux.HandleFunc("/hub/revoke", hub.RevokeAdminHandler)
+		mgmtMux.HandleFunc("/hub/revoke", hub.AdminOnly("revoke", hub.RevokeAdminHandler))
+		mgmtMux.HandleFunc("/hub/tags", hub.AdminOnly("tags", hub.TagsAdminHandler))
+		mgmtMux.HandleFunc("/hub/providers", hub.ProvidersAdminHandler)
+		mgmtMux.HandleFunc("/hub/audit", hub.AdminOnly("audit", hub.AuditAdminHandler))
+		mgmtMux.HandleFunc("/hub/dispatch", hub.AdminOnly("dispatch", hub.DispatchAdminHandler))
+		mgmtMux.HandleFunc("/hub/command", hub.AdminOnly("command", hub.CommandAdminHandler))
+		mgmtMux.HandleFunc("/hub/reload", hub.AdminOnly("reload", hub.ReloadAdminHandler))
+		mgmtMux.HandleFunc("/hub/policy", hub.AdminOnly("policy", hub.PolicyAdminHandler))
+		mgmtMux.HandleFunc("/hub/artifacts", hub.ArtifactsAdminHandler)
 	}
 
 	mgmtServer := &http.Server{
 		Addr:    config.MgmtPort,
-		Handler: mgmtMux,
+		Handler: dashboard.Protect(mgmtMux),
 	}
 
 	log.Printf("管理服务器启动在端口 %s", config.MgmtPort)
+	log.Printf("管理面板: http://127.0.0.1%s/dashboard/", config.MgmtPort)
 
 	if err := mgmtServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Printf("管理服务器启动失败: %v", err)
@@ -167,6 +235,24 @@ func handleSwitch(p *proxy.Proxy, w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleSwitchHistory 返回本机蓝绿切换历史（新的在前）
+func handleSwitchHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	records := proxy.SwitchHistory(limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(records),
+		"records": records,
+	})
+}
+
 // handleStatus 处理状态查询请求
 func handleStatus(p *proxy.Proxy, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -5,6 +5,7 @@ go 1.24.1
 require (
 	github.com/chzyer/readline v1.5.1
 	golang.org/x/term v0.36.0
+	mvdan.cc/sh/v3 v3.12.0
 )
 
 require (
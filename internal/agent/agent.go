@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,11 +18,12 @@ import (
 )
 
 const (
-	maxReActIterations = 30 // 单次 ReAct 最大推理轮数
-	maxAutoResume      = 5  // 超限后最多自动续接次数（总计最多 30×5=150 轮）
-	toolOutputMaxChars = 3000
-	autoResumePrompt   = "请继续完成上面未完成的任务。"
-	autoTitleTurns     = 5
+	maxReActIterations   = 30 // 单次 ReAct 最大推理轮数
+	maxAutoResume        = 5  // 超限后最多自动续接次数（总计最多 30×5=150 轮）
+	toolOutputMaxChars   = 3000
+	maxParallelToolCalls = 4 // 只读工具并行执行的最大并发数
+	autoResumePrompt     = "请继续完成上面未完成的任务。"
+	autoTitleTurns       = 5
 )
 
 var errAgentInterrupted = errors.New("agent interrupted")
@@ -36,12 +38,14 @@ type Agent struct {
 	sessionStore *SessionStore
 	current      *SessionMeta
 	systemPrompt string
+	policy       HubPolicy // 当前生效的 Hub 下发策略
 	runMu        sync.Mutex
 	runCancel    context.CancelFunc // 当前用户轮次的取消函数，用于 Ctrl+C 打断任务
-	lastInput    string             // 用户最后一条消息，用于判断是否已提前确认
-	turnApproved bool               // 当前用户轮次是否已批准写操作（一次批准覆盖整轮）
+	perms        *permissionSet     // 本机权限规则与批准记录
+	headless     *headlessRun       // 非交互模式（RunHeadless）的状态，nil 表示交互模式
+	actions      *actionJournal     // 当前会话的写操作日志，供 /undo 撤销
+	undoNote     []string           // 已撤销但尚未告知模型的操作
 	// 回调函数（由 CLI 注入）
-	confirm        func(prompt string) bool             // 写操作确认
 	readInput      func(prompt string) (string, error)  // 读用户输入
 	print          func(s string)                       // 普通输出
 	opsCommand     func(cmd string, args []string) bool // 运维斜杠命令（由 CLI 注入）
@@ -53,7 +57,6 @@ type Agent struct {
 func New(
 	aiCfg AIConfig,
 	execCtx ExecContext,
-	confirm func(string) bool,
 	readInput func(string) (string, error),
 	print func(string),
 ) (*Agent, error) {
@@ -69,17 +72,25 @@ func New(
 	if err != nil {
 		return nil, fmt.Errorf("初始化会话存储失败: %v", err)
 	}
-	return &Agent{
+	a := &Agent{
 		provider:     provider,
 		executor:     NewToolExecutor(execCtx),
-		ctx:          NewContextManager(aiCfg.ContextLimit),
 		aiCfg:        aiCfg,
 		execCtx:      execCtx,
 		sessionStore: store,
-		confirm:      confirm,
+		perms:        newPermissionSet(),
 		readInput:    readInput,
 		print:        print,
-	}, nil
+	}
+	a.ctx = a.newContextManager()
+	return a, nil
+}
+
+// newContextManager 创建上下文管理器，超限压缩时由模型生成早期对话摘要
+func (a *Agent) newContextManager() *ContextManager {
+	c := NewContextManager(a.aiCfg.ContextLimit)
+	c.SetSummarizer(a.summarizeDropped)
+	return c
 }
 
 // SetOpsHooks 注入运维斜杠命令回调（由 CLI 包设置，避免 agent 依赖 cli）
@@ -120,6 +131,10 @@ func (a *Agent) defaultSlashMenuItems() []SlashCommandItem {
 		{Command: "/load", Description: "打开会话选择器并加载"},
 		{Command: "/new", Description: "创建新的空会话"},
 		{Command: "/current", Description: "查看当前会话信息"},
+		{Command: "/mcp", Description: "查看 MCP 服务器与工具"},
+		{Command: "/tools", Description: "查看可用工具（含自定义工具）"},
+		{Command: "/permissions", Description: "查看权限规则与批准记录"},
+		{Command: "/undo", Description: "撤销最近的写操作"},
 		{Command: "/help", Description: "查看命令说明"},
 		{Command: "/exit", Description: "退出 Agent 模式"},
 	}
@@ -151,10 +166,8 @@ func (a *Agent) Cancel() {
 
 // Run 启动 Agent 交互循环
 func (a *Agent) Run() {
-	a.systemPrompt = a.aiCfg.SystemPrompt
-	if a.systemPrompt == "" {
-		a.systemPrompt = a.defaultSystemPrompt()
-	}
+	a.policy = a.activePolicy()
+	a.systemPrompt = a.buildSystemPrompt(a.policy)
 	if err := a.startNewSession(); err != nil {
 		a.print(fmt.Sprintf("\033[1;31m✗ 创建初始会话失败: %v\033[0m", err))
 		return
@@ -167,6 +180,11 @@ func (a *Agent) Run() {
 	} else {
 		a.print("\033[1;33m⚠ AI 未配置，可用 /agent-config 配置；运维命令如 /status /deploy 可直接使用\033[0m")
 	}
+	a.loadCustomTools()
+	a.loadPermissions()
+	a.checkSandbox()
+	a.startMCP()
+	defer StopMCPServers()
 	a.print("输入问题或指令，\033[1;33m'/help'\033[0m 查看命令，\033[1;33m'/' \033[0m打开命令菜单（↑/↓ 选择），\033[1;33m'/exit'\033[0m 退出")
 	a.print("\033[1;33m'Ctrl+C'\033[0m 运行中断任务/空闲时退出，\033[1;33m'clear'\033[0m 清空会话\n")
 
@@ -224,11 +242,10 @@ func (a *Agent) Run() {
 			continue
 		}
 
-		a.lastInput = input    // 记录用户最后一条消息，供写操作确认使用
-		a.turnApproved = false // 新用户轮次，重置批准状态
-		a.ctx.Add(Message{Role: "user", Content: input})
+		a.refreshHubPolicy()
+		a.ctx.Add(Message{Role: "user", Content: a.takeUndoNote() + input})
 		a.persistSession()
-		if err := a.runReAct(); err != nil {
+		if err := a.runReAct(context.Background()); err != nil {
 			if errors.Is(err, errAgentInterrupted) {
 				a.print("\n\033[1;33m◉ 已中断当前任务\033[0m\n")
 				continue
@@ -285,6 +302,41 @@ func (a *Agent) handleSlashCommand(input string) bool {
 			a.print(fmt.Sprintf("\033[1;36mℹ 当前会话: %s (%s)\033[0m", a.current.Title, a.current.ID))
 		}
 		return true
+	case "/mcp":
+		if arg == "reload" {
+			StopMCPServers()
+			a.startMCP()
+			return true
+		}
+		a.printMCPStatus(MCPStatus())
+		return true
+	case "/tools":
+		if arg == "reload" {
+			a.loadCustomTools()
+		}
+		a.printTools()
+		return true
+	case "/permissions":
+		switch arg {
+		case "reload":
+			a.loadPermissions()
+		case "clear":
+			if err := a.perms.clearApprovals(); err != nil {
+				a.print(fmt.Sprintf("\033[1;31m✗ %v\033[0m", err))
+			} else {
+				a.print("\033[1;36mℹ 已清除全部批准记录\033[0m")
+			}
+			return true
+		}
+		a.printPermissions()
+		return true
+	case "/undo":
+		if arg == "list" {
+			a.printJournal()
+		} else {
+			a.undoActions(arg)
+		}
+		return true
 	case "/exit":
 		a.persistSession()
 		a.print("已退出 Agent 模式")
@@ -310,7 +362,7 @@ func (a *Agent) handleSlashCommand(input string) bool {
 
 func (a *Agent) printCombinedHelp() {
 	a.print("\033[1;34m═══ 会话命令 ═══\033[0m")
-	a.print("  /sessions  /load [编号|ID]  /new  /current  /help  /exit")
+	a.print("  /sessions  /load [编号|ID]  /new  /current  /mcp [reload]  /tools [reload]  /permissions [reload|clear]  /undo [N|list]  /help  /exit")
 	a.print("  clear=清空对话  history=上下文摘要  Ctrl+C=中断当前任务")
 	if a.opsHelp != nil {
 		a.print("")
@@ -319,7 +371,9 @@ func (a *Agent) printCombinedHelp() {
 }
 
 func (a *Agent) startNewSession() error {
-	a.ctx = NewContextManager(a.aiCfg.ContextLimit)
+	a.perms.resetSession()
+	a.undoNote = nil
+	a.ctx = a.newContextManager()
 	a.ctx.Add(Message{Role: "system", Content: a.systemPrompt})
 	meta, err := a.sessionStore.CreateSession(a.ctx.Messages())
 	if err != nil {
@@ -375,7 +429,9 @@ func (a *Agent) loadSessionByRef(ref string) error {
 	if err != nil {
 		return fmt.Errorf("加载会话失败: %v", err)
 	}
-	a.ctx = NewContextManager(a.aiCfg.ContextLimit)
+	a.perms.resetSession()
+	a.undoNote = nil
+	a.ctx = a.newContextManager()
 	for _, msg := range messages {
 		a.ctx.Add(msg)
 	}
@@ -432,9 +488,68 @@ func (a *Agent) generateSessionTitle(ctx context.Context) (string, error) {
 	return title, nil
 }
 
+const compactionPrompt = `你是运维排障对话的上下文压缩助手。对话过长，较早的消息将被移出上下文，请把它们合并进已有摘要，供后续继续排障时参考。
+要求：
+- 保留关键事实：服务器/服务/环境名称、版本、路径、端口、配置项、报错原文的关键部分、执行过的命令或工具及其结论、用户的决定与偏好、尚未完成的事项
+- 省略寒暄、重复内容和已无参考价值的原始输出
+- 用简洁的中文条目列出，不超过 800 字，只输出摘要本身`
+
+// summarizeDropped 由模型把移出上下文的消息合并进滚动摘要
+func (a *Agent) summarizeDropped(previous string, dropped []Message) (string, error) {
+	if a.provider == nil {
+		return "", fmt.Errorf("AI 未配置")
+	}
+	var sb strings.Builder
+	if previous != "" {
+		sb.WriteString("## 已有摘要\n\n" + previous + "\n\n")
+	}
+	sb.WriteString("## 需要合并的早期对话\n\n" + buildCompactionTranscript(dropped))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	resp, err := a.provider.Chat(ctx, []Message{
+		{Role: "system", Content: compactionPrompt},
+		{Role: "user", Content: sb.String()},
+	}, nil)
+	if err != nil {
+		fmt.Fprintf(a.out(), "\033[1;33m⚠ 上下文接近上限，生成早期对话摘要失败（%v），已直接移除 %d 条早期消息\033[0m\n", err, len(dropped))
+		return "", err
+	}
+	fmt.Fprintf(a.out(), "\033[1;36mℹ 上下文接近上限，已将 %d 条早期消息压缩为摘要\033[0m\n", len(dropped))
+	return resp.Content, nil
+}
+
+// buildCompactionTranscript 把待压缩的消息整理成文本（工具输出截短，总长度受限）
+func buildCompactionTranscript(messages []Message) string {
+	const maxTotalRunes = 16000
+	var lines []string
+	for _, m := range messages {
+		switch m.Role {
+		case "user":
+			if strings.TrimSpace(m.Content) != autoResumePrompt {
+				lines = append(lines, "用户: "+trimRunes(m.Content, 1500))
+			}
+		case "assistant":
+			if strings.TrimSpace(m.Content) != "" {
+				lines = append(lines, "助手: "+trimRunes(m.Content, 1500))
+			}
+			for _, tc := range m.ToolCalls {
+				lines = append(lines, fmt.Sprintf("助手调用工具 %s %s", tc.Name, trimRunes(tc.Arguments, 300)))
+			}
+		case "tool":
+			lines = append(lines, fmt.Sprintf("工具 %s 结果: %s", m.Name, trimRunes(m.Content, 800)))
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if r := []rune(text); len(r) > maxTotalRunes {
+		text = string(r[:maxTotalRunes/2]) + "\n[...中间部分已省略...]\n" + string(r[len(r)-maxTotalRunes/2:])
+	}
+	return text
+}
+
 // runReAct 执行 ReAct 循环，支持自动续接
-func (a *Agent) runReAct() error {
-	ctx, cancel := context.WithCancel(context.Background())
+func (a *Agent) runReAct(parent context.Context) error {
+	ctx, cancel := context.WithCancel(parent)
 	a.runMu.Lock()
 	a.runCancel = cancel
 	a.runMu.Unlock()
@@ -455,7 +570,7 @@ func (a *Agent) runReAct() error {
 		}
 		// 未完成（达到单次迭代上限），自动续接
 		if resume < maxAutoResume {
-			fmt.Printf("\n\033[1;36mℹ 任务较复杂，自动继续处理中（第 %d/%d 次续接）...\033[0m\n\n",
+			fmt.Fprintf(a.out(), "\n\033[1;36mℹ 任务较复杂，自动继续处理中（第 %d/%d 次续接）...\033[0m\n\n",
 				resume+1, maxAutoResume)
 			// 注入续接消息，让 AI 知道需要继续
 			a.ctx.Add(Message{
@@ -480,7 +595,7 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 		}
 
 		// —— Think：调用 LLM ——
-		eventCh, err := a.provider.Stream(ctx, a.ctx.Messages(), AllTools)
+		eventCh, err := a.provider.Stream(ctx, a.ctx.Messages(), a.tools())
 		if err != nil {
 			// 区分取消错误和真实错误
 			if ctx.Err() != nil {
@@ -492,16 +607,20 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 		var textBuf strings.Builder
 		var toolCalls []ToolCall
 		var reasoningContent string
+		var usage *Usage
 
-		fmt.Print("\n\033[1;35mAI\033[0m:\n")
-		ms := newMDStream()
+		var ms *mdStream // 非交互模式不渲染流式输出
+		if a.headless == nil {
+			fmt.Print("\n\033[1;35mAI\033[0m:\n")
+			ms = newMDStream()
+		}
 
 		for event := range eventCh {
 			// 流式输出过程中也检查中断
 			select {
 			case <-ctx.Done():
 				ms.finish()
-				fmt.Println()
+				fmt.Fprintln(a.out())
 				return false, errAgentInterrupted
 			default:
 			}
@@ -514,6 +633,7 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 				toolCalls = append(toolCalls, event.ToolCalls...)
 			case "done":
 				reasoningContent = event.ReasoningContent
+				usage = event.Usage
 			case "error":
 				if ctx.Err() != nil {
 					return false, errAgentInterrupted
@@ -523,9 +643,13 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 		}
 
 		ms.finish()
-		fmt.Println()
+		fmt.Fprintln(a.out())
 
 		assistantContent := strings.TrimSpace(textBuf.String())
+		if usage != nil {
+			// 上报的 prompt tokens 对应本次发送的全部消息，用于校准上下文长度
+			a.ctx.RecordUsage(*usage)
+		}
 
 		// 把 assistant 消息写入历史（reasoning_content 需原样传回，否则思考模式模型报 400）
 		a.ctx.Add(Message{
@@ -537,12 +661,16 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 
 		// 没有工具调用 → 正常完成
 		if len(toolCalls) == 0 {
-			fmt.Println()
+			fmt.Fprintln(a.out())
+			if a.headless != nil {
+				a.headless.answer = assistantContent
+			}
 			return true, nil
 		}
 
 		// —— Act + Observe：执行工具调用 ——
-		for _, tc := range toolCalls {
+		// 连续的只读调用并行执行，写操作仍按顺序逐个确认执行；结果按原顺序写入历史
+		for i := 0; i < len(toolCalls); {
 			// 执行前再次检查中断
 			select {
 			case <-ctx.Done():
@@ -550,22 +678,17 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 			default:
 			}
 
-			result, err := a.executeToolCall(tc)
-			content := result
-			if err != nil {
-				content = fmt.Sprintf("执行失败: %v", err)
+			j := i
+			for j < len(toolCalls) && a.parallelSafe(toolCalls[j]) {
+				j++
 			}
-			// 确保工具结果非空：空字符串会导致 Anthropic API 的 content 字段被
-			// omitempty 省略，进而被解析为 null，触发 400 错误
-			if strings.TrimSpace(content) == "" {
-				content = "执行成功（命令无输出）"
+			if j-i < 2 {
+				j = i + 1
 			}
-			a.ctx.Add(Message{
-				Role:       "tool",
-				ToolCallID: tc.ID,
-				Name:       tc.Name,
-				Content:    content,
-			})
+			for k, outcome := range a.executeToolCalls(toolCalls[i:j]) {
+				a.addToolResult(toolCalls[i+k], outcome)
+			}
+			i = j
 		}
 		// 继续下一轮 LLM 推理（分析工具结果）
 	}
@@ -574,49 +697,162 @@ func (a *Agent) runReActOnce(ctx context.Context) (bool, error) {
 	return false, nil
 }
 
-// executeToolCall 执行单个工具调用，写操作需要用户确认
-func (a *Agent) executeToolCall(tc ToolCall) (string, error) {
-	// 查找工具定义
-	var toolDef *ToolDef
-	for i := range AllTools {
-		if AllTools[i].Name == tc.Name {
-			toolDef = &AllTools[i]
-			break
+// toolOutcome 单次工具调用的结果
+type toolOutcome struct {
+	result  string
+	err     error
+	elapsed time.Duration
+}
+
+// parallelSafe 只读且权限策略直接放行的工具调用可与相邻的只读调用并行执行
+func (a *Agent) parallelSafe(tc ToolCall) bool {
+	def := a.lookupTool(tc.Name)
+	return def != nil && def.ReadOnly && !a.policy.ToolDisabled(tc.Name) &&
+		a.perms.evaluate(tc, def).action == PermissionAllow
+}
+
+// executeToolCalls 执行一组工具调用：单个直接执行，多个（均为只读）以最多 maxParallelToolCalls 个并发执行
+func (a *Agent) executeToolCalls(calls []ToolCall) []toolOutcome {
+	outcomes := make([]toolOutcome, len(calls))
+	run := func(i int) {
+		started := time.Now()
+		result, err := a.executeToolCall(calls[i])
+		outcomes[i] = toolOutcome{result: result, err: err, elapsed: time.Since(started)}
+	}
+	if len(calls) == 1 {
+		run(0)
+		return outcomes
+	}
+
+	fmt.Fprintf(a.out(), "\n\033[1;36mℹ 并行执行 %d 个只读工具\033[0m", len(calls))
+	sem := make(chan struct{}, maxParallelToolCalls)
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			run(i)
+		}()
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// addToolResult 记录工具结果并写入对话历史
+func (a *Agent) addToolResult(tc ToolCall, outcome toolOutcome) {
+	if a.headless != nil {
+		a.headless.record(tc, outcome.result, outcome.err, outcome.elapsed)
+	}
+	content := outcome.result
+	if outcome.err != nil {
+		content = fmt.Sprintf("执行失败: %v", outcome.err)
+	}
+	// 确保工具结果非空：空字符串会导致 Anthropic API 的 content 字段被
+	// omitempty 省略，进而被解析为 null，触发 400 错误
+	if strings.TrimSpace(content) == "" {
+		content = "执行成功（命令无输出）"
+	}
+	a.ctx.Add(Message{
+		Role:       "tool",
+		ToolCallID: tc.ID,
+		Name:       tc.Name,
+		Content:    content,
+	})
+}
+
+// lookupTool 查找当前可用工具的定义，未找到返回 nil
+func (a *Agent) lookupTool(name string) *ToolDef {
+	tools := a.tools()
+	for i := range tools {
+		if tools[i].Name == name {
+			return &tools[i]
 		}
 	}
+	return nil
+}
+
+// tools 返回本节点可用的工具（注册表中的内置、自定义工具，Hub 额外提供跨节点工具，另合并已连接
+// MCP 服务器的工具；Hub 策略禁用的工具不提供给模型）
+func (a *Agent) tools() []ToolDef {
+	all := append(defaultRegistry.Definitions(false), mcpToolDefs()...)
+	if len(a.policy.DisabledTools) == 0 {
+		return all
+	}
+	tools := make([]ToolDef, 0, len(all))
+	for _, t := range all {
+		if !a.policy.ToolDisabled(t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// executeToolCall 执行单个工具调用，写操作需要用户确认
+func (a *Agent) executeToolCall(tc ToolCall) (string, error) {
+	toolDef := a.lookupTool(tc.Name)
 
 	argsDisplay := formatArgs(tc.Arguments)
 	// 仅展示工具名称，参数和结果只进入上下文，不刷屏给用户。
-	fmt.Printf("\n\033[1;36m[工具调用]\033[0m %s\n", tc.Name)
-
-	// 判断本次调用是否真正需要确认：
-	// - ToolDef.ReadOnly=true  → 无需确认
-	// - run_shell 但命令是只读性质 → 无需确认
-	// - 其他写操作 → 需要确认
-	needsConfirm := toolDef != nil && !toolDef.ReadOnly
-	if needsConfirm && tc.Name == "run_shell" && isReadOnlyShellCmd(tc.Arguments) {
-		needsConfirm = false
-	}
-
-	if needsConfirm {
-		// 情况1：用户消息本身是确认词，或本轮次已手动批准过 → 静默自动确认
-		if !(isStandaloneAffirmative(a.lastInput) || a.turnApproved) {
-			// 情况2：需要用户手动确认 → 弹出确认框
-			printConfirmBox(tc.Name, argsDisplay)
-			if !a.confirm("") {
-				fmt.Printf("\033[1;31m  ✗ 已取消\033[0m\n")
-				return "用户取消了此操作", nil
-			}
-			// 手动确认后，本轮次剩余写操作均自动放行
-			a.turnApproved = true
+	fmt.Fprintf(a.out(), "\n\033[1;36m[工具调用]\033[0m %s\n", tc.Name)
+
+	// Hub 策略：模型可能仍调用历史上下文中出现过的已禁用工具
+	if a.policy.ToolDisabled(tc.Name) {
+		fmt.Fprintf(a.out(), "\033[1;31m  ✗ 已被 Hub 策略禁用\033[0m\n")
+		return fmt.Sprintf("工具 %s 已被 Hub 策略禁用，请改用其他方式或告知用户", tc.Name), nil
+	}
+	if tc.Name == "run_shell" {
+		if rule, hit := a.forbiddenShell(tc.Arguments); hit {
+			fmt.Fprintf(a.out(), "\033[1;31m  ✗ 命令被 Hub 策略禁止\033[0m\n")
+			return fmt.Sprintf("命令被 Hub 策略禁止（规则: %s），不要尝试绕过，请告知用户", rule), nil
 		}
 	}
 
-	// 执行工具
-	result, err := a.executor.Execute(tc.Name, tc.Arguments)
+	// 本机权限策略：规则按顺序匹配（deny 直接拒绝、ask 需确认、allow 放行），
+	// 未命中规则时只读工具与只读 shell 命令直接执行，其余需确认
+	decision := a.perms.evaluate(tc, toolDef)
+	if decision.action == PermissionDeny {
+		if a.headless != nil {
+			a.headless.denied[tc.ID] = true
+		}
+		fmt.Fprintf(a.out(), "\033[1;31m  ✗ 被权限策略拒绝（%s）\033[0m\n", decision.ruleLabel())
+		return deniedMessage(tc.Name, decision), nil
+	}
+	if decision.action == PermissionAsk {
+		if msg, ok := a.approveToolCall(tc, argsDisplay, decision); !ok {
+			return msg, nil
+		}
+	}
+
+	// 写操作执行前记录原状态，供 /undo 撤销（仅交互会话）
+	var journal *actionJournal
+	var entry *journalEntry
+	if a.headless == nil && isMutatingCall(tc, toolDef) {
+		if journal = a.journal(); journal != nil {
+			entry = journal.begin(tc, a.executor)
+		}
+	}
+
+	// 执行工具（MCP 工具路由到对应服务器）
+	var result string
+	var err error
+	if isMCPTool(tc.Name) {
+		result, err = callMCPTool(tc.Name, tc.Arguments)
+	} else {
+		result, err = a.executor.Execute(tc.Name, tc.Arguments)
+	}
 	if err != nil {
+		if entry != nil {
+			journal.discard(entry)
+		}
 		return "", err
 	}
+	if entry != nil {
+		if jerr := journal.commit(entry); jerr != nil {
+			fmt.Fprintf(a.out(), "\033[1;33m  ⚠ %v\033[0m\n", jerr)
+		}
+	}
 
 	// 截断过长输出
 	result = truncateOutput(result, toolOutputMaxChars)
@@ -624,6 +860,102 @@ func (a *Agent) executeToolCall(tc ToolCall) (string, error) {
 	return result, nil
 }
 
+// approveToolCall 处理需确认的调用：已有本会话/始终批准时直接放行，非交互模式按 --write 策略，
+// 交互模式弹出确认框并按用户选择记录批准范围；未批准时返回告知模型的说明
+func (a *Agent) approveToolCall(tc ToolCall, argsDisplay string, decision permissionDecision) (string, bool) {
+	// Hub 策略禁止批准延续时，每次调用单独确认
+	allowGrants := !a.policy.DisableTurnApproval
+	if how, ok := a.perms.approved(decision.key, allowGrants); ok {
+		fmt.Fprintf(a.out(), "\033[90m  ✓ %s（%s）\033[0m\n", how, decision.scope)
+		return "", true
+	}
+
+	if a.headless != nil {
+		// 非交互模式：无人确认，按命令行指定的写操作策略放行或拒绝（ask 由 MCP 客户端用户确认）
+		if a.headless.allowWrite(tc.Name, argsDisplay) {
+			return "", true
+		}
+		a.headless.denied[tc.ID] = true
+		if a.headless.opts.Write == HeadlessWriteAsk {
+			return fmt.Sprintf("操作 %s 需要确认（%s），未获用户确认（已拒绝或客户端不支持确认），未执行", tc.Name, decision.ruleLabel()), false
+		}
+		fmt.Fprintf(a.out(), "\033[1;31m  ✗ 需确认的操作未被允许（--write=%s）\033[0m\n", a.headless.opts.Write)
+		why := ""
+		if decision.reason != "" {
+			why = "（" + decision.reason + "）"
+		}
+		return fmt.Sprintf("非交互模式下不允许执行需确认的操作 %s%s（--write=%s），请仅用只读工具完成任务，并在回答中说明需要人工执行的操作", tc.Name, why, a.headless.opts.Write), false
+	}
+
+	printConfirmBox(tc.Name, argsDisplay, decision.scope, decision.reason, allowGrants)
+	answer, err := a.readInput("")
+	scope := approveNone
+	if err == nil {
+		scope = parseApprovalAnswer(answer)
+	}
+	if scope > approveOnce && !allowGrants {
+		scope = approveOnce
+	}
+	switch scope {
+	case approveNone:
+		fmt.Printf("\033[1;31m  ✗ 已取消\033[0m\n")
+		return fmt.Sprintf("用户拒绝了此操作（%s），未执行。请询问用户原因或调整方案，不要换用其他方式绕过", decision.ruleLabel()), false
+	case approveSession, approveAlways:
+		if err := a.perms.grant(decision, tc.Name, scope); err != nil {
+			fmt.Printf("\033[1;33m  ⚠ %v\033[0m\n", err)
+		}
+	}
+	return "", true
+}
+
+// activePolicy 连接 Hub 时返回 Hub 下发的策略，否则为空策略
+func (a *Agent) activePolicy() HubPolicy {
+	if a.aiCfg.Provider != "hub" {
+		return HubPolicy{}
+	}
+	return CurrentHubPolicy()
+}
+
+// buildSystemPrompt 本地提示词（自定义或默认）+ Hub 策略追加内容
+func (a *Agent) buildSystemPrompt(policy HubPolicy) string {
+	prompt := a.aiCfg.SystemPrompt
+	if prompt == "" {
+		prompt = a.defaultSystemPrompt()
+	}
+	if strings.TrimSpace(policy.PromptAppend) != "" {
+		prompt += "\n\n## Hub 下发的运维规范（必须遵守）\n\n" + strings.TrimSpace(policy.PromptAppend)
+	}
+	return prompt
+}
+
+// refreshHubPolicy 新用户轮次前检查 Hub 策略是否更新，更新后替换系统提示词
+func (a *Agent) refreshHubPolicy() {
+	policy := a.activePolicy()
+	if policy.Version == a.policy.Version {
+		return
+	}
+	a.policy = policy
+	a.systemPrompt = a.buildSystemPrompt(policy)
+	a.ctx.ReplaceSystem(a.systemPrompt)
+	if policy.Version != "" {
+		a.print(fmt.Sprintf("\033[1;36mℹ 已应用 Hub 策略（版本 %s）\033[0m", policy.Version))
+	}
+}
+
+// forbiddenShell 检查 run_shell 命令是否命中 Hub 禁止规则（按最新拉取的策略，不等下一轮次）
+func (a *Agent) forbiddenShell(argsJSON string) (string, bool) {
+	if a.aiCfg.Provider != "hub" {
+		return "", false
+	}
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", false
+	}
+	return forbiddenShellRule(args.Command)
+}
+
 // formatArgs 格式化工具参数为可读形式
 func formatArgs(argsJSON string) string {
 	if argsJSON == "" || argsJSON == "{}" {
@@ -761,7 +1093,7 @@ func (a *Agent) defaultSystemPrompt() string {
 ## 回复风格
 - 使用中文，简洁明了
 - 执行操作后说明结果和影响
-- 遇到问题主动建议下一步排查方向`, assistantIdentityPrompt(), a.execCtx.CurrentService, nodeRolePrompt(a.aiCfg), contextProfilePrompt(a.aiCfg))
+- 遇到问题主动建议下一步排查方向`, assistantIdentityPrompt(), a.execCtx.CurrentService, nodeRolePrompt(a.aiCfg), contextProfilePrompt(a.aiCfg)+a.executor.sandboxPrompt())
 }
 
 func assistantIdentityPrompt() string {
@@ -784,6 +1116,7 @@ func nodeRolePrompt(aiCfg AIConfig) string {
 - 集中持有 AI 配置；用 /hub-token 生成 Spoke 注册 Token，/hub-status 查看已注册节点及档案
 - 转发 Spoke 的 AI 请求；排查网关问题时可检查 :8000/:8001 与 /__hub__/ Nginx 路由
 - 远程协助 Spoke 时，以节点档案（项目类型、说明）和现场探测为准，**勿默认**对方有蓝绿代理或 Java actuator
+- 涉及 Spoke 的查询与操作用 spoke_list / spoke_status / spoke_logs / spoke_run，经 Hub 控制通道在对应节点执行（spokes 可填 all 查询全部节点，或用标签选择器如 env=prod,project=erp 圈定一组节点）；本地工具只作用于 Hub 本机
 
 **本机运维（同样需自适应）**：
 - Hub 服务器本身不一定是蓝绿架构；管理本机服务时先探查实际环境（进程/端口/容器/systemd）
@@ -819,14 +1152,16 @@ func hubSpokesPrompt() string {
 		return ""
 	}
 	var records []struct {
-		ID      string `json:"id"`
-		Revoked bool   `json:"revoked"`
+		ID      string            `json:"id"`
+		Revoked bool              `json:"revoked"`
+		Tags    map[string]string `json:"tags"`
 		Profile *struct {
-			Label       string `json:"label"`
-			ProjectName string `json:"project_name"`
-			ProjectType string `json:"project_type"`
-			Description string `json:"description"`
-			Hostname    string `json:"hostname"`
+			Label       string            `json:"label"`
+			ProjectName string            `json:"project_name"`
+			ProjectType string            `json:"project_type"`
+			Description string            `json:"description"`
+			Hostname    string            `json:"hostname"`
+			Tags        map[string]string `json:"tags"`
 		} `json:"profile"`
 	}
 	if json.Unmarshal(raw, &records) != nil || len(records) == 0 {
@@ -838,6 +1173,15 @@ func hubSpokesPrompt() string {
 			continue
 		}
 		line := rec.ID
+		tags := map[string]string{}
+		if rec.Profile != nil {
+			for k, v := range rec.Profile.Tags {
+				tags[k] = v
+			}
+		}
+		for k, v := range rec.Tags {
+			tags[k] = v
+		}
 		if rec.Profile != nil {
 			p := rec.Profile
 			if p.Label != "" {
@@ -853,6 +1197,14 @@ func hubSpokesPrompt() string {
 				line += " 主机:" + p.Hostname
 			}
 		}
+		if len(tags) > 0 {
+			keys := make([]string, 0, len(tags))
+			for k := range tags {
+				keys = append(keys, k+"="+tags[k])
+			}
+			sort.Strings(keys)
+			line += " 标签:" + strings.Join(keys, ",")
+		}
 		lines = append(lines, "- "+line)
 		if len(lines) >= 12 {
 			break
@@ -905,7 +1257,7 @@ func spokeProfilePrompt() string {
 func buildTitleSnippet(messages []Message) string {
 	var lines []string
 	for _, msg := range messages {
-		if msg.Role != "user" && msg.Role != "assistant" {
+		if (msg.Role != "user" && msg.Role != "assistant") || msg.Summary {
 			continue
 		}
 		content := strings.TrimSpace(msg.Content)
@@ -943,8 +1295,9 @@ func min(a, b int) int {
 	return b
 }
 
-// printConfirmBox 打印带确认/取消说明的操作确认框
-func printConfirmBox(toolName, argsDisplay string) {
+// printConfirmBox 打印带确认/取消说明的操作确认框；scope 为批准范围说明，reason 为规则说明，
+// grants=false 时（Hub 策略禁止批准延续）只提供仅本次确认
+func printConfirmBox(toolName, argsDisplay, scope, reason string, grants bool) {
 	const W = 52 // 框内可见总宽度（中文算2列）
 
 	border := strings.Repeat("─", W)
@@ -977,13 +1330,25 @@ func printConfirmBox(toolName, argsDisplay string) {
 
 	fmt.Println()
 	fmt.Printf("\033[1;33m┌%s┐\033[0m\n", border)
-	row("\033[1;33m", "  ⚠  即将执行写操作")
+	title := "  ⚠  即将执行写操作"
+	if strings.HasPrefix(scope, "规则 ") {
+		title = "  ⚠  权限规则要求确认"
+	}
+	row("\033[1;33m", title)
 	truncLine("  工具: ", toolName)
 	if argsDisplay != "" {
 		truncLine("  参数: ", argsDisplay)
 	}
+	if reason != "" {
+		truncLine("  说明: ", reason)
+	}
 	fmt.Printf("\033[1;33m├%s┤\033[0m\n", border)
-	row("\033[1;32m", "  ✓ 确认: 直接按 Enter 或输入 y")
+	row("\033[1;32m", "  ✓ 仅本次: 直接按 Enter 或输入 y")
+	if grants && scope != "" {
+		truncLine("  批准范围: ", scope)
+		row("\033[1;32m", "  ✓ 本会话内不再询问: 输入 s")
+		row("\033[1;32m", "  ✓ 始终允许: 输入 a")
+	}
 	row("\033[1;31m", "  ✗ 取消: 输入 n")
 	fmt.Printf("\033[1;33m└%s┘\033[0m\n", border)
 	fmt.Printf("\033[1;33m▶ \033[0m")
@@ -1030,133 +1395,6 @@ func splitToWidth(s string, maxW int) []string {
 	return lines
 }
 
-// isReadOnlyShellCmd 判断 run_shell 的 arguments JSON 中的命令是否为只读操作
-// 只读命令直接执行，不弹确认框
-func isReadOnlyShellCmd(argsJSON string) bool {
-	var args map[string]interface{}
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		return false
-	}
-	cmd := strings.TrimSpace(fmt.Sprintf("%v", args["command"]))
-	if cmd == "" {
-		return false
-	}
-
-	// 包含这些特征 → 明确是写操作，不跳过确认
-	writeSignals := []string{
-		">", ">>", // 重定向写入
-		"rm ", "rmdir ", // 删除
-		"mv ", "cp ", // 移动/复制（可能覆盖）
-		"chmod ", "chown ", // 权限修改
-		"dd ", "mkfs", "fdisk", // 磁盘操作
-		"systemctl ", "service ", // 服务控制（由 manage_systemd 负责）
-		"apt", "yum", "dnf", "pacman", "apk", "pip", "npm install", // 安装
-		"curl -o", "wget -O", "wget -P", // 下载写入
-		"tar -x", "unzip", "gunzip", // 解压
-		"kill ", "pkill ", "killall ", // 进程终止
-		"passwd ", "useradd ", "userdel", // 用户管理
-		"crontab ",          // 定时任务修改
-		"iptables ", "ufw ", // 防火墙修改
-		"mount ", "umount ", // 挂载
-		"sed -i", "awk '", // 原地修改
-	}
-	for _, sig := range writeSignals {
-		if strings.Contains(cmd, sig) {
-			return false
-		}
-	}
-
-	// 这些命令（精确匹配或前缀匹配）→ 明确是只读
-	readOnlyPrefixes := []string{
-		// nginx 检测
-		"nginx -t", "nginx -T",
-		// 目录/文件列表
-		"ls", "ll", "dir", "pwd", "realpath",
-		// 文件查看
-		"cat", "less", "more", "head", "tail", "strings",
-		// 文本处理（非原地修改）
-		"grep", "awk", "sed -n", "sort", "uniq", "wc", "cut", "tr", "diff", "comm",
-		// 文件查找
-		"find", "locate", "which", "type", "whereis",
-		// 磁盘信息
-		"df", "du", "lsblk", "blkid", "fdisk -l",
-		// 进程/负载
-		"ps", "top", "htop", "uptime", "pstree", "pgrep",
-		// 内存/IO
-		"free", "vmstat", "iostat", "sar", "mpstat",
-		// 网络信息（只查看）
-		"netstat", "ss", "lsof", "ifconfig", "ip addr", "ip route", "ip link",
-		// 网络测试（不修改状态）
-		"ping", "traceroute", "tracepath", "nslookup", "dig", "host",
-		"curl -s", "curl -I", "curl --silent", "curl --head", "curl --head",
-		// 系统信息
-		"uname", "hostname", "date", "timedatectl status",
-		"lscpu", "lshw", "dmidecode", "inxi",
-		// 用户信息
-		"whoami", "id", "groups", "w", "who", "last", "lastlog",
-		// 文件信息
-		"stat", "file", "md5sum", "sha256sum", "sha1sum", "xxd",
-		// 环境/变量查看
-		"env", "printenv", "set",
-		// 版本信息
-		"java -version", "java --version",
-		"python --version", "python3 --version",
-		"node --version", "node -v",
-		"go version", "ruby --version", "php --version",
-		"nginx -v", "nginx -V",
-		"mysql --version", "redis-cli --version",
-		// systemd 只读查询
-		"systemctl status", "systemctl is-active", "systemctl is-enabled",
-		"systemctl list-units", "systemctl list-services",
-		// 日志查看
-		"journalctl", "dmesg",
-		// 包查询（不安装）
-		"rpm -q", "rpm -qa", "dpkg -l", "dpkg -s",
-		"apt list", "apt show", "yum list", "yum info",
-		"dnf list", "dnf info",
-	}
-	lowerCmd := strings.ToLower(cmd)
-	for _, prefix := range readOnlyPrefixes {
-		p := strings.ToLower(prefix)
-		if lowerCmd == p {
-			return true // 精确匹配（如 pwd、ls、date）
-		}
-		if strings.HasPrefix(lowerCmd, p+" ") || strings.HasPrefix(lowerCmd, p+"\t") {
-			return true // 前缀匹配（如 ls -la、cat /etc/nginx.conf）
-		}
-	}
-
-	return false
-}
-
-// isStandaloneAffirmative 判断用户消息是否是独立的确认词
-// 用于：用户已经发送了确认意图时，自动跳过写操作的二次确认框
-func isStandaloneAffirmative(s string) bool {
-	s = strings.ToLower(strings.TrimSpace(s))
-	// 纯确认词列表
-	exact := []string{
-		"y", "yes", "ok", "好", "是", "嗯",
-		"确认", "同意", "可以", "行", "好的",
-		"执行", "继续", "去做", "做吧", "没问题",
-		"是的", "对", "对的", "去吧",
-	}
-	for _, w := range exact {
-		if s == w {
-			return true
-		}
-	}
-	// 以确认词开头且整体较短（≤8个字符）的情况，如"确认删除"、"好的去做"
-	if len([]rune(s)) <= 8 {
-		prefixes := []string{"确认", "同意", "好的", "可以", "执行", "去做"}
-		for _, p := range prefixes {
-			if strings.HasPrefix(s, p) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 // renderMarkdown 将 Markdown 文本渲染为带 ANSI 颜色的终端输出
 func renderMarkdown(text string) string {
 	r, err := glamour.NewTermRenderer(
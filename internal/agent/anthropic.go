@@ -68,13 +68,33 @@ type anthropicResponse struct {
 		Name  string      `json:"name"`
 		Input interface{} `json:"input"`
 	} `json:"content"`
-	StopReason string `json:"stop_reason"`
+	StopReason string          `json:"stop_reason"`
+	Usage      *anthropicUsage `json:"usage"`
 	Error      *struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
+// anthropicUsage 开启 prompt 缓存时，输入 token 分为 input、cache_creation、cache_read 三部分
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u *anthropicUsage) toUsage() *Usage {
+	if u == nil {
+		return nil
+	}
+	input := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	if input <= 0 {
+		return nil
+	}
+	return &Usage{InputTokens: input, OutputTokens: u.OutputTokens}
+}
+
 // ——— 消息格式转换 ———
 
 // convertMessages 把内部 []Message 转成 Anthropic 格式
@@ -223,7 +243,7 @@ func (p *anthropicProvider) Chat(ctx context.Context, messages []Message, tools
 			})
 		}
 	}
-	return &ChatResponse{Content: text, ToolCalls: toolCalls}, nil
+	return &ChatResponse{Content: text, ToolCalls: toolCalls, Usage: apiResp.Usage.toUsage()}, nil
 }
 
 // ——— Stream（SSE）———
@@ -283,6 +303,8 @@ func (p *anthropicProvider) parseAnthropicSSE(body io.Reader, ch chan<- StreamEv
 
 	blocks := make(map[int]*anthropicSSEBlock)
 	var currentEvent string
+	// 输入用量在 message_start 中上报，输出用量在 message_delta 中累计上报
+	var usage *Usage
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -300,6 +322,26 @@ func (p *anthropicProvider) parseAnthropicSSE(body io.Reader, ch chan<- StreamEv
 		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
 
 		switch currentEvent {
+		case "message_start":
+			var ev struct {
+				Message struct {
+					Usage *anthropicUsage `json:"usage"`
+				} `json:"message"`
+			}
+			if err := json.Unmarshal([]byte(data), &ev); err == nil {
+				usage = ev.Message.Usage.toUsage()
+			}
+
+		case "message_delta":
+			var ev struct {
+				Usage struct {
+					OutputTokens int `json:"output_tokens"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(data), &ev); err == nil && usage != nil && ev.Usage.OutputTokens > 0 {
+				usage.OutputTokens = ev.Usage.OutputTokens
+			}
+
 		case "content_block_start":
 			var ev struct {
 				Index        int `json:"index"`
@@ -367,7 +409,7 @@ func (p *anthropicProvider) parseAnthropicSSE(body io.Reader, ch chan<- StreamEv
 			delete(blocks, ev.Index)
 
 		case "message_stop":
-			ch <- StreamEvent{Type: "done"}
+			ch <- StreamEvent{Type: "done", Usage: usage}
 			return
 		}
 	}
@@ -375,5 +417,5 @@ func (p *anthropicProvider) parseAnthropicSSE(body io.Reader, ch chan<- StreamEv
 	if err := scanner.Err(); err != nil && err != io.EOF {
 		ch <- StreamEvent{Type: "error", Err: fmt.Errorf("读取流失败: %v", err)}
 	}
-	ch <- StreamEvent{Type: "done"}
+	ch <- StreamEvent{Type: "done", Usage: usage}
 }
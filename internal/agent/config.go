@@ -10,14 +10,16 @@ const appConfigFile = "configs/app_config.json"
 
 // AIConfig LLM 提供商配置
 type AIConfig struct {
-	Provider       string `json:"provider"`        // openai | anthropic | ollama
-	APIKey         string `json:"api_key"`         // API 密钥（ollama 可留空）
-	BaseURL        string `json:"base_url"`        // 覆盖默认 endpoint
-	Model          string `json:"model"`           // 模型名称
-	MaxTokens      int    `json:"max_tokens"`      // 单次最大生成 token 数
-	ContextLimit   int    `json:"context_limit"`   // 保留的历史 token 上限
-	TimeoutSeconds int    `json:"timeout_seconds"` // HTTP 超时
-	SystemPrompt   string `json:"system_prompt"`   // 留空则用默认提示词
+	Provider       string   `json:"provider"`           // openai | anthropic | ollama
+	APIKey         string   `json:"api_key"`            // API 密钥（ollama 可留空）
+	BaseURL        string   `json:"base_url"`           // 覆盖默认 endpoint
+	Model          string   `json:"model"`              // 模型名称
+	MaxTokens      int      `json:"max_tokens"`         // 单次最大生成 token 数
+	ContextLimit   int      `json:"context_limit"`      // 保留的历史 token 上限
+	TimeoutSeconds int      `json:"timeout_seconds"`    // HTTP 超时
+	SystemPrompt   string   `json:"system_prompt"`      // 留空则用默认提示词
+	HubAuth        string   `json:"hub_auth"`           // provider=hub 时的认证方式：bearer（默认）| hmac 请求签名
+	HubURLs        []string `json:"hub_urls,omitempty"` // provider=hub 时的备用 Hub 地址，base_url 不可用时依次切换
 }
 
 // DefaultAIConfig 返回各 provider 的默认配置模板
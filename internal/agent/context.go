@@ -1,18 +1,39 @@
 package agent
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
 
 const (
 	// 工具输出单条最大字符数
 	maxToolOutputChars = 3000
 	// tool 消息在压缩后保留的内容长度
 	compactToolOutputChars = 200
+	// 每条消息的格式开销（role、分隔符等）
+	messageOverheadTokens = 4
+	// 早期对话摘要的最大字符数，避免摘要本身越滚越大
+	maxSummaryRunes = 2000
+
+	summaryUserPrefix = "以下是本会话早期对话的摘要（原始消息已因上下文长度限制移除）：\n\n"
+	summaryAckContent = "好的，我会结合以上摘要继续处理。"
 )
 
+// Summarizer 把被移出上下文的消息合并进滚动摘要：previous 为已有摘要（可能为空），返回新摘要
+type Summarizer func(previous string, dropped []Message) (string, error)
+
 // ContextManager 管理对话历史，并在 token 超限时自动压缩
 type ContextManager struct {
 	messages     []Message
-	contextLimit int // 估算 token 上限（4 char ≈ 1 token）
+	contextLimit int // token 上限（优先使用提供商上报的用量，否则本地估算）
+
+	// 提供商上报的 prompt tokens，对应 messages[:reportedLen]；消息被改写或删除后失效
+	reportedTokens int
+	reportedLen    int
+	usageTotal     Usage // 本会话累计上报用量
+
+	summarize Summarizer
 }
 
 // NewContextManager 创建 ContextManager
@@ -23,6 +44,11 @@ func NewContextManager(contextLimit int) *ContextManager {
 	return &ContextManager{contextLimit: contextLimit}
 }
 
+// SetSummarizer 设置压缩时生成早期对话摘要的回调；未设置或失败时直接丢弃早期对话
+func (c *ContextManager) SetSummarizer(fn Summarizer) {
+	c.summarize = fn
+}
+
 // Add 追加一条消息，并在需要时触发压缩
 func (c *ContextManager) Add(msg Message) {
 	// 截断单条工具输出，避免单条消息就把 context 撑满
@@ -33,6 +59,17 @@ func (c *ContextManager) Add(msg Message) {
 	c.maybeCompact()
 }
 
+// RecordUsage 记录提供商对当前全部消息上报的用量（须在请求返回后、追加 assistant 消息前调用）
+func (c *ContextManager) RecordUsage(u Usage) {
+	if u.InputTokens <= 0 {
+		return
+	}
+	c.reportedTokens = u.InputTokens
+	c.reportedLen = len(c.messages)
+	c.usageTotal.InputTokens += u.InputTokens
+	c.usageTotal.OutputTokens += u.OutputTokens
+}
+
 // Messages 返回当前全部消息（供 provider 使用）
 func (c *ContextManager) Messages() []Message {
 	return c.messages
@@ -48,10 +85,12 @@ func (c *ContextManager) Clear() {
 		}
 	}
 	c.messages = system
+	c.invalidateUsage()
 }
 
 // ReplaceSystem 替换或添加 system 消息（用于恢复历史时更新上下文）
 func (c *ContextManager) ReplaceSystem(content string) {
+	c.invalidateUsage()
 	systemMsg := Message{Role: "system", Content: content}
 	for i, m := range c.messages {
 		if m.Role == "system" {
@@ -66,19 +105,56 @@ func (c *ContextManager) ReplaceSystem(content string) {
 // Len 返回消息数量
 func (c *ContextManager) Len() int { return len(c.messages) }
 
-// estimatedTokens 粗略估算当前 token 数（4 char ≈ 1 token）
+func (c *ContextManager) invalidateUsage() {
+	c.reportedTokens = 0
+	c.reportedLen = 0
+}
+
+// estimatedTokens 当前 token 数：有上报用量时以其为基准，只估算之后追加的消息
 func (c *ContextManager) estimatedTokens() int {
-	total := 0
-	for _, m := range c.messages {
-		total += len(m.Content) / 4
-		for _, tc := range m.ToolCalls {
-			total += len(tc.Arguments) / 4
-		}
+	start, total := 0, 0
+	if c.reportedLen > 0 && c.reportedLen <= len(c.messages) {
+		start, total = c.reportedLen, c.reportedTokens
+	}
+	for _, m := range c.messages[start:] {
+		total += estimateMessageTokens(m)
 	}
 	return total
 }
 
-// maybeCompact 若估算 token 超过 contextLimit 的 80%，压缩旧的工具消息
+func estimateMessageTokens(m Message) int {
+	total := messageOverheadTokens + estimateTextTokens(m.Content) + estimateTextTokens(m.ReasoningContent)
+	for _, tc := range m.ToolCalls {
+		total += estimateTextTokens(tc.Name) + estimateTextTokens(tc.Arguments)
+	}
+	return total
+}
+
+// estimateTextTokens 本地估算 token 数：ASCII 约 4 字符 1 token，
+// 中日韩文字及全角标点约 1 字 1 token，其他非 ASCII 字符约 2 字符 1 token
+func estimateTextTokens(s string) int {
+	ascii, cjk, other := 0, 0, 0
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			ascii++
+		case isCJKRune(r):
+			cjk++
+		default:
+			other++
+		}
+	}
+	return (ascii+3)/4 + cjk + (other+1)/2
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
+
+// maybeCompact 若 token 超过 contextLimit 的 80%：先截短旧的工具输出，
+// 仍超限则移除最早的对话，并把移除的内容合并进滚动摘要
 func (c *ContextManager) maybeCompact() {
 	threshold := int(float64(c.contextLimit) * 0.8)
 	if c.estimatedTokens() <= threshold {
@@ -86,7 +162,6 @@ func (c *ContextManager) maybeCompact() {
 	}
 
 	// 策略：从最早的 tool 消息开始，截短内容为摘要
-	compacted := 0
 	for i := range c.messages {
 		if c.messages[i].Role != "tool" {
 			continue
@@ -95,89 +170,152 @@ func (c *ContextManager) maybeCompact() {
 		if len(content) <= compactToolOutputChars {
 			continue
 		}
-		// 保留前 compactToolOutputChars 字符 + 省略提示
-		c.messages[i].Content = content[:compactToolOutputChars] + "\n[...内容已压缩以节省上下文...]"
-		compacted++
+		// 保留前 compactToolOutputChars 字符 + 省略提示（按 rune 边界截断）
+		cut := compactToolOutputChars
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		c.messages[i].Content = content[:cut] + "\n[...内容已压缩以节省上下文...]"
+		c.invalidateUsage()
 
 		// 压缩后重新检查
 		if c.estimatedTokens() <= threshold {
-			break
+			return
 		}
 	}
 
-	// 若压缩工具消息后仍超限，删除最早的几轮（保留 system 消息）
-	if c.estimatedTokens() > threshold {
-		c.dropOldestRound()
+	// 若压缩工具消息后仍超限，移除最早的对话（保留 system 消息与当前轮次的最近一步）；
+	// 一次降到 60% 以下，给摘要留出空间，也避免之后每追加一条消息都触发压缩
+	target := int(float64(c.contextLimit) * 0.6)
+	var dropped []Message
+	for c.estimatedTokens() > target {
+		removed := c.dropOldest()
+		if len(removed) == 0 {
+			break
+		}
+		dropped = append(dropped, removed...)
+	}
+	if len(dropped) > 0 {
+		c.foldIntoSummary(dropped)
 	}
 }
 
-// dropOldestRound 删除最早的一轮对话（user + assistant + 可能的 tool 消息）
-func (c *ContextManager) dropOldestRound() {
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// dropOldest 移除最早的一段对话并返回：
+// 有多轮时移除最早一轮（user + assistant + 可能的 tool 消息）；
+// 只剩当前一轮时移除其中最早的一步（assistant 工具调用 + 对应 tool 结果），保留用户问题与最近一步
+func (c *ContextManager) dropOldest() []Message {
 	start := 0
-	// 跳过 system 消息
-	for start < len(c.messages) && c.messages[start].Role == "system" {
+	// 跳过 system 消息与已有摘要
+	for start < len(c.messages) && (c.messages[start].Role == "system" || c.messages[start].Summary) {
 		start++
 	}
-	if start >= len(c.messages) {
-		return
-	}
 
-	// 找到第一个 user 消息之后的下一个 user 消息，删除中间这一段
-	firstUser := -1
-	secondUser := -1
+	var users []int
 	for i := start; i < len(c.messages); i++ {
 		if c.messages[i].Role == "user" {
-			if firstUser == -1 {
-				firstUser = i
-			} else {
-				secondUser = i
-				break
+			users = append(users, i)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	from, end := users[0], len(c.messages)
+	if len(users) >= 2 {
+		end = users[1]
+	} else {
+		// 当前轮次内：从用户消息之后找最早的一步
+		var steps []int
+		for i := users[0] + 1; i < len(c.messages); i++ {
+			if c.messages[i].Role == "assistant" {
+				steps = append(steps, i)
 			}
 		}
+		if len(steps) < 2 {
+			return nil
+		}
+		from, end = steps[0], steps[1]
 	}
 
-	if firstUser == -1 {
-		return
+	removed := append([]Message(nil), c.messages[from:end]...)
+	c.messages = append(c.messages[:from], c.messages[end:]...)
+	c.invalidateUsage()
+	return removed
+}
+
+// summaryText 返回已有的滚动摘要
+func (c *ContextManager) summaryText() string {
+	for _, m := range c.messages {
+		if m.Summary && m.Role == "user" {
+			return strings.TrimPrefix(m.Content, summaryUserPrefix)
+		}
 	}
+	return ""
+}
 
-	end := secondUser
-	if end == -1 {
-		end = len(c.messages)
+// foldIntoSummary 把移除的消息合并进滚动摘要，以 user + assistant 一对消息放在 system 之后
+func (c *ContextManager) foldIntoSummary(dropped []Message) {
+	previous := c.summaryText()
+	summary := ""
+	if c.summarize != nil {
+		if s, err := c.summarize(previous, dropped); err == nil {
+			summary = strings.TrimSpace(s)
+		}
+	}
+	if summary == "" {
+		// 无法生成摘要：保留已有摘要，并注明有内容被省略
+		summary = previous
+		if !strings.Contains(summary, "更早的部分对话已省略") {
+			summary = strings.TrimSpace(summary + "\n\n（更早的部分对话已省略，未能生成摘要）")
+		}
+	}
+	if r := []rune(summary); len(r) > maxSummaryRunes {
+		summary = string(r[:maxSummaryRunes]) + "…"
 	}
 
-	// 删除 [firstUser, end) 这段
-	c.messages = append(c.messages[:firstUser], c.messages[end:]...)
+	kept := make([]Message, 0, len(c.messages)+2)
+	insertAt := 0
+	for _, m := range c.messages {
+		if m.Summary {
+			continue
+		}
+		kept = append(kept, m)
+		if m.Role == "system" && insertAt == len(kept)-1 {
+			insertAt = len(kept)
+		}
+	}
+	pair := []Message{
+		{Role: "user", Content: summaryUserPrefix + summary, Summary: true},
+		{Role: "assistant", Content: summaryAckContent, Summary: true},
+	}
+	c.messages = append(kept[:insertAt], append(pair, kept[insertAt:]...)...)
+	c.invalidateUsage()
 }
 
 // Summary 返回当前对话摘要（用于显示）
 func (c *ContextManager) Summary() string {
 	var sb strings.Builder
-	tokens := c.estimatedTokens()
-	sb.WriteString("对话历史: ")
 	counts := map[string]int{}
 	for _, m := range c.messages {
-		counts[m.Role]++
-	}
-	sb.WriteString("用户消息 ")
-	sb.WriteString(itoa(counts["user"]))
-	sb.WriteString(" 条，AI 回复 ")
-	sb.WriteString(itoa(counts["assistant"]))
-	sb.WriteString(" 条，工具调用 ")
-	sb.WriteString(itoa(counts["tool"]))
-	sb.WriteString(" 次，估算 ")
-	sb.WriteString(itoa(tokens))
-	sb.WriteString(" tokens")
-	return sb.String()
-}
-
-func itoa(n int) string {
-	if n == 0 {
-		return "0"
+		if !m.Summary {
+			counts[m.Role]++
+		}
 	}
-	result := ""
-	for n > 0 {
-		result = string(rune('0'+n%10)) + result
-		n /= 10
+	fmt.Fprintf(&sb, "对话历史: 用户消息 %d 条，AI 回复 %d 条，工具调用 %d 次，",
+		counts["user"], counts["assistant"], counts["tool"])
+	if c.reportedLen > 0 {
+		fmt.Fprintf(&sb, "约 %d tokens（基于模型上报用量）", c.estimatedTokens())
+	} else {
+		fmt.Fprintf(&sb, "估算 %d tokens", c.estimatedTokens())
 	}
-	return result
+	fmt.Fprintf(&sb, " / 上限 %d", c.contextLimit)
+	if c.summaryText() != "" {
+		sb.WriteString("，早期对话已压缩为摘要")
+	}
+	if c.usageTotal.InputTokens > 0 {
+		fmt.Fprintf(&sb, "；累计用量 输入 %d、输出 %d tokens", c.usageTotal.InputTokens, c.usageTotal.OutputTokens)
+	}
+	return sb.String()
 }
@@ -0,0 +1,411 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/config"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ——— 自定义工具：configs/agent_tools/*.json 中声明命令模板，把团队的运维手册步骤固化为 Agent 工具 ———
+
+const (
+	customToolsDir           = "configs/agent_tools"
+	customToolDefaultTimeout = 60
+	customToolMaxTimeout     = 600
+)
+
+var (
+	customToolNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	customToolPlaceholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// customToolArgMarker 校验模板时代替占位符的标记，便于在语法树中定位占位符所处的上下文
+const customToolArgMarker = "__ruoyi_custom_tool_arg__"
+
+// CustomToolSpec 自定义工具定义（每个 JSON 文件一个对象或对象数组）
+type CustomToolSpec struct {
+	Name           string                 `json:"name"`
+	Description    string                 `json:"description"`
+	Parameters     map[string]interface{} `json:"parameters"`      // JSON Schema（type=object），留空表示无参数
+	Command        string                 `json:"command"`         // bash 命令模板，{{参数名}} 替换为经过 shell 转义的参数值
+	ReadOnly       bool                   `json:"read_only"`       // true 时无需用户确认
+	TimeoutSeconds int                    `json:"timeout_seconds"` // 默认 60，最大 600
+	Workdir        string                 `json:"workdir"`         // 执行目录，默认 APP_HOME
+}
+
+type customTool struct {
+	spec     CustomToolSpec
+	props    map[string]map[string]interface{}
+	required []string
+}
+
+func (t *customTool) Definition() ToolDef {
+	return ToolDef{
+		Name:        t.spec.Name,
+		Description: t.spec.Description,
+		Parameters:  t.spec.Parameters,
+		ReadOnly:    t.spec.ReadOnly,
+	}
+}
+
+// Execute 校验参数后展开命令模板并以 bash 执行，环境变量与 service.sh 相同（APP_HOME、SERVICE_ID 等）
+func (t *customTool) Execute(e *ToolExecutor, args map[string]interface{}) (string, error) {
+	if err := t.validateArgs(args); err != nil {
+		return "", err
+	}
+	command, err := t.render(args)
+	if err != nil {
+		return "", err
+	}
+
+	cmd, sandbox, err := e.shellCommand(command)
+	if err != nil {
+		return "", err
+	}
+	cmd.Dir = e.execCtx.AppHome
+	if t.spec.Workdir != "" {
+		cmd.Dir = expandHome(t.spec.Workdir)
+		if !filepath.IsAbs(cmd.Dir) && e.execCtx.AppHome != "" {
+			cmd.Dir = filepath.Join(e.execCtx.AppHome, cmd.Dir)
+		}
+	}
+	if cfg, err := config.LoadConfig(); err == nil {
+		if svc := cfg.GetService(e.execCtx.CurrentService); svc != nil {
+			cmd.Env = buildScriptEnv(e.execCtx, svc)
+		}
+	}
+
+	timeout := time.Duration(t.spec.TimeoutSeconds) * time.Second
+	out, err := runWithTimeout(cmd, timeout)
+	notice := sandbox.blockedNotice(out, err)
+	if err != nil {
+		return fmt.Sprintf("命令输出:\n%s\n✗ %v%s", out, err, notice), nil
+	}
+	return out + notice, nil
+}
+
+// validateArgs 按参数 schema 检查必填项、基本类型与枚举值
+func (t *customTool) validateArgs(args map[string]interface{}) error {
+	for _, name := range t.required {
+		if v, ok := args[name]; !ok || v == nil {
+			return fmt.Errorf("缺少必填参数: %s", name)
+		}
+	}
+	for name, v := range args {
+		prop, ok := t.props[name]
+		if !ok || v == nil {
+			continue
+		}
+		typ, _ := prop["type"].(string)
+		valid := true
+		switch typ {
+		case "string":
+			_, valid = v.(string)
+		case "boolean":
+			_, valid = v.(bool)
+		case "number":
+			_, valid = v.(float64)
+		case "integer":
+			f, isNum := v.(float64)
+			valid = isNum && f == float64(int64(f))
+		case "array":
+			_, valid = v.([]interface{})
+		}
+		if !valid {
+			return fmt.Errorf("参数 %s 应为 %s 类型", name, typ)
+		}
+		if enum, ok := prop["enum"].([]interface{}); ok && len(enum) > 0 {
+			matched := false
+			for _, allowed := range enum {
+				matched = matched || fmt.Sprint(allowed) == fmt.Sprint(v)
+			}
+			if !matched {
+				return fmt.Errorf("参数 %s 的值 %v 不在允许范围内: %v", name, v, enum)
+			}
+		}
+	}
+	return nil
+}
+
+// render 展开命令模板：每个 {{参数名}} 替换为单引号转义后的值（数组展开为多个参数），未传入的可选参数替换为 ”
+func (t *customTool) render(args map[string]interface{}) (string, error) {
+	var renderErr error
+	command := customToolPlaceholderRe.ReplaceAllStringFunc(t.spec.Command, func(m string) string {
+		name := customToolPlaceholderRe.FindStringSubmatch(m)[1]
+		v, ok := args[name]
+		if !ok || v == nil {
+			return "''"
+		}
+		if list, ok := v.([]interface{}); ok {
+			quoted := make([]string, 0, len(list))
+			for _, item := range list {
+				s, err := customToolScalar(item)
+				if err != nil {
+					renderErr = fmt.Errorf("参数 %s: %v", name, err)
+					return ""
+				}
+				quoted = append(quoted, shellQuote(s))
+			}
+			return strings.Join(quoted, " ")
+		}
+		s, err := customToolScalar(v)
+		if err != nil {
+			renderErr = fmt.Errorf("参数 %s: %v", name, err)
+			return ""
+		}
+		return shellQuote(s)
+	})
+	return command, renderErr
+}
+
+func customToolScalar(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		if strings.ContainsRune(x, 0) {
+			return "", fmt.Errorf("值包含空字符")
+		}
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("不支持的值类型 %T", v)
+}
+
+// shellQuote 用单引号包裹，内部单引号转义为 '\”
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// newCustomTool 校验定义：名称格式、不与内置工具重名、模板占位符均在参数中声明
+func newCustomTool(spec CustomToolSpec) (*customTool, error) {
+	if !customToolNamePattern.MatchString(spec.Name) || isMCPTool(spec.Name) {
+		return nil, fmt.Errorf("工具名称 %q 无效（小写字母开头，仅含小写字母、数字、下划线，最长 64 字符，不能以 mcp__ 开头）", spec.Name)
+	}
+	if src := defaultRegistry.sourceOf(spec.Name); src != "" && src != toolSourceCustom {
+		return nil, fmt.Errorf("工具名称 %s 与内置工具重名", spec.Name)
+	}
+	if strings.TrimSpace(spec.Description) == "" {
+		return nil, fmt.Errorf("工具 %s 缺少 description", spec.Name)
+	}
+	if strings.TrimSpace(spec.Command) == "" {
+		return nil, fmt.Errorf("工具 %s 缺少 command", spec.Name)
+	}
+	if spec.TimeoutSeconds <= 0 {
+		spec.TimeoutSeconds = customToolDefaultTimeout
+	}
+	if spec.TimeoutSeconds > customToolMaxTimeout {
+		return nil, fmt.Errorf("工具 %s 的 timeout_seconds 不能超过 %d", spec.Name, customToolMaxTimeout)
+	}
+	if spec.Parameters == nil {
+		spec.Parameters = emptyParams()
+	}
+	if typ, _ := spec.Parameters["type"].(string); typ != "object" {
+		return nil, fmt.Errorf("工具 %s 的 parameters.type 必须为 object", spec.Name)
+	}
+
+	t := &customTool{spec: spec, props: make(map[string]map[string]interface{})}
+	if props, ok := spec.Parameters["properties"].(map[string]interface{}); ok {
+		for name, raw := range props {
+			prop, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("工具 %s 的参数 %s 定义无效", spec.Name, name)
+			}
+			if typ, _ := prop["type"].(string); typ == "object" {
+				return nil, fmt.Errorf("工具 %s 的参数 %s 不支持 object 类型", spec.Name, name)
+			}
+			t.props[name] = prop
+		}
+	}
+	if required, ok := spec.Parameters["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, declared := t.props[name]; !declared {
+				return nil, fmt.Errorf("工具 %s 的必填参数 %v 未在 properties 中声明", spec.Name, r)
+			}
+			t.required = append(t.required, name)
+		}
+	}
+	for _, m := range customToolPlaceholderRe.FindAllStringSubmatch(spec.Command, -1) {
+		if _, declared := t.props[m[1]]; !declared {
+			return nil, fmt.Errorf("工具 %s 的命令模板引用了未声明的参数 %s", spec.Name, m[1])
+		}
+	}
+	if err := checkCustomToolTemplate(spec.Command); err != nil {
+		return nil, fmt.Errorf("工具 %s 的命令模板无效: %v", spec.Name, err)
+	}
+	return t, nil
+}
+
+// checkCustomToolTemplate 解析命令模板，要求每个 {{参数名}} 都位于未加引号的单词中：
+// 占位符展开为单引号转义后的值，放进双引号、单引号、heredoc、${...}、算术表达式或注释后转义会失效
+func checkCustomToolTemplate(command string) error {
+	marked := customToolPlaceholderRe.ReplaceAllString(command, customToolArgMarker)
+	total := strings.Count(marked, customToolArgMarker)
+	if total == 0 {
+		return nil
+	}
+	file, err := parseShellCommand(marked)
+	if err != nil {
+		return err
+	}
+	found := 0
+	var visit func(node syntax.Node) bool
+	var visitWord func(w *syntax.Word)
+	visitWord = func(w *syntax.Word) {
+		if w == nil {
+			return
+		}
+		for _, part := range w.Parts {
+			switch x := part.(type) {
+			case *syntax.Lit:
+				found += strings.Count(x.Value, customToolArgMarker)
+			case *syntax.DblQuoted:
+				// 双引号内只检查命令替换，其中的占位符仍在独立的未加引号上下文中
+				for _, inner := range x.Parts {
+					if _, ok := inner.(*syntax.CmdSubst); ok {
+						syntax.Walk(inner, visit)
+					}
+				}
+			case *syntax.CmdSubst, *syntax.ProcSubst:
+				syntax.Walk(x, visit)
+			}
+		}
+	}
+	visit = func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.Word:
+			visitWord(x)
+			return false
+		case *syntax.Redirect:
+			// heredoc 正文不计入
+			visitWord(x.Word)
+			return false
+		case *syntax.Assign:
+			// 数组下标按算术求值，不计入
+			visitWord(x.Value)
+			if x.Array != nil {
+				for _, elem := range x.Array.Elems {
+					visitWord(elem.Value)
+				}
+			}
+			return false
+		case *syntax.ArithmCmd, *syntax.ArithmExp, *syntax.LetClause:
+			// 算术上下文会对引号内的 a[$(...)] 求值
+			return false
+		case *syntax.BinaryTest:
+			if x.Op >= syntax.TsEql && x.Op <= syntax.TsGtr {
+				return false
+			}
+		case *syntax.UnaryTest:
+			if x.Op == syntax.TsVarSet || x.Op == syntax.TsRefVar {
+				return false
+			}
+		}
+		return true
+	}
+	syntax.Walk(file, visit)
+	if found != total {
+		return fmt.Errorf("{{参数}} 只能作为未加引号的单词使用，不能放在引号、heredoc、${...}、算术表达式或注释中")
+	}
+	return nil
+}
+
+// LoadCustomTools 读取 configs/agent_tools/*.json 并替换注册表中的自定义工具，
+// 返回已加载的工具名与各文件的错误（单个文件出错不影响其他文件）
+func LoadCustomTools() ([]string, []error) {
+	files, _ := filepath.Glob(filepath.Join(customToolsDir, "*.json"))
+	sort.Strings(files)
+
+	var tools []Tool
+	var errs []error
+	seen := make(map[string]string)
+	for _, file := range files {
+		specs, err := readCustomToolFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file, err))
+			continue
+		}
+		for _, spec := range specs {
+			t, err := newCustomTool(spec)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", file, err))
+				continue
+			}
+			if prev, dup := seen[spec.Name]; dup {
+				errs = append(errs, fmt.Errorf("%s: 工具 %s 已在 %s 中定义", file, spec.Name, prev))
+				continue
+			}
+			seen[spec.Name] = file
+			tools = append(tools, t)
+		}
+	}
+	errs = append(errs, defaultRegistry.replaceSource(toolSourceCustom, tools)...)
+
+	names := make([]string, 0, len(tools))
+	for _, def := range defaultRegistry.Definitions(true) {
+		if defaultRegistry.sourceOf(def.Name) == toolSourceCustom {
+			names = append(names, def.Name)
+		}
+	}
+	return names, errs
+}
+
+func readCustomToolFile(file string) ([]CustomToolSpec, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimSpace(string(data))
+	var specs []CustomToolSpec
+	if strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &specs)
+	} else {
+		var spec CustomToolSpec
+		err = json.Unmarshal(data, &spec)
+		specs = append(specs, spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析失败: %v", err)
+	}
+	return specs, nil
+}
+
+// loadCustomTools 加载自定义工具并输出结果（目录不存在时静默）
+func (a *Agent) loadCustomTools() {
+	names, errs := LoadCustomTools()
+	if len(names) > 0 {
+		a.print(fmt.Sprintf("\033[1;36mℹ 已加载 %d 个自定义工具: %s\033[0m", len(names), strings.Join(names, ", ")))
+	}
+	for _, err := range errs {
+		a.print(fmt.Sprintf("\033[1;33m⚠ 自定义工具加载失败 %v\033[0m", err))
+	}
+}
+
+// printTools /tools 命令：列出当前提供给模型的工具及来源
+func (a *Agent) printTools() {
+	a.print("\033[1;34m═══ 可用工具 ═══\033[0m")
+	for _, t := range a.tools() {
+		source := defaultRegistry.sourceOf(t.Name)
+		if isMCPTool(t.Name) {
+			source = "mcp"
+		}
+		mode := "只读"
+		if !t.ReadOnly {
+			mode = "\033[1;33m需确认\033[0m"
+		}
+		a.print(fmt.Sprintf("  %-24s %-8s %s", t.Name, source, mode))
+	}
+	a.print(fmt.Sprintf("\033[90m自定义工具目录: %s（/tools reload 重新加载）\033[0m", customToolsDir))
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewCustomToolPlaceholders(t *testing.T) {
+	tests := []struct {
+		command string
+		ok      bool
+	}{
+		// 占位符作为未加引号的单词（可与字面量拼接）
+		{command: "tail -n {{lines}} /var/log/{{service}}.log", ok: true},
+		{command: "grep -c {{ service }} app.log", ok: true},
+		{command: "SERVICE={{service}} ./deploy.sh", ok: true},
+		{command: "for f in {{service}}; do echo \"$f\"; done", ok: true},
+		{command: "echo \"$(systemctl status {{service}})\"", ok: true},
+		{command: "diff <(cat {{service}}.a) {{service}}.b", ok: true},
+		{command: "[[ {{service}} == api ]] && echo api", ok: true},
+		{command: "cat > /tmp/{{service}}.txt <<'EOF'\nstatic\nEOF", ok: true},
+		{command: "uptime", ok: true},
+
+		// 引号、heredoc、参数展开、算术上下文与注释中转义会失效
+		{command: "echo \"service: {{service}}\""},
+		{command: "echo '{{service}}'"},
+		{command: "echo $'{{service}}'"},
+		{command: "cat <<EOF\n{{service}}\nEOF"},
+		{command: "cat <<'EOF'\n{{service}}\nEOF"},
+		{command: "echo ${SERVICE:-{{service}}}"},
+		{command: "echo $(( {{lines}} + 1 ))"},
+		{command: "(( {{lines}} > 10 )) && echo many"},
+		{command: "let n={{lines}}"},
+		{command: "a[{{lines}}]=1"},
+		{command: "[[ {{lines}} -gt 10 ]] && echo many"},
+		{command: "[[ -v {{service}} ]]"},
+		{command: "uptime # {{service}}"},
+	}
+	for _, tt := range tests {
+		spec := CustomToolSpec{
+			Name:        "test_tool",
+			Description: "test",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"service": map[string]interface{}{"type": "string"},
+					"lines":   map[string]interface{}{"type": "integer"},
+				},
+			},
+			Command: tt.command,
+		}
+		_, err := newCustomTool(spec)
+		if tt.ok && err != nil {
+			t.Errorf("%q 应能加载，实际报错: %v", tt.command, err)
+		}
+		if !tt.ok && (err == nil || !strings.Contains(err.Error(), "命令模板无效")) {
+			t.Errorf("%q 应在加载时被拒绝，实际: %v", tt.command, err)
+		}
+	}
+}
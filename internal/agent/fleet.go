@@ -0,0 +1,298 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/buildinfo"
+	"ruoyi-proxy/internal/config"
+)
+
+// ——— Hub 集群工具：经 Hub 控制通道在 Spoke 上执行 ———
+
+const (
+	fleetReadTimeout    = 90 * time.Second
+	fleetWriteTimeout   = 15 * time.Minute
+	fleetOutputPerSpoke = 4000 // 每个 spoke 回传给 AI 的输出上限
+)
+
+// fleetReadOnlyOps 无需确认的远程操作（与 hub.ControlOps 保持一致）
+var fleetReadOnlyOps = map[string]bool{
+	"status":       true,
+	"logs":         true,
+	"proxy-status": true,
+}
+
+func spokesParam() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": "目标 spoke：逗号分隔的 spoke ID、all 表示全部在册节点，或标签选择器（如 env=prod,project=erp；env!=test；region=sh|bj）",
+	}
+}
+
+func serviceParam() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": "Spoke 上的服务 ID，留空为 default",
+	}
+}
+
+// FleetTools Hub 模式下额外提供的跨节点工具
+var FleetTools = []ToolDef{
+	{
+		Name:        "spoke_list",
+		Description: "列出 Hub 已注册的 Spoke 节点及档案（项目类型、说明、标签、服务与当前环境），可按标签选择器过滤",
+		ReadOnly:    true,
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"selector": map[string]interface{}{
+					"type":        "string",
+					"description": "标签选择器，如 env=prod,project=erp 或 state=online；留空列出全部",
+				},
+			},
+		},
+	},
+	{
+		Name:        "spoke_status",
+		Description: "在一个或多个 Spoke 上查询服务运行状态（经 Hub 控制通道执行 status），结果按节点汇总",
+		ReadOnly:    true,
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"spokes":  spokesParam(),
+				"service": serviceParam(),
+			},
+			"required": []string{"spokes"},
+		},
+	},
+	{
+		Name:        "spoke_logs",
+		Description: "在一个或多个 Spoke 上读取应用日志，可按关键字过滤（如 ERROR）",
+		ReadOnly:    true,
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"spokes":  spokesParam(),
+				"service": serviceParam(),
+				"keyword": map[string]interface{}{
+					"type":        "string",
+					"description": "过滤关键字，如 ERROR、Exception",
+				},
+				"lines": map[string]interface{}{
+					"type":        "integer",
+					"description": "返回行数，默认 200",
+					"default":     200,
+				},
+			},
+			"required": []string{"spokes"},
+		},
+	},
+	{
+		Name:        "spoke_run",
+		Description: "在一个或多个 Spoke 上执行运维操作：start/stop/restart/deploy/deploy-lowmem/switch/pull-artifact（写操作需要用户确认，Spoke 也可能按本机策略要求本地确认或拒绝）",
+		ReadOnly:    false,
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"spokes":  spokesParam(),
+				"service": serviceParam(),
+				"op": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"status", "logs", "proxy-status", "start", "stop", "restart", "deploy", "deploy-lowmem", "switch", "pull-artifact"},
+					"description": "要执行的操作",
+				},
+				"env": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"blue", "green"},
+					"description": "op=switch 时的目标环境",
+				},
+				"artifact": map[string]interface{}{
+					"type":        "string",
+					"description": "op=pull-artifact 时的 Hub 制品名（先拉取制品，再 deploy 生效）",
+				},
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "op=pull-artifact 时的制品版本，留空为最新版本",
+				},
+			},
+			"required": []string{"spokes", "op"},
+		},
+	},
+}
+
+// fleetEnabled 本机是否为 Hub（可使用跨节点工具）
+func fleetEnabled() bool {
+	if buildinfo.IsHub() {
+		return true
+	}
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return false
+	}
+	var root struct {
+		Hub struct {
+			Enabled bool `json:"enabled"`
+		} `json:"hub"`
+	}
+	return json.Unmarshal(data, &root) == nil && root.Hub.Enabled
+}
+
+// isReadOnlyFleetRun 判断 spoke_run 的操作是否只读
+func isReadOnlyFleetRun(argsJSON string) bool {
+	var args struct {
+		Op string `json:"op"`
+	}
+	if json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return false
+	}
+	return fleetReadOnlyOps[args.Op]
+}
+
+func mgmtURL(path string) string {
+	port := config.MgmtPort
+	if strings.HasPrefix(port, ":") {
+		return "http://127.0.0.1" + port + path
+	}
+	return "http://" + port + path
+}
+
+func (e *ToolExecutor) spokeList(selector string) (string, error) {
+	resp, err := http.Get(mgmtURL("/hub/status?selector=" + url.QueryEscape(strings.TrimSpace(selector))))
+	if err != nil {
+		return "", fmt.Errorf("无法连接 Hub 管理端口（代理是否在运行？）: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, data, "", "  ") != nil {
+		return string(data), nil
+	}
+	return out.String(), nil
+}
+
+type fleetCommand struct {
+	ID     string `json:"id"`
+	Spoke  string `json:"spoke"`
+	Op     string `json:"op"`
+	Status string `json:"status"`
+	Output string `json:"output"`
+	Error  string `json:"error"`
+}
+
+func (c fleetCommand) finished() bool {
+	return c.Status == "done" || c.Status == "failed" || c.Status == "rejected"
+}
+
+// fleetRun 下发命令并等待各 spoke 执行完毕，汇总输出
+func (e *ToolExecutor) fleetRun(spokes, op, service string, args map[string]string) (string, error) {
+	spokes = strings.TrimSpace(spokes)
+	if spokes == "" {
+		return "", fmt.Errorf("缺少 spokes 参数")
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"spokes":  []string{spokes},
+		"op":      op,
+		"service": service,
+		"args":    args,
+	})
+	resp, err := http.Post(mgmtURL("/hub/dispatch"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("无法连接 Hub 管理端口（代理是否在运行？）: %v", err)
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("下发失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var dispatched struct {
+		Commands []fleetCommand `json:"commands"`
+		Errors   []string       `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &dispatched); err != nil {
+		return "", fmt.Errorf("解析下发结果失败: %v", err)
+	}
+
+	timeout := fleetReadTimeout
+	if !fleetReadOnlyOps[op] {
+		timeout = fleetWriteTimeout
+	}
+	results := make(map[string]fleetCommand, len(dispatched.Commands))
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := 0
+		for _, cmd := range dispatched.Commands {
+			if r, ok := results[cmd.ID]; ok && r.finished() {
+				continue
+			}
+			if latest, err := fetchFleetCommand(cmd.ID); err == nil {
+				results[cmd.ID] = latest
+				if latest.finished() {
+					continue
+				}
+			}
+			pending++
+		}
+		if pending == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	var sb strings.Builder
+	ordered := make([]fleetCommand, 0, len(dispatched.Commands))
+	for _, cmd := range dispatched.Commands {
+		if r, ok := results[cmd.ID]; ok {
+			cmd = r
+		}
+		ordered = append(ordered, cmd)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Spoke < ordered[j].Spoke })
+	for _, cmd := range ordered {
+		status := cmd.Status
+		if !cmd.finished() {
+			status += "（等待超时，命令 " + cmd.ID + " 仍在进行）"
+		}
+		fmt.Fprintf(&sb, "### %s [%s]\n", cmd.Spoke, status)
+		if cmd.Error != "" {
+			fmt.Fprintf(&sb, "错误: %s\n", cmd.Error)
+		}
+		if out := strings.TrimSpace(cmd.Output); out != "" {
+			sb.WriteString(truncateOutput(out, fleetOutputPerSpoke))
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	for _, e := range dispatched.Errors {
+		fmt.Fprintf(&sb, "下发失败: %s\n", e)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func fetchFleetCommand(id string) (fleetCommand, error) {
+	var cmd fleetCommand
+	resp, err := http.Get(mgmtURL("/hub/command?id=" + url.QueryEscape(id)))
+	if err != nil {
+		return cmd, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return cmd, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&cmd)
+	return cmd, err
+}
@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 非交互模式的写操作策略（--write 参数）；其他取值视为逗号分隔的允许工具列表
+const (
+	HeadlessWriteDeny = "deny" // 拒绝全部写操作（默认）
+	HeadlessWriteAll  = "all"  // 允许全部写操作
+	HeadlessWriteAsk  = "ask"  // 逐次通过 Confirm 回调确认（仅 MCP 服务模式，客户端支持 elicitation 时）
+)
+
+// HeadlessOptions 非交互执行参数
+type HeadlessOptions struct {
+	Prompt  string
+	Service string        // 当前服务 ID，默认 default
+	Write   string        // deny、all 或逗号分隔的工具名
+	Timeout time.Duration // 整个任务的超时，0 表示不限
+	Log     io.Writer     // 进度输出（工具调用名称等），nil 表示不输出
+	// Confirm Write=ask 时确认单次写操作；为 nil 时 ask 等同 deny
+	Confirm func(tool, args string) bool
+}
+
+// HeadlessToolCall 非交互模式下的一次工具调用记录（--json 输出）
+type HeadlessToolCall struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	Result     string          `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Denied     bool            `json:"denied,omitempty"` // 写操作被 --write 策略拒绝
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// HeadlessResult 非交互执行结果
+type HeadlessResult struct {
+	Answer    string             `json:"answer"`
+	ToolCalls []HeadlessToolCall `json:"tool_calls"`
+	Error     string             `json:"error,omitempty"`
+	Provider  string             `json:"provider"`
+	Model     string             `json:"model"`
+	Duration  string             `json:"duration"`
+}
+
+type headlessRun struct {
+	opts    HeadlessOptions
+	allowed map[string]bool
+	denied  map[string]bool // 按工具调用 ID 标记被拒绝的写操作
+	calls   []HeadlessToolCall
+	answer  string
+}
+
+func (h *headlessRun) allowWrite(tool, args string) bool {
+	switch h.opts.Write {
+	case HeadlessWriteAll:
+		return true
+	case HeadlessWriteDeny:
+		return false
+	case HeadlessWriteAsk:
+		return h.opts.Confirm != nil && h.opts.Confirm(tool, args)
+	}
+	return h.allowed[tool]
+}
+
+func (h *headlessRun) record(tc ToolCall, result string, err error, elapsed time.Duration) {
+	call := HeadlessToolCall{
+		Name:       tc.Name,
+		Result:     result,
+		Denied:     h.denied[tc.ID],
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if json.Valid([]byte(tc.Arguments)) {
+		call.Arguments = json.RawMessage(tc.Arguments)
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	h.calls = append(h.calls, call)
+}
+
+// parseHeadlessWrite 校验 --write 参数，返回允许的工具集合（deny/all 时为 nil）
+func parseHeadlessWrite(write string) (map[string]bool, error) {
+	if write == HeadlessWriteDeny || write == HeadlessWriteAll || write == HeadlessWriteAsk {
+		return nil, nil
+	}
+	known := make(map[string]bool)
+	for _, t := range append(defaultRegistry.Definitions(true), mcpToolDefs()...) {
+		known[t.Name] = true
+	}
+	allowed := make(map[string]bool)
+	for _, name := range strings.Split(write, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("--write 中的未知工具: %s（可用 deny、all 或逗号分隔的工具名）", name)
+		}
+		allowed[name] = true
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("--write 不能为空（可用 deny、all 或逗号分隔的工具名）")
+	}
+	return allowed, nil
+}
+
+// RunHeadless 非交互执行一次 ReAct 任务（脚本、cron 使用）：不读终端输入，不保存会话，
+// 写操作按 opts.Write 策略放行或拒绝，Hub 下发的策略同样生效
+func RunHeadless(ctx context.Context, opts HeadlessOptions) (HeadlessResult, error) {
+	start := time.Now()
+	if opts.Write == "" {
+		opts.Write = HeadlessWriteDeny
+	}
+	if opts.Service == "" {
+		opts.Service = "default"
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	result := HeadlessResult{ToolCalls: []HeadlessToolCall{}}
+	finish := func(err error) (HeadlessResult, error) {
+		result.Duration = time.Since(start).Round(time.Millisecond).String()
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result, err
+	}
+
+	if strings.TrimSpace(opts.Prompt) == "" {
+		return finish(fmt.Errorf("任务内容为空"))
+	}
+	if opts.Write == HeadlessWriteAsk {
+		return finish(fmt.Errorf("--write=ask 仅用于 mcp 模式，非交互任务请使用 deny、all 或工具列表"))
+	}
+	aiCfg, err := LoadAIConfig()
+	if err != nil {
+		return finish(fmt.Errorf("读取 AI 配置失败: %v", err))
+	}
+	if !aiCfg.IsConfigured() {
+		return finish(fmt.Errorf("AI 未配置，请先在 cli 中运行 /agent-config"))
+	}
+	result.Provider, result.Model = aiCfg.Provider, aiCfg.Model
+
+	a, err := newHeadlessAgent(aiCfg, opts)
+	if err != nil {
+		return finish(err)
+	}
+	// 自定义工具与 MCP 工具需先加载才能出现在工具列表中（--write 也可指定这些工具名）
+	a.loadCustomTools()
+	a.loadPermissions()
+	a.checkSandbox()
+	a.startMCP()
+	defer StopMCPServers()
+	if a.headless.allowed, err = parseHeadlessWrite(opts.Write); err != nil {
+		return finish(err)
+	}
+	run := a.headless
+	a.systemPrompt = a.buildSystemPrompt(a.policy) + headlessPromptSuffix(opts.Write)
+	a.ctx.Add(Message{Role: "system", Content: a.systemPrompt})
+	a.ctx.Add(Message{Role: "user", Content: opts.Prompt})
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	err = a.runReAct(ctx)
+	result.Answer = run.answer
+	result.ToolCalls = append(result.ToolCalls, run.calls...)
+	if errors.Is(err, errAgentInterrupted) {
+		err = fmt.Errorf("任务被中断或超时")
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("任务超时（%s）", opts.Timeout)
+		}
+	}
+	return finish(err)
+}
+
+// newHeadlessAgent 创建无终端交互的 Agent：写操作按 opts.Write 策略处理，Hub 策略照常生效
+func newHeadlessAgent(aiCfg AIConfig, opts HeadlessOptions) (*Agent, error) {
+	logf := func(s string) { fmt.Fprintln(opts.Log, s) }
+	noInput := func(string) (string, error) { return "", io.EOF }
+	a, err := New(aiCfg, BuildExecContext(opts.Service), noInput, logf)
+	if err != nil {
+		return nil, err
+	}
+	a.headless = &headlessRun{opts: opts, denied: make(map[string]bool)}
+	a.policy = a.activePolicy()
+	return a, nil
+}
+
+// headlessPromptSuffix 告知模型当前为无人值守执行，避免其等待用户确认或追问
+func headlessPromptSuffix(write string) string {
+	var policy string
+	switch write {
+	case HeadlessWriteAll:
+		policy = "写操作无需确认，会直接执行，请谨慎操作。"
+	case HeadlessWriteDeny:
+		policy = "不允许任何写操作，只能使用只读工具。"
+	default:
+		policy = "仅允许以下写操作工具直接执行：" + write + "；其他写操作会被拒绝。"
+	}
+	return "\n\n## 非交互模式\n\n本次任务由脚本或定时任务触发，没有用户在线，无法回答追问或确认操作。" + policy +
+		"请独立完成任务，最后一条回复给出完整结论（会被原样输出给调用方）。"
+}
+
+// out 交互模式输出到终端，非交互模式输出到进度日志
+func (a *Agent) out() io.Writer {
+	if a.headless != nil {
+		return a.headless.opts.Log
+	}
+	return os.Stdout
+}
@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HubConnection 读取 Spoke 的 Hub 连接配置（provider=hub 且已注册）
+func HubConnection() (AIConfig, error) {
+	aiCfg, err := LoadAIConfig()
+	if err != nil || aiCfg.Provider != "hub" || !aiCfg.IsConfigured() {
+		return aiCfg, fmt.Errorf("未配置 Hub 连接")
+	}
+	return aiCfg, nil
+}
+
+// HubEndpoints 返回候选 Hub 地址：base_url 在前，hub_urls 依次在后（去重）
+func HubEndpoints(cfg AIConfig) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, u := range append([]string{cfg.BaseURL}, cfg.HubURLs...) {
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		out = append(out, u)
+	}
+	return out
+}
+
+// hubRetryCooldown Hub 地址失败后的冷却时间：冷却期内排到最后尝试，期满后恢复配置顺序（主 Hub 恢复后自动切回）
+const hubRetryCooldown = time.Minute
+
+// HubStandbyHeader 备用 Hub 拒绝心跳与命令通道时附带的响应头，Spoke 据此转向下一个地址
+const HubStandbyHeader = "X-Hub-Standby"
+
+// hubFailed 最近失败的 Hub 地址及失败时间
+var hubFailed struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func markHubFailed(base string, failed bool) {
+	hubFailed.mu.Lock()
+	defer hubFailed.mu.Unlock()
+	if !failed {
+		delete(hubFailed.since, base)
+		return
+	}
+	if hubFailed.since == nil {
+		hubFailed.since = make(map[string]time.Time)
+	}
+	hubFailed.since[base] = time.Now()
+}
+
+// ActiveHubURL 返回当前优先使用的 Hub 地址
+func ActiveHubURL(cfg AIConfig) string {
+	endpoints := orderedHubEndpoints(cfg)
+	if len(endpoints) == 0 {
+		return ""
+	}
+	return endpoints[0]
+}
+
+// orderedHubEndpoints 按配置顺序排列，冷却期内失败过的地址移到最后（仍作为兜底尝试）
+func orderedHubEndpoints(cfg AIConfig) []string {
+	endpoints := HubEndpoints(cfg)
+	hubFailed.mu.Lock()
+	defer hubFailed.mu.Unlock()
+	var ready, cooling []string
+	for _, u := range endpoints {
+		if t, ok := hubFailed.since[u]; ok && time.Since(t) < hubRetryCooldown {
+			cooling = append(cooling, u)
+			continue
+		}
+		ready = append(ready, u)
+	}
+	return append(ready, cooling...)
+}
+
+// newHubRequest 构造以 Spoke 身份访问指定 Hub 的请求（附带凭证）
+func newHubRequest(ctx context.Context, cfg AIConfig, baseURL, method, path string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	AuthorizeHubRequest(req, cfg, body)
+	return req, nil
+}
+
+// hubUnavailable 网关层返回的 502/503/504（非 Hub 自身的 JSON 错误）或备用 Hub 的拒绝视为该 Hub 不可用
+func hubUnavailable(resp *http.Response) bool {
+	if resp.Header.Get(HubStandbyHeader) != "" {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return !strings.Contains(resp.Header.Get("Content-Type"), "application/json")
+	}
+	return false
+}
+
+// SendHubRequest 按配置顺序发送请求（冷却期内失败过的地址排在最后），连接失败或 Hub 不可用时切换到下一个地址；
+// 每次尝试都重新签名。调用方负责关闭响应体。
+func SendHubRequest(ctx context.Context, cfg AIConfig, method, path string, body []byte, timeout time.Duration) (*http.Response, error) {
+	endpoints := orderedHubEndpoints(cfg)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("未配置 Hub 地址")
+	}
+	client := &http.Client{Timeout: timeout}
+	var lastErr error
+	for _, base := range endpoints {
+		if ctx.Err() != nil {
+			break
+		}
+		req, err := newHubRequest(ctx, cfg, base, method, path, body)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() == nil {
+				markHubFailed(base, true)
+			}
+			lastErr = fmt.Errorf("Hub 请求失败（%s）: %v", base, err)
+			continue
+		}
+		if hubUnavailable(resp) && len(endpoints) > 1 {
+			resp.Body.Close()
+			// 备用 Hub 的拒绝只针对该路径，不影响其他请求（如对话）使用它
+			if resp.Header.Get(HubStandbyHeader) == "" {
+				markHubFailed(base, true)
+			}
+			lastErr = fmt.Errorf("Hub 不可用（%s）: HTTP %d", base, resp.StatusCode)
+			continue
+		}
+		markHubFailed(base, false)
+		return resp, nil
+	}
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return nil, lastErr
+}
+
+// DoHubRequest 发送 Hub 请求并读取响应体（最多 8MB），HTTP 4xx/5xx 返回错误
+func DoHubRequest(ctx context.Context, cfg AIConfig, method, path string, body []byte, timeout time.Duration) (int, []byte, error) {
+	resp, err := SendHubRequest(ctx, cfg, method, path, body, timeout)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, data, fmt.Errorf("Hub HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp.StatusCode, data, nil
+}
+
+// HubModels Hub 为当前 spoke 开放的模型别名
+type HubModels struct {
+	Models  []string `json:"models"`
+	Default string   `json:"default,omitempty"`
+}
+
+// FetchHubModels 查询当前 spoke 可用的模型别名
+func FetchHubModels(ctx context.Context, cfg AIConfig) (HubModels, error) {
+	var out HubModels
+	_, data, err := DoHubRequest(ctx, cfg, http.MethodGet, "/__hub__/v1/models", nil, 15*time.Second)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("解析模型列表失败: %v", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const hubPolicyFile = "configs/hub_policy.json"
+
+// HubPolicy Hub 下发给 spoke 的策略文档（Hub 按选择器合并后的结果，版本为内容摘要）
+type HubPolicy struct {
+	Version             string    `json:"version"`
+	PromptAppend        string    `json:"prompt_append,omitempty"`         // 追加到系统提示词末尾
+	DisabledTools       []string  `json:"disabled_tools,omitempty"`        // 禁用的工具名
+	ForbiddenShell      []string  `json:"forbidden_shell,omitempty"`       // run_shell 禁止匹配的正则
+	DisableTurnApproval bool      `json:"disable_turn_approval,omitempty"` // 禁止「本会话/始终允许」，每个需确认的操作单独确认
+	FetchedAt           time.Time `json:"fetched_at,omitzero"`             // spoke 本地拉取时间
+}
+
+// ToolDisabled 工具是否被策略禁用
+func (p HubPolicy) ToolDisabled(name string) bool {
+	for _, t := range p.DisabledTools {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+var hubPolicy struct {
+	mu       sync.RWMutex
+	loaded   bool
+	policy   HubPolicy
+	patterns []*regexp.Regexp
+}
+
+// CurrentHubPolicy 返回当前生效的 Hub 策略；首次调用读取本地缓存，未连接 Hub 时为空策略
+func CurrentHubPolicy() HubPolicy {
+	hubPolicy.mu.RLock()
+	if hubPolicy.loaded {
+		p := hubPolicy.policy
+		hubPolicy.mu.RUnlock()
+		return p
+	}
+	hubPolicy.mu.RUnlock()
+
+	var p HubPolicy
+	if data, err := os.ReadFile(hubPolicyFile); err == nil {
+		_ = json.Unmarshal(data, &p)
+	}
+	setHubPolicy(p)
+	return p
+}
+
+func setHubPolicy(p HubPolicy) {
+	patterns := make([]*regexp.Regexp, 0, len(p.ForbiddenShell))
+	for _, expr := range p.ForbiddenShell {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			// 无法编译的规则按字面量匹配，宁可多拦截
+			re = regexp.MustCompile(regexp.QuoteMeta(expr))
+		}
+		patterns = append(patterns, re)
+	}
+	hubPolicy.mu.Lock()
+	hubPolicy.loaded = true
+	hubPolicy.policy = p
+	hubPolicy.patterns = patterns
+	hubPolicy.mu.Unlock()
+}
+
+// forbiddenShellRule 返回命令命中的禁止规则
+func forbiddenShellRule(command string) (string, bool) {
+	CurrentHubPolicy()
+	hubPolicy.mu.RLock()
+	defer hubPolicy.mu.RUnlock()
+	for i, re := range hubPolicy.patterns {
+		if re.MatchString(command) {
+			return hubPolicy.policy.ForbiddenShell[i], true
+		}
+	}
+	return "", false
+}
+
+// SyncHubPolicy 从 Hub 拉取策略并写入本地缓存（离线启动时沿用），返回策略及是否有变化
+func SyncHubPolicy(ctx context.Context, cfg AIConfig) (HubPolicy, bool, error) {
+	_, data, err := DoHubRequest(ctx, cfg, http.MethodGet, "/__hub__/v1/policy", nil, 15*time.Second)
+	if err != nil {
+		return CurrentHubPolicy(), false, err
+	}
+	var p HubPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return CurrentHubPolicy(), false, fmt.Errorf("解析 Hub 策略失败: %v", err)
+	}
+	changed := p.Version != CurrentHubPolicy().Version
+	p.FetchedAt = time.Now()
+	setHubPolicy(p)
+	if out, err := json.MarshalIndent(p, "", "  "); err == nil {
+		_ = os.MkdirAll(filepath.Dir(hubPolicyFile), 0755)
+		if err := os.WriteFile(hubPolicyFile, out, 0644); err != nil {
+			return p, changed, fmt.Errorf("保存 Hub 策略失败: %v", err)
+		}
+	}
+	return p, changed, nil
+}
@@ -1,19 +1,19 @@
 package agent
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
+// HubRelayModel provider=hub 未指定模型别名时的占位值，由 Hub 决定实际模型
+const HubRelayModel = "hub-relay"
+
 type hubProvider struct {
-	hubURL  string
-	token   string
+	cfg     AIConfig // Hub 地址、凭证与认证方式
 	model   string
 	timeout int
 }
@@ -23,38 +23,35 @@ func (h *hubProvider) Model() string {
 	if h.model != "" {
 		return h.model
 	}
-	return "hub-relay"
+	return HubRelayModel
 }
 
 type hubChatRequest struct {
 	Messages []Message `json:"messages"`
 	Tools    []ToolDef `json:"tools,omitempty"`
+	Model    string    `json:"model,omitempty"` // 模型别名，留空由 Hub 按策略选择
 }
 
 type hubChatResponse struct {
 	Content          string     `json:"content"`
 	ReasoningContent string     `json:"reasoning_content,omitempty"`
 	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+	Usage            *Usage     `json:"usage,omitempty"` // 上游提供商上报的用量
 	Error            string     `json:"error,omitempty"`
 }
 
 func (h *hubProvider) Chat(ctx context.Context, messages []Message, tools []ToolDef) (*ChatResponse, error) {
-	body, err := json.Marshal(hubChatRequest{Messages: messages, Tools: tools})
-	if err != nil {
-		return nil, err
+	req := hubChatRequest{Messages: messages, Tools: tools}
+	if h.model != HubRelayModel {
+		req.Model = h.model
 	}
-	url := strings.TrimRight(h.hubURL, "/") + "/__hub__/v1/chat"
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+h.token)
-
-	client := &http.Client{Timeout: time.Duration(h.timeout) * time.Second}
-	resp, err := client.Do(req)
+	resp, err := SendHubRequest(ctx, h.cfg, http.MethodPost, "/__hub__/v1/chat", body, time.Duration(h.timeout)*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("Hub 请求失败: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
@@ -75,6 +72,7 @@ func (h *hubProvider) Chat(ctx context.Context, messages []Message, tools []Tool
 		Content:          hubResp.Content,
 		ReasoningContent: hubResp.ReasoningContent,
 		ToolCalls:        hubResp.ToolCalls,
+		Usage:            hubResp.Usage,
 	}, nil
 }
 
@@ -93,7 +91,7 @@ func (h *hubProvider) Stream(ctx context.Context, messages []Message, tools []To
 		if len(resp.ToolCalls) > 0 {
 			ch <- StreamEvent{Type: "tool_calls", ToolCalls: resp.ToolCalls}
 		}
-		ch <- StreamEvent{Type: "done", ReasoningContent: resp.ReasoningContent}
+		ch <- StreamEvent{Type: "done", ReasoningContent: resp.ReasoningContent, Usage: resp.Usage}
 	}()
 	return ch, nil
 }
@@ -15,81 +15,52 @@ type hubRegisterRequest struct {
 }
 
 type hubRegisterResponse struct {
-	SpokeID string `json:"spoke_id"`
-	Token   string `json:"token"`
+	SpokeID string   `json:"spoke_id"`
+	Token   string   `json:"token"`
+	Auth    []string `json:"auth,omitempty"` // Hub 支持的认证方式
 }
 
-type hubTokenResponse struct {
-	Token string `json:"token"`
-}
-
-// RequestHubRegisterToken 向 Hub 申请一次性注册 Token
-func RequestHubRegisterToken(hubURL string) (string, error) {
-	hubURL = strings.TrimSpace(hubURL)
-	if hubURL == "" {
-		return "", fmt.Errorf("Hub 地址不能为空")
-	}
-	url := strings.TrimRight(hubURL, "/") + "/__hub__/v1/token"
-	req, err := http.NewRequest(http.MethodPost, url, nil)
-	if err != nil {
-		return "", err
-	}
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("申请注册 Token 失败: %v", err)
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
-	if err != nil {
-		return "", err
-	}
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("申请注册 Token 失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
-	}
-	var out hubTokenResponse
-	if err := json.Unmarshal(data, &out); err != nil {
-		return "", fmt.Errorf("解析注册 Token 响应失败: %v, body=%s", err, strings.TrimSpace(string(data)))
-	}
-	if strings.TrimSpace(out.Token) == "" {
-		return "", fmt.Errorf("Hub 未返回注册 Token，body=%s", strings.TrimSpace(string(data)))
-	}
-	return strings.TrimSpace(out.Token), nil
+// HubRegistration 注册结果
+type HubRegistration struct {
+	SpokeID string
+	Token   string
+	Auth    string // 建议使用的认证方式：Hub 支持签名时为 hmac，否则为 bearer
 }
 
 // RegisterWithHub 使用一次性 Token 向 Hub 注册并获取长期凭证
-func RegisterWithHub(hubURL, oneTimeToken string) (token, spokeID string, err error) {
+func RegisterWithHub(hubURL, oneTimeToken string) (HubRegistration, error) {
+	var reg HubRegistration
 	hubURL = strings.TrimSpace(hubURL)
 	oneTimeToken = strings.TrimSpace(oneTimeToken)
 	if hubURL == "" || oneTimeToken == "" {
-		return "", "", fmt.Errorf("Hub 地址和注册 Token 不能为空")
+		return reg, fmt.Errorf("Hub 地址和注册 Token 不能为空")
 	}
 	body, err := json.Marshal(hubRegisterRequest{Token: oneTimeToken})
 	if err != nil {
-		return "", "", err
+		return reg, err
 	}
 	url := strings.TrimRight(hubURL, "/") + "/__hub__/v1/register"
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return "", "", err
+		return reg, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", "", fmt.Errorf("注册请求失败: %v", err)
+		return reg, fmt.Errorf("注册请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return "", "", err
+		return reg, err
 	}
 	if resp.StatusCode >= 400 {
-		return "", "", fmt.Errorf("注册失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		return reg, fmt.Errorf("注册失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
 	}
 	var out hubRegisterResponse
 	if err := json.Unmarshal(data, &out); err != nil {
-		return "", "", fmt.Errorf("解析注册响应失败（可能 Nginx 未转发到 Hub）: %v, body=%s", err, strings.TrimSpace(string(data)))
+		return reg, fmt.Errorf("解析注册响应失败（可能 Nginx 未转发到 Hub）: %v, body=%s", err, strings.TrimSpace(string(data)))
 	}
 	if out.Token == "" {
 		var proxyErr struct {
@@ -97,9 +68,15 @@ func RegisterWithHub(hubURL, oneTimeToken string) (token, spokeID string, err er
 			Code int    `json:"code"`
 		}
 		if err := json.Unmarshal(data, &proxyErr); err == nil && proxyErr.Msg != "" && proxyErr.Code != 0 {
-			return "", "", fmt.Errorf("Hub 未返回有效凭证，疑似请求被 Java 服务拦截（请检查 Nginx HTTPS server 的 /__hub__/ 路由），body=%s", strings.TrimSpace(string(data)))
+			return reg, fmt.Errorf("Hub 未返回有效凭证，疑似请求被 Java 服务拦截（请检查 Nginx HTTPS server 的 /__hub__/ 路由），body=%s", strings.TrimSpace(string(data)))
+		}
+		return reg, fmt.Errorf("Hub 未返回有效凭证，body=%s", strings.TrimSpace(string(data)))
+	}
+	reg = HubRegistration{SpokeID: out.SpokeID, Token: out.Token, Auth: HubAuthBearer}
+	for _, a := range out.Auth {
+		if a == HubAuthHMAC {
+			reg.Auth = HubAuthHMAC
 		}
-		return "", "", fmt.Errorf("Hub 未返回有效凭证，body=%s", strings.TrimSpace(string(data)))
 	}
-	return out.Token, out.SpokeID, nil
+	return reg, nil
 }
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spoke → Hub 请求签名（ai.hub_auth=hmac）：不再在请求中发送长期凭证，
+// 以凭证派生的签名密钥对 方法/路径/时间戳/随机数/请求体摘要 做 HMAC，Hub 校验时间窗并拒绝重放的随机数。
+const (
+	HubAuthBearer = "bearer"
+	HubAuthHMAC   = "hmac"
+
+	HubSpokeHeader     = "X-Hub-Spoke"
+	HubTimestampHeader = "X-Hub-Timestamp"
+	HubNonceHeader     = "X-Hub-Nonce"
+	HubSignatureHeader = "X-Hub-Signature"
+)
+
+// hubSigningLabel 签名密钥的派生标签，使签名密钥与 Hub 保存的凭证 hash 互相独立
+const hubSigningLabel = "ruoyi-hub-sign"
+
+// HubSigningKey 由长期凭证派生签名密钥：HMAC-SHA256(凭证, 标签)，不可由凭证 hash 推出
+func HubSigningKey(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(hubSigningLabel))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HubSpokeID 由长期凭证的 SHA-256 推导 spoke ID（与 Hub 注册时的规则一致），请求头中不出现凭证本身的任何部分
+func HubSpokeID(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return "spoke-" + hex.EncodeToString(sum[:4])
+}
+
+// HubSignaturePath 签名覆盖的路径：取 /__hub__/ 起的部分，兼容 Hub 地址带前缀的反向代理
+func HubSignaturePath(path string) string {
+	if i := strings.Index(path, "/__hub__/"); i >= 0 {
+		return path[i:]
+	}
+	return path
+}
+
+// HubSignature 计算请求签名（hex）
+func HubSignature(key, method, path, timestamp, nonce string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	payload := strings.Join([]string{
+		strings.ToUpper(method),
+		HubSignaturePath(path),
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodySum[:]),
+	}, "\n")
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuthorizeHubRequest 按配置为 Hub 请求附加凭证：hmac 模式签名，否则使用 Bearer
+func AuthorizeHubRequest(req *http.Request, cfg AIConfig, body []byte) {
+	if cfg.HubAuth != HubAuthHMAC {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+		return
+	}
+	nonceBytes := make([]byte, 16)
+	_, _ = rand.Read(nonceBytes)
+	nonce := hex.EncodeToString(nonceBytes)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Del("Authorization")
+	req.Header.Set(HubSpokeHeader, HubSpokeID(cfg.APIKey))
+	req.Header.Set(HubTimestampHeader, ts)
+	req.Header.Set(HubNonceHeader, nonce)
+	req.Header.Set(HubSignatureHeader, HubSignature(HubSigningKey(cfg.APIKey), req.Method, req.URL.Path, ts, nonce, body))
+}
@@ -0,0 +1,576 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ruoyi-proxy/internal/config"
+)
+
+// ——— 操作日志：按会话记录 Agent 的每次写操作及其原状态，/undo 按逆序撤销 ———
+
+const (
+	journalFileName    = "journal.json"
+	journalMaxSnapshot = 50 << 20 // 单个文件快照上限，超过时不保存快照（该操作无法撤销）
+
+	undoFile          = "file"           // 文件内容与权限（或原本不存在）
+	undoActiveEnv     = "active_env"     // 服务的蓝绿环境
+	undoJVMPreset     = "jvm_preset"     // JVM 预设档位
+	undoServiceScript = "service_script" // 服务控制脚本与项目类型
+	undoSystemd       = "systemd"        // systemd 单元的运行与开机启动状态
+)
+
+// journalEntry 一次写操作；Steps 为空时表示无法自动撤销，原因见 Note
+type journalEntry struct {
+	Seq     int        `json:"seq"`
+	Time    string     `json:"time"`
+	Tool    string     `json:"tool"`
+	Summary string     `json:"summary"`
+	Steps   []undoStep `json:"steps,omitempty"`
+	Note    string     `json:"note,omitempty"`
+	Undone  bool       `json:"undone,omitempty"`
+}
+
+// undoStep 恢复某项原状态所需的信息
+type undoStep struct {
+	Kind        string `json:"kind"`
+	Path        string `json:"path,omitempty"`
+	Existed     bool   `json:"existed,omitempty"`
+	IsDir       bool   `json:"is_dir,omitempty"`
+	Mode        uint32 `json:"mode,omitempty"`
+	Snapshot    string `json:"snapshot,omitempty"` // 日志目录下的快照文件名
+	Service     string `json:"service,omitempty"`
+	ActiveEnv   string `json:"active_env,omitempty"`
+	Preset      *int   `json:"preset,omitempty"` // nil 表示原配置中没有 preset
+	ScriptPath  string `json:"script_path,omitempty"`
+	ProjectType string `json:"project_type,omitempty"`
+	Active      string `json:"active,omitempty"`  // systemctl is-active 的原输出
+	Enabled     string `json:"enabled,omitempty"` // systemctl is-enabled 的原输出
+}
+
+// actionJournal 单个会话的操作日志，保存在 <会话目录>/<会话ID>.journal/
+type actionJournal struct {
+	mu      sync.Mutex
+	dir     string
+	entries []journalEntry
+}
+
+// journal 返回当前会话的操作日志（切换会话后自动切换），无会话时返回 nil
+func (a *Agent) journal() *actionJournal {
+	id := a.currentID()
+	if id == "" || a.sessionStore == nil {
+		return nil
+	}
+	dir := filepath.Join(a.sessionStore.rootDir, id+".journal")
+	if a.actions != nil && a.actions.dir == dir {
+		return a.actions
+	}
+	j := &actionJournal{dir: dir}
+	if data, err := os.ReadFile(filepath.Join(dir, journalFileName)); err == nil {
+		if err := json.Unmarshal(data, &j.entries); err != nil {
+			a.print(fmt.Sprintf("\033[1;33m⚠ 操作日志损坏，已重新开始记录: %v\033[0m", err))
+			j.entries = nil
+		}
+	}
+	a.actions = j
+	return j
+}
+
+// isMutatingCall 判断调用是否会修改系统：非只读工具中，只读 shell 命令与只读跨节点命令除外
+func isMutatingCall(tc ToolCall, def *ToolDef) bool {
+	if def != nil && def.ReadOnly {
+		return false
+	}
+	switch tc.Name {
+	case "run_shell":
+		return shellConfirmReason(tc.Arguments) != nil
+	case "spoke_run":
+		return !isReadOnlyFleetRun(tc.Arguments)
+	}
+	return true
+}
+
+// begin 在执行写操作前记录原状态；返回的记录须在执行后 commit 或 discard
+func (j *actionJournal) begin(tc ToolCall, e *ToolExecutor) *journalEntry {
+	args := map[string]interface{}{}
+	if tc.Arguments != "" {
+		_ = json.Unmarshal([]byte(tc.Arguments), &args)
+	}
+	j.mu.Lock()
+	seq := 1
+	if n := len(j.entries); n > 0 {
+		seq = j.entries[n-1].Seq + 1
+	}
+	j.mu.Unlock()
+
+	entry := &journalEntry{
+		Seq:     seq,
+		Time:    time.Now().Format(time.RFC3339),
+		Tool:    tc.Name,
+		Summary: trimRunes(strings.ReplaceAll(formatArgs(tc.Arguments), "\n", " "), 120),
+	}
+	var err error
+	switch tc.Name {
+	case "write_file":
+		err = j.snapshotPaths(entry, []string{argString(args["path"])})
+	case "delete_file":
+		var paths []string
+		if v, ok := args["paths"].([]interface{}); ok {
+			for _, p := range v {
+				if s, ok := p.(string); ok && s != "" {
+					paths = append(paths, s)
+				}
+			}
+		}
+		if s, ok := args["path"].(string); ok && s != "" {
+			paths = append(paths, s)
+		}
+		err = j.snapshotPaths(entry, paths)
+	case "switch_env":
+		var svc *config.ServiceConfig
+		if svc, err = loadJournalService(e.execCtx.CurrentService); err == nil {
+			entry.Steps = append(entry.Steps, undoStep{Kind: undoActiveEnv, Service: e.execCtx.CurrentService, ActiveEnv: svc.ActiveEnv})
+		}
+	case "configure_service":
+		serviceID, _ := args["service_id"].(string)
+		if serviceID == "" {
+			serviceID = e.execCtx.CurrentService
+		}
+		var svc *config.ServiceConfig
+		if svc, err = loadJournalService(serviceID); err == nil {
+			entry.Steps = append(entry.Steps, undoStep{Kind: undoServiceScript, Service: serviceID,
+				ScriptPath: svc.ScriptPath, ProjectType: svc.ProjectType})
+		}
+	case "update_jvm":
+		var preset *int
+		if preset, err = readJVMPreset(); err == nil {
+			entry.Steps = append(entry.Steps, undoStep{Kind: undoJVMPreset, Preset: preset})
+		}
+	case "manage_systemd":
+		unit, _ := args["service"].(string)
+		if !commandExists("systemctl") {
+			err = fmt.Errorf("非 systemd 系统")
+		} else if unit != "" {
+			entry.Steps = append(entry.Steps, undoStep{Kind: undoSystemd, Service: unit,
+				Active: systemctlQuery("is-active", unit), Enabled: systemctlQuery("is-enabled", unit)})
+		}
+	default:
+		entry.Note = "该操作无法自动撤销"
+	}
+	if err != nil {
+		j.discard(entry)
+		entry.Steps = nil
+		entry.Note = fmt.Sprintf("未能记录原状态，无法撤销: %v", err)
+	}
+	return entry
+}
+
+// snapshotPaths 保存文件快照；目录只记录权限（delete_file 只删除空目录）
+func (j *actionJournal) snapshotPaths(entry *journalEntry, paths []string) error {
+	for i, p := range paths {
+		p = expandHome(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		step := undoStep{Kind: undoFile, Path: abs}
+		info, err := os.Stat(abs)
+		switch {
+		case os.IsNotExist(err):
+			// 原本不存在：撤销时删除
+		case err != nil:
+			return err
+		case info.IsDir():
+			step.Existed, step.IsDir, step.Mode = true, true, uint32(info.Mode().Perm())
+		case !info.Mode().IsRegular():
+			return fmt.Errorf("%s 不是普通文件", abs)
+		case info.Size() > journalMaxSnapshot:
+			return fmt.Errorf("%s 超过 %d MB，未保存快照", abs, journalMaxSnapshot>>20)
+		default:
+			step.Existed, step.Mode = true, uint32(info.Mode().Perm())
+			step.Snapshot = fmt.Sprintf("%04d-%d.snap", entry.Seq, i)
+			if err := j.copyToSnapshot(abs, step.Snapshot); err != nil {
+				return fmt.Errorf("保存 %s 快照失败: %v", abs, err)
+			}
+		}
+		entry.Steps = append(entry.Steps, step)
+	}
+	return nil
+}
+
+func (j *actionJournal) copyToSnapshot(src, name string) error {
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(filepath.Join(j.dir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// commit 操作执行完成后写入日志
+func (j *actionJournal) commit(entry *journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, *entry)
+	return j.save()
+}
+
+// discard 操作未执行（或执行失败）时删除已保存的快照
+func (j *actionJournal) discard(entry *journalEntry) {
+	for _, s := range entry.Steps {
+		if s.Snapshot != "" {
+			_ = os.Remove(filepath.Join(j.dir, s.Snapshot))
+		}
+	}
+}
+
+func (j *actionJournal) save() error {
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("创建操作日志目录失败: %v", err)
+	}
+	data, err := json.MarshalIndent(j.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化操作日志失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(j.dir, journalFileName), data, 0600); err != nil {
+		return fmt.Errorf("保存操作日志失败: %v", err)
+	}
+	return nil
+}
+
+// pending 返回最近 n 个尚未撤销的操作（新的在前）
+func (j *actionJournal) pending(n int) []journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var out []journalEntry
+	for i := len(j.entries) - 1; i >= 0 && len(out) < n; i-- {
+		if !j.entries[i].Undone {
+			out = append(out, j.entries[i])
+		}
+	}
+	return out
+}
+
+// revert 撤销一个操作并标记；步骤按记录的逆序执行
+func (j *actionJournal) revert(seq int, e *ToolExecutor) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	idx := -1
+	for i := range j.entries {
+		if j.entries[i].Seq == seq {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return fmt.Errorf("未找到操作 #%d", seq)
+	}
+	entry := &j.entries[idx]
+	if len(entry.Steps) == 0 {
+		return fmt.Errorf("%s", entry.Note)
+	}
+	var errs []string
+	for i := len(entry.Steps) - 1; i >= 0; i-- {
+		if err := j.revertStep(entry.Steps[i]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "；"))
+	}
+	entry.Undone = true
+	return j.save()
+}
+
+func (j *actionJournal) revertStep(s undoStep) error {
+	switch s.Kind {
+	case undoFile:
+		switch {
+		case !s.Existed:
+			if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("删除 %s 失败: %v", s.Path, err)
+			}
+		case s.IsDir:
+			if err := os.MkdirAll(s.Path, os.FileMode(s.Mode)); err != nil {
+				return fmt.Errorf("恢复目录 %s 失败: %v", s.Path, err)
+			}
+		default:
+			data, err := os.ReadFile(filepath.Join(j.dir, s.Snapshot))
+			if err != nil {
+				return fmt.Errorf("读取 %s 的快照失败: %v", s.Path, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+				return fmt.Errorf("创建目录失败: %v", err)
+			}
+			if err := os.WriteFile(s.Path, data, os.FileMode(s.Mode)); err != nil {
+				return fmt.Errorf("恢复 %s 失败: %v", s.Path, err)
+			}
+			_ = os.Chmod(s.Path, os.FileMode(s.Mode))
+		}
+	case undoActiveEnv, undoServiceScript:
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("读取配置失败: %v", err)
+		}
+		svc := cfg.GetService(s.Service)
+		if svc == nil {
+			return fmt.Errorf("未找到服务配置: %s", s.Service)
+		}
+		if s.Kind == undoActiveEnv {
+			svc.ActiveEnv = s.ActiveEnv
+		} else {
+			svc.ScriptPath, svc.ProjectType = s.ScriptPath, s.ProjectType
+		}
+		if err := config.SaveConfig(cfg); err != nil {
+			return fmt.Errorf("保存配置失败: %v", err)
+		}
+	case undoJVMPreset:
+		return writeJVMPreset(s.Preset)
+	case undoSystemd:
+		return restoreSystemdState(s)
+	default:
+		return fmt.Errorf("未知的撤销类型: %s", s.Kind)
+	}
+	return nil
+}
+
+// describe 撤销内容的简短说明
+func (s undoStep) describe() string {
+	switch s.Kind {
+	case undoFile:
+		switch {
+		case !s.Existed:
+			return "删除新建的 " + s.Path
+		case s.IsDir:
+			return "恢复目录 " + s.Path
+		}
+		return "恢复 " + s.Path
+	case undoActiveEnv:
+		return fmt.Sprintf("服务[%s]环境恢复为 %s", s.Service, orDash(s.ActiveEnv))
+	case undoServiceScript:
+		return fmt.Sprintf("服务[%s]控制脚本恢复为 %s", s.Service, orDash(s.ScriptPath))
+	case undoJVMPreset:
+		if s.Preset == nil {
+			return "移除 JVM 预设档位"
+		}
+		return fmt.Sprintf("JVM 预设恢复为档位 %d", *s.Preset)
+	case undoSystemd:
+		return fmt.Sprintf("%s 恢复为 %s/%s", s.Service, s.Active, s.Enabled)
+	}
+	return s.Kind
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "（空）"
+	}
+	return s
+}
+
+func loadJournalService(serviceID string) (*config.ServiceConfig, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("读取配置失败: %v", err)
+	}
+	svc := cfg.GetService(serviceID)
+	if svc == nil {
+		return nil, fmt.Errorf("未找到服务配置: %s", serviceID)
+	}
+	return svc, nil
+}
+
+// readJVMPreset 读取 app_config.json 中 jvm.preset 的当前值
+func readJVMPreset() (*int, error) {
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	var root struct {
+		JVM map[string]interface{} `json:"jvm"`
+	}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	v, ok := root.JVM["preset"].(float64)
+	if !ok {
+		return nil, nil
+	}
+	preset := int(v)
+	return &preset, nil
+}
+
+// writeJVMPreset 写回 jvm.preset（nil 表示删除该字段），其余配置保持不变
+func writeJVMPreset(preset *int) error {
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	jvm, ok := root["jvm"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("JVM 配置不存在")
+	}
+	if preset == nil {
+		delete(jvm, "preset")
+	} else {
+		jvm["preset"] = float64(*preset)
+	}
+	out, _ := json.MarshalIndent(root, "", "  ")
+	if err := os.WriteFile(appConfigFile, out, 0644); err != nil {
+		return fmt.Errorf("保存失败: %v", err)
+	}
+	return nil
+}
+
+func systemctlQuery(verb, unit string) string {
+	out, _ := exec.Command("systemctl", verb, unit).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// restoreSystemdState 按记录的原状态启动/停止、启用/禁用单元；restart、reload 不改变状态，无需操作
+func restoreSystemdState(s undoStep) error {
+	var actions []string
+	wasActive := s.Active == "active" || s.Active == "activating" || s.Active == "reloading"
+	if now := systemctlQuery("is-active", s.Service); (now == "active") != wasActive {
+		if wasActive {
+			actions = append(actions, "start")
+		} else {
+			actions = append(actions, "stop")
+		}
+	}
+	if now := systemctlQuery("is-enabled", s.Service); now != s.Enabled {
+		switch s.Enabled {
+		case "enabled":
+			actions = append(actions, "enable")
+		case "disabled":
+			actions = append(actions, "disable")
+		}
+	}
+	for _, action := range actions {
+		if out, err := runWithTimeout(exec.Command("systemctl", action, s.Service), 30*time.Second); err != nil {
+			return fmt.Errorf("systemctl %s %s 失败: %v %s", action, s.Service, err, out)
+		}
+	}
+	return nil
+}
+
+// undoActions /undo 命令：列出最近 n 个操作，确认后按从新到旧撤销
+func (a *Agent) undoActions(arg string) {
+	j := a.journal()
+	if j == nil {
+		a.print("\033[1;33m⚠ 当前没有会话\033[0m")
+		return
+	}
+	n := 1
+	if arg != "" {
+		if n = parsePositiveInt(arg); n <= 0 {
+			a.print("\033[1;33m⚠ 用法: /undo [N]，N 为要撤销的操作数\033[0m")
+			return
+		}
+	}
+	entries := j.pending(n)
+	if len(entries) == 0 {
+		a.print("\033[1;36mℹ 本会话没有可撤销的操作\033[0m")
+		return
+	}
+
+	a.print(fmt.Sprintf("\033[1;34m═══ 撤销最近 %d 个操作 ═══\033[0m", len(entries)))
+	for _, entry := range entries {
+		a.print(fmt.Sprintf("  #%d %s %s  \033[90m%s\033[0m", entry.Seq, entry.Time[11:19], entry.Tool, entry.Summary))
+		if len(entry.Steps) == 0 {
+			a.print(fmt.Sprintf("      \033[1;33m%s，将跳过\033[0m", entry.Note))
+		}
+		for _, s := range entry.Steps {
+			a.print("      → " + s.describe())
+		}
+	}
+	answer, err := a.readInput("\033[1;33m确认撤销? (y/n): \033[0m")
+	if answer = strings.ToLower(strings.TrimSpace(answer)); err != nil || (answer != "y" && answer != "yes") {
+		a.print("\033[1;36mℹ 已取消\033[0m")
+		return
+	}
+
+	var done, skipped []string
+	for _, entry := range entries {
+		label := fmt.Sprintf("#%d %s", entry.Seq, entry.Tool)
+		if len(entry.Steps) == 0 {
+			skipped = append(skipped, label)
+			continue
+		}
+		if err := j.revert(entry.Seq, a.executor); err != nil {
+			a.print(fmt.Sprintf("\033[1;31m✗ 撤销 %s 失败: %v\033[0m", label, err))
+			skipped = append(skipped, label)
+			continue
+		}
+		a.print(fmt.Sprintf("\033[1;32m✓ 已撤销 %s\033[0m", label))
+		done = append(done, label+" "+entry.Summary)
+	}
+	if len(done) > 0 {
+		// 下一轮对话时告知模型，避免其按撤销前的状态继续操作
+		a.undoNote = append(a.undoNote, done...)
+	}
+	if len(skipped) > 0 {
+		a.print(fmt.Sprintf("\033[1;33m⚠ 未撤销: %s，如需恢复请手动处理\033[0m", strings.Join(skipped, "、")))
+	}
+}
+
+// takeUndoNote 取出待告知模型的撤销说明，拼接到用户消息前
+func (a *Agent) takeUndoNote() string {
+	if len(a.undoNote) == 0 {
+		return ""
+	}
+	note := "（用户已通过 /undo 撤销以下操作，相关文件与配置已恢复到操作前的状态：\n- " +
+		strings.Join(a.undoNote, "\n- ") + "）\n\n"
+	a.undoNote = nil
+	return note
+}
+
+// printJournal /undo list：列出本会话的操作日志
+func (a *Agent) printJournal() {
+	j := a.journal()
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	a.print("\033[1;34m═══ 本会话操作日志 ═══\033[0m")
+	if len(j.entries) == 0 {
+		a.print("  暂无写操作记录")
+		return
+	}
+	for _, entry := range j.entries {
+		state := "\033[1;32m可撤销\033[0m"
+		switch {
+		case entry.Undone:
+			state = "\033[90m已撤销\033[0m"
+		case len(entry.Steps) == 0:
+			state = "\033[1;33m无法撤销\033[0m"
+		}
+		a.print(fmt.Sprintf("  #%-3d %s %-18s %s  \033[90m%s\033[0m", entry.Seq, entry.Time[11:19], entry.Tool, state, entry.Summary))
+	}
+	a.print(fmt.Sprintf("\033[90m日志目录: %s（/undo [N] 撤销最近 N 个操作）\033[0m", j.dir))
+}
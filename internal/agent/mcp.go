@@ -0,0 +1,743 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mcpProtocolVersion = "2024-11-05"
+	mcpToolPrefix      = "mcp__" // 合并到工具列表时的名称前缀：mcp__<服务器>__<工具>
+	mcpStartTimeout    = 30 * time.Second
+	mcpDefaultTimeout  = 60 * time.Second
+	mcpStderrKeep      = 4096 // 保留服务器 stderr 末尾字节，用于报错
+)
+
+// MCPServerConfig app_config.json 中 mcp.servers 的单个条目（stdio 传输）
+type MCPServerConfig struct {
+	Command        string            `json:"command"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"` // 追加到当前进程环境变量
+	Dir            string            `json:"dir,omitempty"` // 工作目录，默认当前目录
+	Disabled       bool              `json:"disabled,omitempty"`
+	ReadOnlyTools  []string          `json:"read_only_tools,omitempty"` // 无需确认即可执行的工具（原始工具名，* 表示全部）；其余工具按写操作处理
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"` // 单次工具调用超时，默认 60
+}
+
+// MCPConfig app_config.json 的 mcp 字段
+type MCPConfig struct {
+	Servers map[string]MCPServerConfig `json:"servers"`
+}
+
+// LoadMCPConfig 读取 MCP 服务器配置，文件不存在或无 mcp 字段时返回空配置
+func LoadMCPConfig() (MCPConfig, error) {
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return MCPConfig{}, nil
+	}
+	var root struct {
+		MCP MCPConfig `json:"mcp"`
+	}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return MCPConfig{}, fmt.Errorf("解析 MCP 配置失败: %v", err)
+	}
+	return root.MCP, nil
+}
+
+// MCPServerStatus MCP 服务器连接状态（/mcp 命令展示）
+type MCPServerStatus struct {
+	Name       string
+	Command    string
+	Connected  bool
+	Disabled   bool
+	ServerInfo string
+	Tools      []string // 合并后的工具名
+	Error      string
+}
+
+type mcpTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	exposed     string          // 合并后的工具名，listTools 时分配
+}
+
+type mcpRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // 数字或字符串，原样回传
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *mcpRPCError    `json:"error,omitempty"`
+}
+
+// mcpClient 单个 stdio MCP 服务器连接：每行一条 JSON-RPC 消息
+type mcpClient struct {
+	name       string
+	cfg        MCPServerConfig
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stderr     *tailBuffer
+	writeMu    sync.Mutex
+	mu         sync.Mutex
+	nextID     int64
+	pending    map[string]chan mcpMessage // mcpIDKey → 响应通道
+	done       chan struct{}              // 进程退出或读取失败时关闭
+	serverInfo string
+	tools      []mcpTool
+}
+
+func startMCPClient(name string, cfg MCPServerConfig) (*mcpClient, error) {
+	if strings.TrimSpace(cfg.Command) == "" {
+		return nil, fmt.Errorf("未配置 command")
+	}
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &mcpClient{
+		name:    name,
+		cfg:     cfg,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &tailBuffer{max: mcpStderrKeep},
+		pending: make(map[string]chan mcpMessage),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动失败: %v", err)
+	}
+	go c.readLoop(stdout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	defer cancel()
+	if err := c.initialize(ctx); err != nil {
+		c.close()
+		return nil, c.withStderr(err)
+	}
+	if err := c.listTools(ctx); err != nil {
+		c.close()
+		return nil, c.withStderr(err)
+	}
+	return c, nil
+}
+
+func (c *mcpClient) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "ruoyi-proxy", "version": "1.0"},
+	}
+	raw, err := c.request(ctx, "initialize", params)
+	if err != nil {
+		return fmt.Errorf("initialize 失败: %v", err)
+	}
+	var res struct {
+		ServerInfo struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if json.Unmarshal(raw, &res) == nil {
+		c.serverInfo = strings.TrimSpace(res.ServerInfo.Name + " " + res.ServerInfo.Version)
+	}
+	return c.notify("notifications/initialized", nil)
+}
+
+// listTools 拉取全部工具（支持分页游标）
+func (c *mcpClient) listTools(ctx context.Context) error {
+	var all []mcpTool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		raw, err := c.request(ctx, "tools/list", params)
+		if err != nil {
+			return fmt.Errorf("tools/list 失败: %v", err)
+		}
+		var res struct {
+			Tools      []mcpTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return fmt.Errorf("解析 tools/list 响应失败: %v", err)
+		}
+		all = append(all, res.Tools...)
+		if res.NextCursor == "" || res.NextCursor == cursor {
+			break
+		}
+		cursor = res.NextCursor
+	}
+	all = assignMCPToolNames(c.name, all)
+	c.mu.Lock()
+	c.tools = all
+	c.mu.Unlock()
+	return nil
+}
+
+// callTool 调用工具并将 content 转为文本；isError 时返回错误
+func (c *mcpClient) callTool(tool, argsJSON string) (string, error) {
+	args := json.RawMessage(argsJSON)
+	if strings.TrimSpace(argsJSON) == "" || !json.Valid(args) {
+		args = json.RawMessage("{}")
+	}
+	timeout := mcpDefaultTimeout
+	if c.cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(c.cfg.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	raw, err := c.request(ctx, "tools/call", map[string]interface{}{"name": tool, "arguments": args})
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Data     string `json:"data"`
+			Resource struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return "", fmt.Errorf("解析 tools/call 响应失败: %v", err)
+	}
+	var parts []string
+	for _, item := range res.Content {
+		switch item.Type {
+		case "text":
+			parts = append(parts, item.Text)
+		case "resource":
+			if item.Resource.Text != "" {
+				parts = append(parts, item.Resource.Text)
+			} else {
+				parts = append(parts, "[资源 "+item.Resource.URI+"]")
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s，%d 字节 base64]", item.Type, item.MimeType, len(item.Data)))
+		}
+	}
+	if len(parts) == 0 && len(res.StructuredContent) > 0 {
+		parts = append(parts, string(res.StructuredContent))
+	}
+	text := strings.Join(parts, "\n")
+	if res.IsError {
+		if text == "" {
+			text = "工具返回错误"
+		}
+		return "", fmt.Errorf("%s", text)
+	}
+	return text, nil
+}
+
+func (c *mcpClient) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(strconv.FormatInt(c.nextID, 10))
+	key, _ := mcpIDKey(id)
+	ch := make(chan mcpMessage, 1)
+	c.pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(mcpMessage{ID: id, Method: method, Params: mustJSON(params)}); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, fmt.Errorf("%s (code %d)", msg.Error.Message, msg.Error.Code)
+		}
+		return msg.Result, nil
+	case <-c.done:
+		return nil, fmt.Errorf("MCP 服务器 %s 已退出", c.name)
+	case <-ctx.Done():
+		// 通知服务器放弃该请求，避免其继续占用资源
+		_ = c.notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": "timeout"})
+		return nil, fmt.Errorf("%s 超时", method)
+	}
+}
+
+func (c *mcpClient) notify(method string, params interface{}) error {
+	msg := mcpMessage{Method: method}
+	if params != nil {
+		msg.Params = mustJSON(params)
+	}
+	return c.send(msg)
+}
+
+func (c *mcpClient) send(msg mcpMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入 MCP 服务器 %s 失败: %v", c.name, err)
+	}
+	return nil
+}
+
+// readLoop 读取服务器消息：响应按 id 分发；服务器发起的 ping 直接应答，其他请求回复不支持
+func (c *mcpClient) readLoop(stdout io.Reader) {
+	defer close(c.done)
+	r := bufio.NewReader(stdout)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			c.dispatch(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *mcpClient) dispatch(line []byte) {
+	var msg mcpMessage
+	if json.Unmarshal(line, &msg) != nil {
+		return // 服务器向 stdout 输出了非协议内容，忽略
+	}
+	key, hasID := mcpIDKey(msg.ID)
+	switch {
+	case msg.Method != "" && hasID:
+		reply := mcpMessage{ID: msg.ID}
+		if msg.Method == "ping" {
+			reply.Result = json.RawMessage("{}")
+		} else {
+			reply.Error = &mcpRPCError{Code: -32601, Message: "method not supported: " + msg.Method}
+		}
+		_ = c.send(reply)
+	case msg.Method == "notifications/tools/list_changed":
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+			defer cancel()
+			_ = c.listTools(ctx)
+		}()
+	case hasID:
+		c.mu.Lock()
+		ch := c.pending[key]
+		c.mu.Unlock()
+		if ch != nil {
+			// 通道容量为 1，重复的响应直接丢弃，不能阻塞读取循环
+			select {
+			case ch <- msg:
+			default:
+			}
+		}
+	}
+}
+
+// mcpIDKey 规范化 JSON-RPC id：数字与字符串分别编码，1 与 1.0 视为相同；null 或缺省返回 false
+func mcpIDKey(id json.RawMessage) (string, bool) {
+	if len(id) == 0 {
+		return "", false
+	}
+	var v interface{}
+	if json.Unmarshal(id, &v) != nil {
+		return "", false
+	}
+	switch x := v.(type) {
+	case string:
+		return "s:" + x, true
+	case float64:
+		return "n:" + strconv.FormatFloat(x, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+func (c *mcpClient) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close 关闭 stdin 让服务器自行退出，超时后强制结束
+func (c *mcpClient) close() {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		_ = c.cmd.Process.Kill()
+	}
+	_ = c.cmd.Wait()
+}
+
+func (c *mcpClient) withStderr(err error) error {
+	if tail := strings.TrimSpace(c.stderr.String()); tail != "" {
+		if i := strings.LastIndex(tail, "\n"); i >= 0 {
+			tail = tail[i+1:]
+		}
+		return fmt.Errorf("%v（stderr: %s）", err, tail)
+	}
+	return err
+}
+
+func (c *mcpClient) readOnly(tool string) bool {
+	for _, t := range c.cfg.ReadOnlyTools {
+		if t == "*" || t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// tailBuffer 只保留最后 max 字节的 io.Writer
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// ——— 进程内 MCP 服务器管理 ───────────────────────────────────
+
+var mcpState struct {
+	mu        sync.Mutex
+	restartMu sync.Mutex // 并行调用同一已退出服务器时只重启一次
+	started   bool
+	names     []string
+	configs   map[string]MCPServerConfig
+	clients   map[string]*mcpClient
+	errors    map[string]string
+}
+
+var mcpNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// mcpToolName 生成符合提供商要求（^[A-Za-z0-9_-]{1,64}$）的合并工具名
+func mcpToolName(server, tool string) string {
+	name := mcpToolPrefix + mcpNameUnsafe.ReplaceAllString(server, "_") + "__" + mcpNameUnsafe.ReplaceAllString(tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// assignMCPToolNames 为服务器的工具分配合并名：字符替换或截断后重名的工具改用带原名 hash 后缀的名称，
+// 仍然冲突（服务器返回了同名工具）的丢弃
+func assignMCPToolNames(server string, tools []mcpTool) []mcpTool {
+	used := make(map[string]bool, len(tools))
+	out := tools[:0]
+	for _, t := range tools {
+		name := mcpToolName(server, t.Name)
+		if used[name] {
+			sum := sha256.Sum256([]byte(server + "\x00" + t.Name))
+			suffix := "_" + hex.EncodeToString(sum[:4])
+			if len(name) > 64-len(suffix) {
+				name = name[:64-len(suffix)]
+			}
+			name += suffix
+		}
+		if used[name] {
+			continue
+		}
+		used[name] = true
+		t.exposed = name
+		out = append(out, t)
+	}
+	return out
+}
+
+func isMCPTool(name string) bool {
+	return strings.HasPrefix(name, mcpToolPrefix)
+}
+
+// StartMCPServers 按配置启动全部 MCP 服务器（已启动时直接返回），返回每个服务器的连接结果
+func StartMCPServers() []MCPServerStatus {
+	mcpState.mu.Lock()
+	if mcpState.started {
+		mcpState.mu.Unlock()
+		return MCPStatus()
+	}
+	cfg, err := LoadMCPConfig()
+	mcpState.started = true
+	mcpState.configs = cfg.Servers
+	mcpState.clients = make(map[string]*mcpClient)
+	mcpState.errors = make(map[string]string)
+	mcpState.names = mcpState.names[:0]
+	for name := range cfg.Servers {
+		mcpState.names = append(mcpState.names, name)
+	}
+	sort.Strings(mcpState.names)
+	mcpState.mu.Unlock()
+	if err != nil {
+		return []MCPServerStatus{{Name: "mcp", Error: err.Error()}}
+	}
+
+	// 并行启动，避免多个慢启动的服务器（如 npx）叠加等待
+	var wg sync.WaitGroup
+	for name, sc := range cfg.Servers {
+		if sc.Disabled {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, sc MCPServerConfig) {
+			defer wg.Done()
+			client, err := startMCPClient(name, sc)
+			mcpState.mu.Lock()
+			defer mcpState.mu.Unlock()
+			if err != nil {
+				mcpState.errors[name] = err.Error()
+				return
+			}
+			mcpState.clients[name] = client
+		}(name, sc)
+	}
+	wg.Wait()
+	return MCPStatus()
+}
+
+// StopMCPServers 关闭全部 MCP 服务器进程
+func StopMCPServers() {
+	mcpState.mu.Lock()
+	clients := mcpState.clients
+	mcpState.clients = nil
+	mcpState.started = false
+	mcpState.mu.Unlock()
+	for _, c := range clients {
+		c.close()
+	}
+}
+
+// MCPStatus 返回各服务器的连接状态与工具
+func MCPStatus() []MCPServerStatus {
+	mcpState.mu.Lock()
+	defer mcpState.mu.Unlock()
+	out := make([]MCPServerStatus, 0, len(mcpState.names))
+	for _, name := range mcpState.names {
+		sc := mcpState.configs[name]
+		st := MCPServerStatus{
+			Name:     name,
+			Command:  strings.TrimSpace(sc.Command + " " + strings.Join(sc.Args, " ")),
+			Disabled: sc.Disabled,
+			Error:    mcpState.errors[name],
+		}
+		if c := mcpState.clients[name]; c != nil {
+			st.Connected = c.alive()
+			st.ServerInfo = c.serverInfo
+			c.mu.Lock()
+			for _, t := range c.tools {
+				st.Tools = append(st.Tools, t.exposed)
+			}
+			c.mu.Unlock()
+			if !st.Connected && st.Error == "" {
+				st.Error = c.withStderr(fmt.Errorf("进程已退出，下次调用时自动重启")).Error()
+			}
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// mcpToolDefs 已连接服务器的工具定义（未启动时为空）
+func mcpToolDefs() []ToolDef {
+	mcpState.mu.Lock()
+	defer mcpState.mu.Unlock()
+	var defs []ToolDef
+	seen := make(map[string]bool)
+	for _, name := range mcpState.names {
+		c := mcpState.clients[name]
+		if c == nil {
+			continue
+		}
+		c.mu.Lock()
+		for _, t := range c.tools {
+			// 不同服务器的工具合并名相同时（如 a__b/c 与 a/b__c），按服务器名顺序保留第一个，与 callMCPTool 的路由一致
+			if seen[t.exposed] {
+				continue
+			}
+			seen[t.exposed] = true
+			var schema interface{} = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			if len(t.InputSchema) > 0 {
+				schema = t.InputSchema
+			}
+			desc := t.Description
+			if desc == "" {
+				desc = t.Name
+			}
+			defs = append(defs, ToolDef{
+				Name:        t.exposed,
+				Description: fmt.Sprintf("[MCP %s] %s", name, desc),
+				Parameters:  schema,
+				ReadOnly:    c.readOnly(t.Name),
+			})
+		}
+		c.mu.Unlock()
+	}
+	return defs
+}
+
+// callMCPTool 将合并后的工具名路由到对应服务器；服务器进程已退出时先尝试重启一次
+func callMCPTool(name, argsJSON string) (string, error) {
+	mcpState.mu.Lock()
+	var client *mcpClient
+	var tool string
+	for _, server := range mcpState.names {
+		c := mcpState.clients[server]
+		if c == nil {
+			continue
+		}
+		c.mu.Lock()
+		for _, t := range c.tools {
+			if t.exposed == name {
+				client, tool = c, t.Name
+				break
+			}
+		}
+		c.mu.Unlock()
+		if client != nil {
+			break
+		}
+	}
+	mcpState.mu.Unlock()
+	if client == nil {
+		return "", fmt.Errorf("未找到 MCP 工具: %s", name)
+	}
+
+	if !client.alive() {
+		var err error
+		if client, err = restartMCPClient(client); err != nil {
+			return "", err
+		}
+	}
+	return client.callTool(tool, argsJSON)
+}
+
+// restartMCPClient 重启已退出的服务器；其他调用已完成重启时直接使用新连接
+func restartMCPClient(dead *mcpClient) (*mcpClient, error) {
+	mcpState.restartMu.Lock()
+	defer mcpState.restartMu.Unlock()
+	mcpState.mu.Lock()
+	current := mcpState.clients[dead.name]
+	mcpState.mu.Unlock()
+	if current != nil && current != dead && current.alive() {
+		return current, nil
+	}
+
+	restarted, err := startMCPClient(dead.name, dead.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("MCP 服务器 %s 已退出，重启失败: %v", dead.name, err)
+	}
+	dead.cmd.Wait()
+	mcpState.mu.Lock()
+	mcpState.clients[dead.name] = restarted
+	delete(mcpState.errors, dead.name)
+	mcpState.mu.Unlock()
+	return restarted, nil
+}
+
+// startMCP 启动配置的 MCP 服务器并输出连接结果（未配置时静默）
+func (a *Agent) startMCP() {
+	status := StartMCPServers()
+	if len(status) == 0 {
+		return
+	}
+	for _, st := range status {
+		switch {
+		case st.Disabled:
+		case st.Error != "":
+			a.print(fmt.Sprintf("\033[1;33m⚠ MCP 服务器 %s 连接失败: %s\033[0m", st.Name, st.Error))
+		default:
+			a.print(fmt.Sprintf("\033[1;36mℹ 已连接 MCP 服务器 %s（%d 个工具）\033[0m", st.Name, len(st.Tools)))
+		}
+	}
+}
+
+// printMCPStatus /mcp 命令：列出服务器状态与工具
+func (a *Agent) printMCPStatus(status []MCPServerStatus) {
+	if len(status) == 0 {
+		a.print("\033[1;36mℹ 未配置 MCP 服务器（app_config.json 的 mcp.servers）\033[0m")
+		return
+	}
+	a.print("\033[1;34m═══ MCP 服务器 ═══\033[0m")
+	for _, st := range status {
+		state := "\033[1;32m已连接\033[0m"
+		switch {
+		case st.Disabled:
+			state = "已禁用"
+		case !st.Connected:
+			state = "\033[1;31m未连接\033[0m"
+		}
+		line := fmt.Sprintf("  \033[1;36m%s\033[0m  [%s]  %s", st.Name, state, st.Command)
+		if st.ServerInfo != "" {
+			line += "  (" + st.ServerInfo + ")"
+		}
+		a.print(line)
+		if st.Error != "" {
+			a.print("      错误: " + st.Error)
+		}
+		for _, t := range st.Tools {
+			a.print("      - " + t)
+		}
+	}
+	a.print("  /mcp reload 按最新配置重新连接")
+}
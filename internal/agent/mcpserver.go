@@ -0,0 +1,335 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 服务端支持的 MCP 协议版本（工具注解自 2025-03-26 起，elicitation 自 2025-06-18 起）
+var mcpServerVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const mcpElicitTimeout = 5 * time.Minute
+
+// MCPServeOptions MCP 服务模式参数
+type MCPServeOptions struct {
+	Service string    // 当前服务 ID，默认 default
+	Write   string    // ask（默认）、deny、all 或逗号分隔的工具名
+	Log     io.Writer // 调用日志（stdout 为协议通道，日志只能写 stderr）
+}
+
+// mcpServer 通过 stdio 对外提供本机运维工具；工具调用复用 Agent 的确认与安全策略
+type mcpServer struct {
+	agent   *Agent
+	out     io.Writer
+	log     io.Writer
+	writeMu sync.Mutex
+	callMu  sync.Mutex // 工具调用串行执行，与交互模式一致
+
+	mu        sync.Mutex
+	nextID    int64
+	pending   map[string]chan mcpMessage
+	canElicit bool          // 客户端声明了 elicitation 能力，可向用户确认写操作
+	closed    chan struct{} // 输入结束后关闭，等待中的确认请求立即失败
+}
+
+// ServeMCP 在 in/out 上运行 MCP 服务器（每行一条 JSON-RPC 消息），直到 in 关闭或 ctx 取消
+func ServeMCP(ctx context.Context, in io.Reader, out io.Writer, opts MCPServeOptions) error {
+	if opts.Write == "" {
+		opts.Write = HeadlessWriteAsk
+	}
+	if opts.Service == "" {
+		opts.Service = "default"
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	aiCfg, err := LoadAIConfig() // 只用于判断是否连接 Hub（Hub 策略），不需要可用的模型
+	if err != nil {
+		return err
+	}
+
+	s := &mcpServer{out: out, log: opts.Log, pending: make(map[string]chan mcpMessage), closed: make(chan struct{})}
+	a, err := newHeadlessAgent(aiCfg, HeadlessOptions{
+		Service: opts.Service,
+		Write:   opts.Write,
+		Log:     io.Discard, // 工具调用进度由 mcpServer 自行记录
+		Confirm: s.confirm,
+	})
+	if err != nil {
+		return err
+	}
+	names, errs := LoadCustomTools()
+	for _, err := range errs {
+		fmt.Fprintf(s.log, "[mcp] 自定义工具加载失败 %v\n", err)
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(s.log, "[mcp] 已加载 %d 个自定义工具: %s\n", len(names), strings.Join(names, ", "))
+	}
+	for _, err := range a.perms.load() {
+		fmt.Fprintf(s.log, "[mcp] 权限策略 %v\n", err)
+	}
+	if a.headless.allowed, err = parseHeadlessWrite(opts.Write); err != nil {
+		return err
+	}
+	s.agent = a
+	fmt.Fprintf(s.log, "[mcp] 服务已启动（服务 %s，写操作策略 %s）\n", opts.Service, opts.Write)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(strings.TrimSpace(string(line))) > 0 {
+				lines <- line
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(s.closed)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			var msg mcpMessage
+			if err := json.Unmarshal(line, &msg); err != nil {
+				s.send(mcpMessage{Error: &mcpRPCError{Code: -32700, Message: "parse error"}})
+				continue
+			}
+			if msg.Method == "" {
+				s.deliver(msg) // 客户端对 elicitation 请求的响应
+				continue
+			}
+			// 请求在独立 goroutine 中处理，工具调用等待 elicitation 响应时仍能继续读取消息
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handle(msg)
+			}()
+		}
+	}
+}
+
+func (s *mcpServer) handle(msg mcpMessage) {
+	if _, ok := mcpIDKey(msg.ID); !ok {
+		return // 通知（initialized、cancelled 等）无需应答
+	}
+	result, rpcErr := s.dispatch(msg)
+	reply := mcpMessage{ID: msg.ID, Error: rpcErr}
+	if rpcErr == nil {
+		reply.Result = mustJSON(result)
+	}
+	s.send(reply)
+}
+
+func (s *mcpServer) dispatch(msg mcpMessage) (interface{}, *mcpRPCError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string                     `json:"protocolVersion"`
+			Capabilities    map[string]json.RawMessage `json:"capabilities"`
+			ClientInfo      struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"clientInfo"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		version := mcpServerVersions[0]
+		for _, v := range mcpServerVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		_, elicit := params.Capabilities["elicitation"]
+		s.mu.Lock()
+		s.canElicit = elicit
+		s.mu.Unlock()
+		fmt.Fprintf(s.log, "[mcp] 客户端 %s %s 已连接（协议 %s，elicitation: %v）\n",
+			params.ClientInfo.Name, params.ClientInfo.Version, version, elicit)
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+			"serverInfo":      map[string]string{"name": "ruoyi-proxy", "version": "1.0"},
+			"instructions":    "ruoyi-proxy 运维工具：查看服务状态与日志、蓝绿切换、启停部署等。写操作需经确认或按服务端 --write 策略放行。",
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		tools := s.agent.tools()
+		list := make([]map[string]interface{}, 0, len(tools))
+		for _, t := range tools {
+			list = append(list, map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"inputSchema": t.Parameters,
+				"annotations": map[string]interface{}{
+					"readOnlyHint":    t.ReadOnly,
+					"destructiveHint": !t.ReadOnly,
+					"openWorldHint":   false,
+				},
+			})
+		}
+		return map[string]interface{}{"tools": list}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params.Name == "" {
+			return nil, &mcpRPCError{Code: -32602, Message: "invalid params"}
+		}
+		known := false
+		for _, t := range s.agent.tools() {
+			known = known || t.Name == params.Name
+		}
+		if !known {
+			return nil, &mcpRPCError{Code: -32602, Message: "unknown tool: " + params.Name}
+		}
+		return s.callTool(params.Name, params.Arguments), nil
+	}
+	return nil, &mcpRPCError{Code: -32601, Message: "method not found: " + msg.Method}
+}
+
+// callTool 经 Agent.executeToolCall 执行：Hub 禁用工具、禁止的 shell 命令、写操作确认均与交互模式一致
+func (s *mcpServer) callTool(name string, args json.RawMessage) map[string]interface{} {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+
+	argsJSON := "{}"
+	if len(args) > 0 && string(args) != "null" {
+		argsJSON = string(args)
+	}
+	id := fmt.Sprintf("mcp-%d", time.Now().UnixNano())
+	start := time.Now()
+	result, err := s.agent.executeToolCall(ToolCall{ID: id, Name: name, Arguments: argsJSON})
+	denied := s.agent.headless.denied[id]
+	delete(s.agent.headless.denied, id)
+
+	status := "成功"
+	isError := false
+	switch {
+	case err != nil:
+		result, isError, status = "执行失败: "+err.Error(), true, "失败"
+	case denied:
+		isError, status = true, "已拒绝"
+	case strings.TrimSpace(result) == "":
+		result = "执行成功（命令无输出）"
+	}
+	fmt.Fprintf(s.log, "[mcp] %s %s %s（%s）\n", name, formatArgs(argsJSON), status, time.Since(start).Round(time.Millisecond))
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": result}},
+		"isError": isError,
+	}
+}
+
+// confirm 通过 elicitation 请求客户端用户确认写操作；客户端不支持时拒绝
+func (s *mcpServer) confirm(tool, args string) bool {
+	s.mu.Lock()
+	canElicit := s.canElicit
+	s.mu.Unlock()
+	if !canElicit {
+		return false
+	}
+	message := fmt.Sprintf("ruoyi-proxy 请求执行写操作 %s", tool)
+	if args != "" {
+		message += "\n参数: " + args
+	}
+	params := map[string]interface{}{
+		"message": message,
+		"requestedSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"approve": map[string]interface{}{"type": "boolean", "title": "允许执行"},
+			},
+			"required": []string{"approve"},
+		},
+	}
+	raw, err := s.request("elicitation/create", params, mcpElicitTimeout)
+	if err != nil {
+		fmt.Fprintf(s.log, "[mcp] 确认请求失败: %v\n", err)
+		return false
+	}
+	var res struct {
+		Action  string `json:"action"`
+		Content struct {
+			Approve bool `json:"approve"`
+		} `json:"content"`
+	}
+	return json.Unmarshal(raw, &res) == nil && res.Action == "accept" && res.Content.Approve
+}
+
+// request 向客户端发起请求并等待响应
+func (s *mcpServer) request(method string, params interface{}, timeout time.Duration) (json.RawMessage, error) {
+	s.mu.Lock()
+	s.nextID++
+	id := json.RawMessage(strconv.FormatInt(s.nextID, 10))
+	key, _ := mcpIDKey(id)
+	ch := make(chan mcpMessage, 1)
+	s.pending[key] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+	}()
+
+	s.send(mcpMessage{ID: id, Method: method, Params: mustJSON(params)})
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, fmt.Errorf("%s (code %d)", msg.Error.Message, msg.Error.Code)
+		}
+		return msg.Result, nil
+	case <-s.closed:
+		return nil, fmt.Errorf("客户端已断开")
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("%s 超时", method)
+	}
+}
+
+func (s *mcpServer) deliver(msg mcpMessage) {
+	key, ok := mcpIDKey(msg.ID)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	ch := s.pending[key]
+	s.mu.Unlock()
+	if ch != nil {
+		// 通道容量为 1，客户端重复回复同一 id 时丢弃，不能阻塞读取循环
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+func (s *mcpServer) send(msg mcpMessage) {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
@@ -10,6 +10,7 @@ import (
 
 // mdStream 实现"打字机 + 逐段渲染"：
 // 每个字符实时打印（打字机效果），遇到段落边界时上移光标覆写为渲染后的 Markdown。
+// 非交互模式下为 nil，feed/finish 不做任何输出。
 type mdStream struct {
 	rawBuf    strings.Builder
 	termWidth int
@@ -26,6 +27,9 @@ func newMDStream() *mdStream {
 }
 
 func (m *mdStream) feed(text string) {
+	if m == nil {
+		return
+	}
 	for _, r := range text {
 		m.rawBuf.WriteRune(r)
 
@@ -51,6 +55,9 @@ func (m *mdStream) feed(text string) {
 
 // finish 将缓冲区剩余内容渲染输出
 func (m *mdStream) finish() {
+	if m == nil {
+		return
+	}
 	if strings.TrimSpace(m.rawBuf.String()) != "" {
 		m.flushParagraph()
 	}
@@ -19,6 +19,8 @@ type openAIProvider struct {
 	model     string
 	maxTokens int
 	timeout   int
+	// noStreamUsage 接口不支持 stream_options 时置位，之后的流式请求不再请求用量
+	noStreamUsage bool
 }
 
 func (p *openAIProvider) Name() string  { return "openai" }
@@ -54,11 +56,29 @@ type openAITool struct {
 }
 
 type openAIRequest struct {
-	Model     string          `json:"model"`
-	Messages  []openAIMessage `json:"messages"`
-	Tools     []openAITool    `json:"tools,omitempty"`
-	MaxTokens int             `json:"max_tokens,omitempty"`
-	Stream    bool            `json:"stream"`
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Stream        bool                 `json:"stream"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions include_usage 让流式响应在最后一个 chunk 返回用量
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *openAIUsage) toUsage() *Usage {
+	if u == nil || u.PromptTokens <= 0 {
+		return nil
+	}
+	return &Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
 }
 
 type openAIResponse struct {
@@ -66,6 +86,7 @@ type openAIResponse struct {
 		Message      openAIMessage `json:"message"`
 		FinishReason string        `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error"`
@@ -89,6 +110,7 @@ type openAIChunk struct {
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"` // include_usage 时最后一个 chunk（choices 为空）携带
 }
 
 // ——— 转换 ———
@@ -204,6 +226,7 @@ func (p *openAIProvider) Chat(ctx context.Context, messages []Message, tools []T
 		Content:          content,
 		ReasoningContent: msg.ReasoningContent,
 		ToolCalls:        parseToolCalls(msg.ToolCalls),
+		Usage:            apiResp.Usage.toUsage(),
 	}, nil
 }
 
@@ -217,24 +240,28 @@ func (p *openAIProvider) Stream(ctx context.Context, messages []Message, tools [
 		MaxTokens: p.maxTokens,
 		Stream:    true,
 	}
-
-	// 流式请求不设总超时，用 context 控制
-	body, _ := json.Marshal(req)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST",
-		strings.TrimRight(p.baseURL, "/")+"/chat/completions",
-		bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+	if !p.noStreamUsage {
+		req.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
-	httpReq.Header.Set("Accept", "text/event-stream")
 
-	client := &http.Client{} // 无超时，由 ctx 控制
-	resp, err := client.Do(httpReq)
+	// 流式请求不设总超时，用 context 控制
+	resp, err := p.doStreamRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("流式请求失败: %v", err)
 	}
+	if resp.StatusCode == http.StatusBadRequest && req.StreamOptions != nil {
+		// 部分兼容接口不认识 stream_options，去掉后重试，之后不再携带
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(data), "stream_options") {
+			return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+		}
+		p.noStreamUsage = true
+		req.StreamOptions = nil
+		if resp, err = p.doStreamRequest(ctx, req); err != nil {
+			return nil, fmt.Errorf("流式请求失败: %v", err)
+		}
+	}
 	if resp.StatusCode != 200 {
 		data, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
@@ -255,6 +282,22 @@ func (p *openAIProvider) Stream(ctx context.Context, messages []Message, tools [
 	return ch, nil
 }
 
+func (p *openAIProvider) doStreamRequest(ctx context.Context, req openAIRequest) (*http.Response, error) {
+	body, _ := json.Marshal(req)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST",
+		strings.TrimRight(p.baseURL, "/")+"/chat/completions",
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	client := &http.Client{} // 无超时，由 ctx 控制
+	return client.Do(httpReq)
+}
+
 func (p *openAIProvider) parseSSEStream(body io.Reader, ch chan<- StreamEvent) {
 	scanner := bufio.NewScanner(body)
 	scanner.Buffer(make([]byte, 64*1024), 64*1024)
@@ -268,6 +311,7 @@ func (p *openAIProvider) parseSSEStream(body io.Reader, ch chan<- StreamEvent) {
 	accum := make(map[int]*tcAccum)
 
 	var reasoningBuf strings.Builder
+	var usage *Usage
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -283,6 +327,9 @@ func (p *openAIProvider) parseSSEStream(body io.Reader, ch chan<- StreamEvent) {
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
 			continue
 		}
+		if u := chunk.Usage.toUsage(); u != nil {
+			usage = u
+		}
 		if len(chunk.Choices) == 0 {
 			continue
 		}
@@ -337,5 +384,5 @@ func (p *openAIProvider) parseSSEStream(body io.Reader, ch chan<- StreamEvent) {
 	if err := scanner.Err(); err != nil && err != io.EOF {
 		ch <- StreamEvent{Type: "error", Err: fmt.Errorf("读取流失败: %v", err)}
 	}
-	ch <- StreamEvent{Type: "done", ReasoningContent: reasoningBuf.String()}
+	ch <- StreamEvent{Type: "done", ReasoningContent: reasoningBuf.String(), Usage: usage}
 }
@@ -0,0 +1,514 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ——— 权限策略：configs/agent_permissions.json 按工具与参数声明 allow/ask/deny 规则，
+// 用户的「本会话/始终」批准按规则记录，始终批准保存在 configs/agent_approvals.json ———
+
+const (
+	permissionsFile = "configs/agent_permissions.json"
+	approvalsFile   = "configs/agent_approvals.json"
+)
+
+// 规则动作
+const (
+	PermissionAllow = "allow" // 直接执行
+	PermissionAsk   = "ask"   // 需用户确认
+	PermissionDeny  = "deny"  // 拒绝并告知模型原因
+)
+
+// 确认时的批准范围
+type approvalScope int
+
+const (
+	approveNone    approvalScope = iota // 拒绝
+	approveOnce                         // 仅本次
+	approveSession                      // 本会话内同一规则不再询问
+	approveAlways                       // 始终允许（持久化）
+)
+
+// PermissionRule 一条权限规则：工具与各参数条件全部满足时命中
+type PermissionRule struct {
+	Name    string            `json:"name,omitempty"`    // 规则名，用于展示
+	Tools   []string          `json:"tools,omitempty"`   // 工具名，支持通配符（如 mcp__*），为空匹配全部工具
+	Paths   []string          `json:"paths,omitempty"`   // path/paths 参数：目录（含其下全部文件）或通配符
+	Command []string          `json:"command,omitempty"` // run_shell 的 command 参数匹配的正则（allow 规则逐条简单命令匹配）
+	Args    map[string]string `json:"args,omitempty"`    // 其他参数名 → 正则（参数值转为字符串后匹配）
+	Action  string            `json:"action"`            // allow / ask / deny
+	Reason  string            `json:"reason,omitempty"`  // 说明，拒绝或确认时展示给用户与模型
+
+	commandRes []*regexp.Regexp
+	argRes     map[string]*regexp.Regexp
+	key        string
+}
+
+// PermissionPolicy 权限策略文件，规则按顺序匹配，第一条命中的规则生效；
+// 均未命中时按默认行为：只读工具直接执行，其余需确认
+type PermissionPolicy struct {
+	Rules []PermissionRule `json:"rules"`
+}
+
+// ApprovalGrant 一条「始终允许」的批准记录
+type ApprovalGrant struct {
+	Key        string    `json:"key"`
+	Tool       string    `json:"tool"`
+	Scope      string    `json:"scope"` // 批准范围说明，如「规则 nginx-conf」「工具 write_file」
+	ApprovedAt time.Time `json:"approved_at"`
+}
+
+type approvalsDoc struct {
+	Grants []ApprovalGrant `json:"grants"`
+}
+
+// permissionDecision 一次工具调用的权限判定结果
+type permissionDecision struct {
+	action string
+	rule   *PermissionRule // nil 表示未命中规则，按默认行为
+	key    string          // 批准记录的键
+	scope  string          // 批准范围说明
+	reason string          // 需确认的原因（规则说明或 shell 命令分析结果）
+}
+
+func (d permissionDecision) ruleLabel() string {
+	if d.rule == nil {
+		return "默认策略"
+	}
+	return d.rule.label()
+}
+
+// permissionSet 当前生效的规则与批准记录
+type permissionSet struct {
+	mu      sync.RWMutex
+	rules   []*PermissionRule
+	session map[string]bool
+	always  []ApprovalGrant
+}
+
+func newPermissionSet() *permissionSet {
+	return &permissionSet{session: make(map[string]bool)}
+}
+
+// load 重新读取规则与始终批准记录（文件不存在时为空），返回各规则的错误；
+// 出错的规则不会被丢弃：无效动作按 ask 处理，无法编译的正则按字面量匹配，宁可多拦截
+func (p *permissionSet) load() []error {
+	var policy PermissionPolicy
+	var errs []error
+	if data, err := os.ReadFile(permissionsFile); err == nil {
+		if err := json.Unmarshal(data, &policy); err != nil {
+			errs = append(errs, fmt.Errorf("%s: 解析失败: %v（所有需确认的操作仍会询问）", permissionsFile, err))
+		}
+	}
+	rules := make([]*PermissionRule, 0, len(policy.Rules))
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		for _, err := range rule.compile(i) {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", permissionsFile, rule.label(), err))
+		}
+		rules = append(rules, rule)
+	}
+
+	var doc approvalsDoc
+	if data, err := os.ReadFile(approvalsFile); err == nil {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			errs = append(errs, fmt.Errorf("%s: 解析失败: %v", approvalsFile, err))
+		}
+	}
+
+	p.mu.Lock()
+	p.rules = rules
+	p.always = doc.Grants
+	p.mu.Unlock()
+	return errs
+}
+
+// compile 校验动作并编译正则；规则键由规则内容生成，调整顺序不影响已有批准，
+// 修改规则（如放宽正则或路径）后原有的批准随之失效
+func (r *PermissionRule) compile(index int) []error {
+	var errs []error
+	r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+	switch r.Action {
+	case PermissionAllow, PermissionAsk, PermissionDeny:
+	default:
+		errs = append(errs, fmt.Errorf("无效的 action %q，按 ask 处理", r.Action))
+		r.Action = PermissionAsk
+	}
+	compile := func(expr string) *regexp.Regexp {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("正则 %q 无效，按字面量匹配: %v", expr, err))
+			re = regexp.MustCompile(regexp.QuoteMeta(expr))
+		}
+		return re
+	}
+	for _, expr := range r.Command {
+		r.commandRes = append(r.commandRes, compile(expr))
+	}
+	r.argRes = make(map[string]*regexp.Regexp, len(r.Args))
+	for name, expr := range r.Args {
+		r.argRes[name] = compile(expr)
+	}
+
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	r.key = "rule:" + hex.EncodeToString(sum[:12])
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("#%d", index+1)
+	}
+	return errs
+}
+
+func (r *PermissionRule) label() string {
+	return "规则 " + r.Name
+}
+
+// matches 工具名与全部参数条件均满足时命中；声明了条件但调用缺少对应参数时不命中
+func (r *PermissionRule) matches(tool string, args map[string]interface{}) bool {
+	if len(r.Tools) > 0 {
+		hit := false
+		for _, pattern := range r.Tools {
+			if ok, _ := path.Match(pattern, tool); ok || pattern == tool {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+	if len(r.Paths) > 0 {
+		paths := argPaths(args)
+		if len(paths) == 0 {
+			return false
+		}
+		hit := false
+		for _, p := range paths {
+			for _, pattern := range r.Paths {
+				if pathMatches(pattern, p) {
+					hit = true
+				}
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+	if len(r.commandRes) > 0 {
+		command, ok := args["command"].(string)
+		if !ok || !r.matchesCommand(command) {
+			return false
+		}
+	}
+	for name, re := range r.argRes {
+		v, ok := args[name]
+		if !ok || v == nil || !re.MatchString(argString(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesCommand allow 规则要求解析出的每条简单命令（含命令替换中的命令与重定向）都命中某个正则，
+// 避免 ^systemctl status 放行 systemctl status x; rm -rf /，无法解析时不命中；
+// ask/deny 规则整条命令或其中任一简单命令命中即可，宁可多拦截
+func (r *PermissionRule) matchesCommand(command string) bool {
+	units, err := shellSimpleCommands(command)
+	if r.Action == PermissionAllow {
+		if err != nil || len(units) == 0 {
+			return false
+		}
+		for _, u := range units {
+			if !r.commandMatches(u) {
+				return false
+			}
+		}
+		return true
+	}
+	if r.commandMatches(command) {
+		return true
+	}
+	for _, u := range units {
+		if r.commandMatches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PermissionRule) commandMatches(s string) bool {
+	for _, re := range r.commandRes {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// argPaths 取出调用中的路径参数（path 与 paths），规范化为绝对路径并解析符号链接
+func argPaths(args map[string]interface{}) []string {
+	var raw []string
+	if s, ok := args["path"].(string); ok && s != "" {
+		raw = append(raw, s)
+	}
+	if list, ok := args["paths"].([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok && s != "" {
+				raw = append(raw, s)
+			}
+		}
+	}
+	paths := make([]string, 0, len(raw))
+	for _, p := range raw {
+		paths = append(paths, normalizePath(p))
+	}
+	return paths
+}
+
+// normalizePath 与工具执行时一致地解析路径（~ 展开、相对当前目录），
+// 并解析已存在部分的符号链接，避免通过链接绕过目录规则
+func normalizePath(p string) string {
+	p = expandHome(p)
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+		return filepath.Join(dir, filepath.Base(p))
+	}
+	return p
+}
+
+// pathMatches 无通配符时匹配该路径本身及其下全部文件；含通配符时按 glob 匹配，
+// 以 /** 结尾表示目录下全部文件
+func pathMatches(pattern, p string) bool {
+	pattern = filepath.Clean(expandHome(pattern))
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		dir = normalizePath(dir)
+		return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		pattern = normalizePath(pattern)
+		return p == pattern || strings.HasPrefix(p, strings.TrimSuffix(pattern, "/")+"/")
+	}
+	ok, _ := filepath.Match(pattern, p)
+	return ok
+}
+
+func argString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []interface{}, map[string]interface{}:
+		data, _ := json.Marshal(x)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// evaluate 判定一次工具调用：命中的 deny 规则优先，否则第一条命中的规则生效；
+// 未命中时只读工具（含只读 shell 命令）直接执行，其余需确认
+func (p *permissionSet) evaluate(tc ToolCall, def *ToolDef) permissionDecision {
+	args := map[string]interface{}{}
+	if tc.Arguments != "" {
+		_ = json.Unmarshal([]byte(tc.Arguments), &args)
+	}
+
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+	var hit *PermissionRule
+	for _, rule := range rules {
+		if !rule.matches(tc.Name, args) {
+			continue
+		}
+		if rule.Action == PermissionDeny {
+			hit = rule
+			break
+		}
+		if hit == nil {
+			hit = rule
+		}
+	}
+	if hit != nil {
+		return permissionDecision{action: hit.Action, rule: hit, key: hit.key, scope: hit.label(), reason: hit.Reason}
+	}
+
+	needsConfirm := def != nil && !def.ReadOnly
+	reason := ""
+	if needsConfirm && tc.Name == "run_shell" {
+		if err := shellConfirmReason(tc.Arguments); err != nil {
+			reason = "非只读命令: " + err.Error()
+		} else {
+			needsConfirm = false
+		}
+	}
+	if needsConfirm && tc.Name == "spoke_run" && isReadOnlyFleetRun(tc.Arguments) {
+		needsConfirm = false
+	}
+	if !needsConfirm {
+		return permissionDecision{action: PermissionAllow}
+	}
+	// 未命中规则时按工具批准；run_shell、spoke_run 只批准参数完全相同的调用
+	d := permissionDecision{action: PermissionAsk, key: "tool:" + tc.Name, scope: "工具 " + tc.Name, reason: reason}
+	if tc.Name == "run_shell" || tc.Name == "spoke_run" {
+		canonical, _ := json.Marshal(args)
+		d.key += ":" + string(canonical)
+		if tc.Name == "run_shell" {
+			d.scope = "命令 " + trimRunes(argString(args["command"]), 60)
+		} else {
+			d.scope = "操作 " + trimRunes(argString(args["op"])+" "+argString(args["spokes"]), 60)
+		}
+	}
+	return d
+}
+
+// approved 返回该批准键是否已在本会话或始终批准（allowAlways=false 时忽略持久化的批准）
+func (p *permissionSet) approved(key string, allowAlways bool) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.session[key] {
+		return "本会话已批准", true
+	}
+	if allowAlways {
+		for _, g := range p.always {
+			if g.Key == key {
+				return "已始终允许", true
+			}
+		}
+	}
+	return "", false
+}
+
+// grant 记录批准；始终允许写入 approvalsFile
+func (p *permissionSet) grant(d permissionDecision, tool string, scope approvalScope) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch scope {
+	case approveSession:
+		p.session[d.key] = true
+	case approveAlways:
+		p.session[d.key] = true
+		p.always = append(p.always, ApprovalGrant{Key: d.key, Tool: tool, Scope: d.scope, ApprovedAt: time.Now()})
+		return p.saveAlwaysLocked()
+	}
+	return nil
+}
+
+// clearApprovals 清除本会话与始终允许的全部批准
+func (p *permissionSet) clearApprovals() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.session = make(map[string]bool)
+	if len(p.always) == 0 {
+		return nil
+	}
+	p.always = nil
+	return p.saveAlwaysLocked()
+}
+
+// resetSession 新建或切换会话时清除本会话批准
+func (p *permissionSet) resetSession() {
+	p.mu.Lock()
+	p.session = make(map[string]bool)
+	p.mu.Unlock()
+}
+
+func (p *permissionSet) saveAlwaysLocked() error {
+	doc := approvalsDoc{Grants: p.always}
+	if doc.Grants == nil {
+		doc.Grants = []ApprovalGrant{}
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_ = os.MkdirAll(filepath.Dir(approvalsFile), 0755)
+	if err := os.WriteFile(approvalsFile, data, 0644); err != nil {
+		return fmt.Errorf("保存批准记录失败: %v", err)
+	}
+	return nil
+}
+
+// parseApprovalAnswer 解析确认框输入：回车/y 仅本次，s 本会话，a 始终，其他取消
+func parseApprovalAnswer(s string) approvalScope {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "y", "yes", "ok", "确认", "同意", "好", "好的", "可以", "是", "是的":
+		return approveOnce
+	case "s", "session", "会话", "本会话":
+		return approveSession
+	case "a", "always", "始终", "总是":
+		return approveAlways
+	}
+	return approveNone
+}
+
+// deniedMessage 告知模型调用被拒绝的原因，并要求其不要绕过
+func deniedMessage(tool string, d permissionDecision) string {
+	reason := ""
+	if d.reason != "" {
+		reason = "，原因: " + d.reason
+	}
+	return fmt.Sprintf("工具 %s 的本次调用被本机权限策略拒绝（%s%s），未执行。不要换用其他工具或命令绕过该限制；如确需执行，请告知用户手动处理或调整 %s",
+		tool, d.ruleLabel(), reason, permissionsFile)
+}
+
+// loadPermissions 加载权限策略并输出错误（文件不存在时静默）
+func (a *Agent) loadPermissions() {
+	for _, err := range a.perms.load() {
+		a.print(fmt.Sprintf("\033[1;33m⚠ 权限策略 %v\033[0m", err))
+	}
+}
+
+// printPermissions /permissions 命令：列出规则与批准记录
+func (a *Agent) printPermissions() {
+	a.perms.mu.RLock()
+	defer a.perms.mu.RUnlock()
+	a.print("\033[1;34m═══ 权限策略 ═══\033[0m")
+	if len(a.perms.rules) == 0 {
+		a.print(fmt.Sprintf("  未配置规则（%s），只读工具直接执行，其余需确认", permissionsFile))
+	}
+	actionColor := map[string]string{PermissionAllow: "\033[1;32m", PermissionAsk: "\033[1;33m", PermissionDeny: "\033[1;31m"}
+	for _, r := range a.perms.rules {
+		var conds []string
+		if len(r.Tools) > 0 {
+			conds = append(conds, "工具 "+strings.Join(r.Tools, ","))
+		}
+		if len(r.Paths) > 0 {
+			conds = append(conds, "路径 "+strings.Join(r.Paths, ","))
+		}
+		if len(r.Command) > 0 {
+			conds = append(conds, "命令 "+strings.Join(r.Command, " | "))
+		}
+		for name, expr := range r.Args {
+			conds = append(conds, name+"~"+expr)
+		}
+		if len(conds) == 0 {
+			conds = append(conds, "全部调用")
+		}
+		line := fmt.Sprintf("  %s%-5s\033[0m %-14s %s", actionColor[r.Action], r.Action, r.Name, strings.Join(conds, "；"))
+		if r.Reason != "" {
+			line += "  \033[90m(" + r.Reason + ")\033[0m"
+		}
+		a.print(line)
+	}
+	if n := len(a.perms.session); n > 0 {
+		a.print(fmt.Sprintf("本会话已批准: %d 项", n))
+	}
+	for _, g := range a.perms.always {
+		a.print(fmt.Sprintf("  \033[1;32m始终允许\033[0m %s（%s，%s）", g.Scope, g.Tool, g.ApprovedAt.Format("2006-01-02 15:04")))
+	}
+	a.print(fmt.Sprintf("\033[90m/permissions reload 重新加载，/permissions clear 清除全部批准（%s）\033[0m", approvalsFile))
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testPermissions 按 load 的方式编译规则，不读取配置文件
+func testPermissions(t *testing.T, rules ...PermissionRule) *permissionSet {
+	t.Helper()
+	p := newPermissionSet()
+	for i := range rules {
+		rule := rules[i]
+		if errs := rule.compile(i); len(errs) > 0 {
+			t.Fatalf("规则 %d 编译失败: %v", i, errs)
+		}
+		p.rules = append(p.rules, &rule)
+	}
+	return p
+}
+
+func shellCall(command string) ToolCall {
+	args, _ := json.Marshal(map[string]string{"command": command})
+	return ToolCall{Name: "run_shell", Arguments: string(args)}
+}
+
+func TestPermissionEvaluate(t *testing.T) {
+	allowLs := PermissionRule{Name: "ls", Tools: []string{"run_shell"}, Command: []string{`^ls(\s|$)`}, Action: PermissionAllow}
+	allowStatus := PermissionRule{Name: "status", Tools: []string{"run_shell"}, Command: []string{`^systemctl status `}, Action: PermissionAllow}
+	allowAll := PermissionRule{Name: "all", Tools: []string{"run_shell"}, Command: []string{`.*`}, Action: PermissionAllow}
+	denyRm := PermissionRule{Name: "no-rm", Tools: []string{"run_shell"}, Command: []string{`^rm\s`}, Action: PermissionDeny}
+	askNginx := PermissionRule{Name: "nginx", Tools: []string{"write_file"}, Paths: []string{"/etc/nginx"}, Action: PermissionAsk}
+	allowTmp := PermissionRule{Name: "tmp", Tools: []string{"write_file"}, Paths: []string{"/opt/app/tmp/**"}, Action: PermissionAllow}
+
+	shellDef := &ToolDef{Name: "run_shell"}
+	writeDef := &ToolDef{Name: "write_file"}
+	writeCall := func(path string) ToolCall {
+		args, _ := json.Marshal(map[string]string{"path": path, "content": "x"})
+		return ToolCall{Name: "write_file", Arguments: string(args)}
+	}
+
+	tests := []struct {
+		name   string
+		rules  []PermissionRule
+		call   ToolCall
+		def    *ToolDef
+		action string
+		rule   string // 期望命中的规则名，空表示未命中规则
+	}{
+		// allow 规则逐条简单命令匹配
+		{name: "allow 命中", rules: []PermissionRule{allowLs}, call: shellCall("ls -la /opt"), def: shellDef, action: PermissionAllow, rule: "ls"},
+		{name: "allow 覆盖管道中每条命令", rules: []PermissionRule{allowLs}, call: shellCall("ls /opt | ls /tmp"), def: shellDef, action: PermissionAllow, rule: "ls"},
+		{name: "&& 后的命令不在 allow 内", rules: []PermissionRule{allowLs}, call: shellCall("ls && rm -rf x"), def: shellDef, action: PermissionAsk},
+		{name: "分号后的命令不在 allow 内", rules: []PermissionRule{allowStatus}, call: shellCall("systemctl status x; rm -rf /"), def: shellDef, action: PermissionAsk},
+		{name: "命令替换不在 allow 内", rules: []PermissionRule{allowLs}, call: shellCall("ls $(rm -rf x)"), def: shellDef, action: PermissionAsk},
+		{name: "写文件的重定向单独匹配", rules: []PermissionRule{allowStatus}, call: shellCall("systemctl status x > /etc/passwd"), def: shellDef, action: PermissionAsk},
+		{name: "/dev/null 重定向忽略", rules: []PermissionRule{allowLs}, call: shellCall("ls /opt 2>/dev/null"), def: shellDef, action: PermissionAllow, rule: "ls"},
+		{name: "无法解析的命令不被 allow", rules: []PermissionRule{allowLs}, call: shellCall("ls 'unterminated"), def: shellDef, action: PermissionAsk},
+
+		// deny 优先于 allow，且任一简单命令命中即拒绝
+		{name: "deny 在后仍优先", rules: []PermissionRule{allowAll, denyRm}, call: shellCall("rm -rf /tmp/x"), def: shellDef, action: PermissionDeny, rule: "no-rm"},
+		{name: "deny 在前", rules: []PermissionRule{denyRm, allowAll}, call: shellCall("rm -rf /tmp/x"), def: shellDef, action: PermissionDeny, rule: "no-rm"},
+		{name: "deny 命中组合命令中的一条", rules: []PermissionRule{allowLs, denyRm}, call: shellCall("ls && rm -rf x"), def: shellDef, action: PermissionDeny, rule: "no-rm"},
+		{name: "deny 命中命令替换", rules: []PermissionRule{allowAll, denyRm}, call: shellCall("echo $(rm -f x)"), def: shellDef, action: PermissionDeny, rule: "no-rm"},
+		{name: "deny 未命中时 allow 生效", rules: []PermissionRule{allowAll, denyRm}, call: shellCall("ls /opt"), def: shellDef, action: PermissionAllow, rule: "all"},
+
+		// 路径规则
+		{name: "路径 ask", rules: []PermissionRule{askNginx, allowTmp}, call: writeCall("/etc/nginx/conf.d/app.conf"), def: writeDef, action: PermissionAsk, rule: "nginx"},
+		{name: "路径 allow", rules: []PermissionRule{askNginx, allowTmp}, call: writeCall("/opt/app/tmp/a/b.txt"), def: writeDef, action: PermissionAllow, rule: "tmp"},
+		{name: "路径穿越不命中 allow", rules: []PermissionRule{allowTmp}, call: writeCall("/opt/app/tmp/../conf/app.yml"), def: writeDef, action: PermissionAsk},
+
+		// 未命中规则时的默认行为
+		{name: "只读命令直接执行", call: shellCall("df -h"), def: shellDef, action: PermissionAllow},
+		{name: "写工具需确认", call: writeCall("/opt/app/x"), def: writeDef, action: PermissionAsk},
+	}
+	for _, tt := range tests {
+		p := testPermissions(t, tt.rules...)
+		d := p.evaluate(tt.call, tt.def)
+		got := ""
+		if d.rule != nil {
+			got = d.rule.Name
+		}
+		if d.action != tt.action || got != tt.rule {
+			t.Errorf("%s: 期望 %s（规则 %q），实际 %s（规则 %q）", tt.name, tt.action, tt.rule, d.action, got)
+		}
+	}
+}
+
+func TestPermissionGrantKey(t *testing.T) {
+	rule := PermissionRule{Tools: []string{"run_shell"}, Command: []string{`^systemctl restart nginx$`}, Action: PermissionAsk}
+	call := shellCall("systemctl restart nginx")
+	def := &ToolDef{Name: "run_shell"}
+
+	p := testPermissions(t, rule)
+	d := p.evaluate(call, def)
+	if d.action != PermissionAsk || d.rule == nil {
+		t.Fatalf("应命中 ask 规则，实际 %s", d.action)
+	}
+	if err := p.grant(d, call.Name, approveSession); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.approved(d.key, false); !ok {
+		t.Fatalf("本会话批准后应放行")
+	}
+
+	// 规则位置变化（默认名称 #n 随之变化）不影响批准
+	moved := testPermissions(t, PermissionRule{Tools: []string{"read_file"}, Action: PermissionAllow}, rule)
+	moved.session = p.session
+	if d2 := moved.evaluate(call, def); d2.key != d.key {
+		t.Errorf("规则内容未变时批准键应不变: %s != %s", d2.key, d.key)
+	} else if _, ok := moved.approved(d2.key, false); !ok {
+		t.Errorf("调整规则顺序后原有批准应仍然有效")
+	}
+
+	// 修改规则内容（放宽正则）后原有批准失效
+	widened := rule
+	widened.Command = []string{`^systemctl restart `}
+	changed := testPermissions(t, widened)
+	changed.session = p.session
+	d3 := changed.evaluate(call, def)
+	if d3.key == d.key {
+		t.Errorf("规则内容变化后批准键应变化: %s", d3.key)
+	}
+	if _, ok := changed.approved(d3.key, false); ok {
+		t.Errorf("修改规则后原有批准不应继续生效")
+	}
+
+	// 不同规则的批准互不影响
+	other := testPermissions(t, PermissionRule{Tools: []string{"run_shell"}, Command: []string{`^systemctl reload nginx$`}, Action: PermissionAsk})
+	if d4 := other.evaluate(shellCall("systemctl reload nginx"), def); d4.key == d.key {
+		t.Errorf("不同规则的批准键应不同")
+	}
+}
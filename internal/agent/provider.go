@@ -51,8 +51,7 @@ func NewProvider(cfg AIConfig) (Provider, error) {
 
 	case "hub":
 		return &hubProvider{
-			hubURL:  cfg.EffectiveBaseURL(),
-			token:   cfg.APIKey,
+			cfg:     cfg,
 			model:   cfg.Model,
 			timeout: timeout,
 		}, nil
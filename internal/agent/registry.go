@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ——— 工具注册表：内置工具、Hub 集群工具与自定义工具统一注册，按名称分发执行 ———
+
+// Tool 可注册给 Agent 的工具
+type Tool interface {
+	Definition() ToolDef
+	Execute(e *ToolExecutor, args map[string]interface{}) (string, error)
+}
+
+// ToolHandler 工具执行函数，args 为模型传入的 JSON 参数
+type ToolHandler func(e *ToolExecutor, args map[string]interface{}) (string, error)
+
+// 工具来源，自定义工具重新加载时按来源整体替换
+const (
+	toolSourceBuiltin = "builtin"
+	toolSourceFleet   = "fleet"
+	toolSourceCustom  = "custom"
+)
+
+type funcTool struct {
+	def ToolDef
+	run ToolHandler
+}
+
+func (t funcTool) Definition() ToolDef { return t.def }
+
+func (t funcTool) Execute(e *ToolExecutor, args map[string]interface{}) (string, error) {
+	return t.run(e, args)
+}
+
+// NewFuncTool 用定义与执行函数构造工具
+func NewFuncTool(def ToolDef, run ToolHandler) Tool {
+	return funcTool{def: def, run: run}
+}
+
+type toolEntry struct {
+	tool      Tool
+	source    string
+	available func() bool // 为 nil 表示始终可用
+}
+
+// ToolRegistry 工具注册表（保持注册顺序，工具列表按此顺序提供给模型）
+type ToolRegistry struct {
+	mu      sync.RWMutex
+	order   []string
+	entries map[string]toolEntry
+}
+
+// NewToolRegistry 创建空注册表
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{entries: make(map[string]toolEntry)}
+}
+
+var defaultRegistry = NewToolRegistry()
+
+func init() {
+	for _, def := range AllTools {
+		mustRegisterBuiltin(toolSourceBuiltin, def, nil)
+	}
+	for _, def := range FleetTools {
+		mustRegisterBuiltin(toolSourceFleet, def, fleetEnabled)
+	}
+}
+
+func mustRegisterBuiltin(source string, def ToolDef, available func() bool) {
+	run, ok := builtinHandlers[def.Name]
+	if !ok {
+		panic("工具缺少执行函数: " + def.Name)
+	}
+	if err := defaultRegistry.register(NewFuncTool(def, run), source, available); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterTool 向默认注册表添加工具，名称重复时返回错误
+func RegisterTool(t Tool) error {
+	return defaultRegistry.register(t, toolSourceBuiltin, nil)
+}
+
+func (r *ToolRegistry) register(t Tool, source string, available func() bool) error {
+	name := t.Definition().Name
+	if name == "" {
+		return fmt.Errorf("工具名称为空")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.entries[name]; exists {
+		return fmt.Errorf("工具 %s 已注册", name)
+	}
+	r.entries[name] = toolEntry{tool: t, source: source, available: available}
+	r.order = append(r.order, name)
+	return nil
+}
+
+// replaceSource 移除某来源的全部工具后注册新的一组，返回注册失败（如与已有工具重名）的错误
+func (r *ToolRegistry) replaceSource(source string, tools []Tool) []error {
+	r.mu.Lock()
+	order := r.order[:0:0]
+	for _, name := range r.order {
+		if r.entries[name].source == source {
+			delete(r.entries, name)
+			continue
+		}
+		order = append(order, name)
+	}
+	r.order = order
+	r.mu.Unlock()
+
+	var errs []error
+	for _, t := range tools {
+		if err := r.register(t, source, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Lookup 按名称查找工具
+func (r *ToolRegistry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.entries[name]
+	return entry.tool, ok
+}
+
+// Definitions 返回当前可用工具的定义（all=true 时包含暂不可用的工具，如未启用 Hub 时的集群工具）
+func (r *ToolRegistry) Definitions(all bool) []ToolDef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]ToolDef, 0, len(r.order))
+	for _, name := range r.order {
+		entry := r.entries[name]
+		if !all && entry.available != nil && !entry.available() {
+			continue
+		}
+		defs = append(defs, entry.tool.Definition())
+	}
+	return defs
+}
+
+// sourceOf 返回工具来源（builtin/fleet/custom），未注册时为空
+func (r *ToolRegistry) sourceOf(name string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.entries[name].source
+}
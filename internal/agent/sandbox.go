@@ -0,0 +1,360 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SandboxHelperArg 沙箱辅助进程的隐藏子命令：Agent 以 <本程序> __agent-sandbox <spec> 启动，
+// 辅助进程完成隔离（挂载、资源限制）后 exec 为 bash 或 bwrap
+const SandboxHelperArg = "__agent-sandbox"
+
+const (
+	SandboxOff      = "off"      // 不隔离（默认）
+	SandboxAuto     = "auto"     // 可用时隔离，不可用时照常执行
+	SandboxRequired = "required" // 不可用时拒绝执行
+
+	sandboxBackendBwrap     = "bwrap"     // bubblewrap
+	sandboxBackendNamespace = "namespace" // 本程序直接创建 user/mount/net 命名空间
+
+	sandboxSetupPrefix = "[sandbox-setup] " // 辅助进程初始化失败时 stderr 的前缀
+)
+
+// SandboxConfig app_config.json 的 sandbox 字段：Agent 发起的 shell 命令（run_shell、自定义工具）在隔离环境中执行
+type SandboxConfig struct {
+	Mode          string   `json:"mode"`                    // off | auto | required
+	WritablePaths []string `json:"writable_paths"`          // 可写路径，未配置时为 /tmp；支持 ~/ 与 $APP_HOME
+	Network       bool     `json:"network"`                 // 是否允许访问网络（含本机端口），默认禁止
+	MemoryMB      int      `json:"memory_mb,omitempty"`     // 数据段上限，默认 2048
+	CPUSeconds    int      `json:"cpu_seconds,omitempty"`   // CPU 时间上限，默认 300
+	MaxFileMB     int      `json:"max_file_mb,omitempty"`   // 单个文件大小上限，默认 1024
+	MaxProcesses  int      `json:"max_processes,omitempty"` // 进程数上限（按用户统计），默认不限
+}
+
+// LoadSandboxConfig 读取沙箱配置，文件不存在或无 sandbox 字段时返回关闭状态
+func LoadSandboxConfig() (SandboxConfig, error) {
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return SandboxConfig{Mode: SandboxOff}, nil
+	}
+	var root struct {
+		Sandbox SandboxConfig `json:"sandbox"`
+	}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return SandboxConfig{Mode: SandboxOff}, fmt.Errorf("解析沙箱配置失败: %v", err)
+	}
+	cfg := root.Sandbox
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = SandboxOff
+	case SandboxOff, SandboxAuto, SandboxRequired:
+	default:
+		// 无法识别的取值按最严格处理，避免拼写错误导致静默关闭
+		return SandboxConfig{Mode: SandboxRequired}, fmt.Errorf("sandbox.mode 取值无效: %s（可选 off、auto、required）", cfg.Mode)
+	}
+	return cfg, nil
+}
+
+// limits 填充默认值后的资源限制；配置为负数表示不限制
+func (c SandboxConfig) limits() sandboxLimits {
+	pick := func(v, def int) int {
+		switch {
+		case v < 0:
+			return 0
+		case v == 0:
+			return def
+		}
+		return v
+	}
+	return sandboxLimits{
+		MemoryMB:     pick(c.MemoryMB, 2048),
+		CPUSeconds:   pick(c.CPUSeconds, 300),
+		MaxFileMB:    pick(c.MaxFileMB, 1024),
+		MaxProcesses: pick(c.MaxProcesses, 0),
+	}
+}
+
+// writable 解析可写路径：展开 ~/ 与 $APP_HOME，解析符号链接，不存在的路径跳过
+func (c SandboxConfig) writable(appHome string) []string {
+	paths := c.WritablePaths
+	if paths == nil {
+		paths = []string{"/tmp"}
+	}
+	var out []string
+	for _, p := range paths {
+		p = expandHome(strings.TrimSpace(p))
+		if appHome != "" {
+			p = strings.NewReplacer("${APP_HOME}", appHome, "$APP_HOME", appHome).Replace(p)
+		}
+		if !filepath.IsAbs(p) {
+			continue
+		}
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			continue
+		}
+		out = append(out, filepath.Clean(real))
+	}
+	return out
+}
+
+type sandboxLimits struct {
+	MemoryMB     int `json:"memory_mb"`
+	CPUSeconds   int `json:"cpu_seconds"`
+	MaxFileMB    int `json:"max_file_mb"`
+	MaxProcesses int `json:"max_processes"`
+}
+
+// sandboxSpec 传给辅助进程的执行说明
+type sandboxSpec struct {
+	Backend  string        `json:"backend"`
+	Command  string        `json:"command"`
+	Writable []string      `json:"writable"`
+	Network  bool          `json:"network"`
+	Limits   sandboxLimits `json:"limits"`
+}
+
+// sandboxRun 一次沙箱执行的上下文，用于解释命令失败是否由沙箱造成
+type sandboxRun struct {
+	spec sandboxSpec
+}
+
+var (
+	sandboxProbeOnce    sync.Once
+	sandboxProbeBackend string
+	sandboxProbeErr     error
+)
+
+// detectSandboxBackend 探测可用的隔离方式（每个进程只探测一次）：优先 bubblewrap，其次命名空间
+func detectSandboxBackend() (string, error) {
+	sandboxProbeOnce.Do(func() {
+		if runtime.GOOS != "linux" {
+			sandboxProbeErr = fmt.Errorf("仅支持 Linux")
+			return
+		}
+		var reasons []string
+		for _, backend := range []string{sandboxBackendBwrap, sandboxBackendNamespace} {
+			if backend == sandboxBackendBwrap {
+				if _, err := exec.LookPath("bwrap"); err != nil {
+					reasons = append(reasons, "未安装 bwrap")
+					continue
+				}
+			}
+			spec := sandboxSpec{Backend: backend, Command: "true", Writable: []string{}}
+			cmd, err := sandboxHelperCmd(spec)
+			if err == nil {
+				var out []byte
+				if out, err = cmd.CombinedOutput(); err != nil {
+					err = fmt.Errorf("%v %s", err, strings.TrimSpace(strings.TrimPrefix(string(out), sandboxSetupPrefix)))
+				}
+			}
+			if err == nil {
+				sandboxProbeBackend = backend
+				return
+			}
+			reasons = append(reasons, fmt.Sprintf("%s 不可用: %v", backend, strings.TrimSpace(err.Error())))
+		}
+		sandboxProbeErr = fmt.Errorf("%s", strings.Join(reasons, "；"))
+	})
+	return sandboxProbeBackend, sandboxProbeErr
+}
+
+// sandboxHelperCmd 构造启动辅助进程的命令
+func sandboxHelperCmd(spec sandboxSpec) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("无法定位程序路径: %v", err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(self, SandboxHelperArg, string(data))
+	cmd.SysProcAttr = sandboxProcAttr(spec)
+	return cmd, nil
+}
+
+// shellCommand 构造执行 Agent shell 命令的 *exec.Cmd：按 sandbox 配置在隔离环境中运行 bash -c，
+// 未启用或 auto 模式下不可用时直接运行；返回的 sandboxRun 为 nil 表示未隔离
+func (e *ToolExecutor) shellCommand(command string) (*exec.Cmd, *sandboxRun, error) {
+	cfg, err := LoadSandboxConfig()
+	if err != nil && cfg.Mode != SandboxRequired {
+		return nil, nil, err
+	}
+	if cfg.Mode == SandboxOff {
+		return exec.Command("bash", "-c", command), nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("⛔ 沙箱配置错误，命令未执行: %v", err)
+	}
+	backend, err := detectSandboxBackend()
+	if err != nil {
+		if cfg.Mode == SandboxAuto {
+			return exec.Command("bash", "-c", command), nil, nil
+		}
+		return nil, nil, fmt.Errorf("⛔ 沙箱不可用，命令未执行（sandbox.mode=required）: %v", err)
+	}
+	spec := sandboxSpec{
+		Backend:  backend,
+		Command:  command,
+		Writable: cfg.writable(e.execCtx.AppHome),
+		Network:  cfg.Network,
+		Limits:   cfg.limits(),
+	}
+	cmd, err := sandboxHelperCmd(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("⛔ 沙箱启动失败，命令未执行: %v", err)
+	}
+	return cmd, &sandboxRun{spec: spec}, nil
+}
+
+var sandboxBlockSignals = []struct {
+	pattern string
+	reason  string
+	network bool // 仅在禁用网络时才归因于沙箱
+}{
+	{"read-only file system", "写入只读文件系统", false},
+	{"只读文件系统", "写入只读文件系统", false},
+	{"network is unreachable", "网络已禁用", true},
+	{"网络不可达", "网络已禁用", true},
+	{"could not resolve host", "网络已禁用", true},
+	{"temporary failure in name resolution", "网络已禁用", true},
+	{"域名解析暂时失败", "网络已禁用", true},
+	{"couldn't connect to server", "网络已禁用", true},
+	{"connection refused", "网络已禁用", true},
+	{"拒绝连接", "网络已禁用", true},
+	{"file size limit exceeded", "超出单个文件大小上限", false},
+	{"文件大小超出限制", "超出单个文件大小上限", false},
+	{"cpu time limit exceeded", "超出 CPU 时间上限", false},
+	{"超出 cpu 时限", "超出 CPU 时间上限", false},
+	{"cannot allocate memory", "超出内存上限", false},
+	{"无法分配内存", "超出内存上限", false},
+	{"out of memory", "超出内存上限", false},
+	{"memoryerror", "超出内存上限", false}, // Python MemoryError、Java OutOfMemoryError
+	{"fork: resource temporarily unavailable", "超出进程数上限", false},
+	{"fork: 资源暂时不可用", "超出进程数上限", false},
+}
+
+// blockedNotice 根据命令输出判断是否被沙箱拦截，返回追加到工具结果中的说明；未隔离或未拦截时返回空串
+func (r *sandboxRun) blockedNotice(out string, runErr error) string {
+	if r == nil {
+		return ""
+	}
+	text := out
+	if runErr != nil {
+		text += "\n" + runErr.Error()
+	}
+	if i := strings.Index(text, sandboxSetupPrefix); i >= 0 {
+		line := text[i+len(sandboxSetupPrefix):]
+		if j := strings.IndexByte(line, '\n'); j >= 0 {
+			line = line[:j]
+		}
+		return fmt.Sprintf("\n⛔ 沙箱初始化失败，命令未执行: %s", line)
+	}
+	lower := strings.ToLower(text)
+	var reasons []string
+	for _, s := range sandboxBlockSignals {
+		if s.network && r.spec.Network {
+			continue
+		}
+		if strings.Contains(lower, s.pattern) && !containsString(reasons, s.reason) {
+			reasons = append(reasons, s.reason)
+		}
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n⛔ 命令被沙箱拦截: %s（%s）。不要尝试绕过沙箱；如确需执行，请在回答中说明，由用户调整 %s 的 sandbox 配置或手动执行",
+		strings.Join(reasons, "、"), r.spec.describe(), appConfigFile)
+}
+
+// describe 隔离范围的简短说明
+func (s sandboxSpec) describe() string {
+	parts := []string{"文件系统只读"}
+	if len(s.Writable) > 0 {
+		parts[0] += "，可写: " + strings.Join(s.Writable, ", ")
+	}
+	if s.Network {
+		parts = append(parts, "网络可用")
+	} else {
+		parts = append(parts, "网络已禁用")
+	}
+	return strings.Join(parts, "；")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RunSandboxHelper 辅助进程入口：按 spec 完成隔离后 exec 目标命令，失败时以 126 退出
+func RunSandboxHelper(args []string) {
+	var spec sandboxSpec
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%s参数错误\n", sandboxSetupPrefix)
+		os.Exit(126)
+	}
+	if err := json.Unmarshal([]byte(args[0]), &spec); err != nil {
+		fmt.Fprintf(os.Stderr, "%s解析参数失败: %v\n", sandboxSetupPrefix, err)
+		os.Exit(126)
+	}
+	if err := execSandboxed(spec); err != nil {
+		fmt.Fprintf(os.Stderr, "%s%v\n", sandboxSetupPrefix, err)
+		os.Exit(126)
+	}
+	os.Exit(0)
+}
+
+// sandboxStatus 当前沙箱状态的一行说明（启动提示与系统提示词使用）；enabled 为 false 表示命令不隔离
+func (e *ToolExecutor) sandboxStatus() (line string, enabled bool, err error) {
+	cfg, err := LoadSandboxConfig()
+	if err != nil {
+		return "", cfg.Mode == SandboxRequired, err
+	}
+	if cfg.Mode == SandboxOff {
+		return "", false, nil
+	}
+	backend, err := detectSandboxBackend()
+	if err != nil {
+		if cfg.Mode == SandboxAuto {
+			return "", false, fmt.Errorf("沙箱不可用，命令将不隔离执行: %v", err)
+		}
+		return "", true, fmt.Errorf("沙箱不可用，shell 命令将被拒绝执行: %v", err)
+	}
+	spec := sandboxSpec{Backend: backend, Writable: cfg.writable(e.execCtx.AppHome), Network: cfg.Network}
+	return fmt.Sprintf("%s（%s）", backend, spec.describe()), true, nil
+}
+
+// checkSandbox 启动时提示沙箱状态
+func (a *Agent) checkSandbox() {
+	line, _, err := a.executor.sandboxStatus()
+	switch {
+	case err != nil:
+		a.print(fmt.Sprintf("\033[1;33m⚠ %v\033[0m", err))
+	case line != "":
+		a.print(fmt.Sprintf("\033[1;36mℹ shell 命令在沙箱中执行: %s\033[0m", line))
+	}
+}
+
+// sandboxPrompt 启用沙箱时告知模型执行环境的限制
+func (e *ToolExecutor) sandboxPrompt() string {
+	line, enabled, err := e.sandboxStatus()
+	if !enabled {
+		return ""
+	}
+	if err != nil {
+		return "\n## 命令沙箱\nrun_shell 与自定义工具当前无法执行（沙箱不可用），请改用其他工具或请用户手动执行。\n"
+	}
+	return "\n## 命令沙箱\nrun_shell 与自定义工具的命令在沙箱中执行: " + line +
+		"。被沙箱拦截时不要换用其他方式绕过，应向用户说明需要调整 sandbox 配置或手动执行。\n"
+}
@@ -0,0 +1,335 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// syscall 包未导出的常量
+const (
+	rlimitNproc          = 6 // RLIMIT_NPROC
+	prSetNoNewPrivs      = 38
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+	seccompModeFilter    = 2
+	seccompRetAllow      = 0x7fff0000
+	seccompRetErrno      = 0x00050000
+	linuxCapVersion3     = 0x20080522
+	// clone 中创建新命名空间的标志：NEWNS、NEWCGROUP、NEWUTS、NEWIPC、NEWUSER、NEWPID、NEWNET、NEWTIME
+	cloneNamespaceFlags = 0x00020000 | 0x02000000 | 0x04000000 | 0x08000000 | 0x10000000 | 0x20000000 | 0x40000000 | 0x00000080
+)
+
+// sandboxSeccompArch 支持安装 seccomp 过滤器的架构：审计架构号与 syscall 包缺少的系统调用号；
+// 其他架构上 namespace 后端不可用（需安装 bwrap）
+var sandboxSeccompArch = map[string]struct {
+	audit uint32
+	setns uint32
+	x32   bool // amd64 的 x32 调用号带 0x40000000 位，审计架构号相同，需单独拒绝
+}{
+	"amd64": {audit: 0xC000003E, setns: 308, x32: true},
+	"arm64": {audit: 0xC00000B7, setns: 268},
+}
+
+// sandboxProcAttr namespace 模式下辅助进程在新的 user/mount（禁用网络时加 net）命名空间中启动
+func sandboxProcAttr(spec sandboxSpec) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	if spec.Backend != sandboxBackendNamespace {
+		return attr
+	}
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if !spec.Network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	attr.Cloneflags = uintptr(flags)
+	if uid := os.Getuid(); uid == 0 {
+		// root 使用恒等映射，保留读取其他用户文件的能力
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: 0, Size: math.MaxInt32}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: 0, Size: math.MaxInt32}}
+		attr.GidMappingsEnableSetgroups = true
+	} else {
+		// 普通用户只能映射自身，命名空间内显示为 root，实际权限不变
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	}
+	return attr
+}
+
+// execSandboxed 在辅助进程中完成隔离并 exec 目标命令（成功时不返回）
+func execSandboxed(spec sandboxSpec) error {
+	cwd, _ := os.Getwd()
+	if spec.Backend == sandboxBackendNamespace {
+		if err := setupSandboxMounts(spec.Writable); err != nil {
+			return err
+		}
+		// 重新进入工作目录，使其指向新的挂载
+		if cwd != "" {
+			_ = os.Chdir(cwd)
+		}
+	}
+	if err := applySandboxLimits(spec.Limits); err != nil {
+		return err
+	}
+	if spec.Backend == sandboxBackendNamespace {
+		// 命令在新命名空间中拥有全部能力，不加限制即可把只读挂载重新挂载为可写；
+		// 能力、no_new_privs 与 seccomp 均按线程设置，exec 须在同一线程上进行
+		runtime.LockOSThread()
+		if err := lockSandboxPrivileges(); err != nil {
+			return err
+		}
+	}
+
+	argv := []string{"bash", "-c", spec.Command}
+	if spec.Backend == sandboxBackendBwrap {
+		argv = bwrapArgs(spec, cwd)
+	}
+	bin, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("未找到 %s: %v", argv[0], err)
+	}
+	if err := syscall.Exec(bin, argv, os.Environ()); err != nil {
+		return fmt.Errorf("执行 %s 失败: %v", argv[0], err)
+	}
+	return nil
+}
+
+// lockSandboxPrivileges 清空能力边界集与继承能力（root 执行命令后不再获得能力），设置 no_new_privs，
+// 并安装拒绝挂载与创建、进入命名空间的 seccomp 过滤器，最后放弃当前线程的全部能力
+func lockSandboxPrivileges() error {
+	arch, ok := sandboxSeccompArch[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("不支持在 %s 上限制命名空间内的能力，请安装 bwrap", runtime.GOARCH)
+	}
+	for c := uintptr(0); ; c++ {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, c, 0); errno != 0 {
+			if errno == syscall.EINVAL && c > 0 {
+				break // 超出内核支持的最大能力编号
+			}
+			return fmt.Errorf("清空能力边界集失败: %v", errno)
+		}
+	}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 && errno != syscall.EINVAL {
+		return fmt.Errorf("清空环境能力失败: %v", errno)
+	}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("设置 no_new_privs 失败: %v", errno)
+	}
+
+	filter := sandboxSeccompFilter(arch.audit, arch.setns, arch.x32)
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_SECCOMP, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("安装 seccomp 过滤器失败: %v", errno)
+	}
+
+	hdr := struct {
+		version uint32
+		pid     int32
+	}{version: linuxCapVersion3}
+	var data [2]struct{ effective, permitted, inheritable uint32 }
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("放弃能力失败: %v", errno)
+	}
+	return nil
+}
+
+// sandboxSeccompFilter 拒绝 mount、umount2、新挂载 API、pivot_root、chroot、unshare、setns
+// 以及带命名空间标志的 clone；clone3 的参数无法检查，返回 ENOSYS 让 libc 回退到 clone
+func sandboxSeccompFilter(audit, setns uint32, x32 bool) []syscall.SockFilter {
+	stmt := func(code uint16, k uint32) syscall.SockFilter {
+		return syscall.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+		return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	const (
+		load   = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+		jeq    = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+		ret    = syscall.BPF_RET | syscall.BPF_K
+		clone3 = 435
+	)
+	deny := stmt(ret, seccompRetErrno|uint32(syscall.EPERM))
+
+	// seccomp_data: nr 在偏移 0，arch 在 4，args[0] 低 32 位在 16
+	prog := []syscall.SockFilter{
+		stmt(load, 4),
+		jump(jeq, audit, 1, 0),
+		deny, // 其他架构的调用约定（如 amd64 上的 32 位调用）
+		stmt(load, 0),
+	}
+	if x32 {
+		prog = append(prog, jump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, 0x40000000, 0, 1), deny)
+	}
+	denied := []uint32{syscall.SYS_MOUNT, syscall.SYS_UMOUNT2, syscall.SYS_PIVOT_ROOT, syscall.SYS_CHROOT,
+		syscall.SYS_UNSHARE, setns,
+		428, 429, 430, 431, 432, 433, 442, // open_tree、move_mount、fsopen、fsconfig、fsmount、fspick、mount_setattr
+	}
+	for _, nr := range denied {
+		prog = append(prog, jump(jeq, nr, 0, 1), deny)
+	}
+	prog = append(prog,
+		jump(jeq, clone3, 0, 1), stmt(ret, seccompRetErrno|uint32(syscall.ENOSYS)),
+		jump(jeq, syscall.SYS_CLONE, 0, 3),
+		stmt(load, 16),
+		jump(syscall.BPF_JMP|syscall.BPF_JSET|syscall.BPF_K, cloneNamespaceFlags, 0, 1),
+		deny,
+		stmt(ret, seccompRetAllow),
+	)
+	return prog
+}
+
+func bwrapArgs(spec sandboxSpec, cwd string) []string {
+	args := []string{"bwrap", "--die-with-parent", "--ro-bind", "/", "/", "--dev-bind", "/dev", "/dev", "--bind", "/proc", "/proc"}
+	for _, p := range spec.Writable {
+		args = append(args, "--bind", p, p)
+	}
+	if !spec.Network {
+		args = append(args, "--unshare-net")
+	}
+	if cwd != "" {
+		args = append(args, "--chdir", cwd)
+	}
+	return append(args, "--", "bash", "-c", spec.Command)
+}
+
+// setupSandboxMounts 将可写路径绑定为独立挂载，再把其余挂载点逐个重新挂载为只读；/proc 与 /dev 保持原样
+func setupSandboxMounts(writable []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %v", err)
+	}
+	for _, p := range writable {
+		if err := syscall.Mount(p, p, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("绑定可写路径 %s 失败: %v", p, err)
+		}
+	}
+	mounts, err := readMountInfo()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if pathUnder(m.point, "/proc") || pathUnder(m.point, "/dev") {
+			continue
+		}
+		skip := false
+		for _, w := range writable {
+			if pathUnder(m.point, w) {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		flags := uintptr(syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY) | m.flags
+		if err := syscall.Mount("", m.point, "", flags, ""); err != nil {
+			return fmt.Errorf("只读挂载 %s 失败: %v", m.point, err)
+		}
+	}
+	return nil
+}
+
+type mountEntry struct {
+	point string
+	flags uintptr // 需保留的挂载选项（用户命名空间中 nosuid 等被锁定，重新挂载时不能去掉）
+}
+
+// readMountInfo 读取 /proc/self/mountinfo 中的挂载点与挂载选项
+func readMountInfo() ([]mountEntry, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("读取挂载信息失败: %v", err)
+	}
+	defer f.Close()
+
+	optFlags := map[string]uintptr{
+		"nosuid": syscall.MS_NOSUID, "nodev": syscall.MS_NODEV, "noexec": syscall.MS_NOEXEC,
+		"noatime": syscall.MS_NOATIME, "nodiratime": syscall.MS_NODIRATIME,
+		"relatime": syscall.MS_RELATIME, "strictatime": syscall.MS_STRICTATIME,
+	}
+	var mounts []mountEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mountEntry{point: unescapeMountPath(fields[4])}
+		for _, opt := range strings.Split(fields[5], ",") {
+			m.flags |= optFlags[opt]
+		}
+		mounts = append(mounts, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("读取挂载信息失败: %v", err)
+	}
+	return mounts, nil
+}
+
+// unescapeMountPath 还原 mountinfo 中以 \ooo 转义的空格等字符
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func pathUnder(p, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+	return p == dir || strings.HasPrefix(p, filepath.Clean(dir)+"/")
+}
+
+// applySandboxLimits 设置资源上限，由 exec 后的命令及其子进程继承
+func applySandboxLimits(l sandboxLimits) error {
+	set := func(resource int, value, slack uint64, name string) error {
+		if value == 0 {
+			return nil
+		}
+		var cur syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &cur); err != nil {
+			return fmt.Errorf("读取%s上限失败: %v", name, err)
+		}
+		limit := syscall.Rlimit{Cur: value, Max: value + slack}
+		if cur.Max < limit.Max {
+			limit.Max = cur.Max
+		}
+		if limit.Cur > limit.Max {
+			limit.Cur = limit.Max
+		}
+		if err := syscall.Setrlimit(resource, &limit); err != nil {
+			return fmt.Errorf("设置%s上限失败: %v", name, err)
+		}
+		return nil
+	}
+	if err := set(syscall.RLIMIT_DATA, uint64(l.MemoryMB)<<20, 0, "内存"); err != nil {
+		return err
+	}
+	// CPU 硬上限多留 1 秒，先收到 SIGXCPU 再被强制结束，便于识别原因
+	if err := set(syscall.RLIMIT_CPU, uint64(l.CPUSeconds), 1, "CPU 时间"); err != nil {
+		return err
+	}
+	if err := set(syscall.RLIMIT_FSIZE, uint64(l.MaxFileMB)<<20, 0, "文件大小"); err != nil {
+		return err
+	}
+	return set(rlimitNproc, uint64(l.MaxProcesses), 0, "进程数")
+}
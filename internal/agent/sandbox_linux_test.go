@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain 沙箱辅助进程由 os.Executable() 启动，测试中即为测试程序本身
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == SandboxHelperArg {
+		RunSandboxHelper(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+func TestSandboxNamespaceLocksMounts(t *testing.T) {
+	if out, err := runSandboxed(t, sandboxSpec{Backend: sandboxBackendNamespace, Command: "true"}); err != nil {
+		t.Skipf("namespace 后端不可用: %v %s", err, out)
+	}
+	writable, readOnly := t.TempDir(), t.TempDir()
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{name: "写入只读目录", command: "touch " + filepath.Join(readOnly, "plain")},
+		{name: "重新挂载根目录", command: "mount -o remount,bind,rw /"},
+		{name: "重新挂载后写入", command: "for m in $(awk '{print $5}' /proc/self/mountinfo); do mount -o remount,bind,rw \"$m\" 2>/dev/null; done; touch " + filepath.Join(readOnly, "remount")},
+		{name: "新建命名空间", command: "unshare -m true"},
+		{name: "新建用户命名空间", command: "unshare -r -m mount -o remount,bind,rw /"},
+		{name: "进入宿主命名空间", command: "nsenter -t 1 -m true"},
+	}
+	for _, tt := range tests {
+		spec := sandboxSpec{Backend: sandboxBackendNamespace, Command: tt.command, Writable: []string{writable}}
+		if out, err := runSandboxed(t, spec); err == nil {
+			t.Errorf("%s: 应在沙箱中失败，实际成功: %s", tt.name, out)
+		}
+	}
+	entries, _ := os.ReadDir(readOnly)
+	if len(entries) > 0 {
+		t.Errorf("只读目录被写入: %v", entries)
+	}
+
+	spec := sandboxSpec{Backend: sandboxBackendNamespace, Command: "touch " + filepath.Join(writable, "ok"), Writable: []string{writable}}
+	if out, err := runSandboxed(t, spec); err != nil {
+		t.Errorf("写入可写路径失败: %v %s", err, out)
+	}
+}
+
+func runSandboxed(t *testing.T, spec sandboxSpec) (string, error) {
+	t.Helper()
+	cmd, err := sandboxHelperCmd(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package agent
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func sandboxProcAttr(spec sandboxSpec) *syscall.SysProcAttr {
+	return nil
+}
+
+func execSandboxed(spec sandboxSpec) error {
+	return fmt.Errorf("沙箱仅支持 Linux")
+}
@@ -512,7 +512,7 @@ func selectSlashCommandFallback(items []SlashCommandItem, readInput func(string)
 func countRealUserTurns(messages []Message) int {
 	count := 0
 	for _, msg := range messages {
-		if msg.Role == "user" && strings.TrimSpace(msg.Content) != autoResumePrompt {
+		if msg.Role == "user" && !msg.Summary && strings.TrimSpace(msg.Content) != autoResumePrompt {
 			count++
 		}
 	}
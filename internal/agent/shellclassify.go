@@ -0,0 +1,1415 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ——— run_shell 只读判定：用 mvdan.cc/sh 解析为语法树，逐条简单命令检查是否只读 ———
+// 管道、&& || ; &、子 shell、{ } 分组、if/while/for/case、命令替换、进程替换与 here-document 中的命令都会检查；
+// 无法静态确定的内容（函数定义、coproc、命令名含变量或通配符等）一律视为需确认
+
+const maxShellNesting = 16
+
+type shellWord struct {
+	lit     string // 去掉引号与转义后的字面值（含展开时仅为近似值）
+	dynamic bool   // 含变量、命令替换等运行时展开，值无法静态确定
+	glob    bool   // 含未加引号的通配符或花括号展开
+	raw     string // 原始文本，用于提示
+}
+
+func (w shellWord) String() string {
+	if w.raw != "" {
+		return w.raw
+	}
+	return w.lit
+}
+
+// shellClassifier 遍历一段 shell 代码的语法树，遇到第一条非只读的命令即停止
+type shellClassifier struct {
+	src   string
+	depth int
+	err   error
+}
+
+// classifyShellCommand 分析命令是否只读：只读返回 nil，否则返回需要确认的原因
+func classifyShellCommand(command string) error {
+	return classifyShellDepth(command, 0)
+}
+
+func classifyShellDepth(command string, depth int) error {
+	if depth > maxShellNesting {
+		return fmt.Errorf("命令嵌套过深")
+	}
+	file, err := parseShellCommand(command)
+	if err != nil {
+		return err
+	}
+	c := &shellClassifier{src: command, depth: depth}
+	syntax.Walk(file, c.visit)
+	return c.err
+}
+
+func parseShellCommand(command string) (*syntax.File, error) {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("无法解析命令: %v", err)
+	}
+	return file, nil
+}
+
+// shellSimpleCommands 拆出命令中每条简单命令（含命令替换、进程替换中的命令）的原始文本，供权限规则逐条匹配；
+// 会写文件的重定向单独作为一项，输入与 /dev/null 等重定向忽略。函数定义与 coproc 无法逐条匹配，返回错误
+func shellSimpleCommands(command string) ([]string, error) {
+	file, err := parseShellCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	text := func(n syntax.Node) string {
+		return command[n.Pos().Offset():n.End().Offset()]
+	}
+	c := &shellClassifier{src: command}
+	var units []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if err != nil {
+			return false
+		}
+		stmt, ok := node.(*syntax.Stmt)
+		if !ok {
+			return true
+		}
+		switch cmd := stmt.Cmd.(type) {
+		case *syntax.CallExpr, *syntax.DeclClause:
+			units = append(units, text(cmd))
+		case *syntax.FuncDecl:
+			err = fmt.Errorf("不支持分析函数定义 %s", cmd.Name.Value)
+			return false
+		case *syntax.CoprocClause:
+			err = fmt.Errorf("不支持分析 coproc 语句")
+			return false
+		}
+		for _, r := range stmt.Redirs {
+			if checkShellRedirect(r.Op, c.word(r.Word)) != nil {
+				units = append(units, text(r))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// shellConfirmReason 返回 run_shell 命令需要确认的原因，只读命令（直接执行，不弹确认框）返回 nil
+func shellConfirmReason(argsJSON string) error {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return fmt.Errorf("参数无法解析")
+	}
+	if strings.TrimSpace(args.Command) == "" {
+		return fmt.Errorf("命令为空")
+	}
+	return classifyShellCommand(args.Command)
+}
+
+// ——— 语法树遍历 ———
+
+// visit 由 syntax.Walk 调用；命令替换、进程替换、here-document 与赋值中的命令同样会被访问到
+func (c *shellClassifier) visit(node syntax.Node) bool {
+	if c.err != nil {
+		return false
+	}
+	switch n := node.(type) {
+	case *syntax.Stmt:
+		for _, r := range n.Redirs {
+			if err := checkShellRedirect(r.Op, c.word(r.Word)); err != nil {
+				c.err = err
+				return false
+			}
+		}
+	case *syntax.CallExpr:
+		for _, a := range n.Assigns {
+			if a.Name != nil && shellDangerousVars[a.Name.Value] {
+				c.err = fmt.Errorf("修改环境变量 %s", a.Name.Value)
+				return false
+			}
+		}
+		words := make([]shellWord, len(n.Args))
+		for i, w := range n.Args {
+			words[i] = c.word(w)
+		}
+		c.err = classifyShellArgv(words, c.depth)
+	case *syntax.DeclClause:
+		var words []shellWord
+		for _, a := range n.Args {
+			switch {
+			case a.Name != nil:
+				words = append(words, shellWord{lit: a.Name.Value + "=", raw: a.Name.Value})
+			case a.Value != nil:
+				words = append(words, c.word(a.Value))
+			}
+		}
+		if err := checkShellDeclare(words, c.depth); err != nil {
+			c.err = fmt.Errorf("%s: %v", n.Variant.Value, err)
+		}
+	case *syntax.BinaryArithm:
+		if shellArithmAssignOps[n.Op] {
+			c.err = checkShellArithmTarget(n.X)
+		}
+	case *syntax.UnaryArithm:
+		if n.Op == syntax.Inc || n.Op == syntax.Dec {
+			c.err = checkShellArithmTarget(n.X)
+		}
+	case *syntax.LetClause:
+		// let "PATH=1" 中加引号的参数会被再次当作算术表达式求值
+		for _, expr := range n.Exprs {
+			if w, ok := expr.(*syntax.Word); ok && w.Lit() == "" {
+				if c.err = checkShellLetExpr(c.word(w)); c.err != nil {
+					break
+				}
+			}
+		}
+	case *syntax.ParamExp:
+		// ${VAR:=value} 与 ${VAR=value} 在变量未设置时赋值
+		if n.Exp != nil && (n.Exp.Op == syntax.AssignUnset || n.Exp.Op == syntax.AssignUnsetOrNull) &&
+			n.Param != nil && shellDangerousVars[n.Param.Value] {
+			c.err = fmt.Errorf("修改环境变量 %s", n.Param.Value)
+		}
+	case *syntax.FuncDecl:
+		c.err = fmt.Errorf("不支持分析函数定义 %s", n.Name.Value)
+	case *syntax.CoprocClause:
+		c.err = fmt.Errorf("不支持分析 coproc 语句")
+	}
+	return c.err == nil
+}
+
+var shellArithmAssignOps = map[syntax.BinAritOperator]bool{
+	syntax.Assgn: true, syntax.AddAssgn: true, syntax.SubAssgn: true, syntax.MulAssgn: true,
+	syntax.QuoAssgn: true, syntax.RemAssgn: true, syntax.AndAssgn: true, syntax.OrAssgn: true,
+	syntax.XorAssgn: true, syntax.ShlAssgn: true, syntax.ShrAssgn: true,
+}
+
+// checkShellArithmTarget 算术赋值（含 ++、--）的目标不能是 shellDangerousVars
+func checkShellArithmTarget(x syntax.ArithmExpr) error {
+	w, ok := x.(*syntax.Word)
+	if !ok {
+		return nil
+	}
+	name := w.Lit()
+	if name == "" && len(w.Parts) == 1 {
+		if p, ok := w.Parts[0].(*syntax.ParamExp); ok && p.Param != nil {
+			name = p.Param.Value
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("算术赋值的变量名无法静态确定")
+	}
+	if shellDangerousVars[name] {
+		return fmt.Errorf("修改环境变量 %s", name)
+	}
+	return nil
+}
+
+// word 把语法树中的单词还原为字面值，并标记运行时展开与通配符
+func (c *shellClassifier) word(w *syntax.Word) shellWord {
+	var sw shellWord
+	if w == nil {
+		return sw
+	}
+	sw.raw = c.src[w.Pos().Offset():w.End().Offset()]
+	// pattern 只保留未加引号、未转义的字符，用于判断通配符与花括号展开
+	var lit, pattern strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			unescapeShellLit(p.Value, &lit, &pattern)
+		case *syntax.SglQuoted:
+			if p.Dollar && strings.Contains(p.Value, `\`) {
+				sw.dynamic = true // $'...' 中的转义序列
+			}
+			lit.WriteString(p.Value)
+			pattern.WriteByte(0)
+		case *syntax.DblQuoted:
+			for _, inner := range p.Parts {
+				if l, ok := inner.(*syntax.Lit); ok {
+					lit.WriteString(unescapeShellDoubleQuoted(l.Value))
+				} else {
+					sw.dynamic = true
+				}
+			}
+			pattern.WriteByte(0)
+		case *syntax.ExtGlob:
+			sw.glob = true
+			lit.WriteString(p.Op.String() + p.Pattern.Value + ")")
+		default: // 变量、命令替换、算术展开、进程替换
+			sw.dynamic = true
+			pattern.WriteByte(0)
+		}
+	}
+	sw.lit = lit.String()
+	if isShellGlobPattern(pattern.String()) {
+		sw.glob = true
+	}
+	return sw
+}
+
+// unescapeShellLit 去掉未加引号文本中的反斜杠转义；被转义的字符不计入 pattern
+func unescapeShellLit(s string, lit, pattern *strings.Builder) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] != '\n' {
+				lit.WriteByte(s[i])
+				pattern.WriteByte(0)
+			}
+			continue
+		}
+		lit.WriteByte(s[i])
+		pattern.WriteByte(s[i])
+	}
+}
+
+// unescapeShellDoubleQuoted 双引号内反斜杠只转义 $ ` " \ 与换行
+func unescapeShellDoubleQuoted(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+			i++
+			if s[i] != '\n' {
+				sb.WriteByte(s[i])
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+var shellBraceRe = regexp.MustCompile(`\{[^{}\x00]*(,|\.\.)[^{}\x00]*\}`)
+
+// isShellGlobPattern 未加引号的 * ? [...] 或 {a,b}、{1..3} 会在运行时展开为其他单词
+func isShellGlobPattern(p string) bool {
+	if strings.ContainsAny(p, "*?") || shellBraceRe.MatchString(p) {
+		return true
+	}
+	if i := strings.IndexByte(p, '['); i >= 0 && strings.IndexByte(p[i+1:], ']') >= 0 {
+		return true
+	}
+	return false
+}
+
+// checkShellRedirect 输入重定向、here-document 与复制文件描述符不写文件；输出只允许到 /dev/null 等
+func checkShellRedirect(op syntax.RedirOperator, target shellWord) error {
+	switch op {
+	case syntax.RdrIn, syntax.WordHdoc, syntax.Hdoc, syntax.DashHdoc:
+		return nil
+	case syntax.DplIn, syntax.DplOut:
+		if !target.dynamic && (target.lit == "-" || isShellDigits(target.lit)) {
+			return nil
+		}
+		if op == syntax.DplIn {
+			return fmt.Errorf("无法分析的重定向 <&%s", target)
+		}
+	}
+	if target.dynamic || target.glob || !isShellSafeSink(target.lit) {
+		return fmt.Errorf("输出重定向到 %s", target)
+	}
+	return nil
+}
+
+func isShellSafeSink(s string) bool {
+	switch s {
+	case "/dev/null", "/dev/stdout", "/dev/stderr":
+		return true
+	}
+	rest, ok := strings.CutPrefix(s, "/dev/fd/")
+	return ok && isShellDigits(rest)
+}
+
+func isShellDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ——— 命令判定 ———
+
+// shellChecker 检查只读命令的参数，返回非 nil 表示该调用会修改系统或无法判断
+type shellChecker func(args []shellWord, depth int) error
+
+var (
+	shellAssignmentRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(\[[^\]]*\])?\+?=`)
+	// 会改变后续命令查找或 shell 行为的变量
+	shellDangerousVars = map[string]bool{
+		"PATH": true, "LD_PRELOAD": true, "LD_LIBRARY_PATH": true, "LD_AUDIT": true,
+		"BASH_ENV": true, "ENV": true, "IFS": true, "SHELLOPTS": true, "BASHOPTS": true,
+		"PS4": true, "PROMPT_COMMAND": true, "CDPATH": true,
+	}
+	shellTrustedBinDirs = map[string]bool{
+		"/bin": true, "/sbin": true, "/usr/bin": true, "/usr/sbin": true,
+		"/usr/local/bin": true, "/usr/local/sbin": true,
+	}
+)
+
+// classifyShellArgv 检查一条简单命令：去掉前置变量赋值后按命令名查表
+func classifyShellArgv(words []shellWord, depth int) error {
+	for len(words) > 0 {
+		m := shellAssignmentRe.FindStringSubmatch(words[0].lit)
+		if m == nil {
+			break
+		}
+		if shellDangerousVars[m[1]] {
+			return fmt.Errorf("修改环境变量 %s", m[1])
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	name := words[0]
+	if name.dynamic || name.glob {
+		return fmt.Errorf("命令名无法静态确定: %s", name)
+	}
+	cmd := name.lit
+	if strings.Contains(cmd, "/") {
+		if !shellTrustedBinDirs[path.Dir(cmd)] {
+			return fmt.Errorf("执行非系统目录下的程序 %s", cmd)
+		}
+		cmd = path.Base(cmd)
+	}
+	check, ok := shellReadOnlyCommands[cmd]
+	if !ok {
+		return fmt.Errorf("%s 不在只读命令列表中", cmd)
+	}
+	if check == nil {
+		return nil
+	}
+	if err := check(words[1:], depth); err != nil {
+		return fmt.Errorf("%s: %v", cmd, err)
+	}
+	return nil
+}
+
+var shellIdentRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// checkShellVarName 内建命令（read、printf -v 等）写入的变量名须能静态确定且不是 shellDangerousVars
+func checkShellVarName(name shellWord) error {
+	if name.dynamic || name.glob {
+		return fmt.Errorf("变量名无法静态确定: %s", name)
+	}
+	base, _, _ := strings.Cut(name.lit, "[")
+	if shellDangerousVars[base] {
+		return fmt.Errorf("修改环境变量 %s", base)
+	}
+	return nil
+}
+
+// checkShellLetExpr let 的参数是算术表达式，其中出现 shellDangerousVars 即视为可能赋值
+func checkShellLetExpr(w shellWord) error {
+	if w.dynamic {
+		return fmt.Errorf("算术表达式无法静态确定: %s", w)
+	}
+	for _, id := range shellIdentRe.FindAllString(w.lit, -1) {
+		if shellDangerousVars[id] {
+			return fmt.Errorf("修改环境变量 %s", id)
+		}
+	}
+	return nil
+}
+
+func literalShellWords(vals []string) []shellWord {
+	words := make([]shellWord, len(vals))
+	for i, v := range vals {
+		words[i] = shellWord{lit: v}
+	}
+	return words
+}
+
+// literalShellArgs 取出参数字面值；参数含展开或通配符时其实际值（可能是选项）无法确定
+func literalShellArgs(args []shellWord) ([]string, error) {
+	vals := make([]string, len(args))
+	for i, a := range args {
+		if a.dynamic {
+			return nil, fmt.Errorf("参数含变量或命令替换，无法判断: %s", a)
+		}
+		if a.glob {
+			return nil, fmt.Errorf("参数含通配符，无法判断: %s", a)
+		}
+		vals[i] = a.lit
+	}
+	return vals, nil
+}
+
+// shellOpt 拆分后的命令行选项；operand 为 true 时 name 为普通参数
+type shellOpt struct {
+	name    string
+	value   string
+	operand bool
+}
+
+// parseShellOpts 按 getopt 规则拆分参数：短选项可合并（-abc），valueOpts 中的短选项与 longValue 中的长选项带参数
+func parseShellOpts(vals []string, valueOpts string, longValue map[string]bool) []shellOpt {
+	var opts []shellOpt
+	for i := 0; i < len(vals); i++ {
+		a := vals[i]
+		switch {
+		case a == "--":
+			for _, v := range vals[i+1:] {
+				opts = append(opts, shellOpt{name: v, operand: true})
+			}
+			return opts
+		case strings.HasPrefix(a, "--"):
+			name, value, hasValue := strings.Cut(a, "=")
+			if !hasValue && longValue[name] && i+1 < len(vals) {
+				i++
+				value = vals[i]
+			}
+			opts = append(opts, shellOpt{name: name, value: value})
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			for j := 1; j < len(a); j++ {
+				opt := shellOpt{name: "-" + a[j:j+1]}
+				if strings.IndexByte(valueOpts, a[j]) >= 0 {
+					if j+1 < len(a) {
+						opt.value = a[j+1:]
+					} else if i+1 < len(vals) {
+						i++
+						opt.value = vals[i]
+					}
+					opts = append(opts, opt)
+					break
+				}
+				opts = append(opts, opt)
+			}
+		default:
+			opts = append(opts, shellOpt{name: a, operand: true})
+		}
+	}
+	return opts
+}
+
+// shellOptSpec 包装命令的完整选项表。不在表中的选项一律无法判断——把带参数的选项当成开关，
+// 会让选项参数被误认作被执行的命令
+type shellOptSpec struct {
+	flags    string          // 不带参数的短选项
+	values   string          // 带参数的短选项（-tVALUE 或 -t VALUE）
+	optional string          // 参数可选的短选项，只接受紧跟的参数（-eEOF）
+	long     map[string]bool // 长选项，值为 true 表示带参数；--name=VALUE 总是合法
+	numeric  bool            // 接受 -NUM（nice -10）
+	plus     bool            // 接受 +x 形式（bash +e）
+}
+
+// splitShellCommand 按选项表拆开包装命令（timeout、sudo 等）开头的选项，返回选项与被执行的命令
+func splitShellCommand(args []shellWord, spec shellOptSpec) ([]shellOpt, []shellWord, error) {
+	var opts []shellOpt
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a.dynamic || a.glob {
+			return nil, nil, fmt.Errorf("参数含展开，无法判断: %s", a)
+		}
+		switch {
+		case a.lit == "--":
+			return opts, args[i+1:], nil
+		case strings.HasPrefix(a.lit, "--"):
+			name, value, hasValue := strings.Cut(a.lit, "=")
+			takesValue, ok := spec.long[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("无法识别的选项 %s", name)
+			}
+			if takesValue && !hasValue {
+				if i+1 == len(args) {
+					return nil, nil, fmt.Errorf("选项 %s 缺少参数", name)
+				}
+				i++
+				value = args[i].lit
+			}
+			opts = append(opts, shellOpt{name: name, value: value})
+		case len(a.lit) > 1 && (a.lit[0] == '-' || (spec.plus && a.lit[0] == '+')):
+			if spec.numeric && a.lit[0] == '-' && isShellDigits(a.lit[1:]) {
+				opts = append(opts, shellOpt{name: "-n", value: a.lit[1:]})
+				continue
+			}
+		cluster:
+			for j := 1; j < len(a.lit); j++ {
+				opt := shellOpt{name: a.lit[:1] + a.lit[j:j+1]}
+				switch c := a.lit[j]; {
+				case strings.IndexByte(spec.values, c) >= 0:
+					if j+1 < len(a.lit) {
+						opt.value = a.lit[j+1:]
+					} else if i+1 < len(args) {
+						i++
+						opt.value = args[i].lit
+					} else {
+						return nil, nil, fmt.Errorf("选项 %s 缺少参数", opt.name)
+					}
+					opts = append(opts, opt)
+					break cluster
+				case strings.IndexByte(spec.optional, c) >= 0:
+					opt.value = a.lit[j+1:]
+					opts = append(opts, opt)
+					break cluster
+				case strings.IndexByte(spec.flags, c) >= 0:
+					opts = append(opts, opt)
+				default:
+					return nil, nil, fmt.Errorf("无法识别的选项 %s", opt.name)
+				}
+			}
+		default:
+			return opts, args[i:], nil
+		}
+	}
+	return opts, nil, nil
+}
+
+// hasShellOpt 选项列表中是否出现任一名称
+func hasShellOpt(opts []shellOpt, names ...string) (string, bool) {
+	for _, opt := range opts {
+		for _, n := range names {
+			if opt.name == n {
+				return n, true
+			}
+		}
+	}
+	return "", false
+}
+
+// rejectShellOpts 出现任一禁止的选项时返回错误
+func rejectShellOpts(valueOpts string, longValue map[string]bool, reason string, names ...string) shellChecker {
+	return func(args []shellWord, _ int) error {
+		vals, err := literalShellArgs(args)
+		if err != nil {
+			return err
+		}
+		for _, opt := range parseShellOpts(vals, valueOpts, longValue) {
+			if opt.operand {
+				continue
+			}
+			for _, n := range names {
+				if opt.name == n {
+					return fmt.Errorf("%s %s", n, reason)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// subcommandOnly 第一个非选项参数（子命令）须在允许列表中；allowEmpty 表示无子命令也只读
+func subcommandOnly(valueOpts string, allowEmpty bool, allowed ...string) shellChecker {
+	set := make(map[string]bool, len(allowed))
+	for _, s := range allowed {
+		set[s] = true
+	}
+	return func(args []shellWord, _ int) error {
+		vals, err := literalShellArgs(args)
+		if err != nil {
+			return err
+		}
+		for _, opt := range parseShellOpts(vals, valueOpts, nil) {
+			if !opt.operand {
+				continue
+			}
+			if set[opt.name] {
+				return nil
+			}
+			return fmt.Errorf("子命令 %s 可能修改系统", opt.name)
+		}
+		if allowEmpty {
+			return nil
+		}
+		return fmt.Errorf("缺少只读子命令")
+	}
+}
+
+// maxOperands 非选项参数不超过 n 个（如 uniq、xxd 的第二个参数是输出文件）
+func maxOperands(n int, valueOpts string) shellChecker {
+	return func(args []shellWord, _ int) error {
+		vals, err := literalShellArgs(args)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for _, opt := range parseShellOpts(vals, valueOpts, nil) {
+			if opt.operand {
+				count++
+			}
+		}
+		if count > n {
+			return fmt.Errorf("参数过多，可能写入输出文件")
+		}
+		return nil
+	}
+}
+
+func versionOnly(args []shellWord, _ int) error {
+	if len(args) == 1 && !args[0].dynamic {
+		switch args[0].lit {
+		case "-version", "--version", "-v", "-V", "version":
+			return nil
+		}
+	}
+	return fmt.Errorf("仅版本查询视为只读")
+}
+
+// shellReadOnlyCommands 只读命令表：值为 nil 表示任意参数都只读，否则由检查函数判断参数
+var shellReadOnlyCommands map[string]shellChecker
+
+func init() {
+	shellReadOnlyCommands = map[string]shellChecker{
+		// shell 内建与流程控制（只影响当前 shell）
+		"echo": nil, "true": nil, "false": nil, ":": nil, "test": nil, "[": nil, "[[": nil,
+		"cd": nil, "pushd": nil, "popd": nil, "shift": nil, "wait": nil, "exit": nil, "return": nil, "sleep": nil,
+		// 会给变量赋值的内建命令：目标不能是 shellDangerousVars
+		"printf": checkShellPrintf, "read": checkShellRead, "mapfile": checkShellMapfile, "readarray": checkShellMapfile,
+		"getopts": checkShellGetopts, "let": checkShellLet, "unset": checkShellUnset, "set": checkShellSet,
+		"export": checkShellDeclare, "declare": checkShellDeclare, "typeset": checkShellDeclare,
+		"local": checkShellDeclare, "readonly": checkShellDeclare,
+		// 目录/文件查看
+		"ls": nil, "dir": nil, "pwd": nil, "realpath": nil, "readlink": nil, "basename": nil, "dirname": nil,
+		"cat": nil, "more": nil, "head": nil, "tail": nil, "strings": nil, "tac": nil, "nl": nil,
+		"zcat": nil, "bzcat": nil, "xzcat": nil, "zgrep": nil,
+		"less": rejectShellOpts("bhjkoOpPtTxyz#", map[string]bool{"--log-file": true, "--LOG-FILE": true}, "会写日志文件", "-o", "-O", "--log-file", "--LOG-FILE"),
+		"tree": rejectShellOpts("LPIo", nil, "会写输出文件", "-o"),
+		// 文本处理（非原地修改）
+		"grep": nil, "egrep": nil, "fgrep": nil, "wc": nil, "cut": nil, "tr": nil, "diff": nil, "cmp": nil,
+		"comm": nil, "rev": nil, "fold": nil, "paste": nil, "join": nil, "column": nil, "expand": nil,
+		"unexpand": nil, "fmt": nil, "od": nil, "hexdump": nil, "base64": nil, "jq": nil, "seq": nil,
+		"sed":  checkShellSed,
+		"awk":  checkShellAwk,
+		"gawk": checkShellAwk,
+		"sort": rejectShellOpts("kotST", map[string]bool{"--key": true, "--output": true, "--field-separator": true,
+			"--buffer-size": true, "--temporary-directory": true, "--compress-program": true},
+			"会写文件或执行程序", "-o", "--output", "--compress-program"),
+		"uniq": maxOperands(1, "fsw"),
+		"xxd":  maxOperands(1, "cglos"),
+		"tee":  checkShellTee,
+		// 文件查找与信息
+		"find": checkShellFind, "xargs": checkShellXargs,
+		"which": nil, "type": nil, "whereis": nil, "locate": nil,
+		"stat": nil, "md5sum": nil, "sha1sum": nil, "sha256sum": nil, "sha512sum": nil, "cksum": nil,
+		"file": rejectShellOpts("efFm", nil, "会编译 magic 文件", "-C", "--compile"),
+		// 磁盘、进程、内存
+		"df": nil, "du": nil, "lsblk": nil, "blkid": nil,
+		"fdisk": func(args []shellWord, depth int) error {
+			for _, a := range args {
+				if !a.dynamic && (a.lit == "-l" || a.lit == "--list") {
+					return nil
+				}
+			}
+			return fmt.Errorf("仅 fdisk -l 视为只读")
+		},
+		"ps": nil, "top": nil, "htop": nil, "uptime": nil, "pstree": nil, "pgrep": nil,
+		"free": nil, "vmstat": nil, "iostat": nil, "mpstat": nil, "nproc": nil,
+		"sar": rejectShellOpts("fo", nil, "会写数据文件", "-o"),
+		// 网络
+		"netstat": nil, "lsof": nil, "ping": nil, "traceroute": nil, "tracepath": nil,
+		"nslookup": nil, "dig": nil, "host": nil,
+		"ss":       rejectShellOpts("fAFN", nil, "会关闭连接", "-K", "--kill"),
+		"ip":       checkShellIP,
+		"ifconfig": maxOperands(1, ""),
+		"curl":     checkShellCurl,
+		"wget":     checkShellWget,
+		// 系统信息与用户
+		"uname": nil, "arch": nil, "lscpu": nil, "getconf": nil, "getent": nil,
+		"whoami": nil, "id": nil, "groups": nil, "w": nil, "who": nil, "last": nil, "printenv": nil,
+		"lastlog": rejectShellOpts("bctu", nil, "会修改登录记录", "-C", "--clear", "-S", "--set"),
+		"lshw":    rejectShellOpts("cC", map[string]bool{"-dump": true}, "会写文件", "-dump", "--dump"),
+		"dmidecode": rejectShellOpts("dstH", map[string]bool{"--dump-bin": true, "--from-dump": true},
+			"会写文件", "--dump-bin"),
+		"hostname": func(args []shellWord, _ int) error {
+			vals, err := literalShellArgs(args)
+			if err != nil {
+				return err
+			}
+			for _, opt := range parseShellOpts(vals, "F", nil) {
+				if opt.operand || opt.name == "-F" || opt.name == "--file" || opt.name == "-b" || opt.name == "--boot" {
+					return fmt.Errorf("会设置主机名")
+				}
+			}
+			return nil
+		},
+		"date": func(args []shellWord, _ int) error {
+			vals, err := literalShellArgs(args)
+			if err != nil {
+				return err
+			}
+			for _, opt := range parseShellOpts(vals, "dfrs", map[string]bool{"--date": true, "--file": true, "--reference": true, "--set": true}) {
+				if opt.name == "-s" || opt.name == "--set" || (opt.operand && !strings.HasPrefix(opt.name, "+")) {
+					return fmt.Errorf("会设置系统时间")
+				}
+			}
+			return nil
+		},
+		"timedatectl": subcommandOnly("HM", true, "status", "show", "list-timezones", "timesync-status", "show-timesync"),
+		// 服务与日志
+		"systemctl": subcommandOnly("tpHMsno", true, "status", "is-active", "is-enabled", "is-failed",
+			"is-system-running", "list-units", "list-unit-files", "list-timers", "list-sockets",
+			"list-dependencies", "list-jobs", "show", "cat", "get-default", "show-environment", "help"),
+		"journalctl": rejectShellOpts("nuSUpgtoDFMIcb", map[string]bool{"--unit": true, "--since": true, "--until": true,
+			"--priority": true, "--grep": true, "--identifier": true, "--output": true, "--directory": true, "--field": true},
+			"会修改日志", "--vacuum-size", "--vacuum-time", "--vacuum-files", "--rotate", "--flush", "--sync",
+			"--relinquish-var", "--smart-relinquish-var", "--setup-keys", "--update-catalog"),
+		"dmesg": rejectShellOpts("flsFn", nil, "会清空或修改内核日志", "-c", "--read-clear", "-C", "--clear",
+			"-n", "--console-level", "-D", "--console-off", "-E", "--console-on"),
+		"crontab": func(args []shellWord, _ int) error {
+			vals, err := literalShellArgs(args)
+			if err != nil {
+				return err
+			}
+			list := false
+			for _, opt := range parseShellOpts(vals, "u", nil) {
+				switch {
+				case opt.name == "-l":
+					list = true
+				case opt.operand || opt.name != "-u":
+					return fmt.Errorf("仅 crontab -l 视为只读")
+				}
+			}
+			if !list {
+				return fmt.Errorf("仅 crontab -l 视为只读")
+			}
+			return nil
+		},
+		"nginx": func(args []shellWord, _ int) error {
+			vals, err := literalShellArgs(args)
+			if err != nil {
+				return err
+			}
+			check := false
+			for _, opt := range parseShellOpts(vals, "cpgse", nil) {
+				switch opt.name {
+				case "-s":
+					return fmt.Errorf("nginx -s 会控制服务")
+				case "-c", "-e", "-g", "-p":
+					// 自定义配置、错误日志、全局指令与前缀目录会加载模块或写入任意路径
+					return fmt.Errorf("%s %s 会加载或写入指定路径，无法判断", opt.name, opt.value)
+				case "-t", "-T", "-v", "-V", "-h", "-?":
+					check = true
+				}
+			}
+			if !check {
+				return fmt.Errorf("会启动 nginx")
+			}
+			return nil
+		},
+		// 包查询（不安装）
+		"rpm":        checkShellRpm,
+		"dpkg":       checkShellDpkg,
+		"dpkg-query": nil,
+		"apt-cache":  nil,
+		"apt":        subcommandOnly("ocStT", false, "list", "show", "search", "policy", "depends", "rdepends", "showsrc", "madison"),
+		"yum":        subcommandOnly("cedRx", false, "list", "info", "search", "provides", "whatprovides", "repolist", "check-update", "deplist"),
+		"dnf":        subcommandOnly("cedRx", false, "list", "info", "search", "provides", "whatprovides", "repolist", "check-update", "repoquery"),
+		// 版本查询
+		"java": versionOnly, "python": versionOnly, "python3": versionOnly, "node": versionOnly,
+		"ruby": versionOnly, "php": versionOnly, "mysql": versionOnly, "redis-cli": versionOnly,
+		"go": func(args []shellWord, _ int) error {
+			if len(args) >= 1 && !args[0].dynamic && args[0].lit == "version" {
+				return nil
+			}
+			if len(args) >= 1 && !args[0].dynamic && args[0].lit == "env" {
+				return rejectShellOpts("", nil, "会修改 go 环境配置", "-w", "-u")(args[1:], 0)
+			}
+			return fmt.Errorf("仅 go version / go env 视为只读")
+		},
+		"docker": checkShellDocker,
+		"podman": checkShellDocker,
+		// 包装命令：检查被执行的命令
+		"timeout": func(args []shellWord, depth int) error {
+			_, rest, err := splitShellCommand(args, timeoutOpts)
+			if err != nil {
+				return err
+			}
+			if len(rest) < 2 {
+				return fmt.Errorf("无法确定被执行的命令")
+			}
+			return classifyShellArgv(rest[1:], depth)
+		},
+		"nice":    shellWrapper(niceOpts),
+		"nohup":   shellWrapper(nohupOpts),
+		"stdbuf":  shellWrapper(stdbufOpts),
+		"exec":    shellWrapper(execOpts),
+		"time":    checkShellTime,
+		"env":     checkShellEnv,
+		"command": checkShellCommandBuiltin,
+		"sudo":    checkShellSudo,
+		"eval": func(args []shellWord, depth int) error {
+			vals, err := literalShellArgs(args)
+			if err != nil {
+				return err
+			}
+			return classifyShellDepth(strings.Join(vals, " "), depth+1)
+		},
+		"bash": checkShellInterpreter, "sh": checkShellInterpreter, "dash": checkShellInterpreter, "zsh": checkShellInterpreter,
+	}
+}
+
+// 包装命令的选项表，与 GNU coreutils、sudo 1.9、findutils、bash 的 getopt 定义一致
+var (
+	shellHelpVersion = map[string]bool{"--help": false, "--version": false}
+	timeoutOpts      = shellOptSpec{flags: "v", values: "sk", long: map[string]bool{"--foreground": false,
+		"--preserve-status": false, "--verbose": false, "--signal": true, "--kill-after": true,
+		"--help": false, "--version": false}}
+	niceOpts   = shellOptSpec{values: "n", numeric: true, long: map[string]bool{"--adjustment": true, "--help": false, "--version": false}}
+	nohupOpts  = shellOptSpec{long: shellHelpVersion}
+	stdbufOpts = shellOptSpec{values: "ioe", long: map[string]bool{"--input": true, "--output": true, "--error": true,
+		"--help": false, "--version": false}}
+	execOpts = shellOptSpec{flags: "cl", values: "a"}
+	timeOpts = shellOptSpec{flags: "pavqV", values: "fo", long: map[string]bool{"--portability": false, "--append": false,
+		"--verbose": false, "--quiet": false, "--format": true, "--output": true, "--help": false, "--version": false}}
+	envOpts = shellOptSpec{flags: "i0v", values: "uCSP", long: map[string]bool{"--ignore-environment": false,
+		"--null": false, "--debug": false, "--unset": true, "--chdir": true, "--split-string": true,
+		"--block-signal": false, "--default-signal": false, "--ignore-signal": false,
+		"--list-signal-handling": false, "--help": false, "--version": false}}
+	commandOpts = shellOptSpec{flags: "pvV"}
+	sudoOpts    = shellOptSpec{flags: "AbBEeHiKklNnPSsVv", values: "aCcDgpRrTtUu", optional: "h",
+		long: map[string]bool{"--askpass": false, "--auth-type": true, "--background": false, "--bell": false,
+			"--close-from": true, "--login-class": true, "--chdir": true, "--preserve-env": false, "--edit": false,
+			"--group": true, "--set-home": false, "--help": false, "--host": true, "--login": false,
+			"--remove-timestamp": false, "--reset-timestamp": false, "--list": false, "--no-update": false,
+			"--non-interactive": false, "--preserve-groups": false, "--prompt": true, "--chroot": true,
+			"--role": true, "--stdin": false, "--shell": false, "--type": true, "--command-timeout": true,
+			"--other-user": true, "--user": true, "--version": false, "--validate": false}}
+	xargsOpts = shellOptSpec{flags: "0oprtx", values: "adEILnPs", optional: "eil",
+		long: map[string]bool{"--null": false, "--open-tty": false, "--interactive": false, "--no-run-if-empty": false,
+			"--verbose": false, "--exit": false, "--eof": false, "--replace": false, "--max-lines": false,
+			"--arg-file": true, "--delimiter": true, "--max-args": true, "--max-procs": true, "--max-chars": true,
+			"--process-slot-var": true, "--show-limits": false, "--help": false, "--version": false}}
+	interpreterOpts = shellOptSpec{flags: "abcefhiklmnprstuvxBCDEHPT", values: "oO", plus: true,
+		long: map[string]bool{"--debugger": false, "--dump-po-strings": false, "--dump-strings": false,
+			"--help": false, "--login": false, "--noediting": false, "--noprofile": false, "--norc": false,
+			"--posix": false, "--pretty-print": false, "--restricted": false, "--verbose": false, "--version": false,
+			"--init-file": true, "--rcfile": true}}
+)
+
+// shellWrapper 选项之后的参数是被执行的命令（无命令时只读）
+func shellWrapper(spec shellOptSpec) shellChecker {
+	return func(args []shellWord, depth int) error {
+		_, rest, err := splitShellCommand(args, spec)
+		if err != nil {
+			return err
+		}
+		return classifyShellArgv(rest, depth)
+	}
+}
+
+func checkShellDeclare(args []shellWord, _ int) error {
+	for _, a := range args {
+		if !a.dynamic && strings.HasPrefix(a.lit, "-") && strings.Contains(a.lit, "n") {
+			return fmt.Errorf("-n 声明名称引用，无法判断实际修改的变量")
+		}
+		if m := shellAssignmentRe.FindStringSubmatch(a.lit); m != nil && shellDangerousVars[m[1]] {
+			return fmt.Errorf("修改环境变量 %s", m[1])
+		}
+		if a.dynamic && !strings.Contains(a.lit, "=") {
+			return fmt.Errorf("变量名无法静态确定: %s", a)
+		}
+	}
+	return nil
+}
+
+// checkShellPrintf printf -v VAR 把结果赋给变量
+func checkShellPrintf(args []shellWord, _ int) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if args[0].dynamic || args[0].glob {
+		return fmt.Errorf("参数含展开，无法判断: %s", args[0]) // 展开后可能是 -v
+	}
+	switch {
+	case args[0].lit == "-v":
+		if len(args) < 2 {
+			return fmt.Errorf("-v 缺少变量名")
+		}
+		return checkShellVarName(args[1])
+	case strings.HasPrefix(args[0].lit, "-v"):
+		return checkShellVarName(shellWord{lit: args[0].lit[2:], raw: args[0].raw})
+	}
+	return nil
+}
+
+// checkShellRead read [-a 数组] [名称...] 把输入赋给变量
+func checkShellRead(args []shellWord, _ int) error {
+	opts, names, err := splitShellCommand(args, shellOptSpec{flags: "ers", values: "adinNptu"})
+	if err != nil {
+		return err
+	}
+	for _, opt := range opts {
+		if opt.name == "-a" {
+			if err := checkShellVarName(shellWord{lit: opt.value}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, n := range names {
+		if err := checkShellVarName(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkShellMapfile mapfile [选项] [数组]；-C 会对每行执行回调命令
+func checkShellMapfile(args []shellWord, _ int) error {
+	opts, names, err := splitShellCommand(args, shellOptSpec{flags: "t", values: "dnOsuCc"})
+	if err != nil {
+		return err
+	}
+	if _, ok := hasShellOpt(opts, "-C"); ok {
+		return fmt.Errorf("-C 会执行回调命令")
+	}
+	for _, n := range names {
+		if err := checkShellVarName(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkShellGetopts getopts OPTSTRING NAME [参数...]
+func checkShellGetopts(args []shellWord, _ int) error {
+	if len(args) < 2 {
+		return nil
+	}
+	return checkShellVarName(args[1])
+}
+
+func checkShellLet(args []shellWord, _ int) error {
+	for _, a := range args {
+		if err := checkShellLetExpr(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkShellUnset(args []shellWord, _ int) error {
+	_, names, err := splitShellCommand(args, shellOptSpec{flags: "fvn"})
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if err := checkShellVarName(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkShellSet set -k 会把命令后任意位置的 NAME=VALUE 作为该命令的环境变量
+func checkShellSet(args []shellWord, _ int) error {
+	for i, a := range args {
+		if a.dynamic {
+			return fmt.Errorf("参数含展开，无法判断: %s", a)
+		}
+		if a.lit == "--" || a.lit == "-" {
+			return nil
+		}
+		if !strings.HasPrefix(a.lit, "-") && !strings.HasPrefix(a.lit, "+") {
+			return nil
+		}
+		if (a.lit == "-o" || a.lit == "+o") && i+1 < len(args) && args[i+1].lit == "keyword" {
+			return fmt.Errorf("-o keyword 会把参数中的赋值传给命令")
+		}
+		if a.lit[0] == '-' && strings.Contains(a.lit, "k") {
+			return fmt.Errorf("-k 会把参数中的赋值传给命令")
+		}
+	}
+	return nil
+}
+
+func checkShellTime(args []shellWord, depth int) error {
+	opts, rest, err := splitShellCommand(args, timeOpts)
+	if err != nil {
+		return err
+	}
+	if name, ok := hasShellOpt(opts, "-o", "--output"); ok {
+		return fmt.Errorf("%s 会写输出文件", name)
+	}
+	return classifyShellArgv(rest, depth)
+}
+
+func checkShellEnv(args []shellWord, depth int) error {
+	opts, rest, err := splitShellCommand(args, envOpts)
+	if err != nil {
+		return err
+	}
+	if name, ok := hasShellOpt(opts, "-S", "--split-string", "-P"); ok {
+		return fmt.Errorf("%s 无法分析", name)
+	}
+	// classifyShellArgv 会跳过并检查 NAME=VALUE
+	return classifyShellArgv(rest, depth)
+}
+
+func checkShellCommandBuiltin(args []shellWord, depth int) error {
+	opts, rest, err := splitShellCommand(args, commandOpts)
+	if err != nil {
+		return err
+	}
+	if _, ok := hasShellOpt(opts, "-v", "-V"); ok {
+		return nil
+	}
+	return classifyShellArgv(rest, depth)
+}
+
+func checkShellSudo(args []shellWord, depth int) error {
+	opts, rest, err := splitShellCommand(args, sudoOpts)
+	if err != nil {
+		return err
+	}
+	if name, ok := hasShellOpt(opts, "-e", "--edit"); ok {
+		return fmt.Errorf("%s 会编辑文件", name)
+	}
+	if name, ok := hasShellOpt(opts, "-R", "--chroot"); ok {
+		return fmt.Errorf("%s 会在其他根目录下执行命令", name)
+	}
+	if _, ok := hasShellOpt(opts, "-l", "--list"); ok {
+		return nil // 只列出权限，不执行命令
+	}
+	if len(rest) == 0 {
+		if _, ok := hasShellOpt(opts, "-V", "--version", "-v", "--validate", "-k", "--reset-timestamp"); ok {
+			return nil
+		}
+		return fmt.Errorf("未指定命令（会打开 shell）")
+	}
+	if _, ok := hasShellOpt(opts, "-s", "--shell", "-i", "--login"); ok {
+		// 命令交给 shell 执行，按一段脚本分析
+		vals, err := literalShellArgs(rest)
+		if err != nil {
+			return err
+		}
+		return classifyShellDepth(strings.Join(vals, " "), depth+1)
+	}
+	return classifyShellArgv(rest, depth)
+}
+
+// checkShellInterpreter bash -c '...' 递归分析命令字符串，执行脚本文件或读取标准输入无法判断
+func checkShellInterpreter(args []shellWord, depth int) error {
+	opts, rest, err := splitShellCommand(args, interpreterOpts)
+	if err != nil {
+		return err
+	}
+	if name, ok := hasShellOpt(opts, "--init-file", "--rcfile"); ok {
+		return fmt.Errorf("%s 会执行指定文件，无法判断", name)
+	}
+	if _, ok := hasShellOpt(opts, "-s", "+s"); ok {
+		return fmt.Errorf("从标准输入读取命令，无法判断")
+	}
+	if _, ok := hasShellOpt(opts, "-c"); !ok {
+		if len(rest) > 0 {
+			return fmt.Errorf("执行脚本文件 %s，无法判断", rest[0])
+		}
+		return fmt.Errorf("从标准输入读取命令，无法判断")
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("-c 缺少命令")
+	}
+	if rest[0].dynamic || rest[0].glob {
+		return fmt.Errorf("命令字符串含展开，无法判断: %s", rest[0])
+	}
+	return classifyShellDepth(rest[0].lit, depth+1)
+}
+
+// sed 脚本中的 w/W（写文件）与 e（执行命令）命令及 s 命令的 w/e 标志
+var sedWriteRe = regexp.MustCompile(`(^|[;\n{}/0-9$!,])\s*[gpIiMm0-9]*[wWe](\s|$|;|})`)
+
+func checkShellSed(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	var scripts []string
+	explicit := false
+	for _, opt := range parseShellOpts(vals, "efl", map[string]bool{"--expression": true, "--file": true, "--line-length": true}) {
+		switch {
+		case opt.name == "-i" || opt.name == "--in-place":
+			return fmt.Errorf("-i 会原地修改文件")
+		case opt.name == "-f" || opt.name == "--file":
+			return fmt.Errorf("-f 脚本文件无法检查")
+		case opt.name == "-e" || opt.name == "--expression":
+			scripts = append(scripts, opt.value)
+			explicit = true
+		case opt.operand && !explicit && len(scripts) == 0:
+			scripts = append(scripts, opt.name)
+		}
+	}
+	for _, s := range scripts {
+		if sedWriteRe.MatchString(s) {
+			return fmt.Errorf("脚本包含写文件或执行命令（w/W/e）")
+		}
+	}
+	return nil
+}
+
+// awk 程序中的输出重定向（> 后接文件名，比较运算 > 数字除外）
+var awkRedirectRe = regexp.MustCompile(`>\s*[^=0-9\s]`)
+
+func checkShellAwk(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	for _, opt := range parseShellOpts(vals, "fvFilE", map[string]bool{"--file": true, "--assign": true,
+		"--field-separator": true, "--include": true, "--load": true, "--exec": true}) {
+		switch {
+		case opt.name == "-f" || opt.name == "--file" || opt.name == "-E" || opt.name == "--exec":
+			return fmt.Errorf("脚本文件无法检查")
+		case opt.name == "-i" || opt.name == "--include" || opt.name == "-l" || opt.name == "--load":
+			return fmt.Errorf("加载扩展无法检查")
+		case opt.operand:
+			prog := opt.name
+			if strings.Contains(prog, "system") || strings.Contains(strings.ReplaceAll(prog, "||", ""), "|") ||
+				awkRedirectRe.MatchString(prog) {
+				return fmt.Errorf("程序包含 system()、管道或输出重定向")
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func checkShellTee(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	for _, opt := range parseShellOpts(vals, "", nil) {
+		if opt.operand && !isShellSafeSink(opt.name) {
+			return fmt.Errorf("会写入文件 %s", opt.name)
+		}
+	}
+	return nil
+}
+
+func checkShellFind(args []shellWord, depth int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(vals); i++ {
+		switch vals[i] {
+		case "-delete":
+			return fmt.Errorf("-delete 会删除文件")
+		case "-fprint", "-fprint0", "-fprintf", "-fls":
+			return fmt.Errorf("%s 会写文件", vals[i])
+		case "-ok", "-okdir":
+			return fmt.Errorf("%s 需要交互确认", vals[i])
+		case "-exec", "-execdir":
+			end := i + 1
+			for end < len(vals) && vals[end] != ";" && vals[end] != "+" {
+				end++
+			}
+			if end == len(vals) {
+				return fmt.Errorf("%s 未结束", vals[i])
+			}
+			if err := classifyShellArgv(literalShellWords(vals[i+1:end]), depth); err != nil {
+				return fmt.Errorf("%s 执行的命令: %v", vals[i], err)
+			}
+			i = end
+		}
+	}
+	return nil
+}
+
+// checkShellXargs 被执行的命令会追加来自标准输入的参数（可能是任意选项），
+// 因此只接受任意参数都只读的命令
+func checkShellXargs(args []shellWord, depth int) error {
+	_, rest, err := splitShellCommand(args, xargsOpts)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return nil // 默认执行 echo
+	}
+	if err := classifyShellArgv(rest, depth); err != nil {
+		return fmt.Errorf("执行的命令: %v", err)
+	}
+	name := path.Base(rest[0].lit)
+	if check, ok := shellReadOnlyCommands[name]; ok && check != nil {
+		return fmt.Errorf("%s 的参数来自标准输入，无法判断", name)
+	}
+	return nil
+}
+
+func checkShellIP(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	for _, v := range vals {
+		switch v {
+		case "add", "del", "delete", "change", "replace", "set", "flush", "append", "prepend",
+			"save", "restore", "exec", "attach", "detach", "-b", "-batch", "--batch", "-force":
+			return fmt.Errorf("%s 会修改网络配置", v)
+		}
+	}
+	return nil
+}
+
+// shellSinkOK 输出目标为标准输出或 /dev/null
+func shellSinkOK(v string) bool {
+	return v == "-" || isShellSafeSink(v)
+}
+
+func checkShellCurl(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	longValue := map[string]bool{
+		"--output": true, "--upload-file": true, "--form": true, "--form-string": true, "--cookie-jar": true,
+		"--config": true, "--request": true, "--dump-header": true, "--header": true, "--user-agent": true,
+		"--referer": true, "--user": true, "--proxy": true, "--max-time": true, "--connect-timeout": true,
+		"--write-out": true, "--range": true, "--cookie": true, "--cert": true, "--key": true, "--cacert": true,
+		"--resolve": true, "--retry": true, "--limit-rate": true, "--url": true, "--trace": true,
+		"--trace-ascii": true, "--stderr": true, "--libcurl": true, "--etag-save": true, "--hsts": true,
+		"--alt-svc": true, "--output-dir": true, "--quote": true, "--json": true,
+	}
+	for _, opt := range parseShellOpts(vals, "HAeuxmwrbEUYyzCtXDoTdFKQcP", longValue) {
+		if opt.operand {
+			continue
+		}
+		switch opt.name {
+		case "-o", "-O", "--output", "--remote-name", "--remote-name-all", "--remote-header-name", "--output-dir",
+			"--create-dirs", "-T", "--upload-file", "-c", "--cookie-jar", "-K", "--config", "-J":
+			return fmt.Errorf("%s 会写文件或上传", opt.name)
+		case "-d", "-F", "--form", "--form-string", "--json", "-Q", "--quote":
+			return fmt.Errorf("%s 会提交数据", opt.name)
+		case "-X", "--request":
+			if m := strings.ToUpper(opt.value); m != "GET" && m != "HEAD" {
+				return fmt.Errorf("%s %s 可能修改远端状态", opt.name, opt.value)
+			}
+		case "-w", "--write-out":
+			if strings.Contains(opt.value, "%output{") {
+				return fmt.Errorf("%s 中的 %%output{} 会写文件", opt.name)
+			}
+		case "-D", "--dump-header", "--trace", "--trace-ascii", "--stderr", "--libcurl", "--etag-save", "--hsts", "--alt-svc":
+			if !shellSinkOK(opt.value) {
+				return fmt.Errorf("%s 会写文件 %s", opt.name, opt.value)
+			}
+		default:
+			if strings.HasPrefix(opt.name, "--data") || strings.HasPrefix(opt.name, "--upload") {
+				return fmt.Errorf("%s 会提交数据", opt.name)
+			}
+		}
+	}
+	return nil
+}
+
+func checkShellWget(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	longValue := map[string]bool{
+		"--output-document": true, "--output-file": true, "--append-output": true, "--directory-prefix": true,
+		"--execute": true, "--user-agent": true, "--tries": true, "--timeout": true, "--wait": true,
+		"--header": true, "--post-data": true, "--post-file": true, "--method": true, "--body-data": true,
+		"--body-file": true, "--user": true, "--password": true, "--input-file": true, "--level": true,
+	}
+	stdout := false
+	for _, opt := range parseShellOpts(vals, "OoaPeUtTwQiBlARDXI", longValue) {
+		switch opt.name {
+		case "--spider":
+			stdout = true
+		case "-O", "--output-document":
+			if opt.value != "-" {
+				return fmt.Errorf("会下载到文件 %s", opt.value)
+			}
+			stdout = true
+		case "-o", "--output-file", "-a", "--append-output", "-P", "--directory-prefix", "-r", "--recursive",
+			"-m", "--mirror", "-x", "--force-directories", "-e", "--execute":
+			return fmt.Errorf("%s 会写文件", opt.name)
+		case "--post-data", "--post-file", "--body-data", "--body-file":
+			return fmt.Errorf("%s 会提交数据", opt.name)
+		case "--method":
+			if m := strings.ToUpper(opt.value); m != "GET" && m != "HEAD" {
+				return fmt.Errorf("--method %s 可能修改远端状态", opt.value)
+			}
+		}
+	}
+	if !stdout {
+		return fmt.Errorf("会下载到文件（只读需 --spider 或 -O -）")
+	}
+	return nil
+}
+
+func checkShellRpm(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(vals) == 0 || !(strings.HasPrefix(vals[0], "-q") || vals[0] == "--query" ||
+		vals[0] == "-V" || vals[0] == "--verify") {
+		return fmt.Errorf("仅查询（-q）与校验（-V）视为只读")
+	}
+	for _, v := range vals[1:] {
+		switch v {
+		case "-e", "-i", "-U", "-F", "--erase", "--install", "--upgrade", "--freshen", "--import",
+			"--rebuilddb", "--initdb", "--setperms", "--setugids", "--restore":
+			return fmt.Errorf("%s 会修改软件包", v)
+		}
+	}
+	return nil
+}
+
+func checkShellDpkg(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(vals) > 0 {
+		switch vals[0] {
+		case "-l", "--list", "-L", "--listfiles", "-s", "--status", "-S", "--search", "-p", "--print-avail",
+			"--get-selections", "--print-architecture", "--compare-versions", "-c", "--contents", "-I", "--info",
+			"--audit", "-C", "--verify", "-V":
+			return nil
+		}
+	}
+	return fmt.Errorf("仅查询操作视为只读")
+}
+
+var dockerReadOnly = map[string]bool{
+	"ps": true, "images": true, "logs": true, "inspect": true, "version": true, "info": true,
+	"stats": true, "top": true, "port": true, "history": true, "diff": true,
+}
+
+func checkShellDocker(args []shellWord, _ int) error {
+	vals, err := literalShellArgs(args)
+	if err != nil {
+		return err
+	}
+	var operands []string
+	for _, opt := range parseShellOpts(vals, "Hcl", map[string]bool{"--host": true, "--context": true,
+		"--config": true, "--log-level": true}) {
+		if opt.operand {
+			operands = append(operands, opt.name)
+		}
+	}
+	if len(operands) == 0 {
+		return nil
+	}
+	switch sub := operands[0]; {
+	case dockerReadOnly[sub]:
+		return nil
+	case sub == "container" || sub == "image" || sub == "volume" || sub == "network":
+		if len(operands) > 1 && (operands[1] == "ls" || operands[1] == "list" || dockerReadOnly[operands[1]]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("子命令 %s 可能修改容器", strings.Join(operands[:min(2, len(operands))], " "))
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyShellCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		readOnly bool
+		reason   string // 需确认时原因中应包含的内容
+	}{
+		// 只读
+		{command: "ls -la /opt", readOnly: true},
+		{command: "ps aux | grep java | grep -v grep", readOnly: true},
+		{command: "cat app.log 2>/dev/null | tail -n 100 >&2", readOnly: true},
+		{command: "df -h && free -m; uptime", readOnly: true},
+		{command: "for f in $(ls /var/log); do wc -l \"$f\"; done", readOnly: true},
+		{command: "case $x in a) echo a;; *) echo b;; esac", readOnly: true},
+		{command: "grep -c ERROR <<EOF\n$(cat app.log)\nEOF", readOnly: true},
+		{command: "find . -name '*.log' -exec grep -l ERROR {} \\;", readOnly: true},
+		{command: "sudo -u app -- ls /home/app", readOnly: true},
+		{command: "sudo -t unconfined_t cat /etc/hosts", readOnly: true},
+		{command: "sudo -l", readOnly: true},
+		{command: "timeout -s KILL 5 curl -s http://127.0.0.1:8080/health", readOnly: true},
+		{command: "nice -n 10 du -sh /var", readOnly: true},
+		{command: "env -u HOME LANG=C ls", readOnly: true},
+		{command: "xargs -0 -n1 echo < list.txt", readOnly: true},
+		{command: "bash -c 'ls | wc -l'", readOnly: true},
+		{command: "curl -s -w '%{http_code}' http://127.0.0.1", readOnly: true},
+		{command: "nginx -t", readOnly: true},
+		{command: "systemctl status nginx", readOnly: true},
+
+		// 包装命令的选项参数不能被当作被执行的命令
+		{command: "sudo -t ls rm -rf /", reason: "rm 不在只读命令列表中"},
+		{command: "sudo -a ls rm", reason: "rm 不在只读命令列表中"},
+		{command: "sudo -c ls rm", reason: "rm 不在只读命令列表中"},
+		{command: "sudo -t x rm", reason: "rm 不在只读命令列表中"},
+		{command: "sudo -nt x rm -rf /tmp/x", reason: "rm 不在只读命令列表中"},
+		{command: "sudo --prompt ls rm", reason: "rm 不在只读命令列表中"},
+		{command: "sudo --unknown ls", reason: "无法识别的选项"},
+		{command: "sudo -s 'ls; rm -rf /'", reason: "rm 不在只读命令列表中"},
+		{command: "sudo -e /etc/hosts", reason: "会编辑文件"},
+		{command: "timeout -s ls 10 rm x", reason: "rm 不在只读命令列表中"},
+		{command: "env -u ls rm x", reason: "rm 不在只读命令列表中"},
+		{command: "nice -5 rm x", reason: "rm 不在只读命令列表中"},
+		{command: "stdbuf -oL rm x", reason: "rm 不在只读命令列表中"},
+		{command: "xargs -I ls rm", reason: "rm 不在只读命令列表中"},
+		{command: "bash -o posix -c 'rm -rf /'", reason: "rm 不在只读命令列表中"},
+		{command: "bash -xc 'rm -rf /'", reason: "rm 不在只读命令列表中"},
+
+		// 参数中的写操作
+		{command: "curl -s -w '%output{/tmp/x}' u", reason: "%output{}"},
+		{command: "curl -sw '%output{>>/tmp/x}' u", reason: "%output{}"},
+		{command: "curl -o /tmp/x http://example.com", reason: "会写文件"},
+		{command: "nginx -t -c /tmp/x", reason: "-c"},
+		{command: "nginx -e /tmp/x -t", reason: "-e"},
+		{command: "nginx -g 'load_module /tmp/x.so;' -t", reason: "-g"},
+		{command: "sed -i s/a/b/ f", reason: "原地修改"},
+		{command: "find / -name x -delete", reason: "-delete"},
+
+		// 语法结构
+		{command: "ls > /tmp/out", reason: "输出重定向"},
+		{command: "echo $(rm -rf /tmp/x)", reason: "rm 不在只读命令列表中"},
+		{command: "cat <(rm x)", reason: "rm 不在只读命令列表中"},
+		{command: "cat <<EOF\n$(rm x)\nEOF", reason: "rm 不在只读命令列表中"},
+		{command: "x=$(rm y) ls", reason: "rm 不在只读命令列表中"},
+		{command: "ls(){ rm -rf /; }; ls", reason: "函数定义"},
+		{command: "PATH=/tmp ls", reason: "PATH"},
+		{command: "export LD_PRELOAD=/tmp/x.so", reason: "LD_PRELOAD"},
+		{command: "$cmd -rf /", reason: "命令名无法静态确定"},
+
+		// 内建命令给变量赋值
+		{command: "printf -v PATH /tmp; ls", reason: "PATH"},
+		{command: "printf -vPATH /tmp; ls", reason: "PATH"},
+		{command: "read PATH <<< /tmp; ls", reason: "PATH"},
+		{command: "read -r LD_PRELOAD <<< /tmp/x.so; ls", reason: "LD_PRELOAD"},
+		{command: "read -a IFS <<< x", reason: "IFS"},
+		{command: "mapfile -t PATH < f", reason: "PATH"},
+		{command: "mapfile -C 'rm x' -c 1 arr < f", reason: "-C"},
+		{command: "getopts ab PATH", reason: "PATH"},
+		{command: "let PATH=1; ls", reason: "PATH"},
+		{command: "let 'PATH = 1'; ls", reason: "PATH"},
+		{command: "command let PATH=1", reason: "PATH"},
+		{command: "(( PATH += 1 )); ls", reason: "PATH"},
+		{command: "echo $(( IFS++ ))", reason: "IFS"},
+		{command: "echo ${PATH:=/tmp}", reason: "PATH"},
+		{command: "unset PATH; ls", reason: "PATH"},
+		{command: "unset -v LD_PRELOAD", reason: "LD_PRELOAD"},
+		{command: "set -k; ls PATH=/tmp", reason: "-k"},
+		{command: "set -o keyword", reason: "keyword"},
+		{command: "declare -n ref=PATH; ref=/tmp", reason: "-n"},
+		{command: "printf '%s\n' a; read -r line < f; let n=1; unset line; set -e", readOnly: true},
+		{command: "{rm,-rf,/tmp/x}", reason: "命令名无法静态确定"},
+		{command: "r\\m x", reason: "rm 不在只读命令列表中"},
+		{command: "/tmp/ls", reason: "非系统目录"},
+		{command: "ls 'unterminated", reason: "无法解析"},
+	}
+	for _, tt := range tests {
+		err := classifyShellCommand(tt.command)
+		switch {
+		case tt.readOnly && err != nil:
+			t.Errorf("%q 应为只读，实际需要确认: %v", tt.command, err)
+		case !tt.readOnly && err == nil:
+			t.Errorf("%q 应需要确认，实际判定为只读", tt.command)
+		case !tt.readOnly && !strings.Contains(err.Error(), tt.reason):
+			t.Errorf("%q 的原因应包含 %q，实际: %v", tt.command, tt.reason, err)
+		}
+	}
+}
+
+func TestSplitShellCommand(t *testing.T) {
+	tests := []struct {
+		args    string
+		command string
+		wantErr bool
+	}{
+		{args: "-u root ls", command: "ls"},
+		{args: "-uroot ls", command: "ls"},
+		{args: "-nu root ls", command: "ls"},
+		{args: "-t x rm", command: "rm"},
+		{args: "-Et x rm", command: "rm"},
+		{args: "-hhost rm", command: "rm"},
+		{args: "--user=root ls", command: "ls"},
+		{args: "--user root -- rm", command: "rm"},
+		{args: "-u", wantErr: true},
+		{args: "-Z ls", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, rest, err := splitShellCommand(literalShellWords(strings.Fields(tt.args)), sudoOpts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("sudo %s 应返回错误", tt.args)
+			}
+			continue
+		}
+		if err != nil || len(rest) == 0 || rest[0].lit != tt.command {
+			t.Errorf("sudo %s: 被执行的命令应为 %s，实际 %v（%v）", tt.args, tt.command, rest, err)
+		}
+	}
+}
+
+func TestShellSimpleCommands(t *testing.T) {
+	tests := []struct {
+		command string
+		units   []string
+	}{
+		{command: "systemctl status nginx", units: []string{"systemctl status nginx"}},
+		{command: "systemctl status x; rm -rf /", units: []string{"systemctl status x", "rm -rf /"}},
+		{command: "systemctl status $(rm -rf /)", units: []string{"systemctl status $(rm -rf /)", "rm -rf /"}},
+		{command: "systemctl status x 2>/dev/null < in", units: []string{"systemctl status x"}},
+		{command: "systemctl status x > /etc/passwd", units: []string{"systemctl status x", "> /etc/passwd"}},
+		{command: "{ systemctl status x; } 2>/tmp/e", units: []string{"2>/tmp/e", "systemctl status x"}},
+	}
+	for _, tt := range tests {
+		units, err := shellSimpleCommands(tt.command)
+		if err != nil || strings.Join(units, "\n") != strings.Join(tt.units, "\n") {
+			t.Errorf("%q: 应拆分为 %q，实际 %q（%v）", tt.command, tt.units, units, err)
+		}
+	}
+	if _, err := shellSimpleCommands("f(){ rm -rf /; }; f"); err == nil {
+		t.Errorf("函数定义应返回错误")
+	}
+}
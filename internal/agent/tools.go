@@ -334,10 +334,17 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 		args = map[string]interface{}{}
 	}
 
-	switch name {
-	case "get_status":
-		return e.getStatus()
-	case "get_logs":
+	tool, ok := defaultRegistry.Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("未知工具: %s", name)
+	}
+	return tool.Execute(e, args)
+}
+
+// builtinHandlers 内置工具（AllTools、FleetTools）的执行函数，启动时按名称注册
+var builtinHandlers = map[string]ToolHandler{
+	"get_status": func(e *ToolExecutor, args map[string]interface{}) (string, error) { return e.getStatus() },
+	"get_logs": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		logName, _ := args["log_name"].(string)
 		keyword, _ := args["keyword"].(string)
 		lines := 200
@@ -348,11 +355,10 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 			}
 		}
 		return e.getLogs(logName, keyword, lines)
-	case "get_config":
-		return e.getConfig()
-	case "get_system_info":
-		return e.getSystemInfo()
-	case "read_file":
+	},
+	"get_config":      func(e *ToolExecutor, args map[string]interface{}) (string, error) { return e.getConfig() },
+	"get_system_info": func(e *ToolExecutor, args map[string]interface{}) (string, error) { return e.getSystemInfo() },
+	"read_file": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		path, _ := args["path"].(string)
 		maxLines := 200
 		if v, ok := args["max_lines"].(float64); ok && v > 0 {
@@ -362,11 +368,13 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 			}
 		}
 		return e.readFile(path, maxLines)
-	case "list_directory":
+	},
+	"list_directory": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		path, _ := args["path"].(string)
 		showHidden, _ := args["show_hidden"].(bool)
 		return e.listDirectory(path, showHidden)
-	case "systemd_info":
+	},
+	"systemd_info": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		action, _ := args["action"].(string)
 		svc, _ := args["service"].(string)
 		lines := 50
@@ -374,24 +382,29 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 			lines = int(v)
 		}
 		return e.systemdInfo(action, svc, lines)
-	case "service_control":
+	},
+	"service_control": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		action, _ := args["action"].(string)
 		return e.serviceControl(action)
-	case "switch_env":
+	},
+	"switch_env": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		env, _ := args["env"].(string)
 		return e.switchEnv(env)
-	case "update_jvm":
+	},
+	"update_jvm": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		preset := 0
 		if v, ok := args["preset"].(float64); ok {
 			preset = int(v)
 		}
 		return e.updateJVM(preset)
-	case "write_file":
+	},
+	"write_file": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		path, _ := args["path"].(string)
 		content, _ := args["content"].(string)
 		appendMode, _ := args["append"].(bool)
 		return e.writeFile(path, content, appendMode)
-	case "delete_file":
+	},
+	"delete_file": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		// 支持单路径（path）或批量路径（paths 数组）
 		var paths []string
 		if v, ok := args["paths"].([]interface{}); ok {
@@ -405,7 +418,8 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 			paths = append(paths, singlePath)
 		}
 		return e.deleteFiles(paths)
-	case "install_package":
+	},
+	"install_package": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		var packages []string
 		if v, ok := args["packages"].([]interface{}); ok {
 			for _, p := range v {
@@ -419,11 +433,13 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 			updateFirst = v
 		}
 		return e.installPackage(packages, updateFirst)
-	case "manage_systemd":
+	},
+	"manage_systemd": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		action, _ := args["action"].(string)
 		svc, _ := args["service"].(string)
 		return e.manageSystemd(action, svc)
-	case "run_shell":
+	},
+	"run_shell": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		command, _ := args["command"].(string)
 		workdir, _ := args["workdir"].(string)
 		timeout := 60
@@ -434,13 +450,50 @@ func (e *ToolExecutor) Execute(name, argsJSON string) (string, error) {
 			}
 		}
 		return e.runShell(command, workdir, time.Duration(timeout)*time.Second)
-	case "configure_service":
+	},
+	"configure_service": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
 		serviceID, _ := args["service_id"].(string)
 		scriptPath, _ := args["script_path"].(string)
 		projectType, _ := args["project_type"].(string)
 		return e.configureService(serviceID, scriptPath, projectType)
-	default:
-		return "", fmt.Errorf("未知工具: %s", name)
+	},
+	"spoke_list": func(e *ToolExecutor, args map[string]interface{}) (string, error) {
+		selector, _ := args["selector"].(string)
+		return e.spokeList(selector)
+	},
+	"spoke_status": fleetHandler("spoke_status"),
+	"spoke_logs":   fleetHandler("spoke_logs"),
+	"spoke_run":    fleetHandler("spoke_run"),
+}
+
+// fleetHandler 集群工具共用的执行函数：参数转换为 Spoke 控制操作
+func fleetHandler(name string) ToolHandler {
+	return func(e *ToolExecutor, args map[string]interface{}) (string, error) {
+		spokes, _ := args["spokes"].(string)
+		service, _ := args["service"].(string)
+		op := strings.TrimPrefix(name, "spoke_")
+		params := map[string]string{}
+		if name == "spoke_logs" {
+			if v, ok := args["lines"].(float64); ok && v > 0 {
+				params["lines"] = fmt.Sprintf("%d", int(v))
+			}
+			if v, _ := args["keyword"].(string); v != "" {
+				params["keyword"] = v
+			}
+		}
+		if name == "spoke_run" {
+			op, _ = args["op"].(string)
+			if v, _ := args["env"].(string); v != "" {
+				params["env"] = v
+			}
+			if v, _ := args["artifact"].(string); v != "" {
+				params["name"] = v
+			}
+			if v, _ := args["version"].(string); v != "" {
+				params["version"] = v
+			}
+		}
+		return e.fleetRun(spokes, op, service, params)
 	}
 }
 
@@ -910,6 +963,25 @@ func (e *ToolExecutor) serviceControl(action string) (string, error) {
 	return runWithTimeout(cmd, 120*time.Second)
 }
 
+// ScriptCommand 构造当前服务控制脚本的命令（已注入服务环境变量），供需要流式输出的调用方自行执行
+func (e *ToolExecutor) ScriptCommand(args ...string) (*exec.Cmd, error) {
+	scriptPath, err := e.resolveScriptPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("读取配置失败: %v", err)
+	}
+	svc := cfg.GetService(e.execCtx.CurrentService)
+	if svc == nil {
+		return nil, fmt.Errorf("未找到服务配置: %s", e.execCtx.CurrentService)
+	}
+	cmd := exec.Command("bash", append([]string{scriptPath}, args...)...)
+	cmd.Env = buildScriptEnv(e.execCtx, svc)
+	return cmd, nil
+}
+
 func (e *ToolExecutor) switchEnv(env string) (string, error) {
 	if env != "blue" && env != "green" {
 		return "", fmt.Errorf("无效环境: %s", env)
@@ -1178,7 +1250,10 @@ func (e *ToolExecutor) runShell(command, workdir string, timeout time.Duration)
 		return "", fmt.Errorf("请提供命令")
 	}
 
-	cmd := exec.Command("bash", "-c", command)
+	cmd, sandbox, err := e.shellCommand(command)
+	if err != nil {
+		return "", err
+	}
 
 	if workdir != "" {
 		workdir = expandHome(workdir)
@@ -1188,10 +1263,11 @@ func (e *ToolExecutor) runShell(command, workdir string, timeout time.Duration)
 	}
 
 	out, err := runWithTimeout(cmd, timeout)
+	notice := sandbox.blockedNotice(out, err)
 	if err != nil {
-		return fmt.Sprintf("命令输出:\n%s\n✗ %v", out, err), nil
+		return fmt.Sprintf("命令输出:\n%s\n✗ %v%s", out, err, notice), nil
 	}
-	return out, nil
+	return out + notice, nil
 }
 
 func (e *ToolExecutor) configureService(serviceID, scriptPath, projectType string) (string, error) {
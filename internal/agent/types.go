@@ -8,6 +8,7 @@ type Message struct {
 	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`        // role=assistant 且 AI 要调工具时非空
 	ToolCallID       string     `json:"tool_call_id,omitempty"`      // role=tool 时填，对应 ToolCall.ID
 	Name             string     `json:"name,omitempty"`              // role=tool 时填工具名
+	Summary          bool       `json:"summary,omitempty"`           // 上下文压缩生成的早期对话摘要（user+assistant 一对）
 }
 
 // ToolCall AI 请求调用的单个工具
@@ -22,6 +23,13 @@ type ChatResponse struct {
 	Content          string
 	ReasoningContent string
 	ToolCalls        []ToolCall
+	Usage            *Usage // 提供商上报的用量，未上报时为 nil
+}
+
+// Usage 提供商上报的 token 用量
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`  // 本次请求的 prompt tokens（含缓存命中部分）
+	OutputTokens int `json:"output_tokens"` // 本次生成的 tokens
 }
 
 // StreamEvent 流式输出事件
@@ -31,6 +39,7 @@ type StreamEvent struct {
 	ReasoningContent string     // Type=="done" 时有值，携带本轮累积的推理内容
 	ToolCalls        []ToolCall // Type=="tool_calls" 时有值
 	Err              error      // Type=="error" 时有值
+	Usage            *Usage     // Type=="done" 时可能有值（提供商上报的用量）
 }
 
 // ToolDef 工具定义（发给 LLM 的 schema）
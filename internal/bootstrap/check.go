@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"ruoyi-proxy/internal/agent"
+	"ruoyi-proxy/internal/hub"
 )
 
 // CheckItem 单项自检结果
@@ -93,6 +94,20 @@ func RunHubChecks() []CheckItem {
 
 func checkHubAIConfig() CheckItem {
 	item := CheckItem{Name: "hub:ai"}
+	if settings, _ := hub.LoadHubSettings(); len(settings.Providers) > 0 {
+		pool := hub.ProviderStatus()
+		if len(pool) == 0 {
+			item.Detail = "hub.providers 中无有效提供商"
+			return item
+		}
+		names := make([]string, 0, len(pool))
+		for _, p := range pool {
+			names = append(names, p.Name)
+		}
+		item.OK = true
+		item.Detail = fmt.Sprintf("提供商池: %s", strings.Join(names, ", "))
+		return item
+	}
 	aiCfg, err := agent.LoadAIConfig()
 	if err != nil || !aiCfg.IsConfigured() || aiCfg.Provider == "hub" {
 		item.Detail = "未配置有效 AI（请 /agent-config）"
@@ -0,0 +1,154 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/discovery"
+	"ruoyi-proxy/internal/hub"
+)
+
+// DiscoverEnvironment 自动发现本机运行环境（额外解析本工具管理的 nginx 配置）
+func DiscoverEnvironment(ctx context.Context) hub.SpokeDiscovery {
+	return discovery.Run(ctx, LoadAppPaths().NginxConf)
+}
+
+// fillProfileFromDiscovery 用发现结果补全档案中未填写的字段（不覆盖运维手工设置的值）
+func fillProfileFromDiscovery(profile *hub.SpokeProfile, d hub.SpokeDiscovery) {
+	if profile.ProjectType == "" {
+		profile.ProjectType = discovery.InferProjectType(d)
+	}
+	if profile.ProjectName == "" {
+		profile.ProjectName = discovery.InferProjectName(d)
+	}
+	if profile.Domain == "" || profile.Domain == "example.com" {
+		if domain := discovery.InferDomain(d); domain != "" {
+			profile.Domain = domain
+		}
+	}
+	if profile.Description == "" {
+		profile.Description = discovery.Summarize(d)
+	}
+}
+
+// RefreshSpokeProfile 重新发现运行环境并与基线比较；环境或漂移有变化时保存并同步到 Hub。
+// 返回最新档案与是否发生了变化
+func RefreshSpokeProfile(ctx context.Context) (hub.SpokeProfile, bool, error) {
+	profile, err := loadLocalSpokeProfile()
+	if err != nil {
+		return profile, false, fmt.Errorf("读取本机 Spoke 档案失败: %v", err)
+	}
+	d := DiscoverEnvironment(ctx)
+
+	baseline, ok := discovery.LoadBaseline()
+	if !ok {
+		// 旧版本建档时没有基线，以首次发现结果为准
+		if err := discovery.SaveBaseline(d); err != nil {
+			return profile, false, fmt.Errorf("保存发现基线失败: %v", err)
+		}
+		baseline = &d
+	}
+	drift := discovery.Drift(baseline, d)
+
+	changed := profile.Discovery == nil ||
+		discovery.Fingerprint(*profile.Discovery) != discovery.Fingerprint(d) ||
+		!slices.Equal(profile.Drift, drift)
+	profile.Discovery = &d
+	profile.Drift = drift
+	fillProfileFromDiscovery(&profile, d)
+	if err := saveLocalSpokeProfile(profile); err != nil {
+		return profile, changed, err
+	}
+	if changed {
+		if err := SyncProfileToHub(profile); err != nil {
+			return profile, changed, err
+		}
+	}
+	return profile, changed, nil
+}
+
+// AcceptDiscoveryBaseline 将当前发现结果确认为新基线，清空漂移并同步到 Hub
+func AcceptDiscoveryBaseline(profile hub.SpokeProfile) error {
+	if profile.Discovery == nil {
+		return fmt.Errorf("档案中没有发现结果")
+	}
+	// 说明仍是旧基线的自动摘要时一并更新，手工填写的说明保持不变
+	if old, ok := discovery.LoadBaseline(); ok && profile.Description == discovery.Summarize(*old) {
+		profile.Description = discovery.Summarize(*profile.Discovery)
+	}
+	if err := discovery.SaveBaseline(*profile.Discovery); err != nil {
+		return fmt.Errorf("保存发现基线失败: %v", err)
+	}
+	profile.Drift = nil
+	if err := saveLocalSpokeProfile(profile); err != nil {
+		return err
+	}
+	if err := SyncProfileToHub(profile); err != nil {
+		return fmt.Errorf("基线已保存，同步 Hub 失败: %v", err)
+	}
+	return nil
+}
+
+// FormatDiscovery 格式化发现结果（spoke 引导、/spoke-discover 与 Hub 端详情共用）
+func FormatDiscovery(d hub.SpokeDiscovery) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\033[1;34m═══ 运行环境发现 ═══\033[0m\n")
+	for _, p := range d.Processes {
+		ports := ""
+		if len(p.Ports) > 0 {
+			ports = fmt.Sprintf(" 端口 %v", p.Ports)
+		}
+		fmt.Fprintf(&b, "  %-14s %s (pid %d)%s\n", p.Runtime+":", p.Name, p.PID, ports)
+	}
+	if len(d.Ports) > 0 {
+		items := make([]string, 0, len(d.Ports))
+		for _, p := range d.Ports {
+			item := strconv.Itoa(p.Port)
+			if p.Process != "" {
+				item += "/" + p.Process
+			}
+			items = append(items, item)
+		}
+		fmt.Fprintf(&b, "  %-14s %s\n", "监听端口:", strings.Join(items, " "))
+	}
+	for _, u := range d.Units {
+		fmt.Fprintf(&b, "  %-14s %s %s\n", "systemd:", u.Name, u.Description)
+	}
+	for _, s := range d.Sites {
+		names := strings.Join(s.ServerNames, ",")
+		if names == "" {
+			names = "(无 server_name)"
+		}
+		detail := ""
+		if len(s.ProxyPass) > 0 {
+			detail = " → " + strings.Join(s.ProxyPass, ",")
+		} else if s.Root != "" {
+			detail = " root " + s.Root
+		}
+		fmt.Fprintf(&b, "  %-14s %s [%s]%s\n", "nginx:", names, strings.Join(s.Listen, ","), detail)
+	}
+	for _, c := range d.Containers {
+		fmt.Fprintf(&b, "  %-14s %s (%s, %s)\n", "docker:", c.Name, c.Image, c.State)
+	}
+	if len(d.Processes)+len(d.Ports)+len(d.Units)+len(d.Sites)+len(d.Containers) == 0 {
+		b.WriteString("  未发现应用进程、监听端口、nginx 站点或容器\n")
+	}
+	for _, e := range d.Errors {
+		fmt.Fprintf(&b, "  \033[1;33m未能采集 %s\033[0m\n", e)
+	}
+	return b.String()
+}
+
+func saveLocalSpokeProfile(profile hub.SpokeProfile) error {
+	profile.UpdatedAt = time.Now()
+	raw, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return SaveSpokeProfile(raw)
+}
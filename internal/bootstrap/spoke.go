@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"ruoyi-proxy/internal/agent"
 	"ruoyi-proxy/internal/buildinfo"
 	"ruoyi-proxy/internal/config"
+	"ruoyi-proxy/internal/discovery"
 	"ruoyi-proxy/internal/hub"
 )
 
@@ -73,32 +75,41 @@ func runSpokeOnboarding(io *CLIO) error {
 	}
 
 	io.Print("\n\033[1;34m═══ Spoke 首次配置 ═══\033[0m")
-	io.Print("请简要描述本服务器，信息将保存到本地并同步到 Hub 统一管理。\n")
+	io.Print("正在自动发现本机运行环境（进程、端口、systemd、nginx、docker）...")
 
-	detected := DetectProjectType()
-	if detected != "" {
-		io.Print(fmt.Sprintf("\033[1;36m检测到项目类型: %s\033[0m", detected))
-	}
+	found := DiscoverEnvironment(context.Background())
+	io.Print(FormatDiscovery(found))
 
-	label, _ := io.Ask("\033[1;33m服务器用途/别名\033[0m (如: 生产-订单服务): ")
+	label, _ := io.Ask(fmt.Sprintf("\033[1;33m服务器用途/别名\033[0m [默认 %s]: ", Hostname()))
 	label = strings.TrimSpace(label)
 	if label == "" {
 		label = Hostname()
 	}
 
-	projectName, _ := io.Ask("\033[1;33m项目名称\033[0m (如: ruoyi-admin): ")
-	projectName = strings.TrimSpace(projectName)
-
-	typeHint := detected
-	if typeHint == "" {
-		typeHint = "java"
+	// 仅在无法自动识别时询问项目类型
+	projectType := discovery.InferProjectType(found)
+	if projectType == "" {
+		projectType = DetectProjectType()
+	}
+	if projectType == "" {
+		io.Print("\n未能自动识别项目类型: 1=java  2=node  3=python  4=docker  5=go  6=其他")
+		typeChoice, _ := io.Ask("\033[1;33m项目类型\033[0m [默认 java]: ")
+		projectType = mapProjectType(strings.TrimSpace(typeChoice), "java")
 	}
-	io.Print("\n项目类型: 1=java  2=node  3=python  4=docker  5=go  6=其他")
-	typeChoice, _ := io.Ask(fmt.Sprintf("\033[1;33m项目类型\033[0m [默认 %s，直接回车确认]: ", typeHint))
-	projectType := mapProjectType(strings.TrimSpace(typeChoice), typeHint)
 
-	desc, _ := io.Ask("\033[1;33m简要说明\033[0m (可选): ")
-	desc = strings.TrimSpace(desc)
+	var tags map[string]string
+	for {
+		tagInput, _ := io.Ask("\033[1;33m标签\033[0m (可选，如 env=prod,project=erp,region=sh): ")
+		parsed, err := hub.ParseTags([]string{tagInput})
+		if err != nil {
+			io.Print("\033[1;31m" + err.Error() + "\033[0m")
+			continue
+		}
+		if len(parsed) > 0 {
+			tags = parsed
+		}
+		break
+	}
 
 	paths := LoadAppPaths()
 	appHome, _ := os.Getwd()
@@ -106,14 +117,18 @@ func runSpokeOnboarding(io *CLIO) error {
 	profile := hub.SpokeProfile{
 		Hostname:    Hostname(),
 		Label:       label,
-		ProjectName: projectName,
 		ProjectType: projectType,
-		Description: desc,
 		Domain:      paths.Domain,
 		AppHome:     appHome,
+		Tags:        tags,
+		Discovery:   &found,
 		UpdatedAt:   time.Now(),
 	}
 	profile.Services = collectServiceRefs()
+	fillProfileFromDiscovery(&profile, found)
+	if err := discovery.SaveBaseline(found); err != nil {
+		io.Print("\033[1;33m保存发现基线失败: " + err.Error() + "\033[0m")
+	}
 
 	if err := applyProfileLocally(&profile); err != nil {
 		return fmt.Errorf("写入本地配置: %w", err)
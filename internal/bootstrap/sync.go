@@ -1,7 +1,7 @@
 package bootstrap
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,15 +24,7 @@ func SyncProfileToHub(profile hub.SpokeProfile) error {
 	if err != nil {
 		return err
 	}
-	url := strings.TrimRight(aiCfg.BaseURL, "/") + "/__hub__/v1/profile"
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+aiCfg.APIKey)
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := agent.SendHubRequest(context.Background(), aiCfg, http.MethodPost, "/__hub__/v1/profile", body, 30*time.Second)
 	if err != nil {
 		return fmt.Errorf("同步请求失败: %v", err)
 	}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ruoyi-proxy/internal/hub"
+	"ruoyi-proxy/internal/spoke"
+)
+
+// handleHubArtifacts /hub-artifacts [name] — 列出 Hub 制品库
+func (c *CLI) handleHubArtifacts(args []string) {
+	u := mgmtBaseURL() + "/hub/artifacts"
+	if len(args) > 0 {
+		u += "?name=" + url.QueryEscape(args[0])
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		c.printError(fmt.Sprintf("请求失败（代理是否在运行？）: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.printError(fmt.Sprintf("查询失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+		return
+	}
+	var out struct {
+		Artifacts []hub.Artifact `json:"artifacts"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		c.printError(fmt.Sprintf("解析响应失败: %v", err))
+		return
+	}
+	fmt.Printf("\n\033[1;34mHub 制品库 (%d)\033[0m\n", len(out.Artifacts))
+	if len(out.Artifacts) == 0 {
+		fmt.Println("  暂无制品，使用 /hub-artifact-upload <文件> 上传")
+		fmt.Println()
+		return
+	}
+	for _, a := range out.Artifacts {
+		fmt.Printf("  \033[1;36m%s\033[0m@%s  %s  %s  %s\n", a.Name, a.Version, a.FileName,
+			formatBytes(a.Size), a.UploadedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("      sha256: %s\n", a.SHA256)
+	}
+	fmt.Println()
+}
+
+// handleHubArtifactUpload /hub-artifact-upload <文件> [name=] [version=]
+func (c *CLI) handleHubArtifactUpload(args []string) {
+	if len(args) == 0 {
+		c.printError("用法: /hub-artifact-upload <文件> [name=<制品名>] [version=<版本>]")
+		c.printInfo("制品名默认取文件名（去扩展名），版本默认为上传时间")
+		return
+	}
+	path := args[0]
+	base := filepath.Base(path)
+	q := url.Values{}
+	q.Set("name", strings.TrimSuffix(base, filepath.Ext(base)))
+	q.Set("filename", base)
+	for _, arg := range args[1:] {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok || (k != "name" && k != "version") {
+			c.printError("参数格式应为 name=<制品名> 或 version=<版本>: " + arg)
+			return
+		}
+		q.Set(k, v)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		c.printError(fmt.Sprintf("打开文件失败: %v", err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		c.printError(fmt.Sprintf("读取文件信息失败: %v", err))
+		return
+	}
+	c.printInfo(fmt.Sprintf("正在上传 %s（%s）...", base, formatBytes(info.Size())))
+	req, err := http.NewRequest(http.MethodPost, mgmtBaseURL()+"/hub/artifacts?"+q.Encode(), f)
+	if err != nil {
+		c.printError(err.Error())
+		return
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.printError(fmt.Sprintf("上传失败（代理是否在运行？）: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.printError(fmt.Sprintf("上传失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+		return
+	}
+	var art hub.Artifact
+	if err := json.Unmarshal(data, &art); err != nil {
+		c.printError(fmt.Sprintf("解析响应失败: %v", err))
+		return
+	}
+	c.printSuccess(fmt.Sprintf("已上传 %s@%s  sha256: %s", art.Name, art.Version, art.SHA256))
+	c.printInfo(fmt.Sprintf("下发到 Spoke: /hub-run <id,...|all> pull-artifact name=%s version=%s", art.Name, art.Version))
+}
+
+// handleHubArtifactDelete /hub-artifact-delete <name> <version>
+func (c *CLI) handleHubArtifactDelete(args []string) {
+	if len(args) < 2 {
+		c.printError("用法: /hub-artifact-delete <制品名> <版本>")
+		return
+	}
+	if !c.confirmDangerAction(fmt.Sprintf("删除制品 %s@%s", args[0], args[1]), nil) {
+		return
+	}
+	q := url.Values{"name": {args[0]}, "version": {args[1]}}
+	req, _ := http.NewRequest(http.MethodDelete, mgmtBaseURL()+"/hub/artifacts?"+q.Encode(), nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.printError(fmt.Sprintf("请求失败（代理是否在运行？）: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.printError(fmt.Sprintf("删除失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+		return
+	}
+	c.printSuccess(fmt.Sprintf("已删除 %s@%s", args[0], args[1]))
+}
+
+// handleArtifactPull /artifact-pull <name> [version] — (Spoke) 从 Hub 拉取制品到当前服务的 APP_HOME
+func (c *CLI) handleArtifactPull(args []string) {
+	if len(args) == 0 {
+		c.printError("用法: /artifact-pull <制品名> [版本]（省略版本时拉取最新版本）")
+		return
+	}
+	version := ""
+	if len(args) > 1 {
+		version = args[1]
+	}
+	c.printInfo(fmt.Sprintf("正在从 Hub 拉取 %s（服务 %s）...", args[0], c.currentService))
+	path, err := spoke.PullArtifact(context.Background(), c.currentService, args[0], version)
+	if err != nil {
+		c.printError(fmt.Sprintf("拉取失败: %v", err))
+		return
+	}
+	c.printSuccess("制品已保存并通过 SHA-256 校验: " + path)
+	c.printInfo("执行 /deploy 或 /restart 使新版本生效")
+}
+
+// formatBytes 格式化字节数
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,15 +22,18 @@ import (
 	"ruoyi-proxy/internal/bootstrap"
 	"ruoyi-proxy/internal/buildinfo"
 	"ruoyi-proxy/internal/config"
+	"ruoyi-proxy/internal/hub"
+	"ruoyi-proxy/internal/spoke"
 )
 
 // CLI 交互式命令行界面
 type CLI struct {
 	rl             *readline.Instance
 	running        bool
-	proxyPID       int    // 保存代理进程的PID
-	currentService string // 当前操作的服务ID
-	agentCancel    func() // Agent 取消函数，用于 Ctrl+C 中断 ReAct 循环
+	proxyPID       int               // 保存代理进程的PID
+	currentService string            // 当前操作的服务ID
+	agentCancel    func()            // Agent 取消函数，用于 Ctrl+C 中断 ReAct 循环
+	control        *spoke.Controller // Spoke 远程命令通道（未连接 Hub 时为 nil）
 }
 
 // New 创建CLI实例
@@ -99,6 +103,21 @@ func (c *CLI) Start() {
 		readline.PcItem("hub-status"),
 		readline.PcItem("hub-spoke"),
 		readline.PcItem("hub-revoke"),
+		readline.PcItem("hub-tag"),
+		readline.PcItem("hub-providers"),
+		readline.PcItem("hub-audit"),
+		readline.PcItem("hub-export"),
+		readline.PcItem("hub-import"),
+		readline.PcItem("hub-run"),
+		readline.PcItem("hub-command"),
+		readline.PcItem("hub-artifacts"),
+		readline.PcItem("hub-artifact-upload"),
+		readline.PcItem("hub-artifact-delete"),
+		readline.PcItem("artifact-pull"),
+		readline.PcItem("control-pending"),
+		readline.PcItem("control-approve"),
+		readline.PcItem("control-reject"),
+		readline.PcItem("spoke-discover"),
 		readline.PcItem("self-check"),
 		readline.PcItem("fix-nginx-hub"),
 		readline.PcItem("/help"),
@@ -118,11 +137,30 @@ func (c *CLI) Start() {
 		readline.PcItem("/hub-enable"),
 		readline.PcItem("/hub-disable"),
 		readline.PcItem("/hub-revoke"),
+		readline.PcItem("/hub-tag"),
+		readline.PcItem("/hub-providers"),
+		readline.PcItem("/hub-audit"),
+		readline.PcItem("/hub-export"),
+		readline.PcItem("/hub-import"),
+		readline.PcItem("/hub-run"),
+		readline.PcItem("/hub-command"),
+		readline.PcItem("/hub-artifacts"),
+		readline.PcItem("/hub-artifact-upload"),
+		readline.PcItem("/hub-artifact-delete"),
+		readline.PcItem("/artifact-pull"),
+		readline.PcItem("/spoke-discover"),
+		readline.PcItem("/control-pending"),
+		readline.PcItem("/control-approve"),
+		readline.PcItem("/control-reject"),
 		readline.PcItem("/self-check"),
 		readline.PcItem("/fix-nginx-hub"),
 		readline.PcItem("/sessions"),
 		readline.PcItem("/load"),
 		readline.PcItem("/new"),
+		readline.PcItem("/mcp"),
+		readline.PcItem("/tools"),
+		readline.PcItem("/permissions"),
+		readline.PcItem("/undo"),
 		readline.PcItem("/exit"),
 	)
 
@@ -184,6 +222,21 @@ func (c *CLI) readLineWithPrompt(prompt string) (string, error) {
 	return strings.TrimSpace(line), nil
 }
 
+// readPasswordWithPrompt 读取不回显的口令
+func (c *CLI) readPasswordWithPrompt(prompt string) (string, error) {
+	if c.rl == nil {
+		return "", fmt.Errorf("当前模式不支持输入口令")
+	}
+	pass, err := c.rl.ReadPassword(prompt)
+	if err != nil {
+		if err == readline.ErrInterrupt {
+			return "", io.EOF
+		}
+		return "", err
+	}
+	return string(pass), nil
+}
+
 func (c *CLI) setMainPrompt() {
 	if c.rl == nil {
 		return
@@ -462,7 +515,16 @@ func (c *CLI) printHelp() {
 	fmt.Println("  \033[1;33mAI 与 Hub:\033[0m")
 	fmt.Println("    /agent-config   - 配置 AI 提供商")
 	fmt.Println("    /hub-enable     /hub-disable  - Hub 网关开关（需重启代理）")
-	fmt.Println("    /hub-token      /hub-status [id]   /hub-spoke <id>   /hub-revoke <id>")
+	fmt.Println("    /hub-token      /hub-status [id|选择器]   /hub-spoke <id>   /hub-revoke <id>")
+	fmt.Println("    /hub-tag <id|选择器> k=v [-k]  - 设置/删除 Spoke 标签（选择器如 env=prod,project=erp）")
+	fmt.Println("    /hub-providers  - 上游提供商池与故障转移状态")
+	fmt.Println("    /hub-audit [id] [since=24h] [until=] [tool=] [limit=]  - 查询审计日志")
+	fmt.Println("    /hub-export <文件>   /hub-import <文件> [--with-config]  - 加密导出/导入 Hub 状态")
+	fmt.Println("    /hub-run <id,...|all> <操作> [k=v]  - 向 Spoke 下发远程命令   /hub-command [id]")
+	fmt.Println("    /hub-artifacts [名称]   /hub-artifact-upload <文件> [name=] [version=]   /hub-artifact-delete <名称> <版本>")
+	fmt.Println("    /artifact-pull <名称> [版本]  - (Spoke) 从 Hub 拉取制品到 APP_HOME 并校验 SHA-256")
+	fmt.Println("    /control-pending   /control-approve <id>   /control-reject <id>  - (Spoke) 确认远程命令")
+	fmt.Println("    /spoke-discover - (Spoke) 重新发现运行环境，查看并确认漂移")
 	fmt.Println()
 	fmt.Println("  \033[1;33m其他:\033[0m")
 	fmt.Println("    /commands       - 显示此列表")
@@ -642,11 +704,11 @@ func (c *CLI) handleCommand(input string) {
 		c.handleHubToken()
 
 	case "hub-status":
-		if len(args) > 0 {
+		if len(args) > 0 && strings.HasPrefix(args[0], "spoke-") && !hub.LooksLikeSelector(args[0]) {
 			c.handleHubSpoke(args[0])
 			return
 		}
-		c.handleHubStatus()
+		c.handleHubStatus(strings.Join(args, ","))
 
 	case "hub-spoke":
 		if len(args) == 0 {
@@ -662,6 +724,51 @@ func (c *CLI) handleCommand(input string) {
 		}
 		c.handleHubRevoke(args[0])
 
+	case "hub-tag":
+		c.handleHubTag(args)
+
+	case "hub-providers":
+		c.handleHubProviders()
+
+	case "hub-audit":
+		c.handleHubAudit(args)
+
+	case "hub-export":
+		c.handleHubExport(args)
+
+	case "hub-import":
+		c.handleHubImport(args)
+
+	case "hub-run":
+		c.handleHubRun(args)
+
+	case "hub-command":
+		c.handleHubCommand(args)
+
+	case "hub-artifacts":
+		c.handleHubArtifacts(args)
+
+	case "hub-artifact-upload":
+		c.handleHubArtifactUpload(args)
+
+	case "hub-artifact-delete":
+		c.handleHubArtifactDelete(args)
+
+	case "artifact-pull":
+		c.handleArtifactPull(args)
+
+	case "control-pending":
+		c.handleControlPending()
+
+	case "control-approve":
+		c.handleControlDecision(args, true)
+
+	case "control-reject":
+		c.handleControlDecision(args, false)
+
+	case "spoke-discover":
+		c.handleSpokeDiscover()
+
 	case "agent-config":
 		c.AgentConfig()
 
@@ -2414,6 +2521,39 @@ func (c *CLI) runSelfCheck() {
 	bootstrap.RunSelfCheck(io)
 }
 
+// handleSpokeDiscover 重新发现本机运行环境并同步档案；有漂移时询问是否确认为新基线
+func (c *CLI) handleSpokeDiscover() {
+	c.printInfo("正在发现本机运行环境...")
+	profile, changed, err := bootstrap.RefreshSpokeProfile(context.Background())
+	if profile.Discovery == nil {
+		c.printError(err.Error())
+		return
+	}
+	fmt.Print(bootstrap.FormatDiscovery(*profile.Discovery))
+	if err != nil {
+		c.printWarning("同步 Hub 失败: " + err.Error())
+	} else if changed {
+		c.printSuccess("档案已更新并同步到 Hub")
+	}
+	if len(profile.Drift) == 0 {
+		c.printSuccess("与基线一致，无环境漂移")
+		return
+	}
+	fmt.Println("\n\033[1;33m与基线相比的变化:\033[0m")
+	for _, line := range profile.Drift {
+		fmt.Printf("  - %s\n", line)
+	}
+	confirm, _ := c.readLineWithPrompt("\033[1;33m确认以上变化并设为新基线? (y/n): \033[0m")
+	if confirm != "y" && confirm != "Y" && confirm != "yes" {
+		return
+	}
+	if err := bootstrap.AcceptDiscoveryBaseline(profile); err != nil {
+		c.printError(err.Error())
+		return
+	}
+	c.printSuccess("已设为新基线，Hub 端漂移标记已清除")
+}
+
 // extractPort 从URL中提取端口号
 func extractPort(target string) string {
 	// 格式：http://127.0.0.1:8080
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,15 +10,19 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"ruoyi-proxy/internal/agent"
+	"ruoyi-proxy/internal/bootstrap"
 	"ruoyi-proxy/internal/buildinfo"
 	"ruoyi-proxy/internal/config"
 	"ruoyi-proxy/internal/hub"
+	"ruoyi-proxy/internal/spoke"
 )
 
 // ServiceStatus 服务状态
@@ -661,20 +667,6 @@ func (c *CLI) RunAgentPrimary() {
 
 	execCtx := agent.BuildExecContext(c.currentService)
 
-	confirm := func(_ string) bool {
-		line, err := c.readLineWithPrompt("")
-		if err != nil {
-			return false
-		}
-		s := strings.ToLower(strings.TrimSpace(line))
-		if s == "" || s == "y" || s == "yes" || s == "ok" ||
-			s == "确认" || s == "同意" || s == "好" || s == "好的" ||
-			s == "可以" || s == "是" || s == "是的" {
-			return true
-		}
-		return false
-	}
-
 	var agentRef *agent.Agent
 	readInput := func(prompt string) (string, error) {
 		line, err := c.readLineWithPrompt(prompt)
@@ -695,7 +687,7 @@ func (c *CLI) RunAgentPrimary() {
 		fmt.Println(s)
 	}
 
-	a, err := agent.New(aiCfg, execCtx, confirm, readInput, print)
+	a, err := agent.New(aiCfg, execCtx, readInput, print)
 	if err != nil {
 		fmt.Printf("\033[1;31m✗ 创建 Agent 失败: %v\033[0m\n", err)
 		return
@@ -710,6 +702,12 @@ func (c *CLI) RunAgentPrimary() {
 	c.agentCancel = a.Cancel
 	defer func() { c.agentCancel = nil }()
 
+	// 已连接 Hub 时开启心跳与远程命令通道
+	stopHeartbeat := spoke.StartHeartbeat()
+	defer stopHeartbeat()
+	c.control = spoke.Start(true, c.printAsync)
+	defer c.control.Stop()
+
 	a.Run()
 	c.running = false
 }
@@ -721,6 +719,10 @@ func (c *CLI) buildSlashMenuItems() []agent.SlashCommandItem {
 		{Command: "/load", Description: "加载历史会话"},
 		{Command: "/new", Description: "新建会话"},
 		{Command: "/current", Description: "当前会话信息"},
+		{Command: "/mcp", Description: "MCP 服务器与工具"},
+		{Command: "/tools", Description: "可用工具与自定义工具"},
+		{Command: "/permissions", Description: "权限规则与批准记录"},
+		{Command: "/undo", Description: "撤销最近的写操作"},
 		{Command: "/help", Description: "查看命令说明"},
 		{Command: "/commands", Description: "运维命令列表"},
 		{Command: "/start", Description: "启动服务"},
@@ -746,6 +748,21 @@ func (c *CLI) buildSlashMenuItems() []agent.SlashCommandItem {
 		{Command: "/hub-enable", Description: "启用 Hub 网关"},
 		{Command: "/hub-disable", Description: "禁用 Hub 网关"},
 		{Command: "/hub-revoke", Description: "吊销 Spoke"},
+		{Command: "/hub-tag", Description: "设置 Spoke 标签"},
+		{Command: "/hub-providers", Description: "Hub 上游提供商状态"},
+		{Command: "/hub-audit", Description: "查询 Hub 审计日志"},
+		{Command: "/hub-export", Description: "加密导出 Hub 状态"},
+		{Command: "/hub-import", Description: "导入 Hub 状态"},
+		{Command: "/hub-run", Description: "向 Spoke 下发远程命令"},
+		{Command: "/hub-command", Description: "查看远程命令结果"},
+		{Command: "/hub-artifacts", Description: "Hub 制品库列表"},
+		{Command: "/hub-artifact-upload", Description: "上传制品到 Hub"},
+		{Command: "/hub-artifact-delete", Description: "删除 Hub 制品版本"},
+		{Command: "/artifact-pull", Description: "从 Hub 拉取制品"},
+		{Command: "/control-pending", Description: "待确认的 Hub 远程命令"},
+		{Command: "/control-approve", Description: "确认执行 Hub 远程命令"},
+		{Command: "/control-reject", Description: "拒绝 Hub 远程命令"},
+		{Command: "/spoke-discover", Description: "重新发现本机运行环境"},
 		{Command: "/self-check", Description: "运行环境自检"},
 		{Command: "/fix-nginx-hub", Description: "让 AI 修复 Nginx Hub 路由"},
 		{Command: "/init", Description: "环境初始化"},
@@ -763,8 +780,10 @@ var knownOpsCommands = map[string]bool{
 	"quick-deploy": true, "config": true, "config-edit": true,
 	"service-add": true, "service-list": true, "service-remove": true, "service-switch": true,
 	"jvm-config": true, "agent-config": true, "commands": true, "cls": true,
-	"hub-enable": true, "hub-disable": true, "hub-token": true, "hub-status": true, "hub-spoke": true, "hub-revoke": true,
-	"self-check": true, "fix-nginx-hub": true,
+	"hub-enable": true, "hub-disable": true, "hub-token": true, "hub-status": true, "hub-spoke": true, "hub-revoke": true, "hub-tag": true, "hub-providers": true, "hub-audit": true, "hub-export": true, "hub-import": true,
+	"hub-run": true, "hub-command": true, "hub-artifacts": true, "hub-artifact-upload": true, "hub-artifact-delete": true, "artifact-pull": true,
+	"control-pending": true, "control-approve": true, "control-reject": true,
+	"spoke-discover": true, "self-check": true, "fix-nginx-hub": true,
 }
 
 // dispatchOpsCommand 处理 Agent 模式下的 /运维命令
@@ -859,20 +878,55 @@ func (c *CLI) AgentConfig(a ...*agent.Agent) {
 			c.printInfo(fmt.Sprintf("使用已预置 Hub 地址: %s", hubURL))
 		}
 		aiCfg.BaseURL = strings.TrimRight(hubURL, "/")
-		c.printInfo("正在向 Hub 申请注册 Token...")
-		regToken, err := agent.RequestHubRegisterToken(aiCfg.BaseURL)
-		if err != nil {
-			c.printError(fmt.Sprintf("申请注册 Token 失败: %v", err))
+		regToken, err := c.readLineWithPrompt("一次性注册 Token（在 Hub 上运行 /hub-token 获取）: ")
+		if err != nil || strings.TrimSpace(regToken) == "" {
+			c.printError("注册 Token 不能为空")
 			return
 		}
-		secret, spokeID, err := agent.RegisterWithHub(aiCfg.BaseURL, regToken)
+		reg, err := agent.RegisterWithHub(aiCfg.BaseURL, regToken)
 		if err != nil {
 			c.printError(fmt.Sprintf("Hub 注册失败: %v", err))
 			return
 		}
-		aiCfg.APIKey = secret
-		aiCfg.Model = "hub-relay"
-		fmt.Printf("\033[1;32m✓ Hub 注册成功，Spoke ID: %s\033[0m\n", spokeID)
+		aiCfg.APIKey = reg.Token
+		aiCfg.HubAuth = reg.Auth
+		aiCfg.Model = agent.HubRelayModel
+		fmt.Printf("\033[1;32m✓ Hub 注册成功，Spoke ID: %s\033[0m\n", reg.SpokeID)
+		if reg.Auth == agent.HubAuthHMAC {
+			c.printInfo("已启用请求签名（凭证不再随请求发送）")
+		}
+		standbyPrompt := "备用 Hub 地址 (多个用逗号分隔，留空跳过): "
+		if len(aiCfg.HubURLs) > 0 {
+			standbyPrompt = fmt.Sprintf("备用 Hub 地址 (当前: %s, 留空保持, - 清空): ", strings.Join(aiCfg.HubURLs, ","))
+		}
+		if input, err := c.readLineWithPrompt(standbyPrompt); err == nil {
+			switch input = strings.TrimSpace(input); input {
+			case "":
+			case "-":
+				aiCfg.HubURLs = nil
+			default:
+				aiCfg.HubURLs = nil
+				for _, u := range strings.Split(input, ",") {
+					if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+						aiCfg.HubURLs = append(aiCfg.HubURLs, u)
+					}
+				}
+			}
+		}
+		if models, err := agent.FetchHubModels(context.Background(), aiCfg); err == nil && len(models.Models) > 0 {
+			def := models.Default
+			if def == "" {
+				def = "由 Hub 决定"
+			}
+			input, err := c.readLineWithPrompt(fmt.Sprintf("模型别名 (可选: %s，留空使用默认 %s): ", strings.Join(models.Models, ", "), def))
+			if err == nil && strings.TrimSpace(input) != "" {
+				if !slices.Contains(models.Models, strings.TrimSpace(input)) {
+					c.printWarning(fmt.Sprintf("Hub 未开放模型 %s，已使用默认", strings.TrimSpace(input)))
+				} else {
+					aiCfg.Model = strings.TrimSpace(input)
+				}
+			}
+		}
 	} else if provider != "ollama" {
 		apiKey, err := c.readLineWithPrompt(fmt.Sprintf("API Key (当前: %s): ", aiCfg.MaskedKey()))
 		if err != nil {
@@ -1031,18 +1085,40 @@ func (c *CLI) printHubToken(token string) {
 	c.printInfo("在 spoke 服务器运行 /agent-config，选择 hub 并填入此 Token")
 }
 
-func (c *CLI) handleHubStatus() {
+// handleHubStatus 列出 spoke，selector 非空时按标签选择器过滤
+func (c *CLI) handleHubStatus(selector string) {
 	var out struct {
-		Count  int               `json:"count"`
-		Spokes []hub.SpokeRecord `json:"spokes"`
+		Count       int                  `json:"count"`
+		Spokes      []hub.SpokeRecord    `json:"spokes"`
+		Replication hub.ReplicationState `json:"replication"`
+		Security    hub.SecurityStatus   `json:"security"`
 	}
 
-	resp, err := http.Get(mgmtBaseURL() + "/hub/status")
+	resp, err := http.Get(mgmtBaseURL() + "/hub/status?selector=" + url.QueryEscape(selector))
+	if err == nil && resp.StatusCode == http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.printError(strings.TrimSpace(string(body)))
+		return
+	}
 	if err == nil && resp.StatusCode == http.StatusOK {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
 		if json.Unmarshal(body, &out) == nil {
+			if rep := out.Replication; rep.Role == hub.RoleStandby {
+				line := fmt.Sprintf("备用 Hub，主 Hub: %s，上次同步: ", rep.Primary)
+				if rep.LastSync.IsZero() {
+					line += "尚未同步"
+				} else {
+					line += rep.LastSync.Format("2006-01-02 15:04:05")
+				}
+				c.printInfo(line)
+				if rep.LastError != "" {
+					c.printWarning("同步失败: " + rep.LastError)
+				}
+			}
 			c.printHubStatusList(out.Count, out.Spokes)
+			printHubSecurity(out.Security)
 			return
 		}
 	}
@@ -1055,10 +1131,49 @@ func (c *CLI) handleHubStatus() {
 		c.printError(fmt.Sprintf("查询失败: %v", err))
 		return
 	}
-	spokes := hub.ListSpokes()
+	spokes, err := hub.SelectSpokes(selector)
+	if err != nil {
+		c.printError(err.Error())
+		return
+	}
 	c.printHubStatusList(len(spokes), spokes)
 }
 
+// printHubSecurity 公开端点的锁定来源与最近安全事件（无事件时不显示）
+func printHubSecurity(sec hub.SecurityStatus) {
+	if len(sec.Locked) == 0 && len(sec.Recent) == 0 {
+		return
+	}
+	fmt.Println("\033[1;34m安全事件\033[0m")
+	if len(sec.Counts) > 0 {
+		keys := make([]string, 0, len(sec.Counts))
+		for k := range sec.Counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%d", k, sec.Counts[k]))
+		}
+		fmt.Printf("  累计: %s\n", strings.Join(parts, "  "))
+	}
+	for _, l := range sec.Locked {
+		fmt.Printf("  \033[1;31m已锁定\033[0m %s  至 %s（第 %d 次锁定）\n", l.IP, l.Until.Format("15:04:05"), l.Lockouts)
+	}
+	recent := sec.Recent
+	if len(recent) > 5 {
+		recent = recent[:5]
+	}
+	for _, ev := range recent {
+		who := ev.IP
+		if ev.SpokeID != "" {
+			who += " " + ev.SpokeID
+		}
+		fmt.Printf("  %s  %-14s %s  %s  %s\n", ev.Time.Format("01-02 15:04:05"), ev.Type, who, ev.Endpoint, ev.Detail)
+	}
+	fmt.Println()
+}
+
 func (c *CLI) handleHubSpoke(spokeID string) {
 	spokeID = strings.TrimSpace(spokeID)
 	if spokeID == "" {
@@ -1073,6 +1188,7 @@ func (c *CLI) handleHubSpoke(spokeID string) {
 		var item hub.SpokeRecord
 		if json.Unmarshal(body, &item) == nil {
 			c.printHubSpokeDetail(item)
+			c.printHubSpokePolicy(spokeID)
 			return
 		}
 	}
@@ -1091,6 +1207,45 @@ func (c *CLI) handleHubSpoke(spokeID string) {
 		return
 	}
 	c.printHubSpokeDetail(item)
+	c.printHubSpokePolicy(spokeID)
+}
+
+// printHubSpokePolicy 显示下发给该 spoke 的策略（hub.policies 合并结果）
+func (c *CLI) printHubSpokePolicy(spokeID string) {
+	var policy agent.HubPolicy
+	resp, err := http.Get(mgmtBaseURL() + "/hub/policy?spoke=" + url.QueryEscape(spokeID))
+	if err == nil && resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(&policy)
+	} else {
+		settings, _ := hub.LoadHubSettings()
+		if err = hub.LoadSpokes(); err == nil {
+			policy, err = hub.SpokePolicy(settings, spokeID)
+		}
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		c.printWarning(fmt.Sprintf("策略计算失败: %v", err))
+		return
+	}
+	if policy.PromptAppend == "" && len(policy.DisabledTools) == 0 && len(policy.ForbiddenShell) == 0 && !policy.DisableTurnApproval {
+		return
+	}
+	fmt.Printf("  \033[1;33mHub 策略\033[0m (版本 %s)\n", policy.Version)
+	if len(policy.DisabledTools) > 0 {
+		fmt.Printf("    禁用工具: %s\n", strings.Join(policy.DisabledTools, ", "))
+	}
+	if len(policy.ForbiddenShell) > 0 {
+		fmt.Printf("    禁止命令: %s\n", strings.Join(policy.ForbiddenShell, "  "))
+	}
+	if policy.DisableTurnApproval {
+		fmt.Println("    写操作: 每次单独确认")
+	}
+	if policy.PromptAppend != "" {
+		fmt.Printf("    追加提示词: %s\n", truncateRunes(strings.ReplaceAll(policy.PromptAppend, "\n", " "), 80))
+	}
+	fmt.Println()
 }
 
 // runFixNginxHub 提示用户直接让 AI 修复 Nginx Hub 路由
@@ -1103,15 +1258,60 @@ func (c *CLI) runFixNginxHub(a *agent.Agent) {
 	c.printInfo("AI 会读取配置、删除旧块、插入 location ^~ /__hub__/ 并验证 reload")
 }
 
+// spokeStateLabel 在线状态的彩色标签
+func spokeStateLabel(state string) string {
+	switch state {
+	case hub.StateOnline:
+		return "\033[1;32m在线\033[0m"
+	case hub.StateStale:
+		return "\033[1;33m失联\033[0m"
+	case hub.StateRevoked:
+		return "\033[90m已吊销\033[0m"
+	default:
+		return "\033[1;31m离线\033[0m"
+	}
+}
+
+// formatSpokeHealth 心跳健康信息的单行摘要
+func formatSpokeHealth(h *hub.SpokeHealth) string {
+	if h == nil {
+		return ""
+	}
+	parts := []string{"代理: 未运行"}
+	if h.ProxyRunning {
+		parts[0] = "代理: 运行中"
+	}
+	for _, svc := range h.Services {
+		mark := "✓"
+		if !svc.Up {
+			mark = "✗"
+		}
+		parts = append(parts, fmt.Sprintf("%s(%s)%s", svc.ID, svc.ActiveEnv, mark))
+	}
+	if h.DiskUsedPercent > 0 {
+		parts = append(parts, fmt.Sprintf("磁盘: %.0f%% (剩余 %.1fG)", h.DiskUsedPercent, h.DiskFreeGB))
+	}
+	if h.MemUsedPercent > 0 {
+		parts = append(parts, fmt.Sprintf("内存: %.0f%%", h.MemUsedPercent))
+	}
+	if h.Load1 > 0 {
+		parts = append(parts, fmt.Sprintf("负载: %.2f", h.Load1))
+	}
+	return strings.Join(parts, "  ")
+}
+
 func (c *CLI) printHubStatusList(count int, spokes []hub.SpokeRecord) {
+	sort.Slice(spokes, func(i, j int) bool { return spokes[i].ID < spokes[j].ID })
 	fmt.Printf("\n\033[1;34m已注册 Spoke (%d)\033[0m\n", count)
 	for _, s := range spokes {
-		status := "活跃"
-		if s.Revoked {
-			status = "已吊销"
-		}
 		fmt.Printf("  \033[1;36m%s\033[0m  [%s]  创建: %s  最近: %s\n",
-			s.ID, status, s.CreatedAt.Format("2006-01-02 15:04"), s.LastSeen.Format("2006-01-02 15:04"))
+			s.ID, spokeStateLabel(s.State), s.CreatedAt.Format("2006-01-02 15:04"), s.LastSeen.Format("2006-01-02 15:04"))
+		if health := formatSpokeHealth(s.Health); health != "" && s.State != hub.StateRevoked {
+			fmt.Printf("      %s\n", health)
+		}
+		if tags := s.EffectiveTags(); len(tags) > 0 {
+			fmt.Printf("      标签: %s\n", hub.FormatTags(tags))
+		}
 		if s.Profile != nil {
 			p := s.Profile
 			label := p.Label
@@ -1119,6 +1319,9 @@ func (c *CLI) printHubStatusList(count int, spokes []hub.SpokeRecord) {
 				label = p.Hostname
 			}
 			fmt.Printf("      用途: %s  项目: %s (%s)\n", label, p.ProjectName, p.ProjectType)
+			if len(p.Drift) > 0 {
+				fmt.Printf("      \033[1;33m环境漂移: %d 项变化\033[0m\n", len(p.Drift))
+			}
 			if p.Description != "" {
 				fmt.Printf("      说明: %s\n", p.Description)
 			}
@@ -1141,14 +1344,23 @@ func (c *CLI) printHubStatusList(count int, spokes []hub.SpokeRecord) {
 }
 
 func (c *CLI) printHubSpokeDetail(s hub.SpokeRecord) {
-	status := "活跃"
-	if s.Revoked {
-		status = "已吊销"
-	}
 	fmt.Printf("\n\033[1;34mSpoke 详情: %s\033[0m\n", s.ID)
-	fmt.Printf("  状态: %s\n", status)
+	fmt.Printf("  状态: %s\n", spokeStateLabel(s.State))
 	fmt.Printf("  创建: %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Printf("  最近: %s\n", s.LastSeen.Format("2006-01-02 15:04:05"))
+	if !s.LastHeartbeat.IsZero() {
+		fmt.Printf("  心跳: %s\n", s.LastHeartbeat.Format("2006-01-02 15:04:05"))
+	}
+	if health := formatSpokeHealth(s.Health); health != "" {
+		fmt.Printf("  健康: %s\n", health)
+	}
+	if tags := s.EffectiveTags(); len(tags) > 0 {
+		fmt.Printf("  标签: %s", hub.FormatTags(tags))
+		if len(s.Tags) > 0 {
+			fmt.Printf("  (Hub 指定: %s)", hub.FormatTags(s.Tags))
+		}
+		fmt.Println()
+	}
 	if s.Profile == nil {
 		fmt.Println("  档案: 未上报（请在 Spoke 端重新运行 /agent-config 或重启 CLI 触发引导）")
 		fmt.Println()
@@ -1182,9 +1394,69 @@ func (c *CLI) printHubSpokeDetail(s hub.SpokeRecord) {
 			fmt.Printf("    - %s  %s  %s  %s\n", svc.ID, svc.Name, svc.ProjectType, svc.ActiveEnv)
 		}
 	}
+	if len(p.Drift) > 0 {
+		fmt.Println("  \033[1;33m环境漂移（需在 Spoke 端 /spoke-discover 确认）:\033[0m")
+		for _, line := range p.Drift {
+			fmt.Printf("    - %s\n", line)
+		}
+	}
+	if p.Discovery != nil {
+		fmt.Print(bootstrap.FormatDiscovery(*p.Discovery))
+		fmt.Printf("  发现时间: %s\n", p.Discovery.At.Format("2006-01-02 15:04:05"))
+	}
 	fmt.Println()
 }
 
+// handleHubTag /hub-tag <spoke-id|选择器> k=v ... [-k ...]
+func (c *CLI) handleHubTag(args []string) {
+	if len(args) < 2 {
+		c.printError("用法: /hub-tag <spoke-id[,spoke-id]|all|选择器> key=value ... [-key ...]")
+		c.printInfo("示例: /hub-tag spoke-abc12345 env=prod project=erp    /hub-tag env=test -canary")
+		return
+	}
+	var set []string
+	var remove []string
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			remove = append(remove, strings.TrimPrefix(arg, "-"))
+			continue
+		}
+		set = append(set, arg)
+	}
+	tags, err := hub.ParseTags(set)
+	if err != nil {
+		c.printError(err.Error())
+		return
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"spokes": []string{args[0]},
+		"set":    tags,
+		"remove": remove,
+	})
+	resp, err := http.Post(mgmtBaseURL()+"/hub/tags", "application/json", bytes.NewReader(body))
+	if err != nil {
+		c.printError(fmt.Sprintf("请求失败（代理是否在运行？）: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.printError(fmt.Sprintf("设置失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+		return
+	}
+	var out struct {
+		Spokes []hub.SpokeRecord `json:"spokes"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		c.printError(fmt.Sprintf("解析响应失败: %v", err))
+		return
+	}
+	c.printSuccess(fmt.Sprintf("已更新 %d 个 Spoke 的标签", len(out.Spokes)))
+	for _, s := range out.Spokes {
+		fmt.Printf("  \033[1;36m%s\033[0m  %s\n", s.ID, hub.FormatTags(s.EffectiveTags()))
+	}
+}
+
 func (c *CLI) handleHubRevoke(spokeID string) {
 	if !c.confirmDangerAction(fmt.Sprintf("吊销 Spoke: %s", spokeID), []string{"吊销后该节点将无法再通过 Hub 调用 AI"}) {
 		return
@@ -1207,3 +1479,278 @@ func (c *CLI) handleHubRevoke(spokeID string) {
 	}
 	c.printSuccess(fmt.Sprintf("Spoke[%s] 已吊销", spokeID))
 }
+
+func (c *CLI) handleHubProviders() {
+	var out struct {
+		Providers []hub.ProviderHealth `json:"providers"`
+		Routing   hub.HubRouting       `json:"routing"`
+	}
+
+	live := false
+	resp, err := http.Get(mgmtBaseURL() + "/hub/providers")
+	if err == nil && resp.StatusCode == http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		live = json.Unmarshal(body, &out) == nil
+	} else if resp != nil {
+		resp.Body.Close()
+	}
+	if !live {
+		// 回退：读本地配置（无运行时健康数据）
+		settings, _ := hub.LoadHubSettings()
+		out.Providers = hub.ProviderStatus()
+		out.Routing = settings.Routing
+	}
+
+	fmt.Printf("\n\033[1;34mHub 提供商池 (%d)\033[0m\n", len(out.Providers))
+	if len(out.Providers) == 0 {
+		fmt.Println("  未配置有效提供商（hub.providers 或 ai 字段）")
+		fmt.Println()
+		return
+	}
+	now := time.Now()
+	for _, p := range out.Providers {
+		state := "可用"
+		if now.Before(p.CooldownUntil) {
+			state = fmt.Sprintf("冷却中（%s 恢复）", p.CooldownUntil.Format("15:04:05"))
+		}
+		fmt.Printf("  \033[1;36m%s\033[0m  %s / %s  [%s]  成功: %d  失败: %d\n",
+			p.Name, p.Provider, p.Model, state, p.Successes, p.Failures)
+		if p.LastError != "" {
+			fmt.Printf("      最近错误: %s (%s)\n", p.LastError, p.LastFailure.Format("2006-01-02 15:04:05"))
+		}
+	}
+	r := out.Routing
+	if r.Default != "" {
+		fmt.Printf("  默认: %s\n", r.Default)
+	}
+	if len(r.Fallback) > 0 {
+		fmt.Printf("  故障转移: %s\n", strings.Join(r.Fallback, " → "))
+	}
+	for alias, name := range r.Models {
+		fmt.Printf("  模型别名: %s → %s\n", alias, name)
+	}
+	for spokeID, name := range r.Spokes {
+		fmt.Printf("  Spoke: %s → %s\n", spokeID, name)
+	}
+	for _, policy := range r.ModelPolicies {
+		allow := "不限"
+		if len(policy.Allow) > 0 {
+			allow = strings.Join(policy.Allow, ", ")
+		}
+		def := policy.Default
+		if def == "" {
+			def = "-"
+		}
+		fmt.Printf("  模型策略: [%s] 可用: %s  默认: %s\n", policy.Selector, allow, def)
+	}
+	if !live {
+		fmt.Println("  （代理未运行，仅显示本地配置）")
+	}
+	fmt.Println()
+}
+
+// handleHubAudit /hub-audit [spoke-id] [since=24h] [until=2006-01-02] [tool=execute_shell] [limit=20]
+func (c *CLI) handleHubAudit(args []string) {
+	params := url.Values{}
+	for _, arg := range args {
+		if k, v, ok := strings.Cut(arg, "="); ok {
+			switch k {
+			case "spoke", "since", "until", "tool", "limit":
+				params.Set(k, v)
+			default:
+				c.printError("未知参数: " + k + "（可用: spoke, since, until, tool, limit）")
+				return
+			}
+			continue
+		}
+		params.Set("spoke", arg)
+	}
+	if params.Get("limit") == "" {
+		params.Set("limit", "20")
+	}
+
+	var out struct {
+		Count   int              `json:"count"`
+		Entries []hub.AuditEntry `json:"entries"`
+	}
+	resp, err := http.Get(mgmtBaseURL() + "/hub/audit?" + params.Encode())
+	if err == nil && resp.StatusCode == http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &out) == nil {
+			c.printHubAudit(out.Entries)
+			return
+		}
+	}
+	if resp != nil {
+		if resp.StatusCode == http.StatusBadRequest {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			c.printError(strings.TrimSpace(string(body)))
+			return
+		}
+		resp.Body.Close()
+	}
+
+	// 回退：直接读本地审计日志
+	q := hub.AuditQuery{Spoke: params.Get("spoke"), Tool: params.Get("tool")}
+	if q.Since, err = hub.ParseAuditTime(params.Get("since")); err != nil {
+		c.printError(err.Error())
+		return
+	}
+	if q.Until, err = hub.ParseAuditTime(params.Get("until")); err != nil {
+		c.printError(err.Error())
+		return
+	}
+	q.Limit, _ = strconv.Atoi(params.Get("limit"))
+	entries, err := hub.QueryAudit(q)
+	if err != nil {
+		c.printError(fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	c.printHubAudit(entries)
+}
+
+// handleHubExport /hub-export <文件> — 导出 spoke 注册表与 app_config.json（口令加密）
+func (c *CLI) handleHubExport(args []string) {
+	if len(args) == 0 {
+		c.printError("用法: /hub-export <文件>，例如: /hub-export hub-backup.json")
+		return
+	}
+	path := args[0]
+	if _, err := os.Stat(path); err == nil {
+		if !c.confirmDangerAction(fmt.Sprintf("覆盖已有文件: %s", path), nil) {
+			return
+		}
+	}
+	pass, err := c.readPasswordWithPrompt("设置导出口令 (至少 8 位): ")
+	if err != nil {
+		return
+	}
+	again, err := c.readPasswordWithPrompt("再次输入口令: ")
+	if err != nil {
+		return
+	}
+	if pass != again {
+		c.printError("两次输入的口令不一致")
+		return
+	}
+	if err := hub.LoadSpokes(); err != nil {
+		c.printError(fmt.Sprintf("加载 spoke 注册表失败: %v", err))
+		return
+	}
+	data, summary, err := hub.ExportState(pass)
+	if err != nil {
+		c.printError(fmt.Sprintf("导出失败: %v", err))
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		c.printError(fmt.Sprintf("写入文件失败: %v", err))
+		return
+	}
+	c.printSuccess(fmt.Sprintf("已导出到 %s：%d 个 Spoke（已吊销 %d），含 app_config.json: %v", path, summary.Spokes, summary.Revoked, summary.HasConfig))
+	c.printInfo("导出文件包含提供商 API Key 等敏感配置，请妥善保管口令与文件")
+}
+
+// handleHubImport /hub-import <文件> [--with-config] — 覆盖本机 spoke 注册表，可选覆盖 app_config.json
+func (c *CLI) handleHubImport(args []string) {
+	var path string
+	withConfig := false
+	for _, arg := range args {
+		if arg == "--with-config" {
+			withConfig = true
+		} else if path == "" {
+			path = arg
+		}
+	}
+	if path == "" {
+		c.printError("用法: /hub-import <文件> [--with-config]")
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.printError(fmt.Sprintf("读取文件失败: %v", err))
+		return
+	}
+	warnings := []string{"本机 spoke 注册表将被导入内容覆盖"}
+	if withConfig {
+		warnings = append(warnings, "configs/app_config.json 将被覆盖（需重启代理生效）")
+	}
+	if !c.confirmDangerAction(fmt.Sprintf("导入 Hub 状态: %s", path), warnings) {
+		return
+	}
+	pass, err := c.readPasswordWithPrompt("导出口令: ")
+	if err != nil {
+		return
+	}
+	if err := hub.LoadSpokes(); err != nil {
+		c.printError(fmt.Sprintf("加载 spoke 注册表失败: %v", err))
+		return
+	}
+	summary, err := hub.ImportState(data, pass, withConfig)
+	if err != nil {
+		c.printError(fmt.Sprintf("导入失败: %v", err))
+		return
+	}
+	c.printSuccess(fmt.Sprintf("已导入 %d 个 Spoke（已吊销 %d），导出时间 %s", summary.Spokes, summary.Revoked, summary.ExportedAt.Format("2006-01-02 15:04:05")))
+	if withConfig && !summary.HasConfig {
+		c.printWarning("导出文件中不包含 app_config.json，配置未变更")
+	}
+
+	if c.isProxyRunning() {
+		resp, err := http.Post(mgmtBaseURL()+"/hub/reload", "application/json", nil)
+		if err != nil || resp.StatusCode >= 400 {
+			c.printWarning("通知运行中的代理重新加载失败，请执行 /restart")
+		} else {
+			c.printInfo("运行中的代理已重新加载 spoke 注册表")
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if withConfig && summary.HasConfig {
+			c.printInfo("app_config.json 已更新，执行 /restart 后生效")
+		}
+	}
+}
+
+func (c *CLI) printHubAudit(entries []hub.AuditEntry) {
+	fmt.Printf("\n\033[1;34mHub 审计日志 (%d)\033[0m\n", len(entries))
+	for _, e := range entries {
+		provider := e.Provider
+		if provider == "" {
+			provider = "-"
+		}
+		usage := ""
+		if e.Usage != nil {
+			usage = fmt.Sprintf("  tokens: %d/%d", e.Usage.InputTokens, e.Usage.OutputTokens)
+		}
+		fmt.Printf("  \033[1;36m%s\033[0m  %s  提供商: %s  耗时: %dms%s\n",
+			e.Time.Format("2006-01-02 15:04:05"), e.Spoke, provider, e.DurationMs, usage)
+		for _, m := range e.Request {
+			if m.Role == "user" {
+				fmt.Printf("      用户: %s\n", truncateRunes(m.Content, 120))
+			}
+		}
+		if e.Error != "" {
+			fmt.Printf("      \033[31m错误: %s\033[0m\n", truncateRunes(e.Error, 120))
+		}
+		if e.Response != "" {
+			fmt.Printf("      回复: %s\n", truncateRunes(e.Response, 120))
+		}
+		for _, tc := range e.ToolCalls {
+			fmt.Printf("      工具: %s %s\n", tc.Name, truncateRunes(tc.Arguments, 120))
+		}
+	}
+	fmt.Println()
+}
+
+// truncateRunes 单行显示：换行替换为空格，超出 max 个字符截断
+func truncateRunes(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max]) + "…"
+}
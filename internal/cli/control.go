@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/hub"
+)
+
+const hubRunFollowTimeout = 20 * time.Minute
+
+// printAsync 后台 goroutine 输出（不打断当前输入行）
+func (c *CLI) printAsync(s string) {
+	if c.rl != nil {
+		c.rl.Write([]byte(s + "\n"))
+		return
+	}
+	fmt.Println(s)
+}
+
+// handleHubRun /hub-run <spoke,...|all> <op> [service=<id>] [k=v ...]
+func (c *CLI) handleHubRun(args []string) {
+	if len(args) < 2 {
+		c.printError("用法: /hub-run <spoke-id[,spoke-id]|all> <操作> [service=<服务ID>] [参数=值 ...]")
+		c.printInfo("可用操作: status logs proxy-status start stop restart deploy deploy-lowmem switch pull-artifact")
+		c.printInfo("示例: /hub-run all status    /hub-run spoke-abc12345 switch env=green")
+		return
+	}
+	req := map[string]interface{}{
+		"spokes": []string{args[0]},
+		"op":     args[1],
+	}
+	params := map[string]string{}
+	for _, arg := range args[2:] {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			c.printError("参数格式应为 key=value: " + arg)
+			return
+		}
+		if k == "service" {
+			req["service"] = v
+			continue
+		}
+		params[k] = v
+	}
+	if len(params) > 0 {
+		req["args"] = params
+	}
+	if _, ok := hub.ControlOps[args[1]]; !ok {
+		c.printError("不支持的操作: " + args[1])
+		return
+	}
+	if hub.IsWriteOp(args[1]) {
+		if !c.confirmDangerAction(fmt.Sprintf("向 %s 下发写操作: %s", args[0], args[1]),
+			[]string{"命令将在目标 Spoke 上执行（Spoke 可按本机策略拒绝或要求本地确认）"}) {
+			return
+		}
+	}
+
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(mgmtBaseURL()+"/hub/dispatch", "application/json", bytes.NewReader(body))
+	if err != nil {
+		c.printError(fmt.Sprintf("请求失败（代理是否在运行？）: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		c.printError(fmt.Sprintf("下发失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+		return
+	}
+	var out struct {
+		Commands []hub.ControlCommand `json:"commands"`
+		Errors   []string             `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		c.printError(fmt.Sprintf("解析响应失败: %v", err))
+		return
+	}
+	for _, e := range out.Errors {
+		c.printWarning(e)
+	}
+	if len(out.Commands) == 0 {
+		return
+	}
+	c.printInfo(fmt.Sprintf("已下发 %d 条命令，等待 Spoke 执行（Ctrl+C 停止跟踪，命令继续执行）", len(out.Commands)))
+	c.followCommands(out.Commands)
+}
+
+// followCommands 轮询命令状态并增量打印输出
+func (c *CLI) followCommands(cmds []hub.ControlCommand) {
+	stopped := make(chan struct{})
+	prevCancel := c.agentCancel
+	c.agentCancel = func() {
+		select {
+		case <-stopped:
+		default:
+			close(stopped)
+		}
+	}
+	defer func() { c.agentCancel = prevCancel }()
+
+	offsets := make(map[string]int)
+	states := make(map[string]string)
+	remaining := len(cmds)
+	deadline := time.Now().Add(hubRunFollowTimeout)
+	for remaining > 0 && time.Now().Before(deadline) {
+		for i := range cmds {
+			id := cmds[i].ID
+			if states[id] == "finished" {
+				continue
+			}
+			cmd, err := fetchCommand(id)
+			if err != nil {
+				continue
+			}
+			if cmd.Status != states[id] && cmd.Status == hub.CommandPendingApproval {
+				fmt.Printf("\033[1;33m[%s] 等待 Spoke 本地确认...\033[0m\n", cmd.Spoke)
+			}
+			if len(cmd.Output) > offsets[id] {
+				for _, line := range strings.Split(strings.TrimRight(cmd.Output[offsets[id]:], "\n"), "\n") {
+					fmt.Printf("\033[1;36m[%s]\033[0m %s\n", cmd.Spoke, line)
+				}
+				offsets[id] = len(cmd.Output)
+			}
+			states[id] = cmd.Status
+			if cmd.Finished() {
+				states[id] = "finished"
+				remaining--
+				c.printCommandResult(cmd)
+			}
+		}
+		if remaining == 0 {
+			break
+		}
+		select {
+		case <-stopped:
+			c.printInfo("已停止跟踪，可用 /hub-command <id> 查看结果")
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	if remaining > 0 {
+		c.printWarning("部分命令仍未完成，可用 /hub-command <id> 查看结果")
+	}
+}
+
+func (c *CLI) printCommandResult(cmd hub.ControlCommand) {
+	switch cmd.Status {
+	case hub.CommandDone:
+		c.printSuccess(fmt.Sprintf("[%s] %s 完成", cmd.Spoke, cmd.Op))
+	case hub.CommandRejected:
+		c.printWarning(fmt.Sprintf("[%s] %s 被拒绝: %s", cmd.Spoke, cmd.Op, cmd.Error))
+	default:
+		c.printError(fmt.Sprintf("[%s] %s 失败: %s", cmd.Spoke, cmd.Op, cmd.Error))
+	}
+}
+
+func fetchCommand(id string) (hub.ControlCommand, error) {
+	var cmd hub.ControlCommand
+	resp, err := http.Get(mgmtBaseURL() + "/hub/command?id=" + url.QueryEscape(id))
+	if err != nil {
+		return cmd, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return cmd, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&cmd)
+	return cmd, err
+}
+
+// handleHubCommand /hub-command [id] — 查看单条命令结果或最近命令列表
+func (c *CLI) handleHubCommand(args []string) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "cmd-") {
+		cmd, err := fetchCommand(args[0])
+		if err != nil {
+			c.printError(fmt.Sprintf("查询失败: %v", err))
+			return
+		}
+		fmt.Printf("\n\033[1;34m命令 %s\033[0m  spoke: %s  操作: %s  状态: %s\n", cmd.ID, cmd.Spoke, cmd.Op, cmd.Status)
+		fmt.Printf("  下发: %s\n", cmd.CreatedAt.Format("2006-01-02 15:04:05"))
+		if !cmd.FinishedAt.IsZero() {
+			fmt.Printf("  结束: %s\n", cmd.FinishedAt.Format("2006-01-02 15:04:05"))
+		}
+		if cmd.Error != "" {
+			fmt.Printf("  \033[31m错误: %s\033[0m\n", cmd.Error)
+		}
+		if cmd.Output != "" {
+			fmt.Println(strings.Repeat("─", 60))
+			fmt.Println(strings.TrimRight(cmd.Output, "\n"))
+		}
+		fmt.Println()
+		return
+	}
+
+	query := url.Values{}
+	if len(args) > 0 {
+		query.Set("spoke", args[0])
+	}
+	resp, err := http.Get(mgmtBaseURL() + "/hub/command?" + query.Encode())
+	if err != nil {
+		c.printError(fmt.Sprintf("请求失败（代理是否在运行？）: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Commands []hub.ControlCommand `json:"commands"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		c.printError(fmt.Sprintf("解析响应失败: %v", err))
+		return
+	}
+	fmt.Printf("\n\033[1;34m最近远程命令 (%d)\033[0m\n", len(out.Commands))
+	for _, cmd := range out.Commands {
+		fmt.Printf("  \033[1;36m%s\033[0m  %s  %-14s %-16s %s\n",
+			cmd.ID, cmd.CreatedAt.Format("01-02 15:04:05"), cmd.Op, cmd.Status, cmd.Spoke)
+	}
+	fmt.Println()
+}
+
+// handleControlPending /control-pending — Spoke 端查看待确认的远程命令
+func (c *CLI) handleControlPending() {
+	if c.control == nil {
+		c.printInfo("远程命令通道未启用（未连接 Hub 或 spoke.control.enabled=false）")
+		return
+	}
+	pending := c.control.Pending()
+	if len(pending) == 0 {
+		c.printInfo("没有待确认的远程命令")
+		return
+	}
+	fmt.Printf("\n\033[1;34m待确认远程命令 (%d)\033[0m\n", len(pending))
+	for _, cmd := range pending {
+		line := cmd.Op
+		if cmd.Service != "" {
+			line += " service=" + cmd.Service
+		}
+		for k, v := range cmd.Args {
+			line += " " + k + "=" + v
+		}
+		fmt.Printf("  \033[1;36m%s\033[0m  %s  %s\n", cmd.ID, cmd.CreatedAt.Format("15:04:05"), line)
+	}
+	fmt.Println()
+}
+
+// handleControlDecision /control-approve <id> 或 /control-reject <id>
+func (c *CLI) handleControlDecision(args []string, approve bool) {
+	if c.control == nil {
+		c.printInfo("远程命令通道未启用")
+		return
+	}
+	if len(args) == 0 {
+		c.printError("请指定命令 ID，可用 /control-pending 查看")
+		return
+	}
+	var err error
+	if approve {
+		err = c.control.Approve(args[0])
+	} else {
+		err = c.control.Reject(args[0])
+	}
+	if err != nil {
+		c.printError(err.Error())
+		return
+	}
+	if approve {
+		c.printSuccess("已确认，开始执行: " + args[0])
+	} else {
+		c.printInfo("已拒绝: " + args[0])
+	}
+}
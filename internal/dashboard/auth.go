@@ -0,0 +1,204 @@
+package dashboard
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const appConfigFile = "configs/app_config.json"
+
+const (
+	sessionCookie = "ruoyi_mgmt_session"
+	sessionTTL    = 12 * time.Hour
+	// csrfHeader Cookie 会话发起的写请求必须携带此请求头（浏览器跨站表单无法附加）
+	csrfHeader = "X-Mgmt-Request"
+)
+
+// Settings 管理端口配置（app_config.json 的 mgmt 字段）
+type Settings struct {
+	Token string `json:"token,omitempty"` // 管理令牌；为空时面板仅允许本机访问，API 行为不变
+}
+
+// LoadSettings 读取 mgmt 配置，文件或字段缺失时返回默认值
+func LoadSettings() Settings {
+	var settings Settings
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return settings
+	}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return settings
+	}
+	if raw, ok := root["mgmt"]; ok {
+		_ = json.Unmarshal(raw, &settings)
+	}
+	settings.Token = strings.TrimSpace(settings.Token)
+	return settings
+}
+
+// Protect 管理端口鉴权中间件：
+//   - 面板页面：配置了令牌时需登录；未配置时仅允许本机访问
+//   - 其他 API：本机请求直接放行（CLI、脚本、Agent 依赖）；配置了令牌时远程请求需携带 Bearer 令牌或面板会话
+func Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 登录页及其样式无需鉴权
+		if r.URL.Path == loginPath || r.URL.Path == basePath+"style.css" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		settings := LoadSettings()
+		local := isLocalRequest(r)
+		isPage := strings.HasPrefix(r.URL.Path, basePath)
+
+		if settings.Token == "" {
+			if isPage && !local {
+				http.Error(w, "管理面板仅允许本机访问；如需远程访问请在 app_config.json 设置 mgmt.token", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if validBearer(r, settings.Token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if validSession(r, settings.Token) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get(csrfHeader) == "" {
+				http.Error(w, "缺少 "+csrfHeader+" 请求头", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isPage {
+			http.Redirect(w, r, loginPath, http.StatusFound)
+			return
+		}
+		if local {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ruoyi-proxy"`)
+		http.Error(w, "未授权", http.StatusUnauthorized)
+	})
+}
+
+// Authorized 本机请求，或携带有效管理令牌（Bearer / 面板会话）的请求；供不随 Protect 放行远程访问的敏感接口使用
+func Authorized(r *http.Request) bool {
+	if isLocalRequest(r) {
+		return true
+	}
+	token := LoadSettings().Token
+	return token != "" && (validBearer(r, token) || validSession(r, token))
+}
+
+// isLocalRequest 来自回环地址且未经反向代理转发
+func isLocalRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-IP") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func validBearer(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	got := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// sessionValue 会话 Cookie：<过期时间戳>.<HMAC>，修改令牌即令所有会话失效
+func sessionValue(token string, exp time.Time) string {
+	ts := strconv.FormatInt(exp.Unix(), 10)
+	return ts + "." + sessionSignature(token, ts)
+}
+
+func sessionSignature(token, ts string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("mgmt-session:" + ts))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validSession(r *http.Request, token string) bool {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	ts, sig, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return false
+	}
+	exp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(sessionSignature(token, ts)))
+}
+
+// loginHandler GET 返回登录页，POST 校验令牌并写入会话 Cookie
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	settings := LoadSettings()
+	switch r.Method {
+	case http.MethodGet:
+		if settings.Token == "" {
+			http.Redirect(w, r, basePath, http.StatusFound)
+			return
+		}
+		serveStatic(w, r, "static/login.html")
+	case http.MethodPost:
+		if settings.Token == "" {
+			http.Redirect(w, r, basePath, http.StatusFound)
+			return
+		}
+		got := strings.TrimSpace(r.FormValue("token"))
+		if subtle.ConstantTimeCompare([]byte(got), []byte(settings.Token)) != 1 {
+			time.Sleep(time.Second)
+			http.Redirect(w, r, loginPath+"?error=1", http.StatusFound)
+			return
+		}
+		exp := time.Now().Add(sessionTTL)
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    sessionValue(settings.Token, exp),
+			Path:     "/",
+			Expires:  exp,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			Secure:   r.TLS != nil,
+		})
+		http.Redirect(w, r, basePath, http.StatusFound)
+	default:
+		http.Error(w, "只允许 GET/POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// logoutHandler 清除会话 Cookie
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, loginPath, http.StatusFound)
+}
@@ -0,0 +1,51 @@
+package dashboard
+
+import (
+	"embed"
+	"net/http"
+	"path"
+)
+
+//go:embed static/*
+var staticFS embed.FS
+
+const (
+	basePath   = "/dashboard/"
+	loginPath  = "/dashboard-login"
+	logoutPath = "/dashboard-logout"
+)
+
+// Register 在管理端口挂载 Web 管理面板（需配合 Protect 中间件使用）
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc(loginPath, loginHandler)
+	mux.HandleFunc(logoutPath, logoutHandler)
+	mux.HandleFunc(basePath, func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean(r.URL.Path[len(basePath)-1:])
+		if name == "/" {
+			name = "/index.html"
+		}
+		serveStatic(w, r, "static"+name)
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, basePath, http.StatusFound)
+	})
+}
+
+func serveStatic(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := staticFS.ReadFile(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch path.Ext(name) {
+	case ".html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	case ".js":
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	case ".css":
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Write(data)
+}
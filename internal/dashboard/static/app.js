@@ -0,0 +1,236 @@
+'use strict';
+
+var STATE_LABELS = { online: '在线', stale: '失联', offline: '离线', revoked: '已吊销' };
+
+function api(path, method) {
+  return fetch(path, {
+    method: method || 'GET',
+    credentials: 'same-origin',
+    headers: { 'X-Mgmt-Request': '1' }
+  }).then(function (resp) {
+    if (resp.status === 401 || (resp.redirected && resp.url.indexOf('/dashboard-login') >= 0)) {
+      location.href = '/dashboard-login';
+      throw new Error('未登录');
+    }
+    if (!resp.ok) {
+      return resp.text().then(function (t) { throw new Error('HTTP ' + resp.status + ': ' + t.trim()); });
+    }
+    return resp.json();
+  });
+}
+
+function el(tag, attrs, children) {
+  var node = document.createElement(tag);
+  Object.keys(attrs || {}).forEach(function (k) {
+    if (k === 'text') node.textContent = attrs[k];
+    else if (k === 'onclick') node.onclick = attrs[k];
+    else node.setAttribute(k, attrs[k]);
+  });
+  (children || []).forEach(function (c) {
+    if (c == null) return;
+    node.appendChild(typeof c === 'string' ? document.createTextNode(c) : c);
+  });
+  return node;
+}
+
+function fmtTime(s) {
+  if (!s || s.indexOf('0001-') === 0) return '-';
+  var d = new Date(s);
+  var pad = function (n) { return n < 10 ? '0' + n : '' + n; };
+  return d.getFullYear() + '-' + pad(d.getMonth() + 1) + '-' + pad(d.getDate()) + ' ' +
+    pad(d.getHours()) + ':' + pad(d.getMinutes()) + ':' + pad(d.getSeconds());
+}
+
+function envBadge(env) {
+  return el('span', { class: 'badge ' + (env || ''), text: env || '-' });
+}
+
+function loadProxy() {
+  var box = document.getElementById('proxy');
+  return api('/status').then(function (data) {
+    box.className = 'card';
+    box.textContent = '';
+    var ids = Object.keys(data.services || {}).sort();
+    var table = el('table', {}, [
+      el('thead', {}, [el('tr', {}, [el('th', { text: '服务' }), el('th', { text: '当前环境' }), el('th', { text: '蓝' }), el('th', { text: '绿' })])])
+    ]);
+    var tbody = el('tbody');
+    ids.forEach(function (id) {
+      var s = data.services[id];
+      tbody.appendChild(el('tr', {}, [
+        el('td', {}, [el('strong', { text: id }), ' ', el('span', { class: 'muted', text: s.name || '' })]),
+        el('td', {}, [envBadge(s.active_env)]),
+        el('td', { class: 'small', text: s.blue_target }),
+        el('td', { class: 'small', text: s.green_target })
+      ]));
+    });
+    table.appendChild(tbody);
+    box.appendChild(el('p', {}, ['代理状态: ', el('span', { class: 'badge online', text: data.status })]));
+    box.appendChild(table);
+  }).catch(function (err) {
+    box.className = 'card error';
+    box.textContent = '代理状态获取失败: ' + err.message;
+  });
+}
+
+function loadHistory() {
+  var tbody = document.getElementById('history');
+  return api('/switch-history?limit=20').then(function (data) {
+    tbody.textContent = '';
+    if (!data.records || data.records.length === 0) {
+      tbody.appendChild(el('tr', {}, [el('td', { colspan: '3', class: 'muted', text: '暂无切换记录' })]));
+      return;
+    }
+    data.records.forEach(function (r) {
+      tbody.appendChild(el('tr', {}, [
+        el('td', { text: fmtTime(r.time) }),
+        el('td', { text: r.service }),
+        el('td', {}, [envBadge(r.from), ' → ', envBadge(r.to)])
+      ]));
+    });
+  }).catch(function (err) {
+    tbody.textContent = '';
+    tbody.appendChild(el('tr', {}, [el('td', { colspan: '3', class: 'error', text: err.message })]));
+  });
+}
+
+function profileCell(s) {
+  var p = s.profile;
+  var children = [];
+  if (p) {
+    children.push(el('div', {}, [el('strong', { text: p.label || p.hostname || '' })]));
+    var proj = [p.project_name, p.project_type ? '(' + p.project_type + ')' : ''].join(' ').trim();
+    if (proj) children.push(el('div', { class: 'small', text: proj }));
+    if (p.description) children.push(el('div', { class: 'small muted', text: p.description }));
+    if (p.domain) children.push(el('div', { class: 'small muted', text: p.domain }));
+    if (p.drift && p.drift.length) {
+      children.push(el('div', {}, [el('span', { class: 'badge stale', title: p.drift.join('\n'), text: '环境漂移 ' + p.drift.length + ' 项' })]));
+    }
+  } else {
+    children.push(el('span', { class: 'muted', text: '未上报档案' }));
+  }
+  var tags = Object.assign({}, (p && p.tags) || {}, s.tags || {});
+  var keys = Object.keys(tags).sort();
+  if (keys.length) {
+    children.push(el('div', {}, keys.map(function (k) { return el('span', { class: 'tag', text: k + '=' + tags[k] }); })));
+  }
+  return el('td', {}, children);
+}
+
+function servicesCell(s) {
+  var list = (s.health && s.health.services) || [];
+  if (list.length === 0 && s.profile && s.profile.services) {
+    list = s.profile.services.map(function (svc) { return { id: svc.id, active_env: svc.active_env }; });
+  }
+  return el('td', {}, list.map(function (svc) {
+    var mark = svc.up === undefined ? '' : (svc.up ? ' ✓' : ' ✗');
+    return el('div', { class: 'small' }, [svc.id + ' ', envBadge(svc.active_env), mark]);
+  }));
+}
+
+function healthCell(s) {
+  var h = s.health;
+  if (!h || s.state === 'revoked') return el('td', { class: 'muted', text: '-' });
+  var parts = ['代理: ' + (h.proxy_running ? '运行中' : '未运行')];
+  if (h.disk_used_percent) parts.push('磁盘: ' + Math.round(h.disk_used_percent) + '% (剩余 ' + h.disk_free_gb + 'G)');
+  if (h.mem_used_percent) parts.push('内存: ' + Math.round(h.mem_used_percent) + '%');
+  if (h.load1) parts.push('负载: ' + h.load1.toFixed(2));
+  return el('td', { class: 'small' }, parts.map(function (t) { return el('div', { text: t }); }));
+}
+
+function revoke(id) {
+  if (!confirm('确认吊销 ' + id + '？吊销后该节点将无法再通过 Hub 调用 AI。')) return;
+  api('/hub/revoke?spoke=' + encodeURIComponent(id), 'POST').then(loadHub).catch(function (err) {
+    alert('吊销失败: ' + err.message);
+  });
+}
+
+function loadHub() {
+  var selector = document.getElementById('selector').value.trim();
+  return api('/hub/status?selector=' + encodeURIComponent(selector)).then(function (data) {
+    document.getElementById('hub').hidden = false;
+
+    var rep = data.replication || {};
+    var repBox = document.getElementById('replication');
+    repBox.hidden = rep.role !== 'standby';
+    if (rep.role === 'standby') {
+      repBox.className = 'card' + (rep.last_error ? ' error' : '');
+      repBox.textContent = '备用 Hub，主 Hub: ' + (rep.primary || '-') + '，上次同步: ' +
+        (rep.last_sync ? fmtTime(rep.last_sync) : '尚未同步') + (rep.last_error ? '（失败: ' + rep.last_error + '）' : '');
+    }
+
+    var sec = data.security || {};
+    var secBox = document.getElementById('security');
+    var locked = sec.locked || [];
+    secBox.hidden = locked.length === 0;
+    secBox.textContent = locked.length ? '已锁定来源: ' + locked.map(function (l) {
+      return l.ip + '（至 ' + fmtTime(l.until) + '）';
+    }).join('，') : '';
+
+    var states = document.getElementById('states');
+    states.textContent = '';
+    ['online', 'stale', 'offline', 'revoked'].forEach(function (k) {
+      states.appendChild(el('div', { class: 'card' }, [
+        el('div', { class: 'num', text: String((data.states && data.states[k]) || 0) }),
+        el('span', { class: 'badge ' + k, text: STATE_LABELS[k] })
+      ]));
+    });
+
+    var tbody = document.getElementById('spokes');
+    tbody.textContent = '';
+    (data.spokes || []).forEach(function (s) {
+      tbody.appendChild(el('tr', {}, [
+        el('td', {}, [el('strong', { text: s.id }), el('div', { class: 'small muted', text: '注册: ' + fmtTime(s.created_at) })]),
+        el('td', {}, [el('span', { class: 'badge ' + s.state, text: STATE_LABELS[s.state] || s.state })]),
+        profileCell(s),
+        servicesCell(s),
+        healthCell(s),
+        el('td', { class: 'small' }, [
+          el('div', { text: '活动: ' + fmtTime(s.last_seen) }),
+          el('div', { text: '心跳: ' + fmtTime(s.last_heartbeat) })
+        ]),
+        el('td', {}, [s.revoked ? null : el('button', { class: 'danger', text: '吊销', onclick: function () { revoke(s.id); } })])
+      ]));
+    });
+    if (!data.spokes || data.spokes.length === 0) {
+      tbody.appendChild(el('tr', {}, [el('td', { colspan: '7', class: 'muted', text: '没有匹配的 Spoke' })]));
+    }
+  }).catch(function (err) {
+    // Hub 未启用时管理端口没有 /hub/status，隐藏整个区块
+    if (err.message.indexOf('HTTP 404') === 0) {
+      document.getElementById('hub').hidden = true;
+      return;
+    }
+    document.getElementById('hub').hidden = false;
+    var tbody = document.getElementById('spokes');
+    tbody.textContent = '';
+    tbody.appendChild(el('tr', {}, [el('td', { colspan: '7', class: 'error', text: err.message })]));
+  });
+}
+
+function generateToken() {
+  api('/hub/token', 'POST').then(function (data) {
+    var box = document.getElementById('token');
+    box.hidden = false;
+    box.textContent = '';
+    box.appendChild(el('p', { text: '注册 Token（' + Math.round(data.expires_in / 60) + ' 分钟内有效）:' }));
+    box.appendChild(el('div', { class: 'token-box', text: data.token }));
+    box.appendChild(el('p', { class: 'small muted', text: data.hint || '' }));
+  }).catch(function (err) {
+    alert('生成失败: ' + err.message);
+  });
+}
+
+function refresh() {
+  Promise.all([loadProxy(), loadHistory(), loadHub()]).then(function () {
+    document.getElementById('updated').textContent = '更新于 ' + fmtTime(new Date().toISOString());
+  });
+}
+
+document.getElementById('refresh').onclick = function (e) { e.preventDefault(); refresh(); };
+document.getElementById('filter').onclick = loadHub;
+document.getElementById('selector').onkeydown = function (e) { if (e.key === 'Enter') loadHub(); };
+document.getElementById('gen-token').onclick = generateToken;
+
+refresh();
+setInterval(refresh, 30000);
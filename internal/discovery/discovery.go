@@ -0,0 +1,267 @@
+// Package discovery 自动发现 spoke 本机的运行环境：应用进程、监听端口、systemd 服务、
+// nginx 站点与 docker 容器，用于生成 Spoke 档案并检测环境漂移。
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/hub"
+)
+
+const baselineFile = "configs/spoke_discovery_baseline.json"
+
+// Run 执行一次完整发现；单个来源失败只记录到 Errors，不影响其他来源
+func Run(ctx context.Context, nginxPaths ...string) hub.SpokeDiscovery {
+	d := hub.SpokeDiscovery{At: time.Now()}
+
+	ports, err := listeningPorts()
+	if err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("监听端口: %v", err))
+	}
+	d.Ports = ports
+	d.Processes = appProcesses(ports)
+
+	if units, err := systemdUnits(ctx); err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("systemd: %v", err))
+	} else {
+		d.Units = units
+	}
+	if sites, err := nginxSites(nginxPaths); err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("nginx: %v", err))
+	} else {
+		d.Sites = sites
+	}
+	if containers, err := dockerContainers(ctx); err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("docker: %v", err))
+	} else {
+		d.Containers = containers
+	}
+	return d
+}
+
+// InferProjectType 按发现结果推断项目类型（无法判断返回空）
+func InferProjectType(d hub.SpokeDiscovery) string {
+	for _, runtime := range []string{"java", "node", "python"} {
+		for _, p := range d.Processes {
+			if p.Runtime == runtime {
+				return runtime
+			}
+		}
+	}
+	for _, c := range d.Containers {
+		if c.State == "running" {
+			return "docker"
+		}
+	}
+	return ""
+}
+
+// InferProjectName 取第一个应用进程的名称（去掉 .jar 与版本号）
+func InferProjectName(d hub.SpokeDiscovery) string {
+	for _, p := range d.Processes {
+		// ruoyi-admin-3.8.7.jar → ruoyi-admin：去掉扩展名与以数字开头的尾段
+		name := strings.TrimSuffix(strings.TrimSuffix(p.Name, ".jar"), ".js")
+		parts := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' })
+		for len(parts) > 1 && parts[len(parts)-1][0] >= '0' && parts[len(parts)-1][0] <= '9' {
+			parts = parts[:len(parts)-1]
+		}
+		if name = strings.Join(parts, "-"); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// InferDomain 取 nginx 中第一个像域名的 server_name
+func InferDomain(d hub.SpokeDiscovery) string {
+	for _, s := range d.Sites {
+		for _, name := range s.ServerNames {
+			if name != "_" && name != "localhost" && strings.Contains(name, ".") && !strings.HasPrefix(name, "~") {
+				return strings.TrimPrefix(name, "*.")
+			}
+		}
+	}
+	return ""
+}
+
+// Summarize 生成一行摘要，用作自动档案说明
+func Summarize(d hub.SpokeDiscovery) string {
+	var parts []string
+	for _, p := range d.Processes {
+		item := fmt.Sprintf("%s %s", p.Runtime, p.Name)
+		if len(p.Ports) > 0 {
+			item += " :" + joinInts(p.Ports, ",")
+		}
+		parts = append(parts, item)
+	}
+	for _, s := range d.Sites {
+		var names []string
+		for _, name := range s.ServerNames {
+			if name != "_" && name != "localhost" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		item := "nginx " + strings.Join(names, ",")
+		if len(s.ProxyPass) > 0 {
+			item += " → " + s.ProxyPass[0]
+		}
+		parts = append(parts, item)
+	}
+	running := 0
+	for _, c := range d.Containers {
+		if c.State == "running" {
+			running++
+		}
+	}
+	if len(d.Containers) > 0 {
+		parts = append(parts, fmt.Sprintf("容器 %d/%d 运行中", running, len(d.Containers)))
+	}
+	if len(parts) == 0 {
+		return "未发现应用进程、nginx 站点或容器"
+	}
+	return strings.Join(parts, "；")
+}
+
+// Drift 比较基线与当前发现结果，返回可读的变化列表（基线为空时不报告）
+func Drift(baseline *hub.SpokeDiscovery, current hub.SpokeDiscovery) []string {
+	if baseline == nil {
+		return nil
+	}
+	var out []string
+	diff := func(kind string, before, after map[string]string) {
+		for _, k := range sortedKeys(after) {
+			if old, ok := before[k]; !ok {
+				out = append(out, fmt.Sprintf("新增%s %s", kind, k))
+			} else if old != after[k] {
+				out = append(out, fmt.Sprintf("%s %s: %s → %s", kind, k, old, after[k]))
+			}
+		}
+		for _, k := range sortedKeys(before) {
+			if _, ok := after[k]; !ok {
+				out = append(out, fmt.Sprintf("%s %s 已消失", kind, k))
+			}
+		}
+	}
+	diff("进程", processKeys(*baseline), processKeys(current))
+	diff("监听端口", portKeys(*baseline), portKeys(current))
+	diff("systemd 服务", unitKeys(*baseline), unitKeys(current))
+	diff("nginx 站点", siteKeys(*baseline), siteKeys(current))
+	diff("容器", containerKeys(*baseline), containerKeys(current))
+	return out
+}
+
+func processKeys(d hub.SpokeDiscovery) map[string]string {
+	// 同名进程（如多个 worker）合并端口
+	ports := make(map[string][]int)
+	for _, p := range d.Processes {
+		key := p.Runtime + ":" + p.Name
+		ports[key] = append(ports[key], p.Ports...)
+	}
+	m := make(map[string]string, len(ports))
+	for key, list := range ports {
+		sort.Ints(list)
+		m[key] = joinInts(list, ",")
+	}
+	return m
+}
+
+func portKeys(d hub.SpokeDiscovery) map[string]string {
+	m := make(map[string]string)
+	for _, p := range d.Ports {
+		m[strconv.Itoa(p.Port)] = p.Process
+	}
+	return m
+}
+
+func unitKeys(d hub.SpokeDiscovery) map[string]string {
+	m := make(map[string]string)
+	for _, u := range d.Units {
+		m[u.Name] = "running"
+	}
+	return m
+}
+
+func siteKeys(d hub.SpokeDiscovery) map[string]string {
+	m := make(map[string]string)
+	for _, s := range d.Sites {
+		key := strings.Join(s.ServerNames, ",")
+		if key == "" {
+			key = filepath.Base(s.File)
+		}
+		m[key] = strings.Join(s.ProxyPass, ",")
+	}
+	return m
+}
+
+func containerKeys(d hub.SpokeDiscovery) map[string]string {
+	m := make(map[string]string)
+	for _, c := range d.Containers {
+		m[c.Name] = c.State
+	}
+	return m
+}
+
+// Fingerprint 发现结果的稳定摘要（忽略 PID 与时间），用于判断是否需要重新同步
+func Fingerprint(d hub.SpokeDiscovery) string {
+	var b strings.Builder
+	for _, m := range []map[string]string{processKeys(d), portKeys(d), unitKeys(d), siteKeys(d), containerKeys(d)} {
+		for _, k := range sortedKeys(m) {
+			b.WriteString(k + "=" + m[k] + ";")
+		}
+		b.WriteString("|")
+	}
+	return b.String()
+}
+
+// LoadBaseline 读取基线（首次建档或运维确认时保存）
+func LoadBaseline() (*hub.SpokeDiscovery, bool) {
+	data, err := os.ReadFile(baselineFile)
+	if err != nil {
+		return nil, false
+	}
+	var d hub.SpokeDiscovery
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, false
+	}
+	return &d, true
+}
+
+// SaveBaseline 将发现结果保存为新基线
+func SaveBaseline(d hub.SpokeDiscovery) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(baselineFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(baselineFile, data, 0644)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinInts(nums []int, sep string) string {
+	parts := make([]string, len(nums))
+	for i, n := range nums {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, sep)
+}
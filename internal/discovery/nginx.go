@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ruoyi-proxy/internal/hub"
+)
+
+// defaultNginxGlobs 常见发行版的 nginx 配置位置
+var defaultNginxGlobs = []string{
+	"/etc/nginx/nginx.conf",
+	"/etc/nginx/conf.d/*.conf",
+	"/etc/nginx/sites-enabled/*",
+	"/usr/local/nginx/conf/nginx.conf",
+	"/usr/local/nginx/conf/conf.d/*.conf",
+}
+
+// nginxSites 解析 nginx 配置中的 server 块（额外路径来自本工具管理的 nginx 配置）
+func nginxSites(extra []string) ([]hub.DiscoveredSite, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range append(append([]string{}, defaultNginxGlobs...), extra...) {
+		if pattern == "" {
+			continue
+		}
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			if abs, err := filepath.Abs(m); err == nil {
+				m = abs
+			}
+			if real, err := filepath.EvalSymlinks(m); err == nil {
+				m = real
+			}
+			if info, err := os.Stat(m); err != nil || info.IsDir() || seen[m] {
+				continue
+			}
+			seen[m] = true
+			files = append(files, m)
+		}
+	}
+
+	var sites []hub.DiscoveredSite
+	var firstErr error
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sites = append(sites, parseServerBlocks(string(data), file)...)
+	}
+	if len(sites) == 0 {
+		return nil, firstErr
+	}
+	return sites, nil
+}
+
+// parseServerBlocks 按花括号层级扫描配置，收集每个 server 块内的关键指令
+func parseServerBlocks(conf, file string) []hub.DiscoveredSite {
+	var (
+		sites       []hub.DiscoveredSite
+		current     *hub.DiscoveredSite
+		depth       int
+		serverDepth int
+		stmt        []string
+		word        strings.Builder
+		inComment   bool
+		quote       rune
+	)
+	flushWord := func() {
+		if word.Len() > 0 {
+			stmt = append(stmt, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range conf {
+		switch {
+		case inComment:
+			if r == '\n' {
+				inComment = false
+			}
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+		}
+		switch r {
+		case '#':
+			inComment = true
+		case '"', '\'':
+			quote = r
+		case ' ', '\t', '\n', '\r':
+			flushWord()
+		case ';':
+			flushWord()
+			if current != nil && depth >= serverDepth && len(stmt) > 1 {
+				applyDirective(current, stmt)
+			}
+			stmt = nil
+		case '{':
+			flushWord()
+			depth++
+			if current == nil && len(stmt) == 1 && stmt[0] == "server" {
+				current = &hub.DiscoveredSite{File: file}
+				serverDepth = depth
+			}
+			stmt = nil
+		case '}':
+			flushWord()
+			if current != nil && depth == serverDepth {
+				sites = append(sites, *current)
+				current = nil
+			}
+			depth--
+			stmt = nil
+		default:
+			word.WriteRune(r)
+		}
+	}
+	return sites
+}
+
+func applyDirective(site *hub.DiscoveredSite, stmt []string) {
+	switch stmt[0] {
+	case "listen":
+		site.Listen = appendUnique(site.Listen, strings.Join(stmt[1:], " "))
+	case "server_name":
+		for _, name := range stmt[1:] {
+			site.ServerNames = appendUnique(site.ServerNames, name)
+		}
+	case "proxy_pass":
+		site.ProxyPass = appendUnique(site.ProxyPass, stmt[1])
+	case "root":
+		if site.Root == "" {
+			site.Root = stmt[1]
+		}
+	}
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, item := range list {
+		if item == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
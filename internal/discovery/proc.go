@@ -0,0 +1,238 @@
+package discovery
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ruoyi-proxy/internal/hub"
+)
+
+// listeningPorts 解析 /proc/net/tcp{,6} 中处于 LISTEN 状态的套接字，并通过 /proc/*/fd 关联进程
+func listeningPorts() ([]hub.DiscoveredPort, error) {
+	inodes := make(map[string]hub.DiscoveredPort)
+	var firstErr error
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		if err := readListenSockets(file, inodes); err != nil && firstErr == nil && !os.IsNotExist(err) {
+			firstErr = err
+		}
+	}
+	if len(inodes) == 0 {
+		return nil, firstErr
+	}
+
+	owners := socketOwners(inodes)
+	ephemeral := ephemeralPortStart()
+	seen := make(map[string]bool)
+	var ports []hub.DiscoveredPort
+	for inode, p := range inodes {
+		if p.Port >= ephemeral {
+			continue // 临时端口每次启动都会变化，计入会造成漂移误报
+		}
+		if owner, ok := owners[inode]; ok {
+			p.PID = owner
+			p.Process = processName(owner)
+		}
+		key := fmt.Sprintf("%d/%d", p.Port, p.PID)
+		if seen[key] {
+			continue // IPv4 与 IPv6 同时监听同一端口
+		}
+		seen[key] = true
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports, nil
+}
+
+// ephemeralPortStart 读取内核临时端口范围起点（默认 32768）
+func ephemeralPortStart() int {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range")
+	if err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			if n, err := strconv.Atoi(fields[0]); err == nil && n > 1024 {
+				return n
+			}
+		}
+	}
+	return 32768
+}
+
+func readListenSockets(file string, inodes map[string]hub.DiscoveredPort) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 表头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != "0A" {
+			continue
+		}
+		host, portHex, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseInt(portHex, 16, 32)
+		if err != nil {
+			continue
+		}
+		inodes[fields[9]] = hub.DiscoveredPort{Port: int(port), Address: decodeAddr(host)}
+	}
+	return scanner.Err()
+}
+
+// decodeAddr 将 /proc/net/tcp 中的十六进制地址转为可读形式（仅区分常见情况）
+func decodeAddr(hex string) string {
+	switch strings.Trim(hex, "0") {
+	case "":
+		if len(hex) > 8 {
+			return "::"
+		}
+		return "0.0.0.0"
+	case "1":
+		return "::1"
+	}
+	if len(hex) == 8 {
+		b, err := strconv.ParseUint(hex, 16, 32)
+		if err == nil {
+			return fmt.Sprintf("%d.%d.%d.%d", b&0xff, b>>8&0xff, b>>16&0xff, b>>24)
+		}
+	}
+	return hex
+}
+
+// socketOwners 扫描 /proc/*/fd 建立套接字 inode → PID 映射（无权限的进程会被跳过）
+func socketOwners(inodes map[string]hub.DiscoveredPort) map[string]int {
+	owners := make(map[string]int)
+	for _, pid := range pids() {
+		fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if _, ok := inodes[inode]; ok {
+				owners[inode] = pid
+			}
+		}
+	}
+	return owners
+}
+
+// appProcesses 找出 Java/Node/Python 应用进程，并附上其监听端口
+func appProcesses(ports []hub.DiscoveredPort) []hub.DiscoveredProcess {
+	byPID := make(map[int][]int)
+	for _, p := range ports {
+		if p.PID > 0 {
+			byPID[p.PID] = append(byPID[p.PID], p.Port)
+		}
+	}
+
+	var procs []hub.DiscoveredProcess
+	for _, pid := range pids() {
+		args := cmdline(pid)
+		if len(args) == 0 {
+			continue
+		}
+		runtime, name := classify(args)
+		if runtime == "" {
+			continue
+		}
+		command := strings.Join(args, " ")
+		if len(command) > 200 {
+			command = command[:200] + "..."
+		}
+		procs = append(procs, hub.DiscoveredProcess{
+			PID:     pid,
+			Runtime: runtime,
+			Name:    name,
+			Ports:   byPID[pid],
+			Command: command,
+		})
+	}
+	return procs
+}
+
+// classify 根据命令行判断运行时与应用名
+func classify(args []string) (string, string) {
+	bin := filepath.Base(args[0])
+	switch {
+	case bin == "java":
+		for i, a := range args {
+			if a == "-jar" && i+1 < len(args) {
+				return "java", filepath.Base(args[i+1])
+			}
+		}
+		// 无 -jar 时取第一个不以 - 开头且不是 classpath 值的参数作为主类
+		for i := 1; i < len(args); i++ {
+			a := args[i]
+			if a == "-cp" || a == "-classpath" || a == "--class-path" {
+				i++
+				continue
+			}
+			if !strings.HasPrefix(a, "-") {
+				return "java", a
+			}
+		}
+		return "java", "java"
+	case bin == "node" || bin == "nodejs":
+		return "node", scriptName(args)
+	case strings.HasPrefix(bin, "python"):
+		return "python", scriptName(args)
+	}
+	return "", ""
+}
+
+func scriptName(args []string) string {
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if a == "-m" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if !strings.HasPrefix(a, "-") {
+			return filepath.Base(a)
+		}
+	}
+	return filepath.Base(args[0])
+}
+
+func pids() []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var out []int
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			out = append(out, pid)
+		}
+	}
+	return out
+}
+
+func cmdline(pid int) []string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+}
+
+func processName(pid int) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
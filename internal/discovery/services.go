@@ -0,0 +1,99 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/hub"
+)
+
+// systemUnitPrefixes 发行版自带服务，不计入应用环境
+var systemUnitPrefixes = []string{
+	"systemd-", "dbus", "getty@", "serial-getty@", "ssh", "sshd", "cron", "crond", "rsyslog",
+	"polkit", "NetworkManager", "networkd-dispatcher", "wpa_supplicant", "udisks2", "accounts-daemon",
+	"chronyd", "chrony", "ntpd", "auditd", "irqbalance", "tuned", "firewalld", "snapd", "unattended-upgrades",
+	"multipathd", "packagekit", "atd", "lvm2-", "user@", "containerd", "qemu-guest-agent", "cloud-",
+	"ModemManager", "upower", "thermald", "gssproxy", "rpcbind", "postfix", "kdump", "blk-availability",
+}
+
+// systemdUnits 列出运行中的非系统 systemd 服务；非 systemd 启动的主机（如容器）返回空
+func systemdUnits(ctx context.Context) ([]hub.DiscoveredUnit, error) {
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		return nil, nil
+	}
+	out, err := runCommand(ctx, "systemctl", "list-units", "--type=service", "--state=running", "--no-legend", "--no-pager", "--plain")
+	if err != nil {
+		return nil, err
+	}
+	var units []hub.DiscoveredUnit
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// 格式: UNIT LOAD ACTIVE SUB DESCRIPTION...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], ".service")
+		if isSystemUnit(name) {
+			continue
+		}
+		units = append(units, hub.DiscoveredUnit{Name: name, Description: strings.Join(fields[4:], " ")})
+	}
+	return units, nil
+}
+
+func isSystemUnit(name string) bool {
+	for _, prefix := range systemUnitPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dockerContainers 列出全部容器；未安装 docker 时返回空
+func dockerContainers(ctx context.Context) ([]hub.DiscoveredContainer, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, nil
+	}
+	out, err := runCommand(ctx, "docker", "ps", "-a", "--format", "{{json .}}")
+	if err != nil {
+		return nil, err
+	}
+	var containers []hub.DiscoveredContainer
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var row struct {
+			Names string `json:"Names"`
+			Image string `json:"Image"`
+			State string `json:"State"`
+			Ports string `json:"Ports"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			continue
+		}
+		containers = append(containers, hub.DiscoveredContainer{Name: row.Names, Image: row.Image, State: row.State, Ports: row.Ports})
+	}
+	return containers, nil
+}
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			msg, _, _ := strings.Cut(strings.TrimSpace(string(ee.Stderr)), "\n")
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,372 @@
+package hub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	artifactDir       = "configs/hub_artifacts"
+	artifactIndexFile = artifactDir + "/index.json"
+	artifactBlobDir   = artifactDir + "/blobs"
+)
+
+// 制品下载响应头
+const (
+	ArtifactNameHeader    = "X-Artifact-Name"
+	ArtifactVersionHeader = "X-Artifact-Version"
+	ArtifactFileHeader    = "X-Artifact-File"
+	ArtifactSHA256Header  = "X-Artifact-Sha256"
+)
+
+// HubArtifacts 制品库配置（app_config.json 的 hub.artifacts 字段）
+type HubArtifacts struct {
+	MaxSizeMB    int `json:"max_size_mb,omitempty"`   // 单个制品大小上限，默认 512
+	KeepVersions int `json:"keep_versions,omitempty"` // 每个制品保留的版本数，超出时删除最早上传的版本，默认 10
+}
+
+func (a HubArtifacts) maxSize() int64 {
+	return int64(orDefault(a.MaxSizeMB, 512)) << 20
+}
+
+func (a HubArtifacts) keepVersions() int {
+	return orDefault(a.KeepVersions, 10)
+}
+
+// Artifact 制品版本；内容按 SHA-256 寻址，相同内容的多个版本共享同一份文件
+type Artifact struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	FileName   string    `json:"file_name"` // 上传时的原始文件名，spoke 据扩展名决定落盘方式
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+var artifactStore struct {
+	mu sync.Mutex
+}
+
+func loadArtifactIndexLocked() ([]Artifact, error) {
+	data, err := os.ReadFile(artifactIndexFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取制品索引失败: %v", err)
+	}
+	var items []Artifact
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析制品索引失败: %v", err)
+	}
+	return items, nil
+}
+
+func saveArtifactIndexLocked(items []Artifact) error {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].UploadedAt.Before(items[j].UploadedAt)
+	})
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(artifactIndexFile, data, 0600)
+}
+
+func artifactBlobPath(sum string) string {
+	return filepath.Join(artifactBlobDir, sum)
+}
+
+// StoreArtifact 保存上传的制品；版本为空时按上传时间生成，同名同版本不可覆盖
+func StoreArtifact(settings HubSettings, name, version, fileName string, r io.Reader) (Artifact, error) {
+	if err := standbyGuard(); err != nil {
+		return Artifact{}, err
+	}
+	if !artifactNamePattern.MatchString(name) {
+		return Artifact{}, fmt.Errorf("无效的制品名称: %q（仅允许字母、数字、.、_、-）", name)
+	}
+	if version == "" {
+		version = time.Now().Format("20060102-150405")
+	}
+	if !artifactNamePattern.MatchString(version) || version == "latest" {
+		return Artifact{}, fmt.Errorf("无效的版本号: %q", version)
+	}
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == "/" || fileName == "" {
+		fileName = name
+	}
+
+	if err := os.MkdirAll(artifactBlobDir, 0755); err != nil {
+		return Artifact{}, err
+	}
+	tmp, err := os.CreateTemp(artifactBlobDir, ".upload-*.tmp")
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	limit := settings.Artifacts.maxSize()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, limit+1))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Artifact{}, fmt.Errorf("保存制品失败: %v", err)
+	}
+	if size > limit {
+		return Artifact{}, fmt.Errorf("制品超过大小上限 %d MB（hub.artifacts.max_size_mb）", limit>>20)
+	}
+	if size == 0 {
+		return Artifact{}, fmt.Errorf("制品内容为空")
+	}
+	art := Artifact{
+		Name:       name,
+		Version:    version,
+		SHA256:     hex.EncodeToString(h.Sum(nil)),
+		Size:       size,
+		FileName:   fileName,
+		UploadedAt: time.Now(),
+	}
+
+	artifactStore.mu.Lock()
+	defer artifactStore.mu.Unlock()
+	items, err := loadArtifactIndexLocked()
+	if err != nil {
+		return Artifact{}, err
+	}
+	for _, it := range items {
+		if it.Name == name && it.Version == version {
+			return Artifact{}, fmt.Errorf("制品 %s 版本 %s 已存在，版本不可覆盖", name, version)
+		}
+	}
+	if _, err := os.Stat(artifactBlobPath(art.SHA256)); os.IsNotExist(err) {
+		if err := os.Rename(tmp.Name(), artifactBlobPath(art.SHA256)); err != nil {
+			return Artifact{}, fmt.Errorf("保存制品失败: %v", err)
+		}
+	}
+	items = append(items, art)
+	items = pruneArtifactVersions(items, name, settings.Artifacts.keepVersions())
+	if err := saveArtifactIndexLocked(items); err != nil {
+		return Artifact{}, err
+	}
+	removeUnreferencedBlobs(items)
+	return art, nil
+}
+
+// pruneArtifactVersions 只保留指定制品最近上传的 keep 个版本
+func pruneArtifactVersions(items []Artifact, name string, keep int) []Artifact {
+	var versions []Artifact
+	for _, it := range items {
+		if it.Name == name {
+			versions = append(versions, it)
+		}
+	}
+	if len(versions) <= keep {
+		return items
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].UploadedAt.After(versions[j].UploadedAt) })
+	drop := make(map[string]bool)
+	for _, v := range versions[keep:] {
+		drop[v.Version] = true
+	}
+	out := items[:0]
+	for _, it := range items {
+		if it.Name == name && drop[it.Version] {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// removeUnreferencedBlobs 删除索引不再引用的内容文件
+func removeUnreferencedBlobs(items []Artifact) {
+	used := make(map[string]bool, len(items))
+	for _, it := range items {
+		used[it.SHA256] = true
+	}
+	entries, err := os.ReadDir(artifactBlobDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") && !used[e.Name()] {
+			_ = os.Remove(artifactBlobPath(e.Name()))
+		}
+	}
+}
+
+// ListArtifacts 列出制品（name 为空时列出全部），同一制品按上传时间从新到旧
+func ListArtifacts(name string) ([]Artifact, error) {
+	artifactStore.mu.Lock()
+	items, err := loadArtifactIndexLocked()
+	artifactStore.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Artifact, 0, len(items))
+	for _, it := range items {
+		if name == "" || it.Name == name {
+			out = append(out, it)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].UploadedAt.After(out[j].UploadedAt)
+	})
+	return out, nil
+}
+
+// FindArtifact 查找制品版本；版本为空或 latest 时返回最近上传的版本
+func FindArtifact(name, version string) (Artifact, error) {
+	items, err := ListArtifacts(name)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if len(items) == 0 {
+		return Artifact{}, fmt.Errorf("制品不存在: %s", name)
+	}
+	if version == "" || version == "latest" {
+		return items[0], nil
+	}
+	for _, it := range items {
+		if it.Version == version {
+			return it, nil
+		}
+	}
+	return Artifact{}, fmt.Errorf("制品 %s 没有版本 %s", name, version)
+}
+
+// DeleteArtifact 删除制品版本（不再被引用的内容文件一并删除）
+func DeleteArtifact(name, version string) error {
+	if err := standbyGuard(); err != nil {
+		return err
+	}
+	artifactStore.mu.Lock()
+	defer artifactStore.mu.Unlock()
+	items, err := loadArtifactIndexLocked()
+	if err != nil {
+		return err
+	}
+	out := items[:0]
+	found := false
+	for _, it := range items {
+		if it.Name == name && it.Version == version {
+			found = true
+			continue
+		}
+		out = append(out, it)
+	}
+	if !found {
+		return fmt.Errorf("制品 %s 没有版本 %s", name, version)
+	}
+	if err := saveArtifactIndexLocked(out); err != nil {
+		return err
+	}
+	removeUnreferencedBlobs(out)
+	return nil
+}
+
+// ArtifactsAdminHandler /hub/artifacts — GET 列出，POST 上传（请求体为文件内容，?name=&version=&filename=），DELETE ?name=&version=
+func ArtifactsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "artifacts", "管理制品需在 Hub 本机执行，或携带 mgmt.token") {
+		return
+	}
+	q := r.URL.Query()
+	name := strings.TrimSpace(q.Get("name"))
+	switch r.Method {
+	case http.MethodGet:
+		items, err := ListArtifacts(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"artifacts": items})
+	case http.MethodPost:
+		settings, _ := LoadHubSettings()
+		art, err := StoreArtifact(settings, name, strings.TrimSpace(q.Get("version")), q.Get("filename"), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(art)
+	case http.MethodDelete:
+		if err := DeleteArtifact(name, strings.TrimSpace(q.Get("version"))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+	default:
+		http.Error(w, "只允许 GET/POST/DELETE", http.StatusMethodNotAllowed)
+	}
+}
+
+// ArtifactHandler POST /__hub__/v1/artifact — spoke 下载制品（请求体 {"name","version"} 参与签名），
+// 响应头携带 SHA-256 供 spoke 校验
+func ArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	_, body, ok := authenticateSpoke(w, r, "artifact")
+	if !ok {
+		return
+	}
+	var req struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "无效请求体", http.StatusBadRequest)
+		return
+	}
+	art, err := FindArtifact(strings.TrimSpace(req.Name), strings.TrimSpace(req.Version))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(artifactBlobPath(art.SHA256))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "制品内容文件缺失: "+art.SHA256, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(art.Size, 10))
+	w.Header().Set(ArtifactNameHeader, art.Name)
+	w.Header().Set(ArtifactVersionHeader, art.Version)
+	w.Header().Set(ArtifactFileHeader, art.FileName)
+	w.Header().Set(ArtifactSHA256Header, art.SHA256)
+	io.Copy(w, f)
+}
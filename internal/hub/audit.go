@@ -0,0 +1,334 @@
+package hub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+const auditDir = "configs/hub_audit"
+
+const defaultAuditRetentionDays = 90
+
+// HubAudit 审计日志配置（hub.audit 字段）
+type HubAudit struct {
+	Enabled       *bool    `json:"enabled,omitempty"`        // 默认开启
+	RetentionDays int      `json:"retention_days,omitempty"` // 保留天数，默认 90，<0 表示永久保留
+	Redact        []string `json:"redact,omitempty"`         // 额外脱敏正则，匹配内容替换为 [REDACTED]
+}
+
+// IsEnabled 未显式关闭即视为开启
+func (a HubAudit) IsEnabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+func (a HubAudit) retention() int {
+	if a.RetentionDays == 0 {
+		return defaultAuditRetentionDays
+	}
+	return a.RetentionDays
+}
+
+// AuditEntry 一次中转对话的审计记录
+type AuditEntry struct {
+	Time       time.Time        `json:"time"`
+	Spoke      string           `json:"spoke"`
+	Provider   string           `json:"provider,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	Request    []agent.Message  `json:"request"` // 本轮新增消息（上一条 assistant 之后），完整历史已在更早的记录中
+	Response   string           `json:"response,omitempty"`
+	ToolCalls  []agent.ToolCall `json:"tool_calls,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Usage      *agent.Usage     `json:"usage,omitempty"` // 上游提供商上报的 token 用量
+	DurationMs int64            `json:"duration_ms"`
+}
+
+// AuditQuery 审计日志查询条件（零值表示不限）
+type AuditQuery struct {
+	Spoke string
+	Since time.Time
+	Until time.Time
+	Tool  string
+	Limit int
+}
+
+// 内置脱敏规则：常见 API Key、Bearer 凭证、私钥块
+var builtinRedactions = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:sk|sk-ant|rk|pk)-[A-Za-z0-9_\-]{16,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9._~+/=\-]{16,}`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// 口令赋值（password=xxx、"api_key": "xxx"）只替换值，保留键名便于排查
+var assignmentRedaction = regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret|token|api[_-]?key)["']?\s*[:=]\s*["']?)[^\s"',;&]+`)
+
+var (
+	auditMu       sync.Mutex
+	auditRedactMu sync.Mutex
+	auditRedactRx = map[string]*regexp.Regexp{}
+)
+
+func redactPatterns(cfg HubAudit) []*regexp.Regexp {
+	out := append([]*regexp.Regexp{}, builtinRedactions...)
+	auditRedactMu.Lock()
+	defer auditRedactMu.Unlock()
+	for _, pat := range cfg.Redact {
+		rx, ok := auditRedactRx[pat]
+		if !ok {
+			var err error
+			rx, err = regexp.Compile(pat)
+			if err != nil {
+				log.Printf("[hub] 忽略无效的审计脱敏规则 %q: %v", pat, err)
+			}
+			auditRedactRx[pat] = rx
+		}
+		if rx != nil {
+			out = append(out, rx)
+		}
+	}
+	return out
+}
+
+func redactText(s string, patterns []*regexp.Regexp) string {
+	if s == "" {
+		return s
+	}
+	s = assignmentRedaction.ReplaceAllString(s, "${1}[REDACTED]")
+	for _, rx := range patterns {
+		s = rx.ReplaceAllLiteralString(s, "[REDACTED]")
+	}
+	return s
+}
+
+// newRequestMessages 取最后一条 assistant 之后的消息，即本次请求新增的内容
+func newRequestMessages(messages []agent.Message) []agent.Message {
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" {
+			start = i + 1
+			break
+		}
+	}
+	out := make([]agent.Message, 0, len(messages)-start)
+	for _, m := range messages[start:] {
+		if m.Role == "system" {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func auditFile(spokeID string) string {
+	return filepath.Join(auditDir, filepath.Base(spokeID)+".jsonl")
+}
+
+// recordAudit 脱敏后追加一条审计记录；失败只记日志，不影响中转
+func recordAudit(cfg HubAudit, entry AuditEntry) {
+	if !cfg.IsEnabled() || entry.Spoke == "" {
+		return
+	}
+	patterns := redactPatterns(cfg)
+	for i := range entry.Request {
+		m := &entry.Request[i]
+		m.Content = redactText(m.Content, patterns)
+		m.ReasoningContent = ""
+	}
+	entry.Response = redactText(entry.Response, patterns)
+	for i := range entry.ToolCalls {
+		entry.ToolCalls[i].Arguments = redactText(entry.ToolCalls[i].Arguments, patterns)
+	}
+	entry.Error = redactText(entry.Error, patterns)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[hub] 审计记录序列化失败: %v", err)
+		return
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(auditDir, 0700); err != nil {
+		log.Printf("[hub] 创建审计目录失败: %v", err)
+		return
+	}
+	f, err := os.OpenFile(auditFile(entry.Spoke), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("[hub] 打开审计日志失败: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("[hub] 写入审计日志失败: %v", err)
+	}
+}
+
+func (q AuditQuery) match(e AuditEntry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Tool != "" {
+		found := false
+		for _, tc := range e.ToolCalls {
+			if tc.Name == q.Tool {
+				found = true
+				break
+			}
+		}
+		// 工具执行结果随下一次请求上报（role=tool）
+		for _, m := range e.Request {
+			if m.Role == "tool" && m.Name == q.Tool {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// QueryAudit 按条件查询审计日志，按时间升序返回最近 Limit 条
+func QueryAudit(q AuditQuery) ([]AuditEntry, error) {
+	var files []string
+	if q.Spoke != "" {
+		files = []string{auditFile(q.Spoke)}
+	} else {
+		matches, err := filepath.Glob(filepath.Join(auditDir, "*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		files = matches
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	var out []AuditEntry
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("读取审计日志失败: %v", err)
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 16<<20)
+		for sc.Scan() {
+			var e AuditEntry
+			if json.Unmarshal(sc.Bytes(), &e) != nil {
+				continue
+			}
+			if q.match(e) {
+				out = append(out, e)
+			}
+		}
+		f.Close()
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out, nil
+}
+
+// PruneAudit 删除超过保留期的审计记录
+func PruneAudit(cfg HubAudit) error {
+	days := cfg.retention()
+	if days < 0 {
+		return nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	files, err := filepath.Glob(filepath.Join(auditDir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var kept bytes.Buffer
+		dropped := 0
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var e struct {
+				Time time.Time `json:"time"`
+			}
+			if json.Unmarshal(line, &e) == nil && e.Time.Before(cutoff) {
+				dropped++
+				continue
+			}
+			kept.Write(line)
+			kept.WriteByte('\n')
+		}
+		if dropped == 0 {
+			continue
+		}
+		if kept.Len() == 0 {
+			os.Remove(path)
+			continue
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, kept.Bytes(), 0600); err != nil {
+			return fmt.Errorf("写入审计日志失败: %v", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("替换审计日志失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// StartAuditMaintenance 启动时及每天清理一次过期审计记录
+func StartAuditMaintenance() {
+	go func() {
+		for {
+			settings, _ := LoadHubSettings()
+			if err := PruneAudit(settings.Audit); err != nil {
+				log.Printf("[hub] 清理审计日志失败: %v", err)
+			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
+
+// ParseAuditTime 解析查询时间：RFC3339、日期、日期+时间，或相对时长（如 24h 表示 24 小时前）
+func ParseAuditTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", s)
+}
@@ -0,0 +1,134 @@
+package hub
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+// HubSecurity Spoke 请求认证策略（app_config.json 的 hub.security 字段）
+type HubSecurity struct {
+	RequireSignature bool         `json:"require_signature,omitempty"` // 拒绝仅携带 Bearer 凭证的请求
+	MaxSkewSeconds   int          `json:"max_skew_seconds,omitempty"`  // 签名时间戳允许的偏差，默认 300
+	RateLimit        HubRateLimit `json:"rate_limit,omitempty"`        // 公开端点限流、认证失败锁定与请求体上限
+}
+
+func (s HubSecurity) maxSkew() time.Duration {
+	if s.MaxSkewSeconds > 0 {
+		return time.Duration(s.MaxSkewSeconds) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+// nonceCache 记录时间窗内已使用的随机数，拒绝重放
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+var usedNonces = &nonceCache{seen: make(map[string]time.Time)}
+
+// use 首次出现返回 true；过期记录按时间窗定期清理
+func (c *nonceCache) use(key string, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.lastPrune) > window {
+		for k, t := range c.seen {
+			if now.Sub(t) > 2*window {
+				delete(c.seen, k)
+			}
+		}
+		c.lastPrune = now
+	}
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = now
+	return true
+}
+
+// authenticateSpoke 校验 spoke 请求（HMAC 签名或 Bearer 凭证）、按端点限制请求体并按 spoke 限流；失败时已写入响应
+func authenticateSpoke(w http.ResponseWriter, r *http.Request, endpoint string) (string, []byte, bool) {
+	settings, _ := LoadHubSettings()
+	limits := settings.Security.RateLimit
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.bodyLimit(endpoint)))
+	if err != nil {
+		if !recordBodyTooLarge(w, r, endpoint, err) {
+			http.Error(w, "读取请求失败", http.StatusBadRequest)
+		}
+		return "", nil, false
+	}
+
+	var spokeID string
+	if r.Header.Get(agent.HubSignatureHeader) != "" {
+		spokeID, err = verifySignedRequest(r, body, settings.Security)
+		if err != nil {
+			recordAuthFailure(r, limits, endpoint, r.Header.Get(agent.HubSpokeHeader), err.Error())
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return "", nil, false
+		}
+	} else {
+		if settings.Security.RequireSignature {
+			http.Error(w, "Hub 要求请求签名，请在 Spoke 的 ai.hub_auth 中设置 hmac", http.StatusUnauthorized)
+			return "", nil, false
+		}
+		secret := bearerToken(r)
+		if secret == "" {
+			http.Error(w, "缺少 Authorization", http.StatusUnauthorized)
+			return "", nil, false
+		}
+		var ok bool
+		if spokeID, ok = ValidateSpokeToken(secret); !ok {
+			recordAuthFailure(r, limits, endpoint, "", "无效或已吊销的凭证")
+			http.Error(w, "无效或已吊销的凭证", http.StatusUnauthorized)
+			return "", nil, false
+		}
+	}
+	recordAuthSuccess(r)
+	if !allowSpoke(w, r, limits, spokeID, endpoint) {
+		return "", nil, false
+	}
+	return spokeID, body, true
+}
+
+func verifySignedRequest(r *http.Request, body []byte, sec HubSecurity) (string, error) {
+	spokeID := r.Header.Get(agent.HubSpokeHeader)
+	ts := r.Header.Get(agent.HubTimestampHeader)
+	nonce := r.Header.Get(agent.HubNonceHeader)
+	sig := r.Header.Get(agent.HubSignatureHeader)
+	if spokeID == "" || ts == "" || len(nonce) < 16 {
+		return "", fmt.Errorf("签名请求头不完整")
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("无效的签名时间戳")
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > sec.maxSkew() {
+		return "", fmt.Errorf("签名已过期或时钟偏差过大（%s）", skew.Round(time.Second))
+	}
+	spokeID, key, err := spokeSigningKey(spokeID)
+	if err != nil {
+		return "", err
+	}
+	expected := agent.HubSignature(key, r.Method, r.URL.Path, ts, nonce, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", fmt.Errorf("签名校验失败")
+	}
+	if !usedNonces.use(spokeID+":"+nonce, sec.maxSkew()) {
+		return "", fmt.Errorf("重复的请求（nonce 已使用）")
+	}
+	touchSpoke(spokeID)
+	return spokeID, nil
+}
@@ -0,0 +1,128 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+// addTestSpoke 向内存注册表加入一条记录，测试结束时移除并取消延迟落盘
+func addTestSpoke(t *testing.T, id, secret string) {
+	t.Helper()
+	rec := &SpokeRecord{ID: id, TokenHash: hashToken(secret), SigningKey: agent.HubSigningKey(secret)}
+	defaultStore.mu.Lock()
+	defaultStore.spokes[id] = rec
+	defaultStore.byHash[rec.TokenHash] = id
+	defaultStore.mu.Unlock()
+	t.Cleanup(func() {
+		defaultStore.mu.Lock()
+		defer defaultStore.mu.Unlock()
+		delete(defaultStore.spokes, id)
+		delete(defaultStore.byHash, rec.TokenHash)
+		if defaultStore.flushTimer != nil {
+			defaultStore.flushTimer.Stop()
+			defaultStore.flushTimer = nil
+		}
+		defaultStore.dirty = false
+	})
+}
+
+func signedTestRequest(spokeID, secret string, at time.Time, nonce string, signedBody []byte) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, "http://hub.example/__hub__/v1/chat", nil)
+	ts := strconv.FormatInt(at.Unix(), 10)
+	req.Header.Set(agent.HubSpokeHeader, spokeID)
+	req.Header.Set(agent.HubTimestampHeader, ts)
+	req.Header.Set(agent.HubNonceHeader, nonce)
+	req.Header.Set(agent.HubSignatureHeader, agent.HubSignature(agent.HubSigningKey(secret), req.Method, req.URL.Path, ts, nonce, signedBody))
+	return req
+}
+
+func TestVerifySignedRequest(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	const legacySecret = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	spokeID := agent.HubSpokeID(secret)
+	legacyID := "spoke-" + legacySecret[:8]
+	addTestSpoke(t, spokeID, secret)
+	addTestSpoke(t, legacyID, legacySecret)
+
+	if strings.Contains(spokeID, secret[:8]) {
+		t.Fatalf("spoke ID %s 不应包含凭证前缀", spokeID)
+	}
+
+	body := []byte(`{"messages":[]}`)
+	now := time.Now()
+	sec := HubSecurity{}
+	tests := []struct {
+		name   string
+		req    *http.Request
+		body   []byte
+		wantID string
+		err    string // 期望错误中包含的内容，空表示应通过
+	}{
+		{name: "有效签名", req: signedTestRequest(spokeID, secret, now, "nonce-valid-0001", body), body: body, wantID: spokeID},
+		{name: "旧版 ID 注册的 spoke", req: signedTestRequest(agent.HubSpokeID(legacySecret), legacySecret, now, "nonce-legacy-001", body), body: body, wantID: legacyID},
+		{name: "时间戳过期", req: signedTestRequest(spokeID, secret, now.Add(-10*time.Minute), "nonce-expired-01", body), body: body, err: "已过期"},
+		{name: "时间戳超前", req: signedTestRequest(spokeID, secret, now.Add(10*time.Minute), "nonce-future-001", body), body: body, err: "已过期"},
+		{name: "请求体被篡改", req: signedTestRequest(spokeID, secret, now, "nonce-body-00001", body), body: []byte(`{"messages":[{}]}`), err: "签名校验失败"},
+		{name: "凭证错误", req: signedTestRequest(spokeID, legacySecret, now, "nonce-wrongkey01", body), body: body, err: "签名校验失败"},
+		{name: "未知 spoke", req: signedTestRequest("spoke-00000000", secret, now, "nonce-unknown-01", body), body: body, err: "无效或已吊销"},
+		{name: "随机数过短", req: signedTestRequest(spokeID, secret, now, "short", body), body: body, err: "请求头不完整"},
+	}
+	for _, tt := range tests {
+		id, err := verifySignedRequest(tt.req, tt.body, sec)
+		if tt.err == "" {
+			if err != nil || id != tt.wantID {
+				t.Errorf("%s: 应通过并识别为 %s，实际 %q, %v", tt.name, tt.wantID, id, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: 期望错误包含 %q，实际: %v", tt.name, tt.err, err)
+		}
+	}
+
+	// 同一请求重放：首次通过，第二次因 nonce 已使用被拒绝
+	replay := signedTestRequest(spokeID, secret, now, "nonce-replay-001", body)
+	if _, err := verifySignedRequest(replay, body, sec); err != nil {
+		t.Fatalf("首次请求应通过: %v", err)
+	}
+	if _, err := verifySignedRequest(replay, body, sec); err == nil || !strings.Contains(err.Error(), "nonce 已使用") {
+		t.Errorf("重放请求应被拒绝，实际: %v", err)
+	}
+}
+
+func TestImportStateIterationBounds(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, iterations := range []int{0, bundleIterations - 1, bundleMaxIterations + 1, 1 << 40} {
+		data, _ := json.Marshal(encryptedBundle{
+			Version:    bundleVersion,
+			KDF:        bundleKDF,
+			Iterations: iterations,
+			Salt:       make([]byte, 16),
+			Nonce:      make([]byte, 12),
+			Data:       make([]byte, 32),
+		})
+		start := time.Now()
+		_, err := ImportState(data, "passphrase", false)
+		if err == nil || !strings.Contains(err.Error(), "迭代次数") {
+			t.Errorf("迭代次数 %d 应被拒绝，实际: %v", iterations, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("迭代次数 %d 应在派生密钥前被拒绝，实际耗时 %s", iterations, elapsed)
+		}
+	}
+
+	// 正常导出的文件在上下限之内：口令错误时应走到解密步骤
+	data, _, err := ExportState("correct passphrase")
+	if err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	if _, err := ImportState(data, "wrong passphrase", false); err == nil || !strings.Contains(err.Error(), "解密失败") {
+		t.Errorf("错误口令应在解密时失败，实际: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package hub
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	bundleVersion    = 1
+	bundleKDF        = "pbkdf2-sha256"
+	bundleIterations = 600000
+	// bundleMaxIterations 导入时允许的迭代次数上限，防止伪造的导出文件长时间占用 CPU
+	bundleMaxIterations = 10 * bundleIterations
+)
+
+// stateBundle Hub 完整状态（加密前）
+type stateBundle struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Spokes     []storedSpoke   `json:"spokes"` // 含凭证，仅存在于加密内容中
+	AppConfig  json.RawMessage `json:"app_config,omitempty"`
+}
+
+// encryptedBundle 导出文件格式：口令经 PBKDF2 派生 AES-256-GCM 密钥
+type encryptedBundle struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// BundleSummary 导入/导出摘要
+type BundleSummary struct {
+	ExportedAt time.Time `json:"exported_at"`
+	Spokes     int       `json:"spokes"`
+	Revoked    int       `json:"revoked"`
+	HasConfig  bool      `json:"has_config"`
+}
+
+// ExportState 导出 spoke 注册表与 app_config.json，使用口令加密
+func ExportState(passphrase string) ([]byte, BundleSummary, error) {
+	var summary BundleSummary
+	if len(passphrase) < 8 {
+		return nil, summary, fmt.Errorf("口令至少 8 个字符")
+	}
+	if err := FlushSpokes(); err != nil {
+		return nil, summary, fmt.Errorf("保存 spoke 注册表失败: %v", err)
+	}
+	records, err := defaultStore.storage.Load()
+	if err != nil {
+		return nil, summary, err
+	}
+	bundle := stateBundle{
+		Version:    bundleVersion,
+		ExportedAt: time.Now(),
+		Spokes:     toStoredSpokes(records),
+	}
+	if data, err := os.ReadFile(appConfigFile); err == nil && json.Valid(data) {
+		bundle.AppConfig = data
+	}
+	plain, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, summary, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, summary, err
+	}
+	gcm, err := bundleCipher(passphrase, salt, bundleIterations)
+	if err != nil {
+		return nil, summary, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, summary, err
+	}
+	out, err := json.MarshalIndent(encryptedBundle{
+		Version:    bundleVersion,
+		KDF:        bundleKDF,
+		Iterations: bundleIterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plain, []byte(bundleKDF)),
+	}, "", "  ")
+	if err != nil {
+		return nil, summary, err
+	}
+	return out, summarizeBundle(bundle), nil
+}
+
+// ImportState 解密导出包并覆盖本机 spoke 注册表；withConfig 为 true 时同时覆盖 app_config.json
+func ImportState(data []byte, passphrase string, withConfig bool) (BundleSummary, error) {
+	var summary BundleSummary
+	var enc encryptedBundle
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return summary, fmt.Errorf("无法识别的导出文件: %v", err)
+	}
+	if enc.Version != bundleVersion || enc.KDF != bundleKDF {
+		return summary, fmt.Errorf("不支持的导出文件版本: v%d %s", enc.Version, enc.KDF)
+	}
+	if enc.Iterations < bundleIterations || enc.Iterations > bundleMaxIterations {
+		return summary, fmt.Errorf("导出文件参数无效: 迭代次数 %d 不在 %d-%d 之间", enc.Iterations, bundleIterations, bundleMaxIterations)
+	}
+	gcm, err := bundleCipher(passphrase, enc.Salt, enc.Iterations)
+	if err != nil {
+		return summary, err
+	}
+	if len(enc.Nonce) != gcm.NonceSize() {
+		return summary, fmt.Errorf("导出文件已损坏")
+	}
+	plain, err := gcm.Open(nil, enc.Nonce, enc.Data, []byte(bundleKDF))
+	if err != nil {
+		return summary, fmt.Errorf("解密失败：口令错误或文件已损坏")
+	}
+	var bundle stateBundle
+	if err := json.Unmarshal(plain, &bundle); err != nil {
+		return summary, fmt.Errorf("解析导出内容失败: %v", err)
+	}
+
+	if withConfig && len(bundle.AppConfig) > 0 {
+		if err := writeFileAtomic(appConfigFile, bundle.AppConfig, 0600); err != nil {
+			return summary, fmt.Errorf("写入 app_config.json 失败: %v", err)
+		}
+	}
+	if err := ReplaceSpokes(fromStoredSpokes(bundle.Spokes)); err != nil {
+		return summary, err
+	}
+	return summarizeBundle(bundle), nil
+}
+
+func bundleCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations < 10000 || len(salt) < 8 {
+		return nil, fmt.Errorf("导出文件参数无效")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func summarizeBundle(b stateBundle) BundleSummary {
+	s := BundleSummary{ExportedAt: b.ExportedAt, Spokes: len(b.Spokes), HasConfig: len(b.AppConfig) > 0}
+	for _, rec := range b.Spokes {
+		if rec.Revoked {
+			s.Revoked++
+		}
+	}
+	return s
+}
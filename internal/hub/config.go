@@ -9,7 +9,14 @@ const appConfigFile = "configs/app_config.json"
 
 // HubSettings Hub 开关配置（存于 app_config.json 的 hub 字段）
 type HubSettings struct {
-	Enabled bool `json:"enabled"`
+	Enabled     bool           `json:"enabled"`
+	Providers   []HubProvider  `json:"providers,omitempty"`   // 上游 AI 提供商池，留空则使用 ai 字段
+	Routing     HubRouting     `json:"routing,omitempty"`     // 提供商路由与故障转移规则
+	Audit       HubAudit       `json:"audit,omitempty"`       // 中转对话审计日志
+	Security    HubSecurity    `json:"security,omitempty"`    // Spoke 请求认证策略
+	Replication HubReplication `json:"replication,omitempty"` // 主备复制
+	Policies    []PolicyRule   `json:"policies,omitempty"`    // 下发给 spoke 的提示词与工具策略
+	Artifacts   HubArtifacts   `json:"artifacts,omitempty"`   // 制品库
 }
 
 // LoadHubSettings 读取 Hub 开关
@@ -33,7 +40,7 @@ func LoadHubSettings() (HubSettings, error) {
 	return settings, nil
 }
 
-// SaveHubEnabled 更新 hub.enabled 并写回 app_config.json
+// SaveHubEnabled 更新 hub.enabled 并写回 app_config.json（保留 hub 下其他字段）
 func SaveHubEnabled(enabled bool) error {
 	var root map[string]json.RawMessage
 	if data, err := os.ReadFile(appConfigFile); err == nil {
@@ -42,8 +49,15 @@ func SaveHubEnabled(enabled bool) error {
 	if root == nil {
 		root = make(map[string]json.RawMessage)
 	}
-	settings := HubSettings{Enabled: enabled}
-	raw, err := json.Marshal(settings)
+	var hubRoot map[string]json.RawMessage
+	if raw, ok := root["hub"]; ok {
+		_ = json.Unmarshal(raw, &hubRoot)
+	}
+	if hubRoot == nil {
+		hubRoot = make(map[string]json.RawMessage)
+	}
+	hubRoot["enabled"], _ = json.Marshal(enabled)
+	raw, err := json.Marshal(hubRoot)
 	if err != nil {
 		return err
 	}
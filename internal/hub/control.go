@@ -0,0 +1,357 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 远程命令状态
+const (
+	CommandQueued          = "queued"           // 等待 spoke 拉取
+	CommandSent            = "sent"             // 已下发，spoke 尚未回报
+	CommandRunning         = "running"          // 执行中（输出持续回传）
+	CommandPendingApproval = "pending_approval" // 等待 spoke 本地确认
+	CommandDone            = "done"
+	CommandFailed          = "failed"
+	CommandRejected        = "rejected" // spoke 策略拒绝或本地拒绝
+)
+
+// ControlOps 可下发的运维操作白名单，值表示是否为写操作
+var ControlOps = map[string]bool{
+	"status":        false,
+	"logs":          false,
+	"proxy-status":  false,
+	"start":         true,
+	"stop":          true,
+	"restart":       true,
+	"deploy":        true,
+	"deploy-lowmem": true,
+	"switch":        true,
+	"pull-artifact": true,
+}
+
+// IsWriteOp 是否为写操作（未知操作按写操作处理）
+func IsWriteOp(op string) bool {
+	write, ok := ControlOps[op]
+	return !ok || write
+}
+
+const (
+	maxCommandOutput   = 1 << 20 // 单条命令保留的输出上限
+	maxKeptCommands    = 500
+	commandStaleAfter  = 15 * time.Minute // 排队、下发或执行中无回报视为失败
+	commandApprovalTTL = time.Hour        // 等待 spoke 本地确认的最长时间
+)
+
+// ControlCommand Hub 下发给 spoke 的远程命令
+type ControlCommand struct {
+	ID         string            `json:"id"`
+	Spoke      string            `json:"spoke"`
+	Op         string            `json:"op"`
+	Service    string            `json:"service,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Status     string            `json:"status"`
+	Output     string            `json:"output,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	FinishedAt time.Time         `json:"finished_at,omitempty"`
+}
+
+// Finished 命令是否已结束
+func (c ControlCommand) Finished() bool {
+	return c.Status == CommandDone || c.Status == CommandFailed || c.Status == CommandRejected
+}
+
+// ControlResult spoke 回报的执行结果（Output 为增量片段）
+type ControlResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type controlQueue struct {
+	mu       sync.Mutex
+	commands map[string]*ControlCommand
+	order    []string
+	pending  map[string][]string      // spoke ID → 待拉取的命令 ID
+	waiters  map[string]chan struct{} // spoke ID → 长轮询唤醒信号
+}
+
+var defaultControl = &controlQueue{
+	commands: make(map[string]*ControlCommand),
+	pending:  make(map[string][]string),
+	waiters:  make(map[string]chan struct{}),
+}
+
+// EnqueueCommand 为指定 spoke 排入一条远程命令
+func EnqueueCommand(spokeID, op, service string, args map[string]string) (ControlCommand, error) {
+	if _, ok := ControlOps[op]; !ok {
+		return ControlCommand{}, fmt.Errorf("不支持的操作: %s", op)
+	}
+	rec, ok := GetSpoke(spokeID)
+	if !ok {
+		return ControlCommand{}, fmt.Errorf("spoke 不存在: %s", spokeID)
+	}
+	if rec.Revoked {
+		return ControlCommand{}, fmt.Errorf("spoke 已吊销: %s", spokeID)
+	}
+	suffix, err := randomHex(6)
+	if err != nil {
+		return ControlCommand{}, err
+	}
+	now := time.Now()
+	cmd := &ControlCommand{
+		ID:        "cmd-" + suffix,
+		Spoke:     spokeID,
+		Op:        op,
+		Service:   service,
+		Args:      args,
+		Status:    CommandQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	q := defaultControl
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.commands[cmd.ID] = cmd
+	q.order = append(q.order, cmd.ID)
+	q.pending[spokeID] = append(q.pending[spokeID], cmd.ID)
+	q.trimLocked()
+	if ch, ok := q.waiters[spokeID]; ok {
+		close(ch)
+		delete(q.waiters, spokeID)
+	}
+	return *cmd, nil
+}
+
+// trimLocked 将超时命令标记为失败，超出上限时从最早的已结束命令开始清理（未结束的命令跳过保留）
+func (q *controlQueue) trimLocked() {
+	now := time.Now()
+	excess := len(q.order) - maxKeptCommands
+	kept := q.order[:0]
+	for _, id := range q.order {
+		cmd, ok := q.commands[id]
+		if !ok {
+			excess--
+			continue
+		}
+		q.expireLocked(cmd, now)
+		if excess > 0 && cmd.Finished() {
+			delete(q.commands, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	q.order = kept
+}
+
+// takeLocked 取出 spoke 的待下发命令并标记为已下发
+func (q *controlQueue) takeLocked(spokeID string) []ControlCommand {
+	q.trimLocked()
+	ids := q.pending[spokeID]
+	if len(ids) == 0 {
+		return nil
+	}
+	delete(q.pending, spokeID)
+	now := time.Now()
+	out := make([]ControlCommand, 0, len(ids))
+	for _, id := range ids {
+		cmd, ok := q.commands[id]
+		if !ok || cmd.Finished() {
+			continue
+		}
+		cmd.Status = CommandSent
+		cmd.UpdatedAt = now
+		out = append(out, *cmd)
+	}
+	return out
+}
+
+// PollCommands 长轮询：有命令立即返回，否则等待至超时或请求取消
+func PollCommands(ctx context.Context, spokeID string, wait time.Duration) []ControlCommand {
+	q := defaultControl
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	for {
+		q.mu.Lock()
+		if cmds := q.takeLocked(spokeID); len(cmds) > 0 {
+			q.mu.Unlock()
+			return cmds
+		}
+		ch, ok := q.waiters[spokeID]
+		if !ok {
+			ch = make(chan struct{})
+			q.waiters[spokeID] = ch
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-deadline.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// ReportCommandResult 记录 spoke 回报的状态与增量输出
+func ReportCommandResult(spokeID string, res ControlResult) error {
+	q := defaultControl
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cmd, ok := q.commands[res.ID]
+	if !ok || cmd.Spoke != spokeID {
+		return fmt.Errorf("命令不存在: %s", res.ID)
+	}
+	if cmd.Finished() {
+		return fmt.Errorf("命令已结束: %s", res.ID)
+	}
+	switch res.Status {
+	case CommandRunning, CommandPendingApproval, CommandDone, CommandFailed, CommandRejected:
+	default:
+		return fmt.Errorf("无效状态: %s", res.Status)
+	}
+	if res.Output != "" && len(cmd.Output) < maxCommandOutput {
+		out := res.Output
+		if room := maxCommandOutput - len(cmd.Output); len(out) > room {
+			out = out[:room] + "\n[... 输出过长已截断 ...]"
+		}
+		cmd.Output += out
+	}
+	if res.Error != "" {
+		cmd.Error = res.Error
+	}
+	cmd.Status = res.Status
+	cmd.UpdatedAt = time.Now()
+	if cmd.Finished() {
+		cmd.FinishedAt = cmd.UpdatedAt
+	}
+	return nil
+}
+
+// expireLocked 长时间无回报或无人确认的命令标记为失败（spoke 可能已离线）
+func (q *controlQueue) expireLocked(cmd *ControlCommand, now time.Time) {
+	if cmd.Finished() {
+		return
+	}
+	ttl, reason := commandStaleAfter, "spoke 长时间未回报，已超时"
+	if cmd.Status == CommandPendingApproval {
+		ttl, reason = commandApprovalTTL, "spoke 本地确认超时"
+	}
+	if now.Sub(cmd.UpdatedAt) <= ttl {
+		return
+	}
+	if cmd.Status == CommandQueued {
+		ids := q.pending[cmd.Spoke]
+		for i, id := range ids {
+			if id == cmd.ID {
+				ids = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(q.pending, cmd.Spoke)
+		} else {
+			q.pending[cmd.Spoke] = ids
+		}
+	}
+	cmd.Status = CommandFailed
+	cmd.Error = reason
+	cmd.UpdatedAt = now
+	cmd.FinishedAt = now
+}
+
+// GetCommand 返回命令副本
+func GetCommand(id string) (ControlCommand, bool) {
+	q := defaultControl
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cmd, ok := q.commands[id]
+	if !ok {
+		return ControlCommand{}, false
+	}
+	q.expireLocked(cmd, time.Now())
+	return *cmd, true
+}
+
+// ListCommands 返回最近的命令（可按 spoke 过滤），按创建时间倒序
+func ListCommands(spokeID string, limit int) []ControlCommand {
+	q := defaultControl
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	var out []ControlCommand
+	for i := len(q.order) - 1; i >= 0; i-- {
+		cmd, ok := q.commands[q.order[i]]
+		if !ok || (spokeID != "" && cmd.Spoke != spokeID) {
+			continue
+		}
+		q.expireLocked(cmd, now)
+		c := *cmd
+		c.Output = ""
+		out = append(out, c)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// ResolveSpokeTargets 解析目标 spoke：all 表示全部未吊销节点；含 = 或 ! 的按标签选择器匹配，否则为逗号分隔的 ID
+func ResolveSpokeTargets(targets []string) ([]string, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if LooksLikeSelector(t) {
+			matched, err := SelectSpokes(t)
+			if err != nil {
+				return nil, err
+			}
+			for _, rec := range matched {
+				if !rec.Revoked {
+					add(rec.ID)
+				}
+			}
+			continue
+		}
+		for _, id := range strings.Split(t, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			if id == "all" {
+				for _, rec := range ListSpokes() {
+					if !rec.Revoked {
+						add(rec.ID)
+					}
+				}
+				continue
+			}
+			if _, ok := GetSpoke(id); !ok {
+				return nil, fmt.Errorf("spoke 不存在: %s", id)
+			}
+			add(id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("未匹配到任何 spoke")
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
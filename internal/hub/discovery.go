@@ -0,0 +1,54 @@
+package hub
+
+import "time"
+
+// SpokeDiscovery spoke 自动发现的运行环境快照
+type SpokeDiscovery struct {
+	Processes  []DiscoveredProcess   `json:"processes,omitempty"`  // Java/Node/Python 应用进程
+	Ports      []DiscoveredPort      `json:"ports,omitempty"`      // TCP 监听端口
+	Units      []DiscoveredUnit      `json:"units,omitempty"`      // 运行中的 systemd 服务（已排除系统自带服务）
+	Sites      []DiscoveredSite      `json:"sites,omitempty"`      // nginx server 块
+	Containers []DiscoveredContainer `json:"containers,omitempty"` // docker 容器
+	Errors     []string              `json:"errors,omitempty"`     // 未能采集的来源（如无权限）
+	At         time.Time             `json:"at"`
+}
+
+// DiscoveredProcess 应用进程
+type DiscoveredProcess struct {
+	PID     int    `json:"pid"`
+	Runtime string `json:"runtime"` // java/node/python
+	Name    string `json:"name"`    // jar 名、入口脚本或主类
+	Ports   []int  `json:"ports,omitempty"`
+	Command string `json:"command,omitempty"` // 截断后的命令行
+}
+
+// DiscoveredPort 监听端口
+type DiscoveredPort struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"`
+	Process string `json:"process,omitempty"`
+	PID     int    `json:"pid,omitempty"`
+}
+
+// DiscoveredUnit systemd 服务
+type DiscoveredUnit struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// DiscoveredSite nginx server 块
+type DiscoveredSite struct {
+	ServerNames []string `json:"server_names,omitempty"`
+	Listen      []string `json:"listen,omitempty"`
+	ProxyPass   []string `json:"proxy_pass,omitempty"`
+	Root        string   `json:"root,omitempty"`
+	File        string   `json:"file"`
+}
+
+// DiscoveredContainer docker 容器
+type DiscoveredContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	State string `json:"state"` // running/exited/...
+	Ports string `json:"ports,omitempty"`
+}
@@ -2,13 +2,16 @@ package hub
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"ruoyi-proxy/internal/agent"
+	"ruoyi-proxy/internal/dashboard"
 )
 
 type registerRequest struct {
@@ -16,19 +19,22 @@ type registerRequest struct {
 }
 
 type registerResponse struct {
-	SpokeID string `json:"spoke_id"`
-	Token   string `json:"token"`
+	SpokeID string   `json:"spoke_id"`
+	Token   string   `json:"token"`
+	Auth    []string `json:"auth"` // 支持的认证方式
 }
 
 type chatRequest struct {
 	Messages []agent.Message `json:"messages"`
 	Tools    []agent.ToolDef `json:"tools,omitempty"`
+	Model    string          `json:"model,omitempty"` // 模型别名或提供商名称，用于路由
 }
 
 type chatResponse struct {
 	Content          string           `json:"content"`
 	ReasoningContent string           `json:"reasoning_content,omitempty"`
 	ToolCalls        []agent.ToolCall `json:"tool_calls,omitempty"`
+	Usage            *agent.Usage     `json:"usage,omitempty"`
 	Error            string           `json:"error,omitempty"`
 }
 
@@ -38,9 +44,11 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
 		return
 	}
-	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		if !recordBodyTooLarge(w, r, "register", err) {
+			http.Error(w, "读取请求失败", http.StatusBadRequest)
+		}
 		return
 	}
 	var req registerRequest
@@ -50,29 +58,18 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	spokeID, secret, err := RegisterSpoke(strings.TrimSpace(req.Token))
 	if err != nil {
+		if errors.Is(err, errInvalidRegisterToken) {
+			settings, _ := LoadHubSettings()
+			recordAuthFailure(r, settings.Security.RateLimit, "register", "", err.Error())
+		}
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(registerResponse{SpokeID: spokeID, Token: secret})
-}
-
-// RegisterTokenHandler GET/POST /__hub__/v1/token
-func RegisterTokenHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost && r.Method != http.MethodGet {
-		http.Error(w, "只允许 GET/POST", http.StatusMethodNotAllowed)
-		return
-	}
-	token, err := GenerateRegisterToken()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"token":      token,
-		"expires_in": 900,
-		"hint":       "Spoke 将自动使用此 Token 完成注册",
+	json.NewEncoder(w).Encode(registerResponse{
+		SpokeID: spokeID,
+		Token:   secret,
+		Auth:    []string{agent.HubAuthBearer, agent.HubAuthHMAC},
 	})
 }
 
@@ -82,19 +79,8 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
 		return
 	}
-	secret := bearerToken(r)
-	if secret == "" {
-		http.Error(w, "缺少 Authorization", http.StatusUnauthorized)
-		return
-	}
-	spokeID, ok := ValidateSpokeToken(secret)
+	spokeID, body, ok := authenticateSpoke(w, r, "profile")
 	if !ok {
-		http.Error(w, "无效或已吊销的凭证", http.StatusUnauthorized)
-		return
-	}
-	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
-	if err != nil {
-		http.Error(w, "读取请求失败", http.StatusBadRequest)
 		return
 	}
 	var profile SpokeProfile
@@ -120,19 +106,8 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
 		return
 	}
-	secret := bearerToken(r)
-	if secret == "" {
-		http.Error(w, "缺少 Authorization", http.StatusUnauthorized)
-		return
-	}
-	if _, ok := ValidateSpokeToken(secret); !ok {
-		http.Error(w, "无效或已吊销的凭证", http.StatusUnauthorized)
-		return
-	}
-
-	body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
-	if err != nil {
-		http.Error(w, "读取请求失败", http.StatusBadRequest)
+	spokeID, body, ok := authenticateSpoke(w, r, "chat")
+	if !ok {
 		return
 	}
 	var req chatRequest
@@ -141,28 +116,52 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	aiCfg, err := agent.LoadAIConfig()
-	if err != nil || !aiCfg.IsConfigured() || aiCfg.Provider == "hub" {
-		writeChatError(w, "Hub 未配置有效的 AI 提供商，请在本机运行 /agent-config")
+	settings, _ := LoadHubSettings()
+	started := time.Now()
+	entry := AuditEntry{
+		Time:    started,
+		Spoke:   spokeID,
+		Model:   strings.TrimSpace(req.Model),
+		Request: newRequestMessages(req.Messages),
+	}
+	model, err := resolveSpokeModel(settings, spokeID, entry.Model)
+	if err != nil {
+		entry.Error = err.Error()
+		recordAudit(settings.Audit, entry)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(chatResponse{Error: err.Error()})
 		return
 	}
-	provider, err := agent.NewProvider(aiCfg)
+	entry.Model = model
+	chain, err := resolveProviderChain(settings, spokeID, model)
 	if err != nil {
-		writeChatError(w, fmt.Sprintf("创建 Provider 失败: %v", err))
+		entry.Error = err.Error()
+		recordAudit(settings.Audit, entry)
+		writeChatError(w, err.Error())
 		return
 	}
 
-	ctx := r.Context()
-	resp, err := provider.Chat(ctx, req.Messages, req.Tools)
+	resp, used, err := chatWithFailover(r.Context(), settings, chain, req.Messages, req.Tools)
+	entry.Provider = used.Name
+	entry.DurationMs = time.Since(started).Milliseconds()
 	if err != nil {
+		entry.Error = err.Error()
+		recordAudit(settings.Audit, entry)
 		writeChatError(w, fmt.Sprintf("AI 调用失败: %v", err))
 		return
 	}
+	entry.Response = resp.Content
+	entry.ToolCalls = append([]agent.ToolCall(nil), resp.ToolCalls...)
+	entry.Usage = resp.Usage
+	recordAudit(settings.Audit, entry)
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Hub-Provider", used.Name)
 	json.NewEncoder(w).Encode(chatResponse{
 		Content:          resp.Content,
 		ReasoningContent: resp.ReasoningContent,
 		ToolCalls:        resp.ToolCalls,
+		Usage:            resp.Usage,
 	})
 }
 
@@ -180,12 +179,43 @@ func bearerToken(r *http.Request) string {
 	return ""
 }
 
-// TokenAdminHandler POST /hub/token — 生成本机注册 Token
+// TokenAdminHandler POST /hub/token — 生成本机注册 Token；
+// requireAdmin 管理端口上的敏感接口：本机请求或携带有效 mgmt.token 时放行；未配置令牌时远程请求一律拒绝，
+// 失败计入来源 IP 的认证失败次数，达到阈值后锁定
+func requireAdmin(w http.ResponseWriter, r *http.Request, endpoint, message string) bool {
+	if until, locked := ipLocked(clientIP(r)); locked {
+		tooManyRequests(w, until, "认证失败次数过多，来源已被临时锁定")
+		return false
+	}
+	if !dashboard.Authorized(r) {
+		settings, _ := LoadHubSettings()
+		recordAuthFailure(r, settings.Security.RateLimit, endpoint, "", "无效的管理令牌")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ruoyi-proxy"`)
+		http.Error(w, message, http.StatusUnauthorized)
+		return false
+	}
+	recordAuthSuccess(r)
+	return true
+}
+
+// AdminOnly 包装会修改 Hub 状态或读取敏感数据的管理接口，鉴权规则同 requireAdmin
+func AdminOnly(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requireAdmin(w, r, endpoint, "该接口需在 Hub 本机调用，或携带 mgmt.token") {
+			next(w, r)
+		}
+	}
+}
+
+// 未配置 mgmt.token 时管理端口对远程 API 不设防，因此这里单独要求本机请求或管理令牌，失败按来源计数并锁定
 func TokenAdminHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost && r.Method != http.MethodGet {
 		http.Error(w, "只允许 GET/POST", http.StatusMethodNotAllowed)
 		return
 	}
+	if !requireAdmin(w, r, "token", "生成注册 Token 需在 Hub 本机执行，或携带 mgmt.token") {
+		return
+	}
 	token, err := GenerateRegisterToken()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -199,18 +229,99 @@ func TokenAdminHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// StatusAdminHandler GET /hub/status
+// StatusAdminHandler GET /hub/status[?selector=env=prod,project=erp]
 func StatusAdminHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
 		return
 	}
-	items := ListSpokes()
+	items, err := SelectSpokes(strings.TrimSpace(r.URL.Query().Get("selector")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	states := map[string]int{}
+	for _, it := range items {
+		states[it.State]++
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":       len(items),
+		"states":      states,
+		"spokes":      items,
+		"replication": ReplicationStatus(),
+		"security":    CurrentSecurityStatus(20),
+		"time":        time.Now().Format(time.RFC3339),
+	})
+}
+
+// ReloadAdminHandler POST /hub/reload — 重新从磁盘加载 spoke 注册表（hub-import 后使用）
+func ReloadAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := LoadSpokes(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "reloaded", "count": len(ListSpokes())})
+}
+
+// ProvidersAdminHandler GET /hub/providers — 提供商池与故障转移状态
+func ProvidersAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	settings, _ := LoadHubSettings()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": ProviderStatus(),
+		"routing":   settings.Routing,
+		"time":      time.Now().Format(time.RFC3339),
+	})
+}
+
+// AuditAdminHandler GET /hub/audit?spoke=&since=&until=&tool=&limit=
+func AuditAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	query := AuditQuery{
+		Spoke: strings.TrimSpace(q.Get("spoke")),
+		Tool:  strings.TrimSpace(q.Get("tool")),
+		Limit: 50,
+	}
+	var err error
+	if query.Since, err = ParseAuditTime(q.Get("since")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Until, err = ParseAuditTime(q.Get("until")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "无效的 limit 参数", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+	entries, err := QueryAudit(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"count":  len(items),
-		"spokes": items,
-		"time":   time.Now().Format(time.RFC3339),
+		"count":   len(entries),
+		"entries": entries,
 	})
 }
 
@@ -252,3 +363,202 @@ func RevokeAdminHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "revoked", "spoke": spokeID})
 }
+
+type tagsRequest struct {
+	Spokes []string          `json:"spokes"` // spoke ID 列表、all 或标签选择器
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// TagsAdminHandler POST /hub/tags — 在 Hub 端为一个或多个 spoke 设置/删除标签
+func TagsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req tagsRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "无效请求体", http.StatusBadRequest)
+		return
+	}
+	if len(req.Set) == 0 && len(req.Remove) == 0 {
+		http.Error(w, "缺少 set 或 remove", http.StatusBadRequest)
+		return
+	}
+	targets, err := ResolveSpokeTargets(req.Spokes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updated := make([]SpokeRecord, 0, len(targets))
+	for _, id := range targets {
+		if err := SetSpokeTags(id, req.Set, req.Remove); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rec, ok := GetSpoke(id); ok {
+			updated = append(updated, rec)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":  len(updated),
+		"spokes": updated,
+	})
+}
+
+// HeartbeatHandler POST /__hub__/v1/heartbeat — spoke 定期上报存活与健康信息
+func HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if rejectOnStandby(w) {
+		return
+	}
+	spokeID, body, ok := authenticateSpoke(w, r, "heartbeat")
+	if !ok {
+		return
+	}
+	var health SpokeHealth
+	if err := json.Unmarshal(body, &health); err != nil {
+		http.Error(w, "无效请求体", http.StatusBadRequest)
+		return
+	}
+	if err := RecordHeartbeat(spokeID, health); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"interval":       int(HeartbeatInterval / time.Second),
+		"policy_version": policyVersion(spokeID),
+	})
+}
+
+// ControlPollHandler GET /__hub__/v1/control/poll?wait=25 — spoke 长轮询拉取远程命令
+func ControlPollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	if rejectOnStandby(w) {
+		return
+	}
+	spokeID, _, ok := authenticateSpoke(w, r, "control/poll")
+	if !ok {
+		return
+	}
+	wait := 25
+	if v, err := strconv.Atoi(r.URL.Query().Get("wait")); err == nil && v >= 0 && v <= 60 {
+		wait = v
+	}
+	cmds := PollCommands(r.Context(), spokeID, time.Duration(wait)*time.Second)
+	if cmds == nil {
+		cmds = []ControlCommand{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"commands": cmds,
+	})
+}
+
+// ControlResultHandler POST /__hub__/v1/control/result — spoke 回传执行状态与输出
+func ControlResultHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if rejectOnStandby(w) {
+		return
+	}
+	spokeID, body, ok := authenticateSpoke(w, r, "control/result")
+	if !ok {
+		return
+	}
+	var res ControlResult
+	if err := json.Unmarshal(body, &res); err != nil {
+		http.Error(w, "无效请求体", http.StatusBadRequest)
+		return
+	}
+	if err := ReportCommandResult(spokeID, res); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+type dispatchRequest struct {
+	Spokes  []string          `json:"spokes"` // spoke ID 列表，或 ["all"]
+	Op      string            `json:"op"`
+	Service string            `json:"service,omitempty"`
+	Args    map[string]string `json:"args,omitempty"`
+}
+
+// DispatchAdminHandler POST /hub/dispatch — 向一个或多个 spoke 下发远程命令
+func DispatchAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req dispatchRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "无效请求体", http.StatusBadRequest)
+		return
+	}
+	req.Op = strings.TrimSpace(req.Op)
+	if _, ok := ControlOps[req.Op]; !ok {
+		http.Error(w, "不支持的操作: "+req.Op, http.StatusBadRequest)
+		return
+	}
+	targets, err := ResolveSpokeTargets(req.Spokes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cmds := make([]ControlCommand, 0, len(targets))
+	var errs []string
+	for _, id := range targets {
+		cmd, err := EnqueueCommand(id, req.Op, req.Service, req.Args)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", id, err))
+			continue
+		}
+		cmds = append(cmds, cmd)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"commands": cmds,
+		"errors":   errs,
+	})
+}
+
+// CommandAdminHandler GET /hub/command?id=<id> 查询单条命令；不带 id 时按 spoke 列出最近命令
+func CommandAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+	if id := strings.TrimSpace(q.Get("id")); id != "" {
+		cmd, ok := GetCommand(id)
+		if !ok {
+			http.Error(w, "命令不存在: "+id, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(cmd)
+		return
+	}
+	limit := 50
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	cmds := ListCommands(strings.TrimSpace(q.Get("spoke")), limit)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":    len(cmds),
+		"commands": cmds,
+	})
+}
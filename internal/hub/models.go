@@ -0,0 +1,135 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ModelPolicy 按选择器限定 spoke 可请求的模型别名及默认别名，按配置顺序取第一条匹配的策略
+type ModelPolicy struct {
+	Selector string   `json:"selector"`          // 标签选择器，如 env=prod 或 id=spoke-abc12345；all 匹配全部
+	Allow    []string `json:"allow,omitempty"`   // 允许请求的模型别名，留空不限制
+	Default  string   `json:"default,omitempty"` // spoke 未指定模型时使用的别名
+}
+
+// allowsProvider 策略是否允许使用该提供商：提供商名称本身在允许列表中，或允许的别名路由到它；允许列表为空时不限制
+func (p ModelPolicy) allowsProvider(routing HubRouting, name string) bool {
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, alias := range p.Allow {
+		if alias == name || routing.Models[alias] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// knownModels Hub 可识别的模型名：routing.models 中的别名与提供商名称
+func knownModels(settings HubSettings) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for alias := range settings.Routing.Models {
+		if !seen[alias] {
+			seen[alias] = true
+			out = append(out, alias)
+		}
+	}
+	for _, p := range effectiveProviders(settings) {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			out = append(out, p.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// modelPolicyFor 返回匹配该 spoke 的第一条模型策略；选择器无效时返回错误（不放宽限制）
+func modelPolicyFor(settings HubSettings, spokeID string) (ModelPolicy, bool, error) {
+	rec, ok := GetSpoke(spokeID)
+	if !ok {
+		return ModelPolicy{}, false, nil
+	}
+	for _, policy := range settings.Routing.ModelPolicies {
+		sel, err := ParseSelector(policy.Selector)
+		if err != nil {
+			return ModelPolicy{}, false, fmt.Errorf("hub.routing.model_policies 选择器 %q 无效: %v", policy.Selector, err)
+		}
+		if sel.Matches(rec) {
+			return policy, true, nil
+		}
+	}
+	return ModelPolicy{}, false, nil
+}
+
+// SpokeModels 返回该 spoke 可请求的模型别名与默认别名
+func SpokeModels(settings HubSettings, spokeID string) ([]string, string, error) {
+	known := knownModels(settings)
+	policy, ok, err := modelPolicyFor(settings, spokeID)
+	if err != nil || !ok || len(policy.Allow) == 0 {
+		return known, policy.Default, err
+	}
+	var allowed []string
+	for _, name := range known {
+		if containsString(policy.Allow, name) {
+			allowed = append(allowed, name)
+		}
+	}
+	def, _ := resolveSpokeModel(settings, spokeID, "")
+	return allowed, def, nil
+}
+
+// resolveSpokeModel 按策略确定本次请求使用的模型别名：未指定时取策略默认值（有允许列表时须在列表内，
+// 否则取列表中第一个已配置的别名），指定了未知或不在允许列表中的别名时返回错误
+func resolveSpokeModel(settings HubSettings, spokeID, requested string) (string, error) {
+	policy, _, err := modelPolicyFor(settings, spokeID)
+	if err != nil {
+		return "", err
+	}
+	if requested == "" {
+		if len(policy.Allow) == 0 || containsString(policy.Allow, policy.Default) {
+			return policy.Default, nil
+		}
+		known := knownModels(settings)
+		for _, alias := range policy.Allow {
+			if containsString(known, alias) {
+				return alias, nil
+			}
+		}
+		return "", fmt.Errorf("当前 Spoke 允许的模型（%s）均未在 Hub 配置", strings.Join(policy.Allow, ", "))
+	}
+	if !containsString(knownModels(settings), requested) {
+		return "", fmt.Errorf("Hub 未配置模型别名 %s", requested)
+	}
+	if len(policy.Allow) > 0 && !containsString(policy.Allow, requested) {
+		return "", fmt.Errorf("当前 Spoke 不允许使用模型 %s（可用: %s）", requested, strings.Join(policy.Allow, ", "))
+	}
+	return requested, nil
+}
+
+// ModelsHandler GET /__hub__/v1/models — spoke 查询自己可用的模型别名
+func ModelsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	spokeID, _, ok := authenticateSpoke(w, r, "models")
+	if !ok {
+		return
+	}
+	settings, _ := LoadHubSettings()
+	models, def, err := SpokeModels(settings, spokeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"models":  models,
+		"default": def,
+	})
+}
@@ -0,0 +1,116 @@
+package hub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+// PolicyRule 按选择器下发给 spoke 的策略片段（app_config.json 的 hub.policies 字段），
+// 所有匹配的规则按配置顺序合并：提示词依次拼接，禁用工具与禁止命令取并集，任一规则禁止整轮确认即禁止
+type PolicyRule struct {
+	Selector            string   `json:"selector"`                        // 标签选择器，all 匹配全部
+	PromptAppend        string   `json:"prompt_append,omitempty"`         // 追加到 spoke 系统提示词
+	DisabledTools       []string `json:"disabled_tools,omitempty"`        // 禁用的工具名，如 delete_file
+	ForbiddenShell      []string `json:"forbidden_shell,omitempty"`       // run_shell 禁止匹配的正则
+	DisableTurnApproval bool     `json:"disable_turn_approval,omitempty"` // 每个写操作单独确认
+}
+
+// SpokePolicy 计算下发给指定 spoke 的策略文档；版本为内容摘要，内容不变则版本不变
+func SpokePolicy(settings HubSettings, spokeID string) (agent.HubPolicy, error) {
+	var p agent.HubPolicy
+	rec, ok := GetSpoke(spokeID)
+	if !ok {
+		return p, fmt.Errorf("spoke 不存在: %s", spokeID)
+	}
+	var prompts []string
+	for _, rule := range settings.Policies {
+		sel, err := ParseSelector(rule.Selector)
+		if err != nil {
+			return p, fmt.Errorf("hub.policies 选择器 %q 无效: %v", rule.Selector, err)
+		}
+		if !sel.Matches(rec) {
+			continue
+		}
+		for _, expr := range rule.ForbiddenShell {
+			if _, err := regexp.Compile(expr); err != nil {
+				return p, fmt.Errorf("hub.policies 禁止命令规则 %q 无效: %v", expr, err)
+			}
+			if !containsString(p.ForbiddenShell, expr) {
+				p.ForbiddenShell = append(p.ForbiddenShell, expr)
+			}
+		}
+		for _, tool := range rule.DisabledTools {
+			if !containsString(p.DisabledTools, tool) {
+				p.DisabledTools = append(p.DisabledTools, tool)
+			}
+		}
+		if text := strings.TrimSpace(rule.PromptAppend); text != "" {
+			prompts = append(prompts, text)
+		}
+		p.DisableTurnApproval = p.DisableTurnApproval || rule.DisableTurnApproval
+	}
+	p.PromptAppend = strings.Join(prompts, "\n\n")
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return p, err
+	}
+	sum := sha256.Sum256(data)
+	p.Version = hex.EncodeToString(sum[:])[:12]
+	return p, nil
+}
+
+// PolicyHandler GET /__hub__/v1/policy — spoke 拉取自己的策略文档
+func PolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	spokeID, _, ok := authenticateSpoke(w, r, "policy")
+	if !ok {
+		return
+	}
+	writeSpokePolicy(w, spokeID)
+}
+
+// PolicyAdminHandler GET /hub/policy?spoke= — 预览下发给某个 spoke 的策略
+func PolicyAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	spokeID := strings.TrimSpace(r.URL.Query().Get("spoke"))
+	if spokeID == "" {
+		http.Error(w, "缺少 spoke 参数", http.StatusBadRequest)
+		return
+	}
+	writeSpokePolicy(w, spokeID)
+}
+
+func writeSpokePolicy(w http.ResponseWriter, spokeID string) {
+	settings, _ := LoadHubSettings()
+	policy, err := SpokePolicy(settings, spokeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// policyVersion 心跳响应中附带的策略版本，spoke 据此判断是否需要重新拉取
+func policyVersion(spokeID string) string {
+	settings, _ := LoadHubSettings()
+	policy, err := SpokePolicy(settings, spokeID)
+	if err != nil {
+		return ""
+	}
+	return policy.Version
+}
@@ -12,6 +12,9 @@ type SpokeProfile struct {
 	Domain      string            `json:"domain,omitempty"`
 	AppHome     string            `json:"app_home,omitempty"`
 	Services    []SpokeServiceRef `json:"services,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"` // spoke 自报标签，如 env=prod、project=erp、region=sh
+	Discovery   *SpokeDiscovery   `json:"discovery,omitempty"` // 自动发现的运行环境
+	Drift       []string          `json:"drift,omitempty"`     // 与基线相比的环境变化，确认后清空
 	UpdatedAt   time.Time         `json:"updated_at,omitempty"`
 }
 
@@ -22,3 +25,21 @@ type SpokeServiceRef struct {
 	ProjectType string `json:"project_type,omitempty"`
 	ActiveEnv   string `json:"active_env,omitempty"`
 }
+
+// SpokeHealth Spoke 心跳上报的轻量健康信息
+type SpokeHealth struct {
+	ProxyRunning    bool                 `json:"proxy_running"`
+	Services        []SpokeServiceHealth `json:"services,omitempty"`
+	DiskUsedPercent float64              `json:"disk_used_percent,omitempty"` // APP_HOME 所在分区
+	DiskFreeGB      float64              `json:"disk_free_gb,omitempty"`
+	MemUsedPercent  float64              `json:"mem_used_percent,omitempty"`
+	Load1           float64              `json:"load1,omitempty"`
+	ReportedAt      time.Time            `json:"reported_at"`
+}
+
+// SpokeServiceHealth 单个服务的当前环境与可达性
+type SpokeServiceHealth struct {
+	ID        string `json:"id"`
+	ActiveEnv string `json:"active_env,omitempty"`
+	Up        bool   `json:"up"`
+}
@@ -0,0 +1,221 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+const defaultProviderCooldown = 30 * time.Second
+
+// HubProvider 具名上游 AI 提供商（字段与 ai 配置一致，额外带 name）
+type HubProvider struct {
+	Name string `json:"name"`
+	agent.AIConfig
+}
+
+// HubRouting 提供商路由规则：按模型别名 → 按 spoke → 默认，失败后按 fallback 依次转移
+type HubRouting struct {
+	Default         string            `json:"default,omitempty"`          // 默认提供商名称，留空取池中第一个
+	Fallback        []string          `json:"fallback,omitempty"`         // 故障转移顺序
+	Spokes          map[string]string `json:"spokes,omitempty"`           // spoke ID → 提供商名称
+	Models          map[string]string `json:"models,omitempty"`           // 模型别名 → 提供商名称
+	CooldownSeconds int               `json:"cooldown_seconds,omitempty"` // 失败后暂停使用的秒数，默认 30
+	ModelPolicies   []ModelPolicy     `json:"model_policies,omitempty"`   // 按 spoke 限定可用模型别名与默认别名
+}
+
+// ProviderHealth 提供商运行状况（供 /hub/providers 展示）
+type ProviderHealth struct {
+	Name          string    `json:"name"`
+	Provider      string    `json:"provider"`
+	Model         string    `json:"model"`
+	Successes     int       `json:"successes"`
+	Failures      int       `json:"failures"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastFailure   time.Time `json:"last_failure,omitempty"`
+	LastSuccess   time.Time `json:"last_success,omitempty"`
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"`
+}
+
+type providerTracker struct {
+	mu     sync.Mutex
+	health map[string]*ProviderHealth
+}
+
+var providerStats = &providerTracker{health: make(map[string]*ProviderHealth)}
+
+func (t *providerTracker) get(p HubProvider) *ProviderHealth {
+	h, ok := t.health[p.Name]
+	if !ok {
+		h = &ProviderHealth{Name: p.Name}
+		t.health[p.Name] = h
+	}
+	h.Provider = p.Provider
+	h.Model = p.Model
+	return h
+}
+
+func (t *providerTracker) coolingDown(name string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.health[name]
+	return ok && now.Before(h.CooldownUntil)
+}
+
+func (t *providerTracker) recordSuccess(p HubProvider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.get(p)
+	h.Successes++
+	h.LastSuccess = time.Now()
+	h.CooldownUntil = time.Time{}
+}
+
+func (t *providerTracker) recordFailure(p HubProvider, err error, cooldown time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.get(p)
+	h.Failures++
+	h.LastError = err.Error()
+	h.LastFailure = time.Now()
+	h.CooldownUntil = h.LastFailure.Add(cooldown)
+}
+
+// ProviderStatus 返回提供商池及运行状况
+func ProviderStatus() []ProviderHealth {
+	settings, _ := LoadHubSettings()
+	pool := effectiveProviders(settings)
+	providerStats.mu.Lock()
+	defer providerStats.mu.Unlock()
+	out := make([]ProviderHealth, 0, len(pool))
+	for _, p := range pool {
+		out = append(out, *providerStats.get(p))
+	}
+	return out
+}
+
+// effectiveProviders 返回提供商池；未配置 hub.providers 时回退到 ai 字段
+func effectiveProviders(settings HubSettings) []HubProvider {
+	var pool []HubProvider
+	for _, p := range settings.Providers {
+		if strings.TrimSpace(p.Name) == "" || p.Provider == "hub" || !p.IsConfigured() {
+			continue
+		}
+		pool = append(pool, p)
+	}
+	if len(pool) > 0 {
+		return pool
+	}
+	aiCfg, err := agent.LoadAIConfig()
+	if err != nil || !aiCfg.IsConfigured() || aiCfg.Provider == "hub" {
+		return nil
+	}
+	return []HubProvider{{Name: "default", AIConfig: aiCfg}}
+}
+
+// resolveProviderChain 按路由规则给出本次请求的提供商尝试顺序；spoke 的模型策略限定了允许列表时，
+// 按 spoke、默认、故障转移加入的提供商同样须在允许范围内
+func resolveProviderChain(settings HubSettings, spokeID, model string) ([]HubProvider, error) {
+	pool := effectiveProviders(settings)
+	if len(pool) == 0 {
+		return nil, fmt.Errorf("Hub 未配置有效的 AI 提供商，请在本机运行 /agent-config 或配置 hub.providers")
+	}
+	policy, _, err := modelPolicyFor(settings, spokeID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]HubProvider, len(pool))
+	for _, p := range pool {
+		byName[p.Name] = p
+	}
+
+	routing := settings.Routing
+	var names []string
+	if model != "" {
+		if name, ok := routing.Models[model]; ok {
+			names = append(names, name)
+		} else if _, ok := byName[model]; ok {
+			names = append(names, model)
+		}
+	}
+	if name, ok := routing.Spokes[spokeID]; ok {
+		names = append(names, name)
+	}
+	if routing.Default != "" {
+		names = append(names, routing.Default)
+	}
+	names = append(names, pool[0].Name)
+	names = append(names, routing.Fallback...)
+
+	seen := make(map[string]bool)
+	var chain []HubProvider
+	for _, name := range names {
+		p, ok := byName[name]
+		if !ok || seen[name] || !policy.allowsProvider(routing, name) {
+			continue
+		}
+		seen[name] = true
+		chain = append(chain, p)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("没有当前 Spoke 允许使用的提供商（可用模型: %s）", strings.Join(policy.Allow, ", "))
+	}
+
+	// 冷却中的提供商挪到末尾：全部冷却时仍按原顺序尝试
+	now := time.Now()
+	var ready, cooling []HubProvider
+	for _, p := range chain {
+		if providerStats.coolingDown(p.Name, now) {
+			cooling = append(cooling, p)
+		} else {
+			ready = append(ready, p)
+		}
+	}
+	return append(ready, cooling...), nil
+}
+
+// chatWithFailover 依次调用提供商，失败或超时自动转移到下一个
+func chatWithFailover(ctx context.Context, settings HubSettings, chain []HubProvider, messages []agent.Message, tools []agent.ToolDef) (*agent.ChatResponse, HubProvider, error) {
+	cooldown := defaultProviderCooldown
+	if settings.Routing.CooldownSeconds > 0 {
+		cooldown = time.Duration(settings.Routing.CooldownSeconds) * time.Second
+	}
+
+	var errs []string
+	for _, p := range chain {
+		provider, err := agent.NewProvider(p.AIConfig)
+		if err != nil {
+			providerStats.recordFailure(p, err, cooldown)
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name, err))
+			continue
+		}
+		timeout := p.TimeoutSeconds
+		if timeout <= 0 {
+			timeout = 120
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		resp, err := provider.Chat(attemptCtx, messages, tools)
+		cancel()
+		if err == nil {
+			providerStats.recordSuccess(p)
+			return resp, p, nil
+		}
+		// 客户端已断开则不再转移
+		if ctx.Err() != nil {
+			return nil, p, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("超时（%ds）", timeout)
+		}
+		providerStats.recordFailure(p, err, cooldown)
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name, err))
+		log.Printf("[hub] 提供商 %s 调用失败，尝试故障转移: %v", p.Name, err)
+	}
+	return nil, HubProvider{}, fmt.Errorf("所有提供商均失败: %s", strings.Join(errs, "; "))
+}
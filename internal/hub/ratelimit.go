@@ -0,0 +1,338 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HubRateLimit 公开端点的限流与防暴力破解策略（hub.security.rate_limit 字段），未填写的项使用默认值
+type HubRateLimit struct {
+	Disabled              bool           `json:"disabled,omitempty"`
+	PerIPPerMinute        int            `json:"per_ip_per_minute,omitempty"`         // 单个来源 IP 每分钟请求数，默认 300
+	PerSpokePerMinute     int            `json:"per_spoke_per_minute,omitempty"`      // 单个 spoke 每分钟请求数（不含 chat），默认 120
+	ChatPerSpokePerMinute int            `json:"chat_per_spoke_per_minute,omitempty"` // 单个 spoke 每分钟 chat 请求数，默认 30
+	LockoutThreshold      int            `json:"lockout_threshold,omitempty"`         // 连续认证失败多少次后锁定来源 IP，默认 5
+	LockoutSeconds        int            `json:"lockout_seconds,omitempty"`           // 首次锁定时长，之后每次翻倍，默认 60
+	MaxLockoutSeconds     int            `json:"max_lockout_seconds,omitempty"`       // 锁定时长上限，默认 3600
+	MaxBodyKB             map[string]int `json:"max_body_kb,omitempty"`               // 按端点覆盖请求体上限，如 {"chat": 8192}
+}
+
+// defaultBodyLimits 各端点默认请求体上限（字节）
+var defaultBodyLimits = map[string]int64{
+	"token":          1 << 10,
+	"register":       4 << 10,
+	"profile":        1 << 20,
+	"chat":           4 << 20,
+	"models":         1 << 10,
+	"policy":         1 << 10,
+	"heartbeat":      64 << 10,
+	"control/poll":   1 << 10,
+	"control/result": 2 << 20,
+	"replicate":      1 << 10,
+	"artifact":       1 << 10,
+}
+
+func (l HubRateLimit) perIP() int        { return orDefault(l.PerIPPerMinute, 300) }
+func (l HubRateLimit) perSpoke() int     { return orDefault(l.PerSpokePerMinute, 120) }
+func (l HubRateLimit) chatPerSpoke() int { return orDefault(l.ChatPerSpokePerMinute, 30) }
+func (l HubRateLimit) threshold() int    { return orDefault(l.LockoutThreshold, 5) }
+
+// lockoutFor 第 n 次锁定的时长：首次 lockout_seconds，之后翻倍，不超过上限
+func (l HubRateLimit) lockoutFor(n int) time.Duration {
+	base := float64(orDefault(l.LockoutSeconds, 60))
+	max := float64(orDefault(l.MaxLockoutSeconds, 3600))
+	return time.Duration(math.Min(base*math.Pow(2, float64(n-1)), max)) * time.Second
+}
+
+// bodyLimit 端点请求体上限（字节）
+func (l HubRateLimit) bodyLimit(endpoint string) int64 {
+	if kb := l.MaxBodyKB[endpoint]; kb > 0 {
+		return int64(kb) << 10
+	}
+	if n, ok := defaultBodyLimits[endpoint]; ok {
+		return n
+	}
+	return 1 << 20
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// SecurityEvent Hub 安全事件（限流、认证失败、锁定等），保留在内存中供 /hub-status 查看
+type SecurityEvent struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"` // rate_limited / auth_failed / locked / body_too_large
+	IP       string    `json:"ip,omitempty"`
+	SpokeID  string    `json:"spoke_id,omitempty"`
+	Endpoint string    `json:"endpoint,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// LockedIP 当前处于锁定状态的来源 IP
+type LockedIP struct {
+	IP       string    `json:"ip"`
+	Until    time.Time `json:"until"`
+	Failures int       `json:"failures"`
+	Lockouts int       `json:"lockouts"`
+}
+
+// SecurityStatus 安全状态摘要
+type SecurityStatus struct {
+	Locked []LockedIP      `json:"locked"`
+	Counts map[string]int  `json:"counts"` // 自启动以来各类事件次数
+	Recent []SecurityEvent `json:"recent"` // 最近的事件，新的在前
+}
+
+const maxSecurityEvents = 200
+
+// bucket 固定窗口计数（按分钟），limitedAt 用于合并同一窗口内的限流事件
+type bucket struct {
+	window    time.Time
+	count     int
+	limitedAt time.Time
+}
+
+// authFailures 来源 IP 的连续认证失败与锁定记录
+type authFailures struct {
+	failures int
+	lockouts int
+	until    time.Time
+	last     time.Time
+}
+
+var guard = struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*authFailures
+	events    []SecurityEvent
+	counts    map[string]int
+	lastPrune time.Time
+}{
+	buckets:  make(map[string]*bucket),
+	failures: make(map[string]*authFailures),
+	counts:   make(map[string]int),
+}
+
+// Guard 包装公开的 /__hub__/ 端点：来源 IP 锁定检查、按 IP 限流与请求体上限
+func Guard(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings, _ := LoadHubSettings()
+		limits := settings.Security.RateLimit
+		r.Body = http.MaxBytesReader(w, r.Body, limits.bodyLimit(endpoint))
+		if limits.Disabled {
+			next(w, r)
+			return
+		}
+		ip := clientIP(r)
+		if until, locked := ipLocked(ip); locked {
+			tooManyRequests(w, until, "认证失败次数过多，来源已被临时锁定")
+			return
+		}
+		if !allow("ip:"+ip, limits.perIP(), SecurityEvent{Type: "rate_limited", IP: ip, Endpoint: endpoint, Detail: "超过来源 IP 限流"}) {
+			tooManyRequests(w, time.Now().Truncate(time.Minute).Add(time.Minute), "请求过于频繁，请稍后重试")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowSpoke 按 spoke 限流（chat 单独计数）；超限时已写入 429 响应
+func allowSpoke(w http.ResponseWriter, r *http.Request, limits HubRateLimit, spokeID, endpoint string) bool {
+	if limits.Disabled {
+		return true
+	}
+	key, limit := "spoke:"+spokeID, limits.perSpoke()
+	if endpoint == "chat" {
+		key, limit = "chat:"+spokeID, limits.chatPerSpoke()
+	}
+	if allow(key, limit, SecurityEvent{Type: "rate_limited", IP: clientIP(r), SpokeID: spokeID, Endpoint: endpoint, Detail: "超过 spoke 限流"}) {
+		return true
+	}
+	tooManyRequests(w, time.Now().Truncate(time.Minute).Add(time.Minute), "请求过于频繁，请稍后重试")
+	return false
+}
+
+// allow 按分钟窗口计数；同一窗口内首次超限时记录一次事件
+func allow(key string, limit int, ev SecurityEvent) bool {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	now := time.Now()
+	pruneGuardLocked(now)
+	window := now.Truncate(time.Minute)
+	b := guard.buckets[key]
+	if b == nil {
+		b = &bucket{}
+		guard.buckets[key] = b
+	}
+	if !b.window.Equal(window) {
+		b.window, b.count = window, 0
+	}
+	b.count++
+	if b.count <= limit {
+		return true
+	}
+	if !b.limitedAt.Equal(window) {
+		b.limitedAt = window
+		ev.Detail = fmt.Sprintf("%s（%d 次/分钟）", ev.Detail, limit)
+		recordSecurityEventLocked(ev)
+	}
+	return false
+}
+
+// recordAuthFailure 记录来源 IP 的认证失败，连续失败达到阈值后按指数退避锁定
+func recordAuthFailure(r *http.Request, limits HubRateLimit, endpoint, spokeID, reason string) {
+	ip := clientIP(r)
+
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	now := time.Now()
+	recordSecurityEventLocked(SecurityEvent{Type: "auth_failed", IP: ip, SpokeID: spokeID, Endpoint: endpoint, Detail: reason})
+	if limits.Disabled {
+		return
+	}
+	f := guard.failures[ip]
+	if f == nil {
+		f = &authFailures{}
+		guard.failures[ip] = f
+	}
+	f.failures++
+	f.last = now
+	if f.failures < limits.threshold() {
+		return
+	}
+	f.lockouts++
+	f.failures = 0
+	d := limits.lockoutFor(f.lockouts)
+	f.until = now.Add(d)
+	recordSecurityEventLocked(SecurityEvent{Type: "locked", IP: ip, Endpoint: endpoint,
+		Detail: fmt.Sprintf("连续 %d 次认证失败，锁定 %s（第 %d 次）", limits.threshold(), d, f.lockouts)})
+}
+
+// recordAuthSuccess 认证成功后清零连续失败次数（锁定次数保留，再次被锁时继续翻倍）
+func recordAuthSuccess(r *http.Request) {
+	ip := clientIP(r)
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	if f := guard.failures[ip]; f != nil {
+		f.failures = 0
+	}
+}
+
+// recordBodyTooLarge 请求体超过端点上限
+func recordBodyTooLarge(w http.ResponseWriter, r *http.Request, endpoint string, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+	guard.mu.Lock()
+	recordSecurityEventLocked(SecurityEvent{Type: "body_too_large", IP: clientIP(r), Endpoint: endpoint,
+		Detail: fmt.Sprintf("超过 %d KB", maxErr.Limit>>10)})
+	guard.mu.Unlock()
+	http.Error(w, fmt.Sprintf("请求体过大（上限 %d KB）", maxErr.Limit>>10), http.StatusRequestEntityTooLarge)
+	return true
+}
+
+func ipLocked(ip string) (time.Time, bool) {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	f := guard.failures[ip]
+	if f == nil || time.Now().After(f.until) {
+		return time.Time{}, false
+	}
+	return f.until, true
+}
+
+func recordSecurityEventLocked(ev SecurityEvent) {
+	ev.Time = time.Now()
+	guard.counts[ev.Type]++
+	guard.events = append(guard.events, ev)
+	if len(guard.events) > maxSecurityEvents {
+		guard.events = guard.events[len(guard.events)-maxSecurityEvents:]
+	}
+	who := ev.IP
+	if ev.SpokeID != "" {
+		who += " " + ev.SpokeID
+	}
+	log.Printf("[hub] 安全事件 %s: %s %s %s", ev.Type, who, ev.Endpoint, ev.Detail)
+}
+
+// pruneGuardLocked 定期清理过期的计数窗口与失败记录（失败记录保留 24 小时以延续指数退避）
+func pruneGuardLocked(now time.Time) {
+	if now.Sub(guard.lastPrune) < 5*time.Minute {
+		return
+	}
+	guard.lastPrune = now
+	window := now.Truncate(time.Minute)
+	for k, b := range guard.buckets {
+		if b.window.Before(window) {
+			delete(guard.buckets, k)
+		}
+	}
+	for ip, f := range guard.failures {
+		if now.After(f.until) && now.Sub(f.last) > 24*time.Hour {
+			delete(guard.failures, ip)
+		}
+	}
+}
+
+// CurrentSecurityStatus 当前锁定的来源与最近的安全事件
+func CurrentSecurityStatus(recent int) SecurityStatus {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	now := time.Now()
+	status := SecurityStatus{Locked: []LockedIP{}, Counts: make(map[string]int), Recent: []SecurityEvent{}}
+	for ip, f := range guard.failures {
+		if now.Before(f.until) {
+			status.Locked = append(status.Locked, LockedIP{IP: ip, Until: f.until, Failures: f.failures, Lockouts: f.lockouts})
+		}
+	}
+	sort.Slice(status.Locked, func(i, j int) bool { return status.Locked[i].Until.After(status.Locked[j].Until) })
+	for k, v := range guard.counts {
+		status.Counts[k] = v
+	}
+	for i := len(guard.events) - 1; i >= 0 && len(status.Recent) < recent; i-- {
+		status.Recent = append(status.Recent, guard.events[i])
+	}
+	return status
+}
+
+func tooManyRequests(w http.ResponseWriter, until time.Time, msg string) {
+	secs := int(time.Until(until).Seconds()) + 1
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// clientIP 来源 IP；仅当请求来自本机反向代理（nginx）时采信 X-Real-IP / X-Forwarded-For
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return host
+	}
+	if v := strings.TrimSpace(r.Header.Get("X-Real-IP")); v != "" {
+		return v
+	}
+	if v := r.Header.Get("X-Forwarded-For"); v != "" {
+		parts := strings.Split(v, ",")
+		return strings.TrimSpace(parts[len(parts)-1])
+	}
+	return host
+}
@@ -0,0 +1,237 @@
+package hub
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+)
+
+// 复制角色
+const (
+	RolePrimary = "primary"
+	RoleStandby = "standby"
+)
+
+// HubReplication 主备复制配置（app_config.json 的 hub.replication 字段）
+type HubReplication struct {
+	Role            string `json:"role,omitempty"`             // primary（默认）| standby
+	Token           string `json:"token,omitempty"`            // 主备共享密钥；主 Hub 未配置时不开放复制接口
+	Primary         string `json:"primary,omitempty"`          // standby：主 Hub 地址，如 https://hub.example.com
+	IntervalSeconds int    `json:"interval_seconds,omitempty"` // standby：同步间隔，默认 30
+}
+
+// IsStandby 本机是否为备用 Hub
+func (r HubReplication) IsStandby() bool {
+	return r.Role == RoleStandby
+}
+
+func (r HubReplication) interval() time.Duration {
+	if r.IntervalSeconds > 0 {
+		return time.Duration(r.IntervalSeconds) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// ReplicationState 备用 Hub 的同步状态
+type ReplicationState struct {
+	Role      string    `json:"role"`
+	Primary   string    `json:"primary,omitempty"`
+	LastSync  time.Time `json:"last_sync,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+	Spokes    int       `json:"spokes,omitempty"`
+}
+
+var replication struct {
+	mu    sync.Mutex
+	state ReplicationState
+}
+
+// ReplicationStatus 返回当前复制状态
+func ReplicationStatus() ReplicationState {
+	settings, _ := LoadHubSettings()
+	replication.mu.Lock()
+	defer replication.mu.Unlock()
+	st := replication.state
+	st.Role = RolePrimary
+	if settings.Replication.IsStandby() {
+		st.Role = RoleStandby
+		st.Primary = settings.Replication.Primary
+	}
+	return st
+}
+
+// standbyGuard 备用 Hub 的注册表以主 Hub 为准，本机写入会在下次同步时被覆盖
+func standbyGuard() error {
+	settings, _ := LoadHubSettings()
+	if settings.Replication.IsStandby() {
+		return fmt.Errorf("当前为备用 Hub，注册、吊销与标签请在主 Hub 操作")
+	}
+	return nil
+}
+
+// rejectOnStandby 备用 Hub 不接收心跳与命令通道（命令只在主 Hub 下发），返回 503 让 Spoke 转向主 Hub
+func rejectOnStandby(w http.ResponseWriter) bool {
+	settings, _ := LoadHubSettings()
+	if !settings.Replication.IsStandby() {
+		return false
+	}
+	w.Header().Set(agent.HubStandbyHeader, "1")
+	http.Error(w, "当前为备用 Hub，心跳与远程命令请连接主 Hub", http.StatusServiceUnavailable)
+	return true
+}
+
+// ReplicateHandler GET /__hub__/v1/replicate — 主 Hub 向备用 Hub 提供 spoke 注册表
+func ReplicateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	settings, _ := LoadHubSettings()
+	token := strings.TrimSpace(settings.Replication.Token)
+	if token == "" || settings.Replication.IsStandby() {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(token)) != 1 {
+		recordAuthFailure(r, settings.Security.RateLimit, "replicate", "", "无效的复制凭证")
+		http.Error(w, "无效的复制凭证", http.StatusUnauthorized)
+		return
+	}
+	// 凭证 hash 与签名密钥只以复制密钥加密后传输，不以明文出现在响应中
+	plain, err := json.Marshal(toStoredSpokes(ListSpokes()))
+	if err != nil {
+		http.Error(w, "序列化注册表失败", http.StatusInternalServerError)
+		return
+	}
+	gcm, err := replicationCipher(token)
+	if err != nil {
+		http.Error(w, "加密注册表失败", http.StatusInternalServerError)
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		http.Error(w, "加密注册表失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replicationPayload{
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, plain, []byte(replicationLabel)),
+		Time:  time.Now().Format(time.RFC3339),
+	})
+}
+
+// replicationLabel 复制加密密钥的派生标签（同时作为 GCM 附加数据）
+const replicationLabel = "ruoyi-hub-replicate"
+
+// replicationPayload 复制响应：含凭证的注册表经 AES-256-GCM 加密
+type replicationPayload struct {
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+	Time  string `json:"time"`
+}
+
+// replicationCipher 由复制密钥派生 AES-256-GCM：HMAC-SHA256(token, 标签)
+func replicationCipher(token string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(replicationLabel))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// StartReplication 备用 Hub 后台定期从主 Hub 同步注册表；非 standby 时不启动
+func StartReplication() {
+	settings, _ := LoadHubSettings()
+	rep := settings.Replication
+	if !rep.IsStandby() {
+		return
+	}
+	if rep.Primary == "" || rep.Token == "" {
+		log.Printf("[hub] 备用模式缺少 replication.primary 或 replication.token，未启动同步")
+		return
+	}
+	log.Printf("[hub] 备用 Hub 模式，每 %s 从 %s 同步 spoke 注册表", rep.interval(), rep.Primary)
+	go func() {
+		for {
+			err := syncFromPrimary(context.Background(), rep)
+			replication.mu.Lock()
+			if err != nil {
+				replication.state.LastError = err.Error()
+			} else {
+				replication.state.LastError = ""
+				replication.state.LastSync = time.Now()
+			}
+			replication.mu.Unlock()
+			if err != nil {
+				log.Printf("[hub] 同步主 Hub 失败: %v", err)
+			}
+			time.Sleep(rep.interval())
+		}
+	}()
+}
+
+func syncFromPrimary(ctx context.Context, rep HubReplication) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	url := strings.TrimRight(rep.Primary, "/") + "/__hub__/v1/replicate"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+rep.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var payload replicationPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return fmt.Errorf("解析复制数据失败: %v", err)
+	}
+	gcm, err := replicationCipher(rep.Token)
+	if err != nil {
+		return err
+	}
+	if len(payload.Nonce) != gcm.NonceSize() {
+		return fmt.Errorf("复制数据格式无效（主 Hub 版本过旧？）")
+	}
+	plain, err := gcm.Open(nil, payload.Nonce, payload.Data, []byte(replicationLabel))
+	if err != nil {
+		return fmt.Errorf("解密复制数据失败：复制密钥不一致")
+	}
+	var items []storedSpoke
+	if err := json.Unmarshal(plain, &items); err != nil {
+		return fmt.Errorf("解析复制数据失败: %v", err)
+	}
+	if err := ReplaceSpokes(fromStoredSpokes(items)); err != nil {
+		return err
+	}
+	replication.mu.Lock()
+	replication.state.Spokes = len(items)
+	replication.mu.Unlock()
+	return nil
+}
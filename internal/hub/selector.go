@@ -0,0 +1,250 @@
+package hub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 标签选择器语法（逗号分隔，条件之间为“与”关系）：
+//
+//	env=prod          标签等于
+//	env!=prod         标签不等于（或不存在）
+//	env=prod|staging  标签取值之一
+//	region            存在该标签
+//	!canary           不存在该标签
+//	all               全部在册节点
+//
+// 除自定义标签外，还可使用内置键 id、state（online/stale/offline/revoked）、project_type。
+
+type selectorOp int
+
+const (
+	selEquals selectorOp = iota
+	selNotEquals
+	selExists
+	selNotExists
+)
+
+type selectorTerm struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+// Selector 已解析的标签选择器
+type Selector struct {
+	terms []selectorTerm
+	all   bool
+}
+
+// ParseSelector 解析选择器表达式；空字符串等价于 all
+func ParseSelector(expr string) (Selector, error) {
+	var sel Selector
+	for _, raw := range strings.Split(expr, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if raw == "all" {
+			sel.all = true
+			continue
+		}
+		term, err := parseSelectorTerm(raw)
+		if err != nil {
+			return Selector{}, err
+		}
+		sel.terms = append(sel.terms, term)
+	}
+	if len(sel.terms) == 0 {
+		sel.all = true
+	}
+	return sel, nil
+}
+
+func parseSelectorTerm(raw string) (selectorTerm, error) {
+	if k, v, ok := strings.Cut(raw, "!="); ok {
+		return newSelectorTerm(k, selNotEquals, v, raw)
+	}
+	if k, v, ok := strings.Cut(raw, "="); ok {
+		return newSelectorTerm(k, selEquals, v, raw)
+	}
+	if strings.HasPrefix(raw, "!") {
+		return newSelectorTerm(raw[1:], selNotExists, "", raw)
+	}
+	return newSelectorTerm(raw, selExists, "", raw)
+}
+
+func newSelectorTerm(key string, op selectorOp, value, raw string) (selectorTerm, error) {
+	key = strings.TrimSpace(key)
+	if !validTagKey(key) {
+		return selectorTerm{}, fmt.Errorf("无效的选择条件: %s", raw)
+	}
+	term := selectorTerm{key: key, op: op}
+	if op == selEquals || op == selNotEquals {
+		for _, v := range strings.Split(value, "|") {
+			if v = strings.TrimSpace(v); v != "" {
+				term.values = append(term.values, v)
+			}
+		}
+		if len(term.values) == 0 {
+			return selectorTerm{}, fmt.Errorf("选择条件缺少取值: %s", raw)
+		}
+	}
+	return term, nil
+}
+
+// IsAll 是否匹配全部节点
+func (s Selector) IsAll() bool {
+	return s.all && len(s.terms) == 0
+}
+
+// String 规范化后的表达式
+func (s Selector) String() string {
+	if s.IsAll() {
+		return "all"
+	}
+	parts := make([]string, 0, len(s.terms))
+	for _, t := range s.terms {
+		switch t.op {
+		case selEquals:
+			parts = append(parts, t.key+"="+strings.Join(t.values, "|"))
+		case selNotEquals:
+			parts = append(parts, t.key+"!="+strings.Join(t.values, "|"))
+		case selExists:
+			parts = append(parts, t.key)
+		case selNotExists:
+			parts = append(parts, "!"+t.key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Matches 判断 spoke 是否满足全部条件
+func (s Selector) Matches(rec SpokeRecord) bool {
+	labels := selectorLabels(rec)
+	for _, t := range s.terms {
+		v, ok := labels[t.key]
+		switch t.op {
+		case selEquals:
+			if !ok || !containsString(t.values, v) {
+				return false
+			}
+		case selNotEquals:
+			if ok && containsString(t.values, v) {
+				return false
+			}
+		case selExists:
+			if !ok {
+				return false
+			}
+		case selNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// selectorLabels 生效标签 + 内置键
+func selectorLabels(rec SpokeRecord) map[string]string {
+	labels := rec.EffectiveTags()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels["id"] = rec.ID
+	if rec.State != "" {
+		labels["state"] = rec.State
+	}
+	if rec.Profile != nil && rec.Profile.ProjectType != "" {
+		labels["project_type"] = rec.Profile.ProjectType
+	}
+	return labels
+}
+
+// SelectSpokes 返回匹配选择器的 spoke（按 ID 排序）；非 all 选择器默认不含已吊销节点
+func SelectSpokes(expr string) ([]SpokeRecord, error) {
+	sel, err := ParseSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SpokeRecord, 0)
+	for _, rec := range ListSpokes() {
+		if sel.Matches(rec) && (sel.IsAll() || !rec.Revoked || sel.mentionsRevoked()) {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// mentionsRevoked 选择器显式按 state=revoked 过滤时保留已吊销节点
+func (s Selector) mentionsRevoked() bool {
+	for _, t := range s.terms {
+		if t.key == "state" && t.op == selEquals && containsString(t.values, StateRevoked) {
+			return true
+		}
+	}
+	return false
+}
+
+// LooksLikeSelector 判断目标表达式是否包含选择条件（而非单纯的 spoke ID 列表）
+func LooksLikeSelector(expr string) bool {
+	return strings.ContainsAny(expr, "=!")
+}
+
+// validTagKey 标签键：字母、数字、- _ . /
+func validTagKey(key string) bool {
+	if key == "" || len(key) > 63 {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTags 解析 k=v 形式的标签列表（逗号或空白分隔）
+func ParseTags(items []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, item := range items {
+		for _, kv := range strings.FieldsFunc(item, func(r rune) bool { return r == ',' || r == ' ' }) {
+			k, v, ok := strings.Cut(kv, "=")
+			k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+			if !ok || v == "" || !validTagKey(k) {
+				return nil, fmt.Errorf("无效的标签（应为 key=value）: %s", kv)
+			}
+			tags[k] = v
+		}
+	}
+	return tags, nil
+}
+
+// FormatTags 以 k=v 形式按键排序输出
+func FormatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SpokeStorage spoke 注册表持久化后端（默认 JSON 文件，可替换为嵌入式 KV / SQLite）；
+// SpokeRecord 的凭证字段不参与 JSON 序列化，自定义后端需自行保存 TokenHash 与 SigningKey
+type SpokeStorage interface {
+	Load() ([]SpokeRecord, error)
+	Save(records []SpokeRecord) error
+}
+
+// jsonFileStorage 以单个 JSON 文件保存注册表，写入采用临时文件 + rename 保证原子性
+type jsonFileStorage struct {
+	path string
+}
+
+// NewJSONFileStorage 创建 JSON 文件存储
+func NewJSONFileStorage(path string) SpokeStorage {
+	return &jsonFileStorage{path: path}
+}
+
+func (s *jsonFileStorage) Load() ([]SpokeRecord, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取 spoke 配置失败: %v", err)
+	}
+	var items []storedSpoke
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析 spoke 配置失败: %v", err)
+	}
+	return fromStoredSpokes(items), nil
+}
+
+func (s *jsonFileStorage) Save(records []SpokeRecord) error {
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	data, err := json.MarshalIndent(toStoredSpokes(records), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0600)
+}
+
+// writeFileAtomic 先写同目录临时文件并 fsync，再 rename 覆盖，避免进程中断留下半截文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
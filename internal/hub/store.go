@@ -5,10 +5,15 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"ruoyi-proxy/internal/agent"
 )
 
 const spokesFile = "configs/hub_spokes.json"
@@ -19,69 +24,200 @@ type pendingTokenRecord struct {
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
-// SpokeRecord 已注册 spoke 节点
+// SpokeRecord 已注册 spoke 节点；凭证字段不参与 JSON 输出（状态接口、管理接口、模型工具均直接序列化该结构）
 type SpokeRecord struct {
-	ID        string        `json:"id"`
-	TokenHash string        `json:"token_hash"`
-	CreatedAt time.Time     `json:"created_at"`
-	LastSeen  time.Time     `json:"last_seen"`
-	Revoked   bool          `json:"revoked"`
-	Profile   *SpokeProfile `json:"profile,omitempty"`
+	ID            string            `json:"id"`
+	TokenHash     string            `json:"-"` // 凭证 SHA-256，用于 Bearer 校验
+	SigningKey    string            `json:"-"` // 请求签名密钥（agent.HubSigningKey），与 TokenHash 相互独立
+	CreatedAt     time.Time         `json:"created_at"`
+	LastSeen      time.Time         `json:"last_seen"`
+	LastHeartbeat time.Time         `json:"last_heartbeat,omitempty"`
+	Revoked       bool              `json:"revoked"`
+	Profile       *SpokeProfile     `json:"profile,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`   // Hub 端指定的标签，覆盖 spoke 上报的同名标签
+	Health        *SpokeHealth      `json:"health,omitempty"` // 最近一次心跳上报
+	State         string            `json:"state,omitempty"`  // online/stale/offline/revoked，读取时计算，不落盘
+}
+
+// storedSpoke 含凭证的完整记录，仅用于落盘、加密导出与加密复制
+type storedSpoke struct {
+	SpokeRecord
+	TokenHash  string `json:"token_hash"`
+	SigningKey string `json:"signing_key,omitempty"`
+}
+
+func toStoredSpokes(records []SpokeRecord) []storedSpoke {
+	out := make([]storedSpoke, len(records))
+	for i, rec := range records {
+		rec.State = ""
+		out[i] = storedSpoke{SpokeRecord: rec, TokenHash: rec.TokenHash, SigningKey: rec.SigningKey}
+	}
+	return out
+}
+
+func fromStoredSpokes(items []storedSpoke) []SpokeRecord {
+	out := make([]SpokeRecord, len(items))
+	for i, item := range items {
+		out[i] = item.SpokeRecord
+		out[i].TokenHash = item.TokenHash
+		out[i].SigningKey = item.SigningKey
+	}
+	return out
+}
+
+// Spoke 在线状态
+const (
+	StateOnline  = "online"
+	StateStale   = "stale"
+	StateOffline = "offline"
+	StateRevoked = "revoked"
+)
+
+// HeartbeatInterval Spoke 心跳间隔；超过 3 个间隔未收到视为 stale，超过 10 个视为 offline
+const HeartbeatInterval = 30 * time.Second
+
+// deriveState 根据最近心跳（无心跳时退回最近活动时间）推算在线状态
+func deriveState(rec SpokeRecord, now time.Time) string {
+	if rec.Revoked {
+		return StateRevoked
+	}
+	last := rec.LastHeartbeat
+	if last.IsZero() {
+		last = rec.LastSeen
+	}
+	switch age := now.Sub(last); {
+	case age <= 3*HeartbeatInterval:
+		return StateOnline
+	case age <= 10*HeartbeatInterval:
+		return StateStale
+	default:
+		return StateOffline
+	}
+}
+
+// withState 返回带在线状态的副本
+func withState(rec *SpokeRecord, now time.Time) SpokeRecord {
+	out := *rec
+	out.State = deriveState(out, now)
+	return out
+}
+
+// EffectiveTags spoke 上报标签与 Hub 指定标签合并后的结果（Hub 优先）
+func (r SpokeRecord) EffectiveTags() map[string]string {
+	if len(r.Tags) == 0 && (r.Profile == nil || len(r.Profile.Tags) == 0) {
+		return nil
+	}
+	tags := make(map[string]string)
+	if r.Profile != nil {
+		for k, v := range r.Profile.Tags {
+			tags[k] = v
+		}
+	}
+	for k, v := range r.Tags {
+		tags[k] = v
+	}
+	return tags
 }
 
+// lastSeenFlushDelay LastSeen/心跳等高频字段的合并落盘延迟
+const lastSeenFlushDelay = 5 * time.Second
+
 type spokeStore struct {
 	mu           sync.RWMutex
 	spokes       map[string]*SpokeRecord
+	byHash       map[string]string // token hash → spoke ID
+	storage      SpokeStorage
+	dirty        bool        // 有未落盘的高频更新
+	flushTimer   *time.Timer // 延迟落盘定时器
 	pendingToken string
 	pendingExp   time.Time
 }
 
 var defaultStore = &spokeStore{
-	spokes: make(map[string]*SpokeRecord),
+	spokes:  make(map[string]*SpokeRecord),
+	byHash:  make(map[string]string),
+	storage: NewJSONFileStorage(spokesFile),
+}
+
+// SetSpokeStorage 替换注册表存储后端（需在 LoadSpokes 之前调用）
+func SetSpokeStorage(storage SpokeStorage) {
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+	defaultStore.storage = storage
 }
 
-// LoadSpokes 从磁盘加载 spoke 注册表
+// LoadSpokes 从存储加载 spoke 注册表
 func LoadSpokes() error {
 	defaultStore.mu.Lock()
 	defer defaultStore.mu.Unlock()
 
-	data, err := os.ReadFile(spokesFile)
+	items, err := defaultStore.storage.Load()
 	if err != nil {
-		if os.IsNotExist(err) {
-			defaultStore.spokes = make(map[string]*SpokeRecord)
-			return nil
-		}
-		return fmt.Errorf("读取 spoke 配置失败: %v", err)
-	}
-	var items []SpokeRecord
-	if err := json.Unmarshal(data, &items); err != nil {
-		return fmt.Errorf("解析 spoke 配置失败: %v", err)
+		return err
 	}
 	defaultStore.spokes = make(map[string]*SpokeRecord, len(items))
+	defaultStore.byHash = make(map[string]string, len(items))
 	for i := range items {
 		rec := items[i]
 		defaultStore.spokes[rec.ID] = &rec
+		defaultStore.byHash[rec.TokenHash] = rec.ID
 	}
+	defaultStore.dirty = false
 	return nil
 }
 
+// saveSpokesLocked 立即落盘（注册、吊销、档案变更等关键写入）
 func saveSpokesLocked() error {
 	items := make([]SpokeRecord, 0, len(defaultStore.spokes))
 	for _, rec := range defaultStore.spokes {
-		items = append(items, *rec)
+		item := *rec
+		item.State = ""
+		items = append(items, item)
 	}
-	data, err := json.MarshalIndent(items, "", "  ")
-	if err != nil {
+	if err := defaultStore.storage.Save(items); err != nil {
 		return err
 	}
-	if err := os.MkdirAll("configs", 0755); err != nil {
-		return err
+	defaultStore.dirty = false
+	return nil
+}
+
+// markDirtyLocked 标记高频字段已变更，延迟合并落盘
+func markDirtyLocked() {
+	defaultStore.dirty = true
+	if defaultStore.flushTimer != nil {
+		return
+	}
+	defaultStore.flushTimer = time.AfterFunc(lastSeenFlushDelay, func() {
+		defaultStore.mu.Lock()
+		defer defaultStore.mu.Unlock()
+		defaultStore.flushTimer = nil
+		if defaultStore.dirty {
+			if err := saveSpokesLocked(); err != nil {
+				log.Printf("[hub] 保存 spoke 注册表失败: %v", err)
+			}
+		}
+	})
+}
+
+// FlushSpokes 立即写出尚未落盘的更新（进程退出前调用）
+func FlushSpokes() error {
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+	if defaultStore.flushTimer != nil {
+		defaultStore.flushTimer.Stop()
+		defaultStore.flushTimer = nil
+	}
+	if !defaultStore.dirty {
+		return nil
 	}
-	return os.WriteFile(spokesFile, data, 0644)
+	return saveSpokesLocked()
 }
 
 // GenerateRegisterToken 生成一次性注册 Token（15 分钟有效，写入磁盘供 CLI/代理共享）
 func GenerateRegisterToken() (string, error) {
+	if err := standbyGuard(); err != nil {
+		return "", err
+	}
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
@@ -152,56 +288,132 @@ func consumeRegisterToken(token string) bool {
 
 // RegisterSpoke 校验一次性 Token 并颁发长期凭证
 func RegisterSpoke(oneTimeToken string) (spokeID, secret string, err error) {
-	if !consumeRegisterToken(oneTimeToken) {
-		return "", "", fmt.Errorf("注册 Token 无效或已过期")
-	}
-	secret, err = randomHex(32)
-	if err != nil {
+	if err := standbyGuard(); err != nil {
 		return "", "", err
 	}
-	spokeID = "spoke-" + secret[:8]
-	hash := hashToken(secret)
+	if !consumeRegisterToken(oneTimeToken) {
+		return "", "", errInvalidRegisterToken
+	}
 
 	defaultStore.mu.Lock()
 	defer defaultStore.mu.Unlock()
+	for {
+		if secret, err = randomHex(32); err != nil {
+			return "", "", err
+		}
+		spokeID = agent.HubSpokeID(secret)
+		if _, exists := defaultStore.spokes[spokeID]; !exists {
+			break
+		}
+	}
+	hash := hashToken(secret)
 	now := time.Now()
 	defaultStore.spokes[spokeID] = &SpokeRecord{
-		ID:        spokeID,
-		TokenHash: hash,
-		CreatedAt: now,
-		LastSeen:  now,
+		ID:         spokeID,
+		TokenHash:  hash,
+		SigningKey: agent.HubSigningKey(secret),
+		CreatedAt:  now,
+		LastSeen:   now,
 	}
+	defaultStore.byHash[hash] = spokeID
 	if err := saveSpokesLocked(); err != nil {
 		return "", "", err
 	}
 	return spokeID, secret, nil
 }
 
-// ValidateSpokeToken 校验 spoke 长期凭证
+// errInvalidRegisterToken 注册 Token 校验失败（计入来源 IP 的认证失败次数）
+var errInvalidRegisterToken = errors.New("注册 Token 无效或已过期")
+
+// ValidateSpokeToken 校验 spoke 长期凭证（按 hash 索引查找，LastSeen 延迟落盘）
 func ValidateSpokeToken(secret string) (string, bool) {
+	if secret == "" {
+		return "", false
+	}
 	hash := hashToken(secret)
+	defaultStore.mu.RLock()
+	id, ok := defaultStore.byHash[hash]
+	rec := defaultStore.spokes[id]
+	valid := ok && rec != nil && !rec.Revoked
+	needKey := valid && rec.SigningKey == ""
+	defaultStore.mu.RUnlock()
+	if !valid {
+		return "", false
+	}
+	if needKey {
+		// 早期注册的记录没有独立签名密钥，凭证出现时补齐，之后即可改用签名
+		saveSigningKey(id, agent.HubSigningKey(secret))
+	}
+
+	touchSpoke(id)
+	return id, true
+}
+
+func saveSigningKey(spokeID, key string) {
 	defaultStore.mu.Lock()
 	defer defaultStore.mu.Unlock()
-	for id, rec := range defaultStore.spokes {
-		if rec.Revoked {
-			continue
+	if rec, ok := defaultStore.spokes[spokeID]; ok && rec.SigningKey == "" {
+		rec.SigningKey = key
+		if err := saveSpokesLocked(); err != nil {
+			log.Printf("[hub] 保存 spoke 签名密钥失败: %v", err)
 		}
-		if rec.TokenHash == hash {
-			rec.LastSeen = time.Now()
-			_ = saveSpokesLocked()
-			return id, true
+	}
+}
+
+// spokeSigningKey 返回未吊销 spoke 的签名密钥
+func spokeSigningKey(spokeID string) (string, string, error) {
+	defaultStore.mu.RLock()
+	defer defaultStore.mu.RUnlock()
+	rec, ok := defaultStore.spokes[spokeID]
+	if !ok {
+		rec, ok = legacySpokeLocked(spokeID)
+	}
+	if !ok || rec.Revoked {
+		return "", "", fmt.Errorf("无效或已吊销的凭证")
+	}
+	if rec.SigningKey == "" {
+		return "", "", fmt.Errorf("Hub 尚无该 Spoke 的签名密钥，请以 bearer 方式连接一次或重新注册")
+	}
+	return rec.ID, rec.SigningKey, nil
+}
+
+// legacySpokeLocked 旧版注册的 spoke ID 取自凭证前缀，与 Spoke 现在按凭证 hash 推导的 ID 不同，
+// 按凭证 hash 前缀找回对应记录（多条匹配时不予识别）
+func legacySpokeLocked(spokeID string) (*SpokeRecord, bool) {
+	prefix := strings.TrimPrefix(spokeID, "spoke-")
+	if prefix == spokeID || len(prefix) != 8 {
+		return nil, false
+	}
+	var found *SpokeRecord
+	for hash, id := range defaultStore.byHash {
+		if strings.HasPrefix(hash, prefix) {
+			if found != nil {
+				return nil, false
+			}
+			found = defaultStore.spokes[id]
 		}
 	}
-	return "", false
+	return found, found != nil
+}
+
+// touchSpoke 更新最近活动时间（延迟落盘）
+func touchSpoke(spokeID string) {
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+	if rec, ok := defaultStore.spokes[spokeID]; ok {
+		rec.LastSeen = time.Now()
+		markDirtyLocked()
+	}
 }
 
 // ListSpokes 返回所有 spoke（副本）
 func ListSpokes() []SpokeRecord {
 	defaultStore.mu.RLock()
 	defer defaultStore.mu.RUnlock()
+	now := time.Now()
 	out := make([]SpokeRecord, 0, len(defaultStore.spokes))
 	for _, rec := range defaultStore.spokes {
-		out = append(out, *rec)
+		out = append(out, withState(rec, now))
 	}
 	return out
 }
@@ -214,7 +426,24 @@ func GetSpoke(spokeID string) (SpokeRecord, bool) {
 	if !ok {
 		return SpokeRecord{}, false
 	}
-	return *rec, true
+	return withState(rec, time.Now()), true
+}
+
+// RecordHeartbeat 记录 spoke 心跳与健康信息
+func RecordHeartbeat(spokeID string, health SpokeHealth) error {
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+	rec, ok := defaultStore.spokes[spokeID]
+	if !ok {
+		return fmt.Errorf("spoke 不存在: %s", spokeID)
+	}
+	now := time.Now()
+	health.ReportedAt = now
+	rec.Health = &health
+	rec.LastHeartbeat = now
+	rec.LastSeen = now
+	markDirtyLocked()
+	return nil
 }
 
 // UpdateSpokeProfile 更新 spoke 节点档案（Hub 集中管理）
@@ -234,8 +463,70 @@ func UpdateSpokeProfile(spokeID string, profile SpokeProfile) error {
 	return saveSpokesLocked()
 }
 
+// ReplaceSpokes 以给定记录整体替换注册表（导入、备 Hub 同步），保留本机更新的活动时间与心跳
+func ReplaceSpokes(records []SpokeRecord) error {
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+	spokes := make(map[string]*SpokeRecord, len(records))
+	byHash := make(map[string]string, len(records))
+	for i := range records {
+		rec := records[i]
+		rec.State = ""
+		if local, ok := defaultStore.spokes[rec.ID]; ok && local.TokenHash == rec.TokenHash {
+			if local.LastSeen.After(rec.LastSeen) {
+				rec.LastSeen = local.LastSeen
+			}
+			if local.LastHeartbeat.After(rec.LastHeartbeat) {
+				rec.LastHeartbeat = local.LastHeartbeat
+				rec.Health = local.Health
+			}
+		}
+		spokes[rec.ID] = &rec
+		if !rec.Revoked {
+			byHash[rec.TokenHash] = rec.ID
+		}
+	}
+	defaultStore.spokes = spokes
+	defaultStore.byHash = byHash
+	return saveSpokesLocked()
+}
+
+// SetSpokeTags 在 Hub 端设置/删除 spoke 标签（set 中的键覆盖，remove 中的键删除）
+func SetSpokeTags(spokeID string, set map[string]string, remove []string) error {
+	if err := standbyGuard(); err != nil {
+		return err
+	}
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+	rec, ok := defaultStore.spokes[spokeID]
+	if !ok {
+		return fmt.Errorf("spoke 不存在: %s", spokeID)
+	}
+	for k, v := range set {
+		if !validTagKey(k) || v == "" {
+			return fmt.Errorf("无效的标签: %s=%s", k, v)
+		}
+	}
+	if rec.Tags == nil {
+		rec.Tags = make(map[string]string)
+	}
+	for k, v := range set {
+		rec.Tags[k] = v
+	}
+	for _, k := range remove {
+		delete(rec.Tags, k)
+	}
+	if len(rec.Tags) == 0 {
+		rec.Tags = nil
+	}
+	return saveSpokesLocked()
+}
+
 // RevokeSpoke 吊销指定 spoke
 func RevokeSpoke(spokeID string) error {
+	if err := standbyGuard(); err != nil {
+		return err
+	}
 	defaultStore.mu.Lock()
 	defer defaultStore.mu.Unlock()
 	rec, ok := defaultStore.spokes[spokeID]
@@ -243,6 +534,7 @@ func RevokeSpoke(spokeID string) error {
 		return fmt.Errorf("spoke 不存在: %s", spokeID)
 	}
 	rec.Revoked = true
+	delete(defaultStore.byHash, rec.TokenHash)
 	return saveSpokesLocked()
 }
 
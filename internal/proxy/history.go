@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const switchHistoryFile = "configs/switch_history.jsonl"
+
+// switchHistoryKeep 历史文件超过该条数时在下次追加时截断
+const switchHistoryKeep = 1000
+
+// SwitchRecord 一次蓝绿切换记录
+type SwitchRecord struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+}
+
+var historyMu sync.Mutex
+
+// recordSwitch 追加切换记录（失败只影响历史展示，不影响切换本身）
+func recordSwitch(records ...SwitchRecord) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(switchHistoryFile), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(switchHistoryFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(f)
+	for _, rec := range records {
+		_ = enc.Encode(rec)
+	}
+	f.Close()
+
+	if all := readSwitchHistoryLocked(); len(all) > switchHistoryKeep {
+		rewriteSwitchHistoryLocked(all[len(all)-switchHistoryKeep:])
+	}
+}
+
+// SwitchHistory 返回最近 limit 条切换记录（新的在前），limit<=0 返回全部
+func SwitchHistory(limit int) []SwitchRecord {
+	historyMu.Lock()
+	all := readSwitchHistoryLocked()
+	historyMu.Unlock()
+
+	out := make([]SwitchRecord, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		out = append(out, all[i])
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+func readSwitchHistoryLocked() []SwitchRecord {
+	f, err := os.Open(switchHistoryFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []SwitchRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec SwitchRecord
+		if json.Unmarshal(sc.Bytes(), &rec) == nil {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+func rewriteSwitchHistoryLocked(records []SwitchRecord) {
+	tmp := switchHistoryFile + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(f)
+	for _, rec := range records {
+		_ = enc.Encode(rec)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	_ = os.Rename(tmp, switchHistoryFile)
+}
@@ -198,8 +198,13 @@ func (p *Proxy) SwitchService(serviceID, env string) error {
 		return fmt.Errorf("服务不存在: %s", serviceID)
 	}
 
+	from := svc.ActiveEnv
 	svc.ActiveEnv = env
-	return config.SaveConfig(p.config)
+	if err := config.SaveConfig(p.config); err != nil {
+		return err
+	}
+	recordSwitch(SwitchRecord{Time: time.Now(), Service: serviceID, From: from, To: env})
+	return nil
 }
 
 // SwitchAll 切换所有服务的环境
@@ -207,10 +212,17 @@ func (p *Proxy) SwitchAll(env string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	for _, svc := range p.config.Services {
+	now := time.Now()
+	records := make([]SwitchRecord, 0, len(p.config.Services))
+	for id, svc := range p.config.Services {
+		records = append(records, SwitchRecord{Time: now, Service: id, From: svc.ActiveEnv, To: env})
 		svc.ActiveEnv = env
 	}
-	return config.SaveConfig(p.config)
+	if err := config.SaveConfig(p.config); err != nil {
+		return err
+	}
+	recordSwitch(records...)
+	return nil
 }
 
 // AddService 添加新服务
@@ -0,0 +1,134 @@
+package spoke
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+	"ruoyi-proxy/internal/config"
+	"ruoyi-proxy/internal/hub"
+)
+
+const artifactTimeout = 30 * time.Minute
+
+// PullArtifact 从 Hub 拉取制品到 APP_HOME 并校验 SHA-256。
+// JAR 按服务 jar_file 模式命名为带时间戳的文件（service.sh 按版本排序取最新），其他文件保留原名。
+// 返回落盘路径
+func PullArtifact(ctx context.Context, service, name, version string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("缺少制品名称")
+	}
+	if service == "" {
+		service = "default"
+	}
+	appHome := agent.BuildExecContext(service).AppHome
+	if appHome == "" {
+		return "", fmt.Errorf("未找到 scripts/service.sh，无法确定 APP_HOME")
+	}
+	cfg, err := agent.HubConnection()
+	if err != nil {
+		return "", err
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": name, "version": version})
+	resp, err := agent.SendHubRequest(ctx, cfg, http.MethodPost, "/__hub__/v1/artifact", body, artifactTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("Hub 返回 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	want := strings.ToLower(resp.Header.Get(hub.ArtifactSHA256Header))
+	if want == "" {
+		return "", fmt.Errorf("Hub 响应缺少 %s，拒绝落盘", hub.ArtifactSHA256Header)
+	}
+
+	// 先写入同目录的隐藏临时文件，校验通过后再改名，避免 service.sh 选中未完成的文件
+	tmp, err := os.CreateTemp(appHome, ".artifact-*")
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("下载制品失败: %v", err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return "", fmt.Errorf("下载不完整: %d/%d 字节", n, resp.ContentLength)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("SHA-256 校验失败: 期望 %s，实际 %s", want, got)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+
+	fileName := resp.Header.Get(hub.ArtifactFileHeader)
+	target, err := artifactTarget(appHome, service, fileName, name)
+	if err != nil {
+		return "", err
+	}
+	// 同一秒内多次拉取 JAR 时时间戳文件名相同，顺延到下一秒
+	for i := 0; i < 3 && strings.HasSuffix(target, ".jar"); i++ {
+		if _, statErr := os.Stat(target); statErr != nil {
+			break
+		}
+		time.Sleep(time.Second)
+		if target, err = artifactTarget(appHome, service, fileName, name); err != nil {
+			return "", err
+		}
+	}
+	if _, err := os.Stat(target); err == nil {
+		return "", fmt.Errorf("目标文件已存在: %s", target)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", fmt.Errorf("保存制品失败: %v", err)
+	}
+	return target, nil
+}
+
+// artifactTarget 计算落盘路径：.jar 按服务 jar_file 模式生成 前缀+YYYYMMDD-HHMMSS+后缀 的文件名
+func artifactTarget(appHome, service, fileName, name string) (string, error) {
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == string(filepath.Separator) || fileName == "" {
+		fileName = name
+	}
+	if !strings.HasSuffix(strings.ToLower(fileName), ".jar") {
+		return filepath.Join(appHome, fileName), nil
+	}
+
+	pattern := "ruoyi-*.jar"
+	if cfg, err := config.LoadConfig(); err == nil {
+		if svc := cfg.GetService(service); svc != nil && svc.JarFile != "" {
+			pattern = svc.JarFile
+		}
+	}
+	start := strings.IndexAny(pattern, "*?[")
+	end := strings.LastIndex(pattern, "*")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("jar_file 模式 %q 不含通配符，无法生成时间戳文件名", pattern)
+	}
+	jarName := pattern[:start] + time.Now().Format("20060102-150405") + pattern[end+1:]
+	if ok, _ := filepath.Match(pattern, jarName); !ok {
+		return "", fmt.Errorf("生成的文件名 %s 不匹配 jar_file 模式 %s", jarName, pattern)
+	}
+	return filepath.Join(appHome, jarName), nil
+}
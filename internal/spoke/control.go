@@ -0,0 +1,419 @@
+package spoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+	"ruoyi-proxy/internal/config"
+	"ruoyi-proxy/internal/hub"
+)
+
+const (
+	pollWaitSeconds = 25
+	scriptTimeout   = 15 * time.Minute
+	flushInterval   = time.Second
+)
+
+// Controller Spoke 端远程命令通道：长轮询 Hub 拉取命令，按本机策略执行并回传结果
+type Controller struct {
+	interactive bool
+	notify      func(string)
+	cancel      context.CancelFunc
+
+	execMu  sync.Mutex // 命令串行执行，避免并发部署
+	mu      sync.Mutex
+	pending map[string]hub.ControlCommand // 等待本地确认的写操作
+}
+
+// NewController 创建远程命令通道；interactive 表示有操作员可在 CLI 中确认写操作
+func NewController(interactive bool, notify func(string)) *Controller {
+	if notify == nil {
+		notify = func(string) {}
+	}
+	return &Controller{
+		interactive: interactive,
+		notify:      notify,
+		pending:     make(map[string]hub.ControlCommand),
+	}
+}
+
+// Start 后台启动通道；未连接 Hub 或本机关闭远程命令时返回 nil
+func Start(interactive bool, notify func(string)) *Controller {
+	if _, err := agent.HubConnection(); err != nil {
+		return nil
+	}
+	if !LoadSettings().Control.IsEnabled() {
+		return nil
+	}
+	c := NewController(interactive, notify)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.Run(ctx)
+	return c
+}
+
+// Stop 停止后台通道
+func (c *Controller) Stop() {
+	if c != nil && c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Run 阻塞运行长轮询循环，直到 ctx 取消
+func (c *Controller) Run(ctx context.Context) {
+	backoff := 5 * time.Second
+	for ctx.Err() == nil {
+		cmds, err := c.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = 5 * time.Second
+		for _, cmd := range cmds {
+			c.handle(ctx, cmd)
+		}
+	}
+}
+
+func (c *Controller) poll(ctx context.Context) ([]hub.ControlCommand, error) {
+	cfg, err := agent.HubConnection()
+	if err != nil {
+		return nil, err
+	}
+	path := "/__hub__/v1/control/poll?wait=" + strconv.Itoa(pollWaitSeconds)
+	_, data, err := agent.DoHubRequest(ctx, cfg, http.MethodGet, path, nil, (pollWaitSeconds+15)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Commands []hub.ControlCommand `json:"commands"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析 Hub 命令失败: %v", err)
+	}
+	return out.Commands, nil
+}
+
+// handle 按本机策略决定拒绝、待确认或执行
+func (c *Controller) handle(ctx context.Context, cmd hub.ControlCommand) {
+	settings := LoadSettings().Control
+	if !settings.IsEnabled() {
+		c.reject(cmd, "本机已关闭远程命令（spoke.control.enabled=false）")
+		return
+	}
+	if _, ok := hub.ControlOps[cmd.Op]; !ok {
+		c.reject(cmd, "不支持的操作: "+cmd.Op)
+		return
+	}
+	if !settings.Allows(cmd.Op) {
+		c.reject(cmd, fmt.Sprintf("操作 %s 不在本机白名单（spoke.control.allow）", cmd.Op))
+		return
+	}
+	if hub.IsWriteOp(cmd.Op) {
+		switch settings.Policy() {
+		case WritePolicyDeny:
+			c.reject(cmd, "本机禁止远程写操作（spoke.control.write_policy=deny）")
+			return
+		case WritePolicyConfirm:
+			if !c.interactive {
+				c.reject(cmd, "写操作需在 Spoke CLI 中确认，当前为无人值守模式")
+				return
+			}
+			c.mu.Lock()
+			c.pending[cmd.ID] = cmd
+			c.mu.Unlock()
+			c.report(hub.ControlResult{ID: cmd.ID, Status: hub.CommandPendingApproval})
+			c.notify(fmt.Sprintf("\033[1;33m[Hub] 收到远程命令 %s: %s，需确认。/control-approve %s 执行，/control-reject %s 拒绝\033[0m",
+				cmd.ID, describeCommand(cmd), cmd.ID, cmd.ID))
+			return
+		}
+	}
+	go c.execute(ctx, cmd)
+}
+
+// Pending 返回等待本地确认的命令
+func (c *Controller) Pending() []hub.ControlCommand {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]hub.ControlCommand, 0, len(c.pending))
+	for _, cmd := range c.pending {
+		out = append(out, cmd)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func (c *Controller) takePending(id string) (hub.ControlCommand, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cmd, ok := c.pending[id]
+	if !ok {
+		return hub.ControlCommand{}, fmt.Errorf("没有待确认的命令: %s", id)
+	}
+	delete(c.pending, id)
+	return cmd, nil
+}
+
+// Approve 确认并执行待确认命令
+func (c *Controller) Approve(id string) error {
+	cmd, err := c.takePending(id)
+	if err != nil {
+		return err
+	}
+	go c.execute(context.Background(), cmd)
+	return nil
+}
+
+// Reject 拒绝待确认命令
+func (c *Controller) Reject(id string) error {
+	cmd, err := c.takePending(id)
+	if err != nil {
+		return err
+	}
+	c.reject(cmd, "Spoke 操作员已拒绝")
+	return nil
+}
+
+func (c *Controller) reject(cmd hub.ControlCommand, reason string) {
+	c.report(hub.ControlResult{ID: cmd.ID, Status: hub.CommandRejected, Error: reason})
+	c.notify(fmt.Sprintf("\033[1;33m[Hub] 已拒绝远程命令 %s（%s）: %s\033[0m", cmd.ID, describeCommand(cmd), reason))
+}
+
+func (c *Controller) report(res hub.ControlResult) {
+	cfg, err := agent.HubConnection()
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	_, _, _ = agent.DoHubRequest(context.Background(), cfg, http.MethodPost, "/__hub__/v1/control/result", body, 30*time.Second)
+}
+
+// execute 执行命令，输出按秒批量回传
+func (c *Controller) execute(ctx context.Context, cmd hub.ControlCommand) {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	c.notify(fmt.Sprintf("\033[1;36m[Hub] 执行远程命令 %s: %s\033[0m", cmd.ID, describeCommand(cmd)))
+	out := newResultStream(c, cmd.ID)
+	err := runOp(ctx, cmd, out)
+	out.finish(err)
+}
+
+func runOp(ctx context.Context, cmd hub.ControlCommand, out io.Writer) error {
+	service := cmd.Service
+	if service == "" {
+		service = "default"
+	}
+	executor := agent.NewToolExecutor(agent.BuildExecContext(service))
+
+	var text string
+	var err error
+	switch cmd.Op {
+	case "status":
+		text, err = executor.Execute("get_status", "")
+	case "logs":
+		args := map[string]interface{}{}
+		if n, convErr := strconv.Atoi(cmd.Args["lines"]); convErr == nil && n > 0 {
+			args["lines"] = n
+		}
+		if v := cmd.Args["keyword"]; v != "" {
+			args["keyword"] = v
+		}
+		if v := cmd.Args["log_name"]; v != "" {
+			args["log_name"] = v
+		}
+		argsJSON, _ := json.Marshal(args)
+		text, err = executor.Execute("get_logs", string(argsJSON))
+	case "proxy-status":
+		text, err = localProxyStatus()
+	case "switch":
+		text, err = switchEnv(executor, service, cmd.Args["env"])
+	case "pull-artifact":
+		var path string
+		path, err = PullArtifact(ctx, service, cmd.Args["name"], cmd.Args["version"])
+		if err == nil {
+			text = "制品已保存并通过 SHA-256 校验: " + path
+		}
+	case "start", "stop", "restart", "deploy", "deploy-lowmem":
+		return runScript(ctx, executor, out, cmd.Op)
+	default:
+		return fmt.Errorf("不支持的操作: %s", cmd.Op)
+	}
+	if text != "" {
+		io.WriteString(out, text+"\n")
+	}
+	return err
+}
+
+// runScript 执行服务控制脚本，输出实时写入 out
+func runScript(ctx context.Context, executor *agent.ToolExecutor, out io.Writer, action string) error {
+	cmd, err := executor.ScriptCommand(action)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("命令执行失败: %v", err)
+		}
+		return nil
+	case <-time.After(scriptTimeout):
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("命令超时（%s）", scriptTimeout)
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		return ctx.Err()
+	}
+}
+
+func mgmtURL(path string) string {
+	port := config.MgmtPort
+	if strings.HasPrefix(port, ":") {
+		return "http://127.0.0.1" + port + path
+	}
+	return "http://" + port + path
+}
+
+func localProxyStatus() (string, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(mgmtURL("/status"))
+	if err != nil {
+		return "代理未运行", nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, data, "", "  ") == nil {
+		return pretty.String(), nil
+	}
+	return string(data), nil
+}
+
+// switchEnv 代理运行时通过管理端口即时切换，否则仅写配置
+func switchEnv(executor *agent.ToolExecutor, service, env string) (string, error) {
+	if env != "blue" && env != "green" {
+		return "", fmt.Errorf("无效环境: %q（必须是 blue 或 green）", env)
+	}
+	query := url.Values{"env": {env}, "service": {service}}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(mgmtURL("/switch?"+query.Encode()), "application/json", nil)
+	if err == nil {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("切换失败 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+		return fmt.Sprintf("服务[%s]已切换到 %s 环境", service, env), nil
+	}
+	argsJSON, _ := json.Marshal(map[string]string{"env": env})
+	return executor.Execute("switch_env", string(argsJSON))
+}
+
+func describeCommand(cmd hub.ControlCommand) string {
+	parts := []string{cmd.Op}
+	if cmd.Service != "" {
+		parts = append(parts, "service="+cmd.Service)
+	}
+	keys := make([]string, 0, len(cmd.Args))
+	for k := range cmd.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+cmd.Args[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// resultStream 缓冲命令输出，定时以增量形式回传 Hub
+type resultStream struct {
+	c    *Controller
+	id   string
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newResultStream(c *Controller, id string) *resultStream {
+	s := &resultStream{c: c, id: id, stop: make(chan struct{})}
+	c.report(hub.ControlResult{ID: id, Status: hub.CommandRunning})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if chunk := s.take(); chunk != "" {
+					c.report(hub.ControlResult{ID: id, Status: hub.CommandRunning, Output: chunk})
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *resultStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *resultStream) take() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chunk := s.buf.String()
+	s.buf.Reset()
+	return chunk
+}
+
+func (s *resultStream) finish(err error) {
+	close(s.stop)
+	s.wg.Wait()
+	res := hub.ControlResult{ID: s.id, Status: hub.CommandDone, Output: s.take()}
+	if err != nil {
+		res.Status = hub.CommandFailed
+		res.Error = err.Error()
+	}
+	s.c.report(res)
+	if err != nil {
+		s.c.notify(fmt.Sprintf("\033[1;31m[Hub] 远程命令 %s 失败: %v\033[0m", s.id, err))
+	} else {
+		s.c.notify(fmt.Sprintf("\033[1;32m[Hub] 远程命令 %s 已完成\033[0m", s.id))
+	}
+}
@@ -0,0 +1,27 @@
+package spoke
+
+import (
+	"context"
+	"time"
+
+	"ruoyi-proxy/internal/bootstrap"
+)
+
+// DiscoveryInterval 运行环境重新发现的间隔
+const DiscoveryInterval = 10 * time.Minute
+
+// RunDiscovery 阻塞定期重新发现本机运行环境，环境或漂移变化时同步档案到 Hub，直到 ctx 取消。
+// 未建档（无本机 Spoke 档案）时每轮静默跳过
+func RunDiscovery(ctx context.Context) {
+	// 启动后稍作延迟，避免与首次心跳、引导同时进行
+	delay := time.Minute
+	for {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		_, _, _ = bootstrap.RefreshSpokeProfile(ctx)
+		delay = DiscoveryInterval
+	}
+}
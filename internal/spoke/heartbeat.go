@@ -0,0 +1,198 @@
+package spoke
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"ruoyi-proxy/internal/agent"
+	"ruoyi-proxy/internal/config"
+	"ruoyi-proxy/internal/hub"
+)
+
+// StartHeartbeat 后台定期向 Hub 发送心跳并重新发现运行环境，返回停止函数；未连接 Hub 时不启动
+func StartHeartbeat() func() {
+	if _, err := agent.HubConnection(); err != nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go RunHeartbeat(ctx)
+	go RunDiscovery(ctx)
+	return cancel
+}
+
+// RunHeartbeat 阻塞发送心跳直到 ctx 取消（立即发送一次，之后按 Hub 返回的间隔）
+func RunHeartbeat(ctx context.Context) {
+	interval := hub.HeartbeatInterval
+	for {
+		if next, err := sendHeartbeat(ctx); err == nil && next > 0 {
+			interval = next
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func sendHeartbeat(ctx context.Context) (time.Duration, error) {
+	cfg, err := agent.HubConnection()
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(CollectHealth())
+	if err != nil {
+		return 0, err
+	}
+	_, data, err := agent.DoHubRequest(ctx, cfg, http.MethodPost, "/__hub__/v1/heartbeat", body, 15*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Interval      int    `json:"interval"`
+		PolicyVersion string `json:"policy_version"`
+	}
+	_ = json.Unmarshal(data, &resp)
+	// 策略版本变化时重新拉取；失败则沿用本地缓存，下次心跳重试
+	if resp.PolicyVersion != "" && resp.PolicyVersion != agent.CurrentHubPolicy().Version {
+		_, _, _ = agent.SyncHubPolicy(ctx, cfg)
+	}
+	return time.Duration(resp.Interval) * time.Second, nil
+}
+
+// CollectHealth 采集本机轻量健康信息（只读，不执行脚本）
+func CollectHealth() hub.SpokeHealth {
+	var h hub.SpokeHealth
+	h.ProxyRunning, h.Services = serviceHealth()
+	h.DiskUsedPercent, h.DiskFreeGB = diskUsage(agent.BuildExecContext("default").AppHome)
+	h.MemUsedPercent = memUsedPercent()
+	h.Load1 = loadAverage()
+	return h
+}
+
+// serviceHealth 代理运行时以管理端口的实时状态为准，否则读本地配置
+func serviceHealth() (bool, []hub.SpokeServiceHealth) {
+	type svcInfo struct {
+		ActiveEnv   string `json:"active_env"`
+		BlueTarget  string `json:"blue_target"`
+		GreenTarget string `json:"green_target"`
+	}
+	services := map[string]svcInfo{}
+	proxyRunning := false
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	if resp, err := client.Get(mgmtURL("/status")); err == nil {
+		var status struct {
+			Services map[string]svcInfo `json:"services"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&status) == nil {
+			proxyRunning = true
+			services = status.Services
+		}
+		resp.Body.Close()
+	}
+	if !proxyRunning {
+		if cfg, err := config.LoadConfig(); err == nil {
+			for id, svc := range cfg.Services {
+				services[id] = svcInfo{ActiveEnv: svc.ActiveEnv, BlueTarget: svc.BlueTarget, GreenTarget: svc.GreenTarget}
+			}
+		}
+	}
+
+	out := make([]hub.SpokeServiceHealth, 0, len(services))
+	for id, svc := range services {
+		target := svc.BlueTarget
+		if svc.ActiveEnv == "green" {
+			target = svc.GreenTarget
+		}
+		out = append(out, hub.SpokeServiceHealth{ID: id, ActiveEnv: svc.ActiveEnv, Up: targetReachable(target)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return proxyRunning, out
+}
+
+func targetReachable(target string) bool {
+	addr := strings.TrimPrefix(strings.TrimPrefix(target, "http://"), "https://")
+	if i := strings.Index(addr, "/"); i >= 0 {
+		addr = addr[:i]
+	}
+	if addr == "" {
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func diskUsage(path string) (usedPercent, freeGB float64) {
+	if path == "" {
+		path = "."
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil || st.Blocks == 0 {
+		return 0, 0
+	}
+	bsize := uint64(st.Bsize)
+	total := st.Blocks * bsize
+	free := st.Bavail * bsize
+	used := total - st.Bfree*bsize
+	usedPercent = float64(used) / float64(used+free) * 100
+	return round1(usedPercent), round1(float64(free) / (1 << 30))
+}
+
+// memUsedPercent 读取 /proc/meminfo（非 Linux 返回 0）
+func memUsedPercent() float64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	var total, available float64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, _ := strconv.ParseFloat(fields[1], 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = v
+		case "MemAvailable:":
+			available = v
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return round1((total - available) / total * 100)
+}
+
+func loadAverage() float64 {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(fields[0], 64)
+	return v
+}
+
+func round1(v float64) float64 {
+	return float64(int(v*10+0.5)) / 10
+}
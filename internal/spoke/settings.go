@@ -0,0 +1,72 @@
+package spoke
+
+import (
+	"encoding/json"
+	"os"
+)
+
+const appConfigFile = "configs/app_config.json"
+
+// 写操作策略
+const (
+	WritePolicyDeny    = "deny"    // 拒绝所有远程写操作
+	WritePolicyConfirm = "confirm" // 需在 Spoke CLI 中确认（默认）
+	WritePolicyAllow   = "allow"   // 白名单内直接执行
+)
+
+// ControlSettings 远程命令策略（app_config.json 的 spoke.control 字段）
+type ControlSettings struct {
+	Enabled     *bool    `json:"enabled,omitempty"`      // 默认开启
+	Allow       []string `json:"allow,omitempty"`        // 允许的操作，留空则允许全部已知操作
+	WritePolicy string   `json:"write_policy,omitempty"` // deny / confirm / allow，默认 confirm
+}
+
+// Settings Spoke 端配置（app_config.json 的 spoke 字段）
+type Settings struct {
+	Control ControlSettings `json:"control"`
+}
+
+// IsEnabled 未显式关闭即视为开启
+func (c ControlSettings) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Allows 操作是否在本机白名单内
+func (c ControlSettings) Allows(op string) bool {
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, a := range c.Allow {
+		if a == op || a == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy 返回规范化后的写操作策略
+func (c ControlSettings) Policy() string {
+	switch c.WritePolicy {
+	case WritePolicyDeny, WritePolicyAllow:
+		return c.WritePolicy
+	default:
+		return WritePolicyConfirm
+	}
+}
+
+// LoadSettings 读取 spoke 配置，文件或字段缺失时返回默认值
+func LoadSettings() Settings {
+	var settings Settings
+	data, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return settings
+	}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return settings
+	}
+	if raw, ok := root["spoke"]; ok {
+		_ = json.Unmarshal(raw, &settings)
+	}
+	return settings
+}